@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/enum"
+	"github.com/urfave/cli/v3"
+)
+
+func integrationCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "integration",
+		Usage: "Manage the integrations of a stack",
+		Commands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List the integrations of a stack",
+				ArgsUsage: "STACK_ID",
+				Action:    listIntegrations,
+			},
+			{
+				Name:      "install",
+				Usage:     "Install an integration: bridge, block-explorer or monitoring",
+				ArgsUsage: "TYPE STACK_ID",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "YAML file with the fields of the install request, required by block-explorer and monitoring",
+					},
+					expandEnvFlag(),
+				},
+				Action: installIntegration,
+			},
+			{
+				Name:      "uninstall",
+				Usage:     "Uninstall an integration: bridge, block-explorer or monitoring",
+				ArgsUsage: "TYPE STACK_ID",
+				Action:    uninstallIntegration,
+			},
+		},
+	}
+}
+
+func listIntegrations(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	integrations, err := c.GetIntegrations(ctx, stackId)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tSTATUS\tREASON")
+	for _, integration := range integrations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", integration.ID, integration.Type, integration.Status, integration.Reason)
+	}
+	return w.Flush()
+}
+
+// integrationArgs returns the integration type and the stack id given as arguments
+func integrationArgs(cmd *cli.Command) (enum.IntegrationType, string, error) {
+	if cmd.Args().Len() != 2 {
+		return "", "", fmt.Errorf("expected an integration type and a stack id, usage: %s %s", cmd.FullName(), cmd.ArgsUsage)
+	}
+	return enum.IntegrationType(cmd.Args().Get(0)), cmd.Args().Get(1), nil
+}
+
+func installIntegration(ctx context.Context, cmd *cli.Command) error {
+	integrationType, stackId, err := integrationArgs(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	switch integrationType {
+	case enum.IntegrationTypeBridge:
+		err = c.InstallBridge(ctx, stackId)
+	case enum.IntegrationTypeBlockExplorer:
+		var request dtos.InstallBlockExplorerRequest
+		if err := readRequiredYAMLFile(cmd, &request); err != nil {
+			return err
+		}
+		err = c.InstallBlockExplorer(ctx, stackId, request)
+	case enum.IntegrationTypeMonitoring:
+		var request dtos.InstallMonitoringRequest
+		if err := readRequiredYAMLFile(cmd, &request); err != nil {
+			return err
+		}
+		err = c.InstallMonitoring(ctx, stackId, request)
+	default:
+		return fmt.Errorf("unsupported integration %s", integrationType)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func uninstallIntegration(ctx context.Context, cmd *cli.Command) error {
+	integrationType, stackId, err := integrationArgs(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	uninstall := map[enum.IntegrationType]func(context.Context, string) error{
+		enum.IntegrationTypeBridge:        c.UninstallBridge,
+		enum.IntegrationTypeBlockExplorer: c.UninstallBlockExplorer,
+		enum.IntegrationTypeMonitoring:    c.UninstallMonitoring,
+	}[integrationType]
+	if uninstall == nil {
+		return fmt.Errorf("unsupported integration %s", integrationType)
+	}
+	if err := uninstall(ctx, stackId); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func readRequiredYAMLFile(cmd *cli.Command, v interface{}) error {
+	if cmd.String("file") == "" {
+		return fmt.Errorf("--file is required to install %s", cmd.Args().Get(0))
+	}
+	return readYAMLFile(cmd.String("file"), cmd.Bool("expand-env"), v)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/urfave/cli/v3"
+)
+
+func logsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "logs",
+		Usage:     "Print the deployment or integration logs of a stack",
+		ArgsUsage: "STACK_ID",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "deployment",
+				Aliases: []string{"d"},
+				Usage:   "Only print the log of this deployment",
+			},
+			&cli.StringFlag{
+				Name:    "integration",
+				Aliases: []string{"i"},
+				Usage:   "Only print the log of this integration",
+			},
+			&cli.BoolFlag{
+				Name:    "follow",
+				Aliases: []string{"f"},
+				Usage:   "Wait for new lines until the deployments or the integration end",
+			},
+		},
+		Action: printLogs,
+	}
+}
+
+// printLogs prints the logs of the deployments in the order of their steps, so following a stack tails every step
+// of its deployment in turn
+func printLogs(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+	follow := cmd.Bool("follow")
+
+	if integrationId := cmd.String("integration"); integrationId != "" {
+		return c.StreamIntegrationLogs(ctx, stackId, integrationId, follow, os.Stdout)
+	}
+	if deploymentId := cmd.String("deployment"); deploymentId != "" {
+		return c.StreamDeploymentLogs(ctx, stackId, deploymentId, follow, os.Stdout)
+	}
+
+	deployments, err := c.GetDeployments(ctx, stackId)
+	if err != nil {
+		return err
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Step < deployments[j].Step
+	})
+
+	for _, deployment := range deployments {
+		// Steps that did not start have no log yet, they are only waited for when following
+		if !follow && deployment.Status == entities.DeploymentStatusPending {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "==> step %d (%s)\n", deployment.Step, deployment.ID)
+		if err := c.StreamDeploymentLogs(ctx, stackId, deployment.ID.String(), follow, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// trhctl is a command-line client of the TRH backend API
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tokamak-network/trh-backend/pkg/client"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the commands, in addition to 0 on success
+const (
+	exitError            = 1
+	exitUnexpectedStatus = 2
+	exitTimeout          = 3
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd := &cli.Command{
+		Name:  "trhctl",
+		Usage: "Manage the stacks of a TRH backend",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "url",
+				Usage:   "Base URL of the backend",
+				Value:   "http://localhost:8000",
+				Sources: cli.EnvVars("TRH_API_URL"),
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "API key of the caller",
+				Sources: cli.EnvVars("TRH_API_KEY"),
+			},
+		},
+		Commands: []*cli.Command{
+			stackCommand(),
+			integrationCommand(),
+			logsCommand(),
+		},
+	}
+
+	if err := cmd.Run(ctx, os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitError)
+	}
+}
+
+func newClient(cmd *cli.Command) (*client.Client, error) {
+	apiKey := cmd.String("api-key")
+	if apiKey == "" {
+		return nil, errors.New("an API key is required, set --api-key or TRH_API_KEY")
+	}
+	return client.New(cmd.String("url"), apiKey), nil
+}
+
+// stackIdArg returns the stack id given as the first argument of the command
+func stackIdArg(cmd *cli.Command) (string, error) {
+	if cmd.Args().Len() != 1 {
+		return "", fmt.Errorf("expected a stack id, usage: %s %s", cmd.FullName(), cmd.ArgsUsage)
+	}
+	return cmd.Args().First(), nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
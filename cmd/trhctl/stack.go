@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/client"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+func stackCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stack",
+		Usage: "Manage Thanos stacks",
+		Commands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "Deploy a stack described by a YAML file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "YAML file with the fields of the deploy request",
+						Required: true,
+					},
+					expandEnvFlag(),
+					&cli.BoolFlag{
+						Name:  "wait",
+						Usage: "Wait for the deployment to finish",
+					},
+					timeoutFlag(),
+				},
+				Action: createStack,
+			},
+			{
+				Name:  "list",
+				Usage: "List the stacks",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "selector",
+						Aliases: []string{"l"},
+						Usage:   "Label selector, e.g. env=prod,team=core",
+					},
+					&cli.BoolFlag{
+						Name:  "archived",
+						Usage: "Include the purged stacks",
+					},
+				},
+				Action: listStacks,
+			},
+			{
+				Name:      "get",
+				Usage:     "Show a stack",
+				ArgsUsage: "STACK_ID",
+				Action:    getStack,
+			},
+			{
+				Name:      "status",
+				Usage:     "Show the status of a stack",
+				ArgsUsage: "STACK_ID",
+				Action:    getStackStatus,
+			},
+			{
+				Name:      "history",
+				Usage:     "Show the status transitions of a stack and of its deployments and integrations",
+				ArgsUsage: "STACK_ID",
+				Action:    getStackHistory,
+			},
+			{
+				Name:      "stop",
+				Usage:     "Stop the deployment of a stack",
+				ArgsUsage: "STACK_ID",
+				Action: stackAction(func(ctx context.Context, c *client.Client, stackId string) error {
+					return c.StopThanosStack(ctx, stackId)
+				}),
+			},
+			{
+				Name:      "resume",
+				Usage:     "Resume the deployment of a stack",
+				ArgsUsage: "STACK_ID",
+				Action: stackAction(func(ctx context.Context, c *client.Client, stackId string) error {
+					return c.ResumeThanosStack(ctx, stackId)
+				}),
+			},
+			{
+				Name:      "terminate",
+				Usage:     "Destroy a stack",
+				ArgsUsage: "STACK_ID",
+				Action: stackAction(func(ctx context.Context, c *client.Client, stackId string) error {
+					return c.TerminateThanosStack(ctx, stackId)
+				}),
+			},
+			{
+				Name:      "purge",
+				Usage:     "Archive a terminated stack and remove its deployment directory",
+				ArgsUsage: "STACK_ID",
+				Action: stackAction(func(ctx context.Context, c *client.Client, stackId string) error {
+					return c.PurgeThanosStack(ctx, stackId)
+				}),
+			},
+			{
+				Name:      "wait",
+				Usage:     "Wait for a stack to reach a final status, exits with 2 when it is not the expected one and 3 on timeout",
+				ArgsUsage: "STACK_ID",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "for",
+						Usage: "Expected statuses",
+						Value: []string{string(entities.StackStatusDeployed)},
+					},
+					timeoutFlag(),
+				},
+				Action: waitStack,
+			},
+		},
+	}
+}
+
+// expandEnvFlag opts in to the expansion of the ${VAR} references of the YAML files, which would otherwise corrupt the
+// secrets containing a $
+func expandEnvFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "expand-env",
+		Usage: "Replace the ${VAR} references in the values of the YAML file with environment variables",
+	}
+}
+
+func timeoutFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Maximum time to wait",
+		Value: 2 * time.Hour,
+	}
+}
+
+// stackAction runs a call on the stack given as argument
+func stackAction(call func(ctx context.Context, c *client.Client, stackId string) error) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		stackId, err := stackIdArg(cmd)
+		if err != nil {
+			return err
+		}
+		c, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		if err := call(ctx, c, stackId); err != nil {
+			return err
+		}
+		fmt.Println("OK")
+		return nil
+	}
+}
+
+func createStack(ctx context.Context, cmd *cli.Command) error {
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	var request dtos.DeployThanosRequest
+	if err := readYAMLFile(cmd.String("file"), cmd.Bool("expand-env"), &request); err != nil {
+		return err
+	}
+
+	stackId, err := c.CreateThanosStack(ctx, request)
+	if err != nil {
+		return err
+	}
+	fmt.Println(stackId)
+
+	if !cmd.Bool("wait") {
+		return nil
+	}
+	return waitForStatus(ctx, cmd, stackId, []string{string(entities.StackStatusDeployed)})
+}
+
+func listStacks(ctx context.Context, cmd *cli.Command) error {
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	stacks, err := c.GetThanosStacks(ctx, cmd.String("selector"), cmd.Bool("archived"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tNETWORK\tSTATUS")
+	for _, stack := range stacks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stack.ID, stack.Name, stack.Network, stack.Status)
+	}
+	return w.Flush()
+}
+
+func getStack(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	stack, err := c.GetThanosStack(ctx, stackId)
+	if err != nil {
+		return err
+	}
+	return printJSON(stack)
+}
+
+func getStackStatus(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	status, err := c.GetThanosStackStatus(ctx, stackId)
+	if err != nil {
+		return err
+	}
+	fmt.Println(status)
+	return nil
+}
+
+func getStackHistory(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	transitions, err := c.GetThanosStackHistory(ctx, stackId)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSUBJECT\tID\tFROM\tTO\tREASON")
+	for _, transition := range transitions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			transition.CreatedAt.Format(time.RFC3339), transition.Subject, transition.SubjectID,
+			transition.FromStatus, transition.ToStatus, transition.Reason)
+	}
+	return w.Flush()
+}
+
+func waitStack(ctx context.Context, cmd *cli.Command) error {
+	stackId, err := stackIdArg(cmd)
+	if err != nil {
+		return err
+	}
+	return waitForStatus(ctx, cmd, stackId, cmd.StringSlice("for"))
+}
+
+// waitForStatus waits until the stack is no longer in progress and maps its final status to the exit code
+func waitForStatus(ctx context.Context, cmd *cli.Command, stackId string, expected []string) error {
+	c, err := newClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cmd.Duration("timeout"))
+	defer cancel()
+
+	status, err := c.WaitForThanosStack(ctx, stackId, 10*time.Second)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return cli.Exit(fmt.Sprintf("timed out waiting for stack %s, status is %s", stackId, status), exitTimeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(status)
+	if !slices.Contains(expected, string(status)) {
+		return cli.Exit(fmt.Sprintf("stack %s is %s", stackId, status), exitUnexpectedStatus)
+	}
+	return nil
+}
+
+// readYAMLFile decodes a YAML file into a request DTO through its JSON field names, unknown fields are rejected. With
+// expandEnv, the ${VAR} references in the string values are replaced with environment variables.
+func readYAMLFile(path string, expandEnv bool, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("invalid YAML in %s: %w", path, err)
+	}
+	if expandEnv {
+		expandEnvValues(document)
+	}
+	payload, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request in %s: %w", path, err)
+	}
+	return nil
+}
+
+// expandEnvValues replaces the environment variable references in the string values of the document
+func expandEnvValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		return os.ExpandEnv(value)
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = expandEnvValues(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = expandEnvValues(item)
+		}
+	}
+	return value
+}
@@ -15,19 +15,59 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/health": {
+        "/audit": {
             "get": {
-                "description": "Get health",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get the most recent mutating API calls and status transitions, newest first. The records of a stack or a project require its admins, the whole trail requires system administrators.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "Audit"
+                ],
+                "summary": "Get Audit Records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stack ID, also matches the calls which created the stack",
+                        "name": "stackId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Project ID",
+                        "name": "projectId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID of the caller",
+                        "name": "actorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, inclusive",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, exclusive",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of records, defaults to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get health",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -38,19 +78,86 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos": {
+        "/audit/export": {
             "get": {
-                "description": "Get All Stacks",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download every record matching the filters as JSON Lines, oldest first. The limit is ignored.",
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Audit"
+                ],
+                "summary": "Export Audit Records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stack ID, also matches the calls which created the stack",
+                        "name": "stackId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Project ID",
+                        "name": "projectId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID of the caller",
+                        "name": "actorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, inclusive",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, exclusive",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One JSON record per line",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/credentials": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get the credential profiles of the project, or of every project accessible to the caller",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Credentials"
+                ],
+                "summary": "Get Credentials",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Project ID",
+                        "name": "projectId",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get All Stacks",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -61,7 +168,12 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Deploy Thanos Stack",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Save a cloud credential profile of the project once its keys are validated. Stacks reference it by id in place of the AWS keys and region. The secret key is never returned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -69,17 +181,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Credentials"
                 ],
-                "summary": "Deploy Thanos Stack",
+                "summary": "Create Credential",
                 "parameters": [
                     {
-                        "description": "Deploy Thanos Stack Request",
+                        "description": "Create Credential Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.DeployThanosRequest"
+                            "$ref": "#/definitions/dtos.CreateCredentialRequest"
                         }
                     }
                 ],
@@ -93,23 +205,25 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}": {
+        "/credentials/{id}": {
             "get": {
-                "description": "Get Stack By ID",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get a credential profile, without its secret key",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Credentials"
                 ],
-                "summary": "Get Stack By ID",
+                "summary": "Get Credential",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Credential ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -125,7 +239,12 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "Update Network",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace the keys of a credential profile once they are validated. The stacks using it are not redeployed, they pick up the new keys on their next operation.",
                 "consumes": [
                     "application/json"
                 ],
@@ -133,24 +252,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Credentials"
                 ],
-                "summary": "Update Network",
+                "summary": "Rotate Credential",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Credential ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Update Network Request",
+                        "description": "Update Credential Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.UpdateNetworkRequest"
+                            "$ref": "#/definitions/dtos.UpdateCredentialRequest"
                         }
                     }
                 ],
@@ -164,21 +283,23 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Terminate Thanos Stack",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Delete a credential profile, it must not be used by any stack other than terminated ones",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Credentials"
                 ],
-                "summary": "Terminate Thanos Stack",
+                "summary": "Delete Credential",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Credential ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -194,9 +315,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/deployments": {
+        "/health": {
             "get": {
-                "description": "Get Deployments",
+                "description": "Get health",
                 "consumes": [
                     "application/json"
                 ],
@@ -204,16 +325,40 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "health"
                 ],
-                "summary": "Get Deployments",
+                "summary": "Get health",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the managed keys of the project, or of every project accessible to the caller",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Keys"
+                ],
+                "summary": "Get Keys",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Project ID",
+                        "name": "projectId",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -224,11 +369,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/stacks/thanos/{id}/deployments/{deploymentId}": {
-            "get": {
-                "description": "Get Stack Deployment",
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Import a private key into the keystore of the project, or generate a new one when privateKey is not set. Stacks reference the key by id for their admin, sequencer, batcher and proposer accounts. Only the address of the key is returned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -236,23 +384,18 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Keys"
                 ],
-                "summary": "Get Stack Deployment",
+                "summary": "Create Key",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Thanos Stack ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Deployment ID",
-                        "name": "deploymentId",
-                        "in": "path",
-                        "required": true
+                        "description": "Create Key Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateOperatorKeyRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -265,33 +408,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/deployments/{deploymentId}/status": {
+        "/keys/{id}": {
             "get": {
-                "description": "Get Stack Deployment Status",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get a managed key, without its private key",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Keys"
                 ],
-                "summary": "Get Stack Deployment Status",
+                "summary": "Get Key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Deployment ID",
-                        "name": "deploymentId",
-                        "in": "path",
-                        "required": true
                     }
                 ],
                 "responses": {
@@ -302,25 +440,25 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/stacks/thanos/{id}/integrations": {
-            "get": {
-                "description": "Get Integrations",
-                "consumes": [
-                    "application/json"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Delete a managed key, it must not be used by any stack other than terminated ones",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Keys"
                 ],
-                "summary": "Get Integrations",
+                "summary": "Delete Key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -336,9 +474,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/integrations/block-explorer": {
+        "/projects": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the projects the caller is a member of",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access"
+                ],
+                "summary": "Get Projects",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Install Block Explorer",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a project, the caller becomes its admin",
                 "consumes": [
                     "application/json"
                 ],
@@ -346,24 +512,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Access"
                 ],
-                "summary": "Install Block Explorer",
+                "summary": "Create Project",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Thanos Stack ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Install Block Explorer Request",
+                        "description": "Create Project Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.InstallBlockExplorerRequest"
+                            "$ref": "#/definitions/dtos.CreateProjectRequest"
                         }
                     }
                 ],
@@ -375,23 +534,27 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Uninstall Block Explorer",
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/projects/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get a project and its members",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Access"
                 ],
-                "summary": "Uninstall Block Explorer",
+                "summary": "Get Project",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -407,9 +570,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/integrations/bridge": {
-            "post": {
-                "description": "Install Bridge",
+        "/projects/{id}/members": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a member to the project or change the role of an existing member",
                 "consumes": [
                     "application/json"
                 ],
@@ -417,18 +585,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Access"
                 ],
-                "summary": "Install Bridge",
+                "summary": "Set Project Member",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    }
-                ],
+                    },
+                    {
+                        "description": "Set Project Member Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SetProjectMemberRequest"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -437,26 +614,37 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/projects/{id}/members/{userId}": {
             "delete": {
-                "description": "Uninstall Bridge",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Remove a member from the project",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Access"
                 ],
-                "summary": "Uninstall Bridge",
+                "summary": "Remove Project Member",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -469,9 +657,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/integrations/monitoring": {
+        "/projects/{id}/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the webhook subscriptions of the project",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get Webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Project ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Install Monitoring",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Subscribe a URL to the lifecycle events of the project's stacks. The signing secret is only returned once.",
                 "consumes": [
                     "application/json"
                 ],
@@ -479,24 +704,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Webhooks"
                 ],
-                "summary": "Install Monitoring",
+                "summary": "Create Webhook",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Install Monitoring Request",
+                        "description": "Create Webhook Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.InstallMonitoringRequest"
+                            "$ref": "#/definitions/dtos.CreateWebhookRequest"
                         }
                     }
                 ],
@@ -508,26 +733,37 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/projects/{id}/webhooks/{webhookId}": {
             "delete": {
-                "description": "Uninstall Monitoring",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Delete a webhook subscription and its delivery log",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Webhooks"
                 ],
-                "summary": "Uninstall Monitoring",
+                "summary": "Delete Webhook",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "webhookId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -540,33 +776,41 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/integrations/{integrationId}": {
+        "/projects/{id}/webhooks/{webhookId}/deliveries": {
             "get": {
-                "description": "Get Integration By ID",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get the most recent delivery attempts of a webhook subscription",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Thanos Stack"
+                    "Webhooks"
                 ],
-                "summary": "Get Integration By ID",
+                "summary": "Get Webhook Deliveries",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
+                        "description": "Project ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Integration ID",
-                        "name": "integrationId",
+                        "description": "Webhook ID",
+                        "name": "webhookId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of deliveries, defaults to 50",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -579,11 +823,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/register-candidates": {
+        "/stacks/import": {
             "post": {
-                "description": "Register Candidates",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Restore a bundle exported by another instance into the project. The instances must share the same STACK_BUNDLE_SIGNING_KEY.",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
@@ -591,13 +840,20 @@ const docTemplate = `{
                 "tags": [
                     "Thanos Stack"
                 ],
-                "summary": "Register Candidates",
+                "summary": "Import Stack",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Thanos Stack ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Project ID",
+                        "name": "projectId",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Stack bundle",
+                        "name": "bundle",
+                        "in": "formData",
                         "required": true
                     }
                 ],
@@ -611,9 +867,54 @@ const docTemplate = `{
                 }
             }
         },
-        "/stacks/thanos/{id}/resume": {
+        "/stacks/thanos": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get All Stacks",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get All Stacks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated key=value labels the stacks must carry, e.g. env=prod,team=core",
+                        "name": "labelSelector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the purged stacks",
+                        "name": "includeArchived",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Resume Thanos Stack",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deploy Thanos Stack",
                 "consumes": [
                     "application/json"
                 ],
@@ -623,7 +924,85 @@ const docTemplate = `{
                 "tags": [
                     "Thanos Stack"
                 ],
-                "summary": "Resume Thanos Stack",
+                "summary": "Deploy Thanos Stack",
+                "parameters": [
+                    {
+                        "description": "Deploy Thanos Stack Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.DeployThanosRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/adopt": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register a chain deployed with the trh-sdk CLI. Its configuration is read from the settings.json of the deployment directory, which must be accessible to the backend and is used in place. The AWS credentials of settings.json are used unless provided. When an adoption root is configured, the directory must be inside it and project operators may adopt it, otherwise only system administrators may adopt.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Adopt Thanos Stack",
+                "parameters": [
+                    {
+                        "description": "Adopt Thanos Stack Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.AdoptThanosStackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Stack By ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack By ID",
                 "parameters": [
                     {
                         "type": "string",
@@ -641,11 +1020,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/stacks/thanos/{id}/status": {
-            "get": {
-                "description": "Get Stack Status",
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update Network",
                 "consumes": [
                     "application/json"
                 ],
@@ -655,7 +1037,7 @@ const docTemplate = `{
                 "tags": [
                     "Thanos Stack"
                 ],
-                "summary": "Get Stack Status",
+                "summary": "Update Network",
                 "parameters": [
                     {
                         "type": "string",
@@ -663,6 +1045,15 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Update Network Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateNetworkRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -673,11 +1064,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/stacks/thanos/{id}/stop": {
-            "post": {
-                "description": "Stop Thanos Stack",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Terminate Thanos Stack",
                 "consumes": [
                     "application/json"
                 ],
@@ -687,7 +1081,7 @@ const docTemplate = `{
                 "tags": [
                     "Thanos Stack"
                 ],
-                "summary": "Stop Thanos Stack",
+                "summary": "Terminate Thanos Stack",
                 "parameters": [
                     {
                         "type": "string",
@@ -695,6 +1089,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Archive a terminated stack and remove its deployment directory instead",
+                        "name": "purge",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -705,19 +1105,1262 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "dtos.DeployThanosRequest": {
-            "type": "object",
-            "required": [
-                "adminAccount",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update the name, description and labels of the stack. Labels replace the existing ones.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Update Stack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update Stack Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateStackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/clone": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create and deploy a new stack from the configuration of an existing one. Fields provided in the body override the copied configuration. The installed block explorer and monitoring are cloned when their secrets are provided, otherwise they are reported in skippedIntegrations. Cloning into another project requires new AWS credentials and system accounts, the secrets of the source stack are not copied.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Clone Thanos Stack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Clone Thanos Stack Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CloneThanosStackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/credentials": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace the AWS keys of the stack after an IAM key rotation. The keys must be valid in the region of the stack and able to see its deployed resources. The config is stored as a new revision and nothing is redeployed. Stacks using a credential profile are updated by rotating the profile.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Update Stack Credentials",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update Stack Credentials Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateStackCredentialsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/deployments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Deployments",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Deployments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/deployments/{deploymentId}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Stack Deployment",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack Deployment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment ID",
+                        "name": "deploymentId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/deployments/{deploymentId}/logs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stream the log of a deployment as plain text. With follow, the response stays open for new lines until the deployment ends.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack Deployment Logs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment ID",
+                        "name": "deploymentId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Follow the log while the deployment runs",
+                        "name": "follow",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/deployments/{deploymentId}/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Stack Deployment Status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack Deployment Status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment ID",
+                        "name": "deploymentId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download a signed bundle of the stack: its database rows, its deployment directory and optionally its logs. The bundle contains the stack credentials.",
+                "produces": [
+                    "application/gzip"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Export Thanos Stack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the stack logs",
+                        "name": "includeLogs",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the status transitions of the stack and of its deployments and integrations, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack History",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Integrations",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Integrations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations/block-explorer": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Install Block Explorer",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Install Block Explorer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Install Block Explorer Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.InstallBlockExplorerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Uninstall Block Explorer",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Uninstall Block Explorer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations/bridge": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Install Bridge",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Install Bridge",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Uninstall Bridge",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Uninstall Bridge",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations/monitoring": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Install Monitoring",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Install Monitoring",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Install Monitoring Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.InstallMonitoringRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Uninstall Monitoring",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Uninstall Monitoring",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations/{integrationId}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Integration By ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Integration By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Integration ID",
+                        "name": "integrationId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/integrations/{integrationId}/logs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stream the log of an integration as plain text. With follow, the response stays open for new lines until the integration is installed or uninstalled.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack Integration Logs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Integration ID",
+                        "name": "integrationId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Follow the log while the integration is installed or uninstalled",
+                        "name": "follow",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/register-candidates": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register Candidates",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Register Candidates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resume Thanos Stack",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Resume Thanos Stack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get Stack Status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Get Stack Status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/stacks/thanos/{id}/stop": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stop Thanos Stack",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Thanos Stack"
+                ],
+                "summary": "Stop Thanos Stack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Thanos Stack ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all users, only allowed for system administrators",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access"
+                ],
+                "summary": "Get Users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a user and return its API key, only allowed for system administrators",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access"
+                ],
+                "summary": "Create User",
+                "parameters": [
+                    {
+                        "description": "Create User Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the user authenticated by the API key",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access"
+                ],
+                "summary": "Get Current User",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Response"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dtos.AdoptThanosStackRequest": {
+            "type": "object",
+            "required": [
+                "deploymentPath",
+                "projectId"
+            ],
+            "properties": {
+                "awsAccessKey": {
+                    "type": "string"
+                },
+                "awsAssumeRole": {
+                    "$ref": "#/definitions/dtos.AwsAssumeRole"
+                },
+                "awsSecretAccessKey": {
+                    "type": "string"
+                },
+                "deploymentPath": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "projectId": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.AwsAssumeRole": {
+            "type": "object",
+            "required": [
+                "roleArn"
+            ],
+            "properties": {
+                "externalId": {
+                    "type": "string"
+                },
+                "roleArn": {
+                    "type": "string"
+                },
+                "sessionDuration": {
+                    "description": "seconds, from 900 to 43200, defaults to 3600",
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CloneBlockExplorerSecrets": {
+            "type": "object",
+            "required": [
+                "coinmarketcapKey",
+                "databasePassword"
+            ],
+            "properties": {
+                "coinmarketcapKey": {
+                    "type": "string"
+                },
+                "databasePassword": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CloneThanosStackRequest": {
+            "type": "object",
+            "properties": {
+                "adminAccount": {
+                    "type": "string"
+                },
+                "adminKeyId": {
+                    "type": "string"
+                },
+                "awsAccessKey": {
+                    "type": "string"
+                },
+                "awsAssumeRole": {
+                    "$ref": "#/definitions/dtos.AwsAssumeRole"
+                },
+                "awsRegion": {
+                    "type": "string"
+                },
+                "awsSecretAccessKey": {
+                    "type": "string"
+                },
+                "batchSubmissionFrequency": {
+                    "type": "integer"
+                },
+                "batcherAccount": {
+                    "type": "string"
+                },
+                "batcherKeyId": {
+                    "type": "string"
+                },
+                "blockExplorer": {
+                    "description": "Secrets of the integrations installed on the source stack, they are not copied.\nAn installed integration is only cloned when its secrets are provided.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dtos.CloneBlockExplorerSecrets"
+                        }
+                    ]
+                },
+                "chainName": {
+                    "type": "string"
+                },
+                "challengePeriod": {
+                    "type": "integer"
+                },
+                "credentialId": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "l1BeaconUrl": {
+                    "type": "string"
+                },
+                "l1RpcUrl": {
+                    "type": "string"
+                },
+                "l2BlockTime": {
+                    "type": "integer"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "monitoring": {
+                    "$ref": "#/definitions/dtos.InstallMonitoringRequest"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "network": {
+                    "$ref": "#/definitions/entities.DeploymentNetwork"
+                },
+                "outputRootFrequency": {
+                    "type": "integer"
+                },
+                "projectId": {
+                    "type": "string"
+                },
+                "proposerAccount": {
+                    "type": "string"
+                },
+                "proposerKeyId": {
+                    "type": "string"
+                },
+                "registerCandidate": {
+                    "type": "boolean"
+                },
+                "registerCandidateParams": {
+                    "$ref": "#/definitions/dtos.RegisterCandidateRequest"
+                },
+                "sequencerAccount": {
+                    "type": "string"
+                },
+                "sequencerKeyId": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateCredentialRequest": {
+            "type": "object",
+            "required": [
                 "awsAccessKey",
                 "awsRegion",
                 "awsSecretAccessKey",
+                "name",
+                "projectId"
+            ],
+            "properties": {
+                "awsAccessKey": {
+                    "type": "string"
+                },
+                "awsRegion": {
+                    "type": "string"
+                },
+                "awsSecretAccessKey": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "projectId": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateOperatorKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "projectId"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "privateKey": {
+                    "type": "string"
+                },
+                "projectId": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateProjectRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "isAdmin": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateWebhookRequest": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.WebhookEventType"
+                    }
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.DeployThanosRequest": {
+            "type": "object",
+            "required": [
                 "batchSubmissionFrequency",
-                "batcherAccount",
                 "chainName",
                 "challengePeriod",
                 "l1BeaconUrl",
@@ -725,16 +2368,27 @@ const docTemplate = `{
                 "l2BlockTime",
                 "network",
                 "outputRootFrequency",
-                "proposerAccount",
-                "sequencerAccount"
+                "projectId"
             ],
             "properties": {
                 "adminAccount": {
                     "type": "string"
                 },
+                "adminKeyId": {
+                    "description": "managed key of the project, in place of the private key",
+                    "type": "string"
+                },
                 "awsAccessKey": {
                     "type": "string"
                 },
+                "awsAssumeRole": {
+                    "description": "role assumed with the AWS keys or the credential profile",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dtos.AwsAssumeRole"
+                        }
+                    ]
+                },
                 "awsRegion": {
                     "type": "string"
                 },
@@ -749,6 +2403,9 @@ const docTemplate = `{
                 "batcherAccount": {
                     "type": "string"
                 },
+                "batcherKeyId": {
+                    "type": "string"
+                },
                 "chainName": {
                     "type": "string"
                 },
@@ -757,9 +2414,16 @@ const docTemplate = `{
                     "type": "integer",
                     "minimum": 1
                 },
+                "credentialId": {
+                    "description": "credential profile of the project, in place of the AWS keys and region",
+                    "type": "string"
+                },
                 "deploymentPath": {
                     "type": "string"
                 },
+                "description": {
+                    "type": "string"
+                },
                 "l1BeaconUrl": {
                     "type": "string"
                 },
@@ -771,6 +2435,15 @@ const docTemplate = `{
                     "type": "integer",
                     "minimum": 1
                 },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
                 "network": {
                     "enum": [
                         "Mainnet",
@@ -788,9 +2461,15 @@ const docTemplate = `{
                     "type": "integer",
                     "minimum": 1
                 },
+                "projectId": {
+                    "type": "string"
+                },
                 "proposerAccount": {
                     "type": "string"
                 },
+                "proposerKeyId": {
+                    "type": "string"
+                },
                 "registerCandidate": {
                     "type": "boolean"
                 },
@@ -799,6 +2478,9 @@ const docTemplate = `{
                 },
                 "sequencerAccount": {
                     "type": "string"
+                },
+                "sequencerKeyId": {
+                    "type": "string"
                 }
             }
         },
@@ -855,6 +2537,48 @@ const docTemplate = `{
                 }
             }
         },
+        "dtos.SetProjectMemberRequest": {
+            "type": "object",
+            "required": [
+                "role",
+                "userId"
+            ],
+            "properties": {
+                "role": {
+                    "enum": [
+                        "viewer",
+                        "operator",
+                        "admin"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.ProjectRole"
+                        }
+                    ]
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.UpdateCredentialRequest": {
+            "type": "object",
+            "required": [
+                "awsAccessKey",
+                "awsSecretAccessKey"
+            ],
+            "properties": {
+                "awsAccessKey": {
+                    "type": "string"
+                },
+                "awsRegion": {
+                    "type": "string"
+                },
+                "awsSecretAccessKey": {
+                    "type": "string"
+                }
+            }
+        },
         "dtos.UpdateNetworkRequest": {
             "type": "object",
             "properties": {
@@ -866,6 +2590,38 @@ const docTemplate = `{
                 }
             }
         },
+        "dtos.UpdateStackCredentialsRequest": {
+            "type": "object",
+            "required": [
+                "awsAccessKey",
+                "awsSecretAccessKey"
+            ],
+            "properties": {
+                "awsAccessKey": {
+                    "type": "string"
+                },
+                "awsSecretAccessKey": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.UpdateStackRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
         "entities.DeploymentNetwork": {
             "type": "string",
             "enum": [
@@ -879,6 +2635,19 @@ const docTemplate = `{
                 "DeploymentNetworkLocalDevnet"
             ]
         },
+        "entities.ProjectRole": {
+            "type": "string",
+            "enum": [
+                "viewer",
+                "operator",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "ProjectRoleViewer",
+                "ProjectRoleOperator",
+                "ProjectRoleAdmin"
+            ]
+        },
         "entities.Response": {
             "type": "object",
             "properties": {
@@ -890,11 +2659,31 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "entities.WebhookEventType": {
+            "type": "string",
+            "enum": [
+                "stack.deployed",
+                "stack.failed_to_deploy",
+                "stack.terminated",
+                "integration.installed",
+                "integration.failed_to_install"
+            ],
+            "x-enum-varnames": [
+                "WebhookEventStackDeployed",
+                "WebhookEventStackFailedToDeploy",
+                "WebhookEventStackTerminated",
+                "WebhookEventIntegrationInstalled",
+                "WebhookEventIntegrationFailedToInstall"
+            ]
         }
     },
     "securityDefinitions": {
-        "NoAuth": {
-            "type": "basic"
+        "ApiKeyAuth": {
+            "description": "Bearer \u003cAPI key\u003e. The X-API-Key header is accepted as well.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,524 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when CONFIG_FILE is not set, if it exists
+const DefaultFile = "config.yaml"
+
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Database    DatabaseConfig    `yaml:"database"`
+	TaskManager TaskManagerConfig `yaml:"taskManager"`
+	Storage     StorageConfig     `yaml:"storage"`
+	LogStorage  LogStorageConfig  `yaml:"logStorage"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Keystore    KeystoreConfig    `yaml:"keystore"`
+	Bundles     BundlesConfig     `yaml:"bundles"`
+}
+
+type ServerConfig struct {
+	Port     string    `yaml:"port"`
+	GRPCPort string    `yaml:"grpcPort"`
+	TLS      TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig serves both the REST and the gRPC APIs over TLS when the certificate and its key are set
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allowOrigins"`
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+}
+
+// DatabaseDriverPostgres and DatabaseDriverSQLite are the values of DatabaseConfig.Driver
+const (
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+)
+
+type DatabaseConfig struct {
+	// Driver is either postgres or sqlite. SQLite runs a single node without an external database, such as locally or
+	// in the end-to-end tests.
+	Driver string `yaml:"driver"`
+	// Path is the file of the SQLite database, :memory: keeps it in memory until the process exits
+	Path     string `yaml:"path"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	// SSLMode is passed as the sslmode of the connection, the driver default is used when empty
+	SSLMode         string        `yaml:"sslMode"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+}
+
+// TaskManagerConfig sizes the pool running the deployments and the other long-running tasks
+type TaskManagerConfig struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queueSize"`
+}
+
+type StorageConfig struct {
+	// Root holds the deployments, the imported bundles and by default the logs, it defaults to ./storage
+	Root string `yaml:"root"`
+	// AdoptionRoot holds the CLI deployments that project operators may adopt. When empty, only the system
+	// administrators may adopt deployments.
+	AdoptionRoot string `yaml:"adoptionRoot"`
+}
+
+// LogSinkFile, LogSinkDatabase and LogSinkS3 are the values of LogStorageConfig.Sink
+const (
+	LogSinkFile     = "file"
+	LogSinkDatabase = "database"
+	LogSinkS3       = "s3"
+)
+
+// LogStorageConfig stores the logs of the SDK commands run on the stacks, see the logsink package
+type LogStorageConfig struct {
+	// Sink is either file, database or s3
+	Sink string `yaml:"sink"`
+	// Dir holds the logs of the file sink, it defaults to the logs directory of the storage root
+	Dir string `yaml:"dir"`
+	// MaxAge deletes the logs not written to for longer, they are kept forever when zero
+	MaxAge time.Duration `yaml:"maxAge"`
+	// MaxSizeMB deletes the oldest logs once the logs take more space, there is no limit when zero
+	MaxSizeMB int `yaml:"maxSizeMB"`
+	// Compress gzips the logs of the file sink once they are not written to anymore
+	Compress bool `yaml:"compress"`
+	// PruneInterval is the period of the retention, compressing and deleting the logs
+	PruneInterval time.Duration      `yaml:"pruneInterval"`
+	S3            LogStorageS3Config `yaml:"s3"`
+}
+
+// LogStorageS3Config locates the bucket of the s3 sink, any S3-compatible object store can be used. The credentials
+// are read from the default AWS configuration when the keys are empty.
+type LogStorageS3Config struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+	// Endpoint is the URL of an S3-compatible store, such as MinIO, it is empty for AWS
+	Endpoint     string `yaml:"endpoint"`
+	Region       string `yaml:"region"`
+	AccessKey    string `yaml:"accessKey"`
+	SecretKey    string `yaml:"secretKey"`
+	UsePathStyle bool   `yaml:"usePathStyle"`
+}
+
+type LoggingConfig struct {
+	Level string `yaml:"level"`
+	// Format is either console or json
+	Format string `yaml:"format"`
+}
+
+type AuthConfig struct {
+	// AdminAPIKey bootstraps the first system administrator
+	AdminAPIKey string `yaml:"adminApiKey"`
+}
+
+// EncryptionConfig holds the master keys encrypting the secrets at rest, see secrets.LoadKeyring
+type EncryptionConfig struct {
+	Keys         string `yaml:"keys"`
+	KeysFile     string `yaml:"keysFile"`
+	CurrentKeyID string `yaml:"currentKeyId"`
+}
+
+type KeystoreConfig struct {
+	// Passphrase encrypts the JSON keystores of the managed operator keys, they are disabled when empty. It cannot
+	// be changed once keys are stored.
+	Passphrase string `yaml:"passphrase"`
+}
+
+type BundlesConfig struct {
+	// SigningKey is shared by the instances exchanging stack bundles, export and import are disabled when empty
+	SigningKey string `yaml:"signingKey"`
+}
+
+// Default returns the configuration used for the settings missing from the file and the environment
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:     "8000",
+			GRPCPort: "9090",
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders: []string{"*"},
+		},
+		Database: DatabaseConfig{
+			Driver:       DatabaseDriverPostgres,
+			Host:         "localhost",
+			Port:         "5432",
+			MaxIdleConns: 2,
+		},
+		TaskManager: TaskManagerConfig{
+			Workers:   5,
+			QueueSize: 20,
+		},
+		Storage: StorageConfig{
+			Root: "storage",
+		},
+		LogStorage: LogStorageConfig{
+			Sink:          LogSinkFile,
+			Compress:      true,
+			PruneInterval: time.Hour,
+		},
+		Logging: LoggingConfig{
+			Level:  "debug",
+			Format: "console",
+		},
+	}
+}
+
+// Load reads the YAML file named by CONFIG_FILE, or config.yaml if it exists, then applies the environment
+// variables over it and validates the result
+func Load() (*Config, error) {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+	return load(path, os.LookupEnv)
+}
+
+func load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// Misspelled settings would otherwise be silently ignored
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the settings with the environment variables which are set and not empty
+func (c *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	bindings := []struct {
+		name string
+		set  func(value string) error
+	}{
+		{"PORT", setString(&c.Server.Port)},
+		{"GRPC_PORT", setString(&c.Server.GRPCPort)},
+		{"TLS_CERT_FILE", setString(&c.Server.TLS.CertFile)},
+		{"TLS_KEY_FILE", setString(&c.Server.TLS.KeyFile)},
+		{"CORS_ALLOW_ORIGINS", setList(&c.CORS.AllowOrigins)},
+		{"CORS_ALLOW_METHODS", setList(&c.CORS.AllowMethods)},
+		{"CORS_ALLOW_HEADERS", setList(&c.CORS.AllowHeaders)},
+		{"CORS_EXPOSE_HEADERS", setList(&c.CORS.ExposeHeaders)},
+		{"CORS_ALLOW_CREDENTIALS", setBool(&c.CORS.AllowCredentials)},
+		{"DATABASE_DRIVER", setString(&c.Database.Driver)},
+		{"SQLITE_PATH", setString(&c.Database.Path)},
+		{"POSTGRES_HOST", setString(&c.Database.Host)},
+		{"POSTGRES_PORT", setString(&c.Database.Port)},
+		{"POSTGRES_USER", setString(&c.Database.User)},
+		{"POSTGRES_PASSWORD", setString(&c.Database.Password)},
+		{"POSTGRES_DB", setString(&c.Database.Name)},
+		{"POSTGRES_SSLMODE", setString(&c.Database.SSLMode)},
+		{"POSTGRES_MAX_OPEN_CONNS", setInt(&c.Database.MaxOpenConns)},
+		{"POSTGRES_MAX_IDLE_CONNS", setInt(&c.Database.MaxIdleConns)},
+		{"POSTGRES_CONN_MAX_LIFETIME", setDuration(&c.Database.ConnMaxLifetime)},
+		{"POSTGRES_CONN_MAX_IDLE_TIME", setDuration(&c.Database.ConnMaxIdleTime)},
+		{"TASK_MANAGER_WORKERS", setInt(&c.TaskManager.Workers)},
+		{"TASK_MANAGER_QUEUE_SIZE", setInt(&c.TaskManager.QueueSize)},
+		{"STORAGE_ROOT", setString(&c.Storage.Root)},
+		{"STORAGE_ADOPTION_ROOT", setString(&c.Storage.AdoptionRoot)},
+		{"LOG_STORAGE_SINK", setString(&c.LogStorage.Sink)},
+		{"LOG_STORAGE_DIR", setString(&c.LogStorage.Dir)},
+		{"LOG_STORAGE_MAX_AGE", setDuration(&c.LogStorage.MaxAge)},
+		{"LOG_STORAGE_MAX_SIZE_MB", setInt(&c.LogStorage.MaxSizeMB)},
+		{"LOG_STORAGE_COMPRESS", setBool(&c.LogStorage.Compress)},
+		{"LOG_STORAGE_PRUNE_INTERVAL", setDuration(&c.LogStorage.PruneInterval)},
+		{"LOG_STORAGE_S3_BUCKET", setString(&c.LogStorage.S3.Bucket)},
+		{"LOG_STORAGE_S3_PREFIX", setString(&c.LogStorage.S3.Prefix)},
+		{"LOG_STORAGE_S3_ENDPOINT", setString(&c.LogStorage.S3.Endpoint)},
+		{"LOG_STORAGE_S3_REGION", setString(&c.LogStorage.S3.Region)},
+		{"LOG_STORAGE_S3_ACCESS_KEY", setString(&c.LogStorage.S3.AccessKey)},
+		{"LOG_STORAGE_S3_SECRET_KEY", setString(&c.LogStorage.S3.SecretKey)},
+		{"LOG_STORAGE_S3_USE_PATH_STYLE", setBool(&c.LogStorage.S3.UsePathStyle)},
+		{"LOG_LEVEL", setString(&c.Logging.Level)},
+		{"LOG_FORMAT", setString(&c.Logging.Format)},
+		{"ADMIN_API_KEY", setString(&c.Auth.AdminAPIKey)},
+		{"ENCRYPTION_KEYS", setString(&c.Encryption.Keys)},
+		{"ENCRYPTION_KEYS_FILE", setString(&c.Encryption.KeysFile)},
+		{"ENCRYPTION_KEY_ID", setString(&c.Encryption.CurrentKeyID)},
+		{"KEYSTORE_PASSPHRASE", setString(&c.Keystore.Passphrase)},
+		{"STACK_BUNDLE_SIGNING_KEY", setString(&c.Bundles.SigningKey)},
+	}
+
+	var errs []error
+	for _, binding := range bindings {
+		value, ok := lookupEnv(binding.name)
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		if err := binding.set(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", binding.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the settings and resolves the storage root to an absolute path
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validatePort(c.Server.Port); err != nil {
+		errs = append(errs, fmt.Errorf("server.port: %w", err))
+	}
+	if err := validatePort(c.Server.GRPCPort); err != nil {
+		errs = append(errs, fmt.Errorf("server.grpcPort: %w", err))
+	}
+	if c.Server.Port == c.Server.GRPCPort {
+		errs = append(errs, errors.New("server.port and server.grpcPort must differ"))
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls.certFile and server.tls.keyFile must be set together"))
+	}
+	for _, file := range []string{c.Server.TLS.CertFile, c.Server.TLS.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls: %w", err))
+		}
+	}
+
+	if err := c.CORS.Build().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("cors: %w", err))
+	}
+	if c.CORS.AllowCredentials && c.CORS.allowsAllOrigins() {
+		errs = append(errs, errors.New("cors.allowCredentials requires explicit origins"))
+	}
+
+	switch c.Database.Driver {
+	case DatabaseDriverPostgres:
+		if c.Database.Host == "" {
+			errs = append(errs, errors.New("database.host is required"))
+		}
+		if err := validatePort(c.Database.Port); err != nil {
+			errs = append(errs, fmt.Errorf("database.port: %w", err))
+		}
+		if c.Database.User == "" {
+			errs = append(errs, errors.New("database.user is required"))
+		}
+		if c.Database.Name == "" {
+			errs = append(errs, errors.New("database.name is required"))
+		}
+	case DatabaseDriverSQLite:
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database.path is required by the sqlite driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be postgres or sqlite, got %q", c.Database.Driver))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database.maxOpenConns and database.maxIdleConns must not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.maxIdleConns must not exceed database.maxOpenConns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("database.connMaxLifetime and database.connMaxIdleTime must not be negative"))
+	}
+
+	if c.TaskManager.Workers < 1 {
+		errs = append(errs, errors.New("taskManager.workers must be at least 1"))
+	}
+	if c.TaskManager.QueueSize < 0 {
+		errs = append(errs, errors.New("taskManager.queueSize must not be negative"))
+	}
+
+	if c.Storage.Root == "" {
+		errs = append(errs, errors.New("storage.root is required"))
+	} else if root, err := filepath.Abs(c.Storage.Root); err != nil {
+		errs = append(errs, fmt.Errorf("storage.root: %w", err))
+	} else {
+		c.Storage.Root = root
+	}
+	if c.Storage.AdoptionRoot != "" {
+		if root, err := filepath.Abs(c.Storage.AdoptionRoot); err != nil {
+			errs = append(errs, fmt.Errorf("storage.adoptionRoot: %w", err))
+		} else {
+			c.Storage.AdoptionRoot = root
+		}
+	}
+
+	switch c.LogStorage.Sink {
+	case LogSinkFile:
+		if c.LogStorage.Dir == "" {
+			c.LogStorage.Dir = filepath.Join(c.Storage.Root, "logs")
+		} else if dir, err := filepath.Abs(c.LogStorage.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("logStorage.dir: %w", err))
+		} else {
+			c.LogStorage.Dir = dir
+		}
+	case LogSinkDatabase:
+	case LogSinkS3:
+		if c.LogStorage.S3.Bucket == "" {
+			errs = append(errs, errors.New("logStorage.s3.bucket is required by the s3 sink"))
+		}
+		if c.LogStorage.S3.Region == "" {
+			errs = append(errs, errors.New("logStorage.s3.region is required by the s3 sink"))
+		}
+		if (c.LogStorage.S3.AccessKey == "") != (c.LogStorage.S3.SecretKey == "") {
+			errs = append(errs, errors.New("logStorage.s3.accessKey and logStorage.s3.secretKey must be set together"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("logStorage.sink must be file, database or s3, got %q", c.LogStorage.Sink))
+	}
+	if c.LogStorage.MaxAge < 0 || c.LogStorage.MaxSizeMB < 0 {
+		errs = append(errs, errors.New("logStorage.maxAge and logStorage.maxSizeMB must not be negative"))
+	}
+	if c.LogStorage.PruneInterval <= 0 {
+		errs = append(errs, errors.New("logStorage.pruneInterval must be positive"))
+	}
+
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+	if c.Logging.Format != "console" && c.Logging.Format != "json" {
+		errs = append(errs, fmt.Errorf("logging.format must be console or json, got %q", c.Logging.Format))
+	}
+
+	if c.Encryption.Keys != "" && c.Encryption.KeysFile != "" {
+		errs = append(errs, errors.New("encryption.keys and encryption.keysFile are exclusive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Build returns the settings of the CORS middleware
+func (c CORSConfig) Build() cors.Config {
+	config := cors.DefaultConfig()
+	if c.allowsAllOrigins() {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOrigins = c.AllowOrigins
+	}
+	config.AllowMethods = c.AllowMethods
+	config.AllowHeaders = c.AllowHeaders
+	config.ExposeHeaders = c.ExposeHeaders
+	config.AllowCredentials = c.AllowCredentials
+	return config
+}
+
+func (c CORSConfig) allowsAllOrigins() bool {
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePort(port string) error {
+	value, err := strconv.Atoi(port)
+	if err != nil || value < 1 || value > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func setString(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+		return nil
+	}
+}
+
+func setInt(target *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+func setBool(target *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+func setDuration(target *time.Duration) func(string) error {
+	return func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+// setList splits comma-separated values
+func setList(target *[]string) func(string) error {
+	return func(value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target = list
+		return nil
+	}
+}
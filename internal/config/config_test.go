@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envOf(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: "8080"
+cors:
+  allowOrigins: ["https://app.example.com"]
+database:
+  user: trh
+  name: trh_db
+  maxOpenConns: 10
+  connMaxLifetime: 30m
+taskManager:
+  workers: 8
+logging:
+  level: info
+  format: json
+`)
+
+	cfg, err := load(path, envOf(map[string]string{
+		"PORT":                    "9000",
+		"POSTGRES_HOST":           "db",
+		"POSTGRES_MAX_IDLE_CONNS": "4",
+		"CORS_ALLOW_ORIGINS":      "https://a.example.com, https://b.example.com",
+		"TASK_MANAGER_QUEUE_SIZE": "",
+		"STORAGE_ADOPTION_ROOT":   "deployments",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.Server.Port != "9000" || cfg.Server.GRPCPort != "9090" {
+		t.Errorf("unexpected ports %s and %s", cfg.Server.Port, cfg.Server.GRPCPort)
+	}
+	if got := strings.Join(cfg.CORS.AllowOrigins, ","); got != "https://a.example.com,https://b.example.com" {
+		t.Errorf("unexpected origins %s", got)
+	}
+	if cfg.Database.Host != "db" || cfg.Database.User != "trh" || cfg.Database.MaxOpenConns != 10 ||
+		cfg.Database.MaxIdleConns != 4 || cfg.Database.ConnMaxLifetime != 30*time.Minute {
+		t.Errorf("unexpected database config %+v", cfg.Database)
+	}
+	// Empty variables do not override the settings
+	if cfg.TaskManager.Workers != 8 || cfg.TaskManager.QueueSize != 20 {
+		t.Errorf("unexpected task manager config %+v", cfg.TaskManager)
+	}
+	if cfg.Logging.Level != "info" || cfg.Logging.Format != "json" {
+		t.Errorf("unexpected logging config %+v", cfg.Logging)
+	}
+	if !filepath.IsAbs(cfg.Storage.Root) || filepath.Base(cfg.Storage.Root) != "storage" {
+		t.Errorf("storage root %s is not resolved", cfg.Storage.Root)
+	}
+	if !filepath.IsAbs(cfg.Storage.AdoptionRoot) || filepath.Base(cfg.Storage.AdoptionRoot) != "deployments" {
+		t.Errorf("adoption root %s is not resolved", cfg.Storage.AdoptionRoot)
+	}
+}
+
+func TestLoadRejectsUnknownSettings(t *testing.T) {
+	path := writeFile(t, `
+server:
+  prot: "8080"
+`)
+
+	if _, err := load(path, envOf(nil)); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Fatalf("expected the unknown setting to be rejected, got %v", err)
+	}
+}
+
+func TestLoadReportsEveryInvalidSetting(t *testing.T) {
+	_, err := load("", envOf(map[string]string{
+		"POSTGRES_USER":          "trh",
+		"POSTGRES_DB":            "trh_db",
+		"PORT":                   "http",
+		"TLS_CERT_FILE":          "server.crt",
+		"CORS_ALLOW_CREDENTIALS": "true",
+		"TASK_MANAGER_WORKERS":   "0",
+		"LOG_FORMAT":             "text",
+	}))
+	if err == nil {
+		t.Fatal("expected the configuration to be invalid")
+	}
+
+	for _, expected := range []string{
+		"server.port",
+		"server.tls.certFile and server.tls.keyFile",
+		"cors.allowCredentials",
+		"taskManager.workers",
+		"logging.format",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedVariables(t *testing.T) {
+	_, err := load("", envOf(map[string]string{
+		"POSTGRES_USER":              "trh",
+		"POSTGRES_DB":                "trh_db",
+		"POSTGRES_CONN_MAX_LIFETIME": "forever",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "POSTGRES_CONN_MAX_LIFETIME") {
+		t.Fatalf("expected the malformed duration to be rejected, got %v", err)
+	}
+}
+
+func TestLoadSQLiteWithoutPostgresSettings(t *testing.T) {
+	cfg, err := load("", envOf(map[string]string{
+		"DATABASE_DRIVER": "sqlite",
+		"SQLITE_PATH":     "trh.db",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Database.Driver != DatabaseDriverSQLite || cfg.Database.Path != "trh.db" {
+		t.Errorf("unexpected database config %+v", cfg.Database)
+	}
+
+	_, err = load("", envOf(map[string]string{"DATABASE_DRIVER": "sqlite"}))
+	if err == nil || !strings.Contains(err.Error(), "database.path") {
+		t.Fatalf("expected the missing path to be rejected, got %v", err)
+	}
+
+	_, err = load("", envOf(map[string]string{"DATABASE_DRIVER": "mysql"}))
+	if err == nil || !strings.Contains(err.Error(), "database.driver") {
+		t.Fatalf("expected the unknown driver to be rejected, got %v", err)
+	}
+}
+
+func TestLoadLogStorage(t *testing.T) {
+	cfg, err := load("", envOf(map[string]string{
+		"POSTGRES_USER": "trh",
+		"POSTGRES_DB":   "trh_db",
+		"STORAGE_ROOT":  "/var/lib/trh",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LogStorage.Sink != LogSinkFile || cfg.LogStorage.Dir != "/var/lib/trh/logs" || !cfg.LogStorage.Compress {
+		t.Errorf("unexpected default log storage %+v", cfg.LogStorage)
+	}
+	if cfg.Storage.AdoptionRoot != "" {
+		t.Errorf("adoption root = %q, want none by default", cfg.Storage.AdoptionRoot)
+	}
+
+	cfg, err = load("", envOf(map[string]string{
+		"POSTGRES_USER":           "trh",
+		"POSTGRES_DB":             "trh_db",
+		"LOG_STORAGE_SINK":        "s3",
+		"LOG_STORAGE_MAX_AGE":     "720h",
+		"LOG_STORAGE_S3_BUCKET":   "trh-logs",
+		"LOG_STORAGE_S3_REGION":   "us-east-1",
+		"LOG_STORAGE_S3_ENDPOINT": "http://minio:9000",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LogStorage.MaxAge != 720*time.Hour || cfg.LogStorage.S3.Endpoint != "http://minio:9000" {
+		t.Errorf("unexpected log storage %+v", cfg.LogStorage)
+	}
+
+	_, err = load("", envOf(map[string]string{
+		"POSTGRES_USER":             "trh",
+		"POSTGRES_DB":               "trh_db",
+		"LOG_STORAGE_SINK":          "s3",
+		"LOG_STORAGE_S3_ACCESS_KEY": "key",
+		"LOG_STORAGE_MAX_SIZE_MB":   "-1",
+	}))
+	for _, expected := range []string{
+		"logStorage.s3.bucket",
+		"logStorage.s3.region",
+		"logStorage.s3.accessKey and logStorage.s3.secretKey",
+		"logStorage.maxAge and logStorage.maxSizeMB",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+
+	_, err = load("", envOf(map[string]string{
+		"POSTGRES_USER":    "trh",
+		"POSTGRES_DB":      "trh_db",
+		"LOG_STORAGE_SINK": "syslog",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "logStorage.sink") {
+		t.Fatalf("expected the unknown sink to be rejected, got %v", err)
+	}
+}
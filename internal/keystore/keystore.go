@@ -0,0 +1,77 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	gethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+)
+
+var ErrInvalidPrivateKey = errors.New("invalid private key")
+
+// Keystore seals the operator private keys into Web3 Secret Storage (v3) JSON keystores, encrypted with scrypt and
+// AES-128-CTR under the passphrase of the instance. The keystores can be decrypted by any Ethereum wallet.
+type Keystore struct {
+	passphrase string
+	scryptN    int
+	scryptP    int
+}
+
+// New returns a keystore sealing the keys with the passphrase, or nil when the passphrase is empty
+func New(passphrase string) *Keystore {
+	if passphrase == "" {
+		return nil
+	}
+	return &Keystore{
+		passphrase: passphrase,
+		scryptN:    gethKeystore.StandardScryptN,
+		scryptP:    gethKeystore.StandardScryptP,
+	}
+}
+
+// Generate returns a new random private key
+func Generate() (*ecdsa.PrivateKey, error) {
+	return crypto.GenerateKey()
+}
+
+// ParsePrivateKey parses a hex private key, with or without the 0x prefix
+func ParsePrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	key, err := crypto.HexToECDSA(utils.TrimPrivateKey(privateKey))
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	return key, nil
+}
+
+// Address returns the checksummed address of the key
+func Address(key *ecdsa.PrivateKey) string {
+	return crypto.PubkeyToAddress(key.PublicKey).Hex()
+}
+
+// Encrypt returns the JSON keystore of the key
+func (k *Keystore) Encrypt(key *ecdsa.PrivateKey) ([]byte, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return gethKeystore.EncryptKey(&gethKeystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, k.passphrase, k.scryptN, k.scryptP)
+}
+
+// Decrypt returns the private key of the JSON keystore as hex, without the 0x prefix like the private keys of the
+// stack configs
+func (k *Keystore) Decrypt(keyJSON []byte) (string, error) {
+	key, err := gethKeystore.DecryptKey(keyJSON, k.passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt keystore: %w", err)
+	}
+	return utils.TrimPrivateKey(hexutil.Encode(crypto.FromECDSA(key.PrivateKey))), nil
+}
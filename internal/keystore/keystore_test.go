@@ -0,0 +1,53 @@
+package keystore
+
+import (
+	"errors"
+	"testing"
+
+	gethKeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+func newTestKeystore(passphrase string) *Keystore {
+	return &Keystore{
+		passphrase: passphrase,
+		scryptN:    gethKeystore.LightScryptN,
+		scryptP:    gethKeystore.LightScryptP,
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	const privateKey = "0xb71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	keyJSON, err := newTestKeystore("passphrase").Encrypt(key)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	decrypted, err := newTestKeystore("passphrase").Decrypt(keyJSON)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if decrypted != privateKey[2:] {
+		t.Errorf("Decrypt = %s, want %s", decrypted, privateKey[2:])
+	}
+
+	if _, err := newTestKeystore("other").Decrypt(keyJSON); err == nil {
+		t.Error("Decrypt with another passphrase succeeded")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	if _, err := ParsePrivateKey("0x1234"); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("ParsePrivateKey error = %v, want ErrInvalidPrivateKey", err)
+	}
+}
+
+func TestNewWithoutPassphrase(t *testing.T) {
+	if New("") != nil {
+		t.Error("New returned a keystore without passphrase")
+	}
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+const RequestIDField = "requestId"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id carried by ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the logger annotated with the request id carried by ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return Logger.With(zap.String(RequestIDField, requestID))
+	}
+	return Logger
+}
+
+func InfoContext(ctx context.Context, msg string, fields ...zap.Field) {
+	FromContext(ctx).Info(msg, fields...)
+}
+
+func ErrorContext(ctx context.Context, msg string, fields ...zap.Field) {
+	FromContext(ctx).Error(msg, fields...)
+}
+
+func DebugContext(ctx context.Context, msg string, fields ...zap.Field) {
+	FromContext(ctx).Debug(msg, fields...)
+}
+
+func WarnContext(ctx context.Context, msg string, fields ...zap.Field) {
+	FromContext(ctx).Warn(msg, fields...)
+}
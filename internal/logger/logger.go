@@ -7,15 +7,22 @@ import (
 
 var Logger *zap.Logger
 
-func Init() {
+// Init builds the logger writing the entries from the level on, in the console or json format
+func Init(level string, format string) {
 	config := zap.NewDevelopmentConfig()
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.EncoderConfig.StacktraceKey = ""
-	config.Encoding = "console"
+	config.Encoding = format
 
-	var err error
-	Logger, err = config.Build()
+	parsedLevel, err := zapcore.ParseLevel(level)
+	if err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	config.Level = zap.NewAtomicLevelAt(parsedLevel)
+
+	// Secrets of the requests and the errors must never be written to the logs
+	Logger, err = config.Build(zap.WrapCore(NewRedactingCore))
 	if err != nil {
 		panic("failed to initialize logger: " + err.Error())
 	}
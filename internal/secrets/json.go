@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// EncryptJSONFields encrypts the listed top-level string fields of a JSON object held by the row with EncryptField, so
+// a value cannot be moved to another row or field. Without a keyring the document is returned as is.
+func (k *Keyring) EncryptJSONFields(document []byte, fields []string, rowID string) ([]byte, error) {
+	if k == nil || len(document) == 0 {
+		return document, nil
+	}
+	object, ok, err := decodeObject(document)
+	if err != nil || !ok {
+		return document, err
+	}
+
+	changed := false
+	for _, field := range fields {
+		value, isString := stringField(object, field)
+		if !isString || value == "" {
+			continue
+		}
+		encrypted, err := k.EncryptField(value, rowID, field)
+		if err != nil {
+			return nil, err
+		}
+		if encrypted == value {
+			continue
+		}
+		if object[field], err = json.Marshal(encrypted); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return document, nil
+	}
+	return json.Marshal(object)
+}
+
+// DecryptJSONFields decrypts every encrypted top-level string field of a JSON object held by the row
+func (k *Keyring) DecryptJSONFields(document []byte, rowID string) ([]byte, error) {
+	if len(document) == 0 {
+		return document, nil
+	}
+	object, ok, err := decodeObject(document)
+	if err != nil || !ok {
+		return document, err
+	}
+
+	changed := false
+	for field := range object {
+		value, isString := stringField(object, field)
+		if !isString || !IsEncrypted(value) {
+			continue
+		}
+		plaintext, err := k.DecryptField(value, rowID, field)
+		if err != nil {
+			return nil, err
+		}
+		if object[field], err = json.Marshal(plaintext); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return document, nil
+	}
+	return json.Marshal(object)
+}
+
+// EncryptField encrypts the value of the field of the row, with "<row id>|<field>" as additional data. A value already
+// encrypted for the row with the current key is returned as is, the others are re-encrypted, including those
+// encrypted with an older key or before the values were bound to their row. Without a keyring the value is returned
+// as is.
+func (k *Keyring) EncryptField(value string, rowID string, field string) (string, error) {
+	if k == nil || value == "" {
+		return value, nil
+	}
+	if IsEncrypted(value) {
+		plaintext, bound, err := k.decryptField(value, rowID, field)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+		}
+		if bound && KeyID(value) == k.currentID {
+			return value, nil
+		}
+		value = string(plaintext)
+	}
+	return k.Encrypt([]byte(value), fieldAdditionalData(rowID, field))
+}
+
+// DecryptField decrypts the value of the field of the row encrypted by EncryptField, plaintext values are returned as
+// is
+func (k *Keyring) DecryptField(value string, rowID string, field string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	plaintext, _, err := k.decryptField(value, rowID, field)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// fieldAdditionalData binds a value to its row and field, as "<row id>|<field>"
+func fieldAdditionalData(rowID string, field string) []byte {
+	return []byte(rowID + "|" + field)
+}
+
+// decryptField opens the value of the field of the row. The values encrypted before they were bound to their row,
+// with the field name alone as additional data, are still accepted, bound is false for them.
+func (k *Keyring) decryptField(value string, rowID string, field string) ([]byte, bool, error) {
+	plaintext, err := k.Decrypt(value, fieldAdditionalData(rowID, field))
+	if err == nil {
+		return plaintext, true, nil
+	}
+	if errors.Is(err, ErrNoKeyring) || errors.Is(err, ErrUnknownKey) {
+		return nil, false, err
+	}
+	if plaintext, legacyErr := k.Decrypt(value, []byte(field)); legacyErr == nil {
+		return plaintext, false, nil
+	}
+	return nil, false, err
+}
+
+// decodeObject decodes a JSON object, ok is false for other JSON values such as null
+func decodeObject(document []byte) (map[string]json.RawMessage, bool, error) {
+	if trimmed := bytes.TrimSpace(document); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false, nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(document, &object); err != nil {
+		return nil, false, err
+	}
+	return object, true, nil
+}
+
+func stringField(object map[string]json.RawMessage, field string) (string, bool) {
+	raw, ok := object[field]
+	if !ok {
+		return "", false
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,129 @@
+package secrets
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeTestObject(t *testing.T, document []byte) map[string]string {
+	t.Helper()
+	var object map[string]string
+	if err := json.Unmarshal(document, &object); err != nil {
+		t.Fatalf("invalid document %s: %v", document, err)
+	}
+	return object
+}
+
+func TestEncryptDecryptJSONFields(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", "k1")
+	document := []byte(`{"name":"thanos","secret":"value","empty":""}`)
+
+	encrypted, err := keyring.EncryptJSONFields(document, []string{"secret", "empty", "missing"}, "row")
+	if err != nil {
+		t.Fatalf("EncryptJSONFields: %v", err)
+	}
+	object := decodeTestObject(t, encrypted)
+	if object["name"] != "thanos" || object["empty"] != "" || !IsEncrypted(object["secret"]) {
+		t.Errorf("encrypted document = %s, want only the secret encrypted", encrypted)
+	}
+
+	decrypted, err := keyring.DecryptJSONFields(encrypted, "row")
+	if err != nil {
+		t.Fatalf("DecryptJSONFields: %v", err)
+	}
+	if object := decodeTestObject(t, decrypted); object["secret"] != "value" || object["name"] != "thanos" {
+		t.Errorf("decrypted document = %s, want the original values", decrypted)
+	}
+
+	// The values are bound to their row and field
+	if _, err := keyring.DecryptJSONFields(encrypted, "other"); err == nil {
+		t.Error("decrypted the value of another row")
+	}
+	moved, err := json.Marshal(map[string]string{"other": object["secret"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.DecryptJSONFields(moved, "row"); err == nil {
+		t.Error("decrypted the value of another field")
+	}
+}
+
+func TestEncryptJSONFieldsReencrypts(t *testing.T) {
+	old := newTestKeyring(t, "k1", "k1")
+	oldSecret, err := old.Encrypt([]byte("old"), fieldAdditionalData("row", "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := newTestKeyring(t, "k2", "k1", "k2")
+	currentSecret, err := rotated.Encrypt([]byte("current"), fieldAdditionalData("row", "current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unboundSecret, err := rotated.Encrypt([]byte("unbound"), []byte("unbound"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := json.Marshal(map[string]string{
+		"old":     oldSecret,
+		"current": currentSecret,
+		"unbound": unboundSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := rotated.EncryptJSONFields(document, []string{"old", "current", "unbound"}, "row")
+	if err != nil {
+		t.Fatalf("EncryptJSONFields: %v", err)
+	}
+	object := decodeTestObject(t, encrypted)
+	if object["current"] != currentSecret {
+		t.Error("re-encrypted a value already encrypted with the current key")
+	}
+	if object["old"] == oldSecret || KeyID(object["old"]) != "k2" {
+		t.Errorf("old value = %s, want it re-encrypted with k2", object["old"])
+	}
+	if object["unbound"] == unboundSecret {
+		t.Error("kept a value not bound to its row")
+	}
+
+	decrypted, err := rotated.DecryptJSONFields(encrypted, "row")
+	if err != nil {
+		t.Fatalf("DecryptJSONFields: %v", err)
+	}
+	object = decodeTestObject(t, decrypted)
+	if object["old"] != "old" || object["current"] != "current" || object["unbound"] != "unbound" {
+		t.Errorf("decrypted document = %s, want the original values", decrypted)
+	}
+
+	// A document already up to date is returned as is
+	again, err := rotated.EncryptJSONFields(encrypted, []string{"old", "current", "unbound"}, "row")
+	if err != nil || string(again) != string(encrypted) {
+		t.Errorf("EncryptJSONFields changed an up to date document: %s, %v", again, err)
+	}
+}
+
+func TestJSONFieldsWithoutKeyring(t *testing.T) {
+	var none *Keyring
+	document := []byte(`{"secret":"value"}`)
+	if encrypted, err := none.EncryptJSONFields(document, []string{"secret"}, "row"); err != nil || string(encrypted) != string(document) {
+		t.Errorf("EncryptJSONFields without a keyring = %s, %v, want the document as is", encrypted, err)
+	}
+	if decrypted, err := none.DecryptJSONFields(document, "row"); err != nil || string(decrypted) != string(document) {
+		t.Errorf("DecryptJSONFields of plaintext values = %s, %v, want the document as is", decrypted, err)
+	}
+
+	encrypted, err := newTestKeyring(t, "k1", "k1").EncryptJSONFields(document, []string{"secret"}, "row")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := none.DecryptJSONFields(encrypted, "row"); err == nil {
+		t.Error("decrypted a value without a keyring")
+	}
+
+	for _, document := range []string{"", "null", `"text"`} {
+		if got, err := none.DecryptJSONFields([]byte(document), "row"); err != nil || string(got) != document {
+			t.Errorf("DecryptJSONFields(%q) = %s, %v, want it as is", document, got, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Encrypted values are envelopes "enc:v1:<key id>:<wrapped data key>:<ciphertext>". Each value is sealed with its
+// own random data key, which is sealed with the master key named by the key id. Both use AES-256-GCM.
+const (
+	envelopePrefix = "enc:v1:"
+	keySize        = 32
+)
+
+var (
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+	ErrNoKeyring  = errors.New("secret is encrypted but no encryption key is configured")
+	ErrUnknownKey = errors.New("secret is encrypted with an unknown key")
+)
+
+// Keyring holds the master keys by id. New secrets are encrypted with the current key, the other keys are kept to
+// decrypt the secrets encrypted before a rotation.
+type Keyring struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+func NewKeyring(keys map[string][]byte, currentID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption key")
+	}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("encryption key %s must be %d bytes", id, keySize)
+		}
+	}
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current encryption key %q is not configured", currentID)
+	}
+	return &Keyring{currentID: currentID, keys: keys}, nil
+}
+
+// LoadKeyring reads the master keys from content, or from the file at path when set, as "<id>:<base64 key>" entries
+// separated by commas or new lines. currentID selects the current key and defaults to the first entry. It returns nil
+// when no key is configured.
+func LoadKeyring(content string, path string, currentID string) (*Keyring, error) {
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content = string(b)
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte)
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(content, ",", "\n")))
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid encryption key entry, expected <id>:<base64 key>")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %w", id, err)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("duplicate encryption key %s", id)
+		}
+		keys[id] = key
+		if currentID == "" {
+			currentID = id
+		}
+	}
+	return NewKeyring(keys, currentID)
+}
+
+// CurrentKeyID returns the id of the key new secrets are encrypted with
+func (k *Keyring) CurrentKeyID() string {
+	return k.currentID
+}
+
+// IsEncrypted reports whether the value is an envelope
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID returns the id of the master key of an envelope
+func KeyID(value string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, envelopePrefix), ":")
+	return id
+}
+
+// Encrypt seals the plaintext in an envelope with the current key. The additional data, such as the name of the
+// field holding the value, must be given again to decrypt it.
+func (k *Keyring) Encrypt(plaintext []byte, additionalData []byte) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + k.currentID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt opens an envelope created by Encrypt with the same additional data
+func (k *Keyring) Decrypt(value string, additionalData []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, errors.New("secret is not encrypted")
+	}
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	masterKey, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed encrypted secret")
+	}
+
+	dataKey, err := open(masterKey, wrappedKey, []byte(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, ciphertext, additionalData)
+}
+
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errors.New("failed to decrypt secret")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,172 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func newTestKeyring(t *testing.T, currentID string, ids ...string) *Keyring {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for i, id := range ids {
+		keys[id] = testKey(byte(i + 1))
+	}
+	keyring, err := NewKeyring(keys, currentID)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", "k1")
+
+	encrypted, err := keyring.Encrypt([]byte("secret"), []byte("field"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || KeyID(encrypted) != "k1" || strings.Contains(encrypted, "secret") {
+		t.Fatalf("envelope = %s, want a value encrypted with k1", encrypted)
+	}
+	other, err := keyring.Encrypt([]byte("secret"), []byte("field"))
+	if err != nil || other == encrypted {
+		t.Errorf("encrypting twice gave %s, %v, want a new envelope", other, err)
+	}
+
+	decrypted, err := keyring.Decrypt(encrypted, []byte("field"))
+	if err != nil || string(decrypted) != "secret" {
+		t.Errorf("Decrypt = %q, %v, want secret", decrypted, err)
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", "k1")
+	encrypted, err := keyring.Encrypt([]byte("secret"), []byte("field"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := keyring.Decrypt(encrypted, []byte("other")); err == nil {
+		t.Error("decrypted with other additional data")
+	}
+
+	parts := strings.Split(encrypted, ":")
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 1
+	parts[len(parts)-1] = base64.RawStdEncoding.EncodeToString(ciphertext)
+	if _, err := keyring.Decrypt(strings.Join(parts, ":"), []byte("field")); err == nil {
+		t.Error("decrypted a tampered value")
+	}
+
+	if _, err := keyring.Decrypt(encrypted[:len(encrypted)-4], []byte("field")); err == nil {
+		t.Error("decrypted a truncated value")
+	}
+	if _, err := keyring.Decrypt("secret", []byte("field")); err == nil {
+		t.Error("decrypted a plaintext value")
+	}
+
+	unknown := newTestKeyring(t, "k2", "k2")
+	if _, err := unknown.Decrypt(encrypted, []byte("field")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt with an unknown key = %v, want ErrUnknownKey", err)
+	}
+	var none *Keyring
+	if _, err := none.Decrypt(encrypted, []byte("field")); !errors.Is(err, ErrNoKeyring) {
+		t.Errorf("Decrypt without a keyring = %v, want ErrNoKeyring", err)
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	old := newTestKeyring(t, "k1", "k1")
+	encrypted, err := old.Encrypt([]byte("secret"), []byte("field"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := newTestKeyring(t, "k2", "k1", "k2")
+	decrypted, err := rotated.Decrypt(encrypted, []byte("field"))
+	if err != nil || string(decrypted) != "secret" {
+		t.Errorf("Decrypt = %q, %v, want secret", decrypted, err)
+	}
+	reencrypted, err := rotated.Encrypt(decrypted, []byte("field"))
+	if err != nil || KeyID(reencrypted) != "k2" {
+		t.Errorf("Encrypt = %s, %v, want a value encrypted with k2", reencrypted, err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	tests := []struct {
+		name      string
+		content   string
+		currentID string
+		want      string
+		wantErr   string
+	}{
+		{name: "empty"},
+		{name: "first entry is current", content: "k1:" + k1 + ",k2:" + k2, want: "k1"},
+		{name: "current key", content: "# keys\nk1:" + k1 + "\n\nk2:" + k2 + "\n", currentID: "k2", want: "k2"},
+		{name: "duplicate", content: "k1:" + k1 + ",k1:" + k2, wantErr: "duplicate encryption key k1"},
+		{name: "missing separator", content: k1, wantErr: "expected <id>:<base64 key>"},
+		{name: "invalid base64", content: "k1:not base64", wantErr: "invalid encryption key k1"},
+		{
+			name:    "invalid size",
+			content: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+			wantErr: "encryption key k1 must be 32 bytes",
+		},
+		{name: "invalid id", content: "k/1:" + k1, wantErr: `invalid encryption key id "k/1"`},
+		{name: "missing current key", content: "k1:" + k1, currentID: "k2", wantErr: `current encryption key "k2" is not configured`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := LoadKeyring(tt.content, "", tt.currentID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadKeyring error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyring: %v", err)
+			}
+			if tt.want == "" {
+				if keyring != nil {
+					t.Errorf("keyring = %+v, want none", keyring)
+				}
+				return
+			}
+			if keyring == nil || keyring.CurrentKeyID() != tt.want {
+				t.Errorf("current key = %v, want %s", keyring, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeyringFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("k1:"+base64.StdEncoding.EncodeToString(testKey(1))+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file takes precedence over the content
+	keyring, err := LoadKeyring("ignored", path, "")
+	if err != nil || keyring == nil || keyring.CurrentKeyID() != "k1" {
+		t.Errorf("LoadKeyring = %v, %v, want the key of the file", keyring, err)
+	}
+	if _, err := LoadKeyring("", filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
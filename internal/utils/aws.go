@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// awsAssumeRoleSourceProfile holds the base keys used to assume the role configured on the default profile
+	awsAssumeRoleSourceProfile = "trh-assume-role-source"
+	awsRoleSessionName         = "trh-backend"
+)
+
+// awsAssumeRoleKeys are the settings of the default profile assuming a role
+var awsAssumeRoleKeys = []string{"role_arn", "source_profile", "external_id", "duration_seconds", "role_session_name"}
+
+type AWSTemporaryCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AssumeAWSRole assumes the role with the keys, through the STS endpoint of the region, and returns its temporary
+// credentials. The keys are passed to the AWS CLI through the environment, the configured profiles are left untouched.
+func AssumeAWSRole(
+	ctx context.Context,
+	accessKey string,
+	secretKey string,
+	region string,
+	roleArn string,
+	externalID string,
+	durationSeconds int,
+) (*AWSTemporaryCredentials, error) {
+	args := []string{"sts", "assume-role",
+		"--role-arn", roleArn,
+		"--role-session-name", awsRoleSessionName,
+		"--duration-seconds", strconv.Itoa(durationSeconds),
+		"--region", region,
+		"--output", "json",
+	}
+	if externalID != "" {
+		args = append(args, "--external-id", externalID)
+	}
+
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	cmd.Env = append(AWSCommandEnv(accessKey, secretKey, ""), "AWS_STS_REGIONAL_ENDPOINTS=regional")
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to assume role %s: %s", roleArn, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to assume role %s: %w", roleArn, err)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+		}
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse the credentials of role %s: %w", roleArn, err)
+	}
+	return &AWSTemporaryCredentials{
+		AccessKeyID:     result.Credentials.AccessKeyId,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+	}, nil
+}
+
+// AWSCommandEnv returns the environment of the process with the credentials, which the AWS CLI uses in place of the
+// configured profiles. The session token is only set for temporary credentials.
+func AWSCommandEnv(accessKey string, secretKey string, sessionToken string) []string {
+	env := make([]string, 0)
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "AWS_SESSION_TOKEN=") && !strings.HasPrefix(variable, "AWS_SECURITY_TOKEN=") {
+			env = append(env, variable)
+		}
+	}
+	env = append(env, "AWS_ACCESS_KEY_ID="+accessKey, "AWS_SECRET_ACCESS_KEY="+secretKey)
+	if sessionToken != "" {
+		env = append(env, "AWS_SESSION_TOKEN="+sessionToken)
+	}
+	return env
+}
+
+// ConfigureAWSAssumeRole makes the default profile assume the role with the keys. The AWS CLI and terraform refresh
+// the temporary credentials of the role on their own, so they outlive its session duration.
+func ConfigureAWSAssumeRole(
+	ctx context.Context,
+	accessKey string,
+	secretKey string,
+	roleArn string,
+	externalID string,
+	durationSeconds int,
+) error {
+	// Drops the external id of the previous role
+	if err := ClearAWSAssumeRole(); err != nil {
+		return err
+	}
+
+	settings := [][]string{
+		{"aws_access_key_id", accessKey, "--profile", awsAssumeRoleSourceProfile},
+		{"aws_secret_access_key", secretKey, "--profile", awsAssumeRoleSourceProfile},
+		{"role_arn", roleArn},
+		{"source_profile", awsAssumeRoleSourceProfile},
+		{"role_session_name", awsRoleSessionName},
+		{"duration_seconds", strconv.Itoa(durationSeconds)},
+	}
+	if externalID != "" {
+		settings = append(settings, []string{"external_id", externalID})
+	}
+	for _, setting := range settings {
+		args := append([]string{"configure", "set"}, setting...)
+		if output, err := exec.CommandContext(ctx, "aws", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to configure %s: %w, %s", setting[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// ClearAWSAssumeRole removes the role of the default profile, which then uses its own keys again.
+// The AWS CLI cannot unset a setting, so the lines are removed from the config file.
+func ClearAWSAssumeRole() error {
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(home, ".aws", "config")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	kept := make([]string, 0, len(lines))
+	inDefault := false
+	changed := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			inDefault = trimmed == "[default]" || trimmed == "[profile default]"
+		} else if inDefault && isAWSAssumeRoleSetting(trimmed) {
+			changed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !changed {
+		return nil
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0600)
+}
+
+func isAWSAssumeRoleSetting(line string) bool {
+	key, _, found := strings.Cut(line, "=")
+	if !found {
+		return false
+	}
+	key = strings.TrimSpace(key)
+	for _, setting := range awsAssumeRoleKeys {
+		if key == setting {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignStackBundleManifest returns the hex encoded HMAC-SHA256 of the manifest of a stack bundle
+func SignStackBundleManifest(key []byte, manifest []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(manifest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyStackBundleManifest reports whether the signature was produced for the manifest with the key
+func VerifyStackBundleManifest(key []byte, manifest []byte, signature string) bool {
+	expected := SignStackBundleManifest(key, manifest)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -4,26 +4,93 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 )
 
+// storageRoot holds the deployments and the imported bundles
+var storageRoot = defaultStorageRoot()
+
+// adoptionRoot holds the directories of the CLI deployments which may be adopted, only the system administrators
+// may adopt deployments when it is empty
+var adoptionRoot string
+
+func defaultStorageRoot() string {
+	rootDir, _ := os.Getwd()
+	return path.Join(rootDir, "storage")
+}
+
+// SetStorageRoot sets the directory holding the files of the stacks, it must be called before any task starts
+func SetStorageRoot(root string) {
+	storageRoot = root
+}
+
+// SetAdoptionRoot sets the directory holding the CLI deployments which may be adopted
+func SetAdoptionRoot(root string) {
+	adoptionRoot = root
+}
+
+func GetAdoptionRoot() string {
+	return adoptionRoot
+}
+
 func GetDeploymentPath(
 	stack string,
 	network entities.DeploymentNetwork,
 	deploymentID string,
 ) string {
-	rootDir, _ := os.Getwd()
-	return path.Join(rootDir, "storage", "deployments", stack, string(network), deploymentID)
+	return path.Join(storageRoot, "deployments", stack, string(network), deploymentID)
 }
 
+// GetLogPath returns the name of a new log of the stack in the log sink
 func GetLogPath(
 	stackID uuid.UUID,
 	plugin string,
 ) string {
-	rootDir, _ := os.Getwd()
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	return path.Join(rootDir, "storage", "logs", stackID.String(), timestamp+fmt.Sprintf("_%s_logs.txt", plugin))
+	return path.Join(GetLogDir(stackID), timestamp+fmt.Sprintf("_%s_logs.txt", plugin))
+}
+
+// GetLogDir returns the directory of the logs of the stack in the log sink
+func GetLogDir(stackID uuid.UUID) string {
+	return stackID.String()
+}
+
+// GetImportPath returns the directory where stack bundles are extracted before being imported
+func GetImportPath() string {
+	return path.Join(storageRoot, "imports")
+}
+
+// IsInStorage reports whether the path is inside the storage root. Adopted stacks are deployed in place, in
+// directories the backend does not own.
+func IsInStorage(p string) bool {
+	return isWithin(storageRoot, p)
+}
+
+// IsInAdoptionRoot reports whether the path is inside the adoption root, it is false when no root is set
+func IsInAdoptionRoot(p string) bool {
+	return adoptionRoot != "" && isWithin(adoptionRoot, p)
+}
+
+// isWithin reports whether the path is inside the root, or inside the directory the root resolves to when the root
+// is or goes through a symlink
+func isWithin(root string, p string) bool {
+	roots := []string{root}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil && resolved != root {
+		roots = append(roots, resolved)
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			continue
+		}
+		if rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
 }
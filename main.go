@@ -1,19 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/tokamak-network/trh-backend/docs"
+	"github.com/tokamak-network/trh-backend/internal/config"
 	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/handlers"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
 	"github.com/tokamak-network/trh-backend/pkg/api/routes"
+	"github.com/tokamak-network/trh-backend/pkg/api/rpc"
 	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/logsink"
 	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/connection"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/migrations"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gorm.io/gorm"
 
 	_ "github.com/tokamak-network/trh-backend/docs"
 )
@@ -25,60 +42,201 @@ import (
 // @host      localhost:${PORT}
 // @BasePath  /api/v1
 
-// @securityDefinitions.basic  NoAuth
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Bearer <API key>. The X-API-Key header is accepted as well.
 func main() {
+	// Load .env file if it exists (optional for Docker runtime)
+	dotenvErr := godotenv.Load(".env")
 
-	logger.Init()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %s", err)
+	}
 
-	// Load .env file if it exists (optional for Docker runtime)
-	if err := godotenv.Load(".env"); err != nil {
-		logger.Infof("No .env file found, using environment variables: %s", err)
+	logger.Init(cfg.Logging.Level, cfg.Logging.Format)
+	if dotenvErr != nil {
+		logger.Infof("No .env file found, using environment variables: %s", dotenvErr)
 	}
+	utils.SetStorageRoot(cfg.Storage.Root)
+	utils.SetAdoptionRoot(cfg.Storage.AdoptionRoot)
 
-	port := os.Getenv("PORT")
+	db, err := connection.Init(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to connect to the database", zap.Error(err))
+	}
+
+	keyring, err := secrets.LoadKeyring(cfg.Encryption.Keys, cfg.Encryption.KeysFile, cfg.Encryption.CurrentKeyID)
+	if err != nil {
+		logger.Fatal("Failed to load the encryption keys", zap.Error(err))
+	}
+	if keyring == nil {
+		logger.Warn("ENCRYPTION_KEYS is not set, the credentials of the stacks are stored in plaintext")
+	}
 
-	if port == "" {
-		port = "8000"
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			// Apply or revert the schema migrations, run before starting a new release
+			migrate(db, os.Args[2:])
+			return
+		case "reencrypt":
+			requireCurrentSchema(db)
+			// Encrypt every stored secret with the current key, run after adding a key or enabling encryption
+			if keyring == nil {
+				logger.Fatal("ENCRYPTION_KEYS is required to re-encrypt the secrets")
+			}
+			updated, err := postgresRepositories.ReencryptSecrets(db, keyring)
+			if err != nil {
+				logger.Fatal("Failed to re-encrypt the secrets", zap.Int("updated", updated), zap.Error(err))
+			}
+			logger.Info("Re-encrypted the secrets", zap.Int("updated", updated), zap.String("keyId", keyring.CurrentKeyID()))
+			return
+		default:
+			logger.Fatalf("Unknown command %s", os.Args[1])
+		}
 	}
 
-	postgresUser := os.Getenv("POSTGRES_USER")
-	postgresHost := os.Getenv("POSTGRES_HOST")
-	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
-	postgresDatabase := os.Getenv("POSTGRES_DB")
-	postgresPort := os.Getenv("POSTGRES_PORT")
+	if cfg.Database.Driver == config.DatabaseDriverSQLite {
+		// A SQLite database belongs to this node alone, which migrates it rather than waiting for the migrate command
+		migrate(db, []string{"up"})
+	}
+	requireCurrentSchema(db)
 
-	postgresDB, err := connection.Init(
-		postgresUser,
-		postgresHost,
-		postgresPassword,
-		postgresDatabase,
-		postgresPort,
-	)
+	logSink, err := logsink.New(context.Background(), cfg.LogStorage, db)
 	if err != nil {
-		logger.Fatal("Failed to connect to postgres", zap.Error(err))
+		logger.Fatal("Failed to set up the log storage", zap.Error(err))
+	}
+	// Delete the logs beyond the retention
+	go logsink.RunRetention(context.Background(), logSink, cfg.LogStorage.PruneInterval)
+
+	server := servers.NewServer(cfg, db, keyring, logSink)
+
+	// The REST and gRPC APIs share the services, and so the task manager running the deployments
+	accessHandler := handlers.NewAccessHandler(server)
+	thanosHandler := handlers.NewThanosHandler(server, accessHandler.AccessService)
+	auditHandler := handlers.NewAuditHandler(server, accessHandler.AccessService)
+
+	// Bootstrap the first system administrator, further users are created through the API
+	if adminAPIKey := cfg.Auth.AdminAPIKey; adminAPIKey != "" {
+		if err := accessHandler.AccessService.EnsureAdminUser(context.Background(), adminAPIKey); err != nil {
+			logger.Fatal("Failed to bootstrap admin user", zap.Error(err))
+		}
 	}
 
+	// Deliver lifecycle events to the webhook subscriptions
+	webhookDispatcher := services.NewWebhookDispatcher(postgresRepositories.NewWebhookRepository(db, keyring))
+	go webhookDispatcher.Run(context.Background())
+
 	// programmatically set swagger info
 	docs.SwaggerInfo.Title = "TRH Backend"
 	docs.SwaggerInfo.Description = "TRH Backend API"
 	docs.SwaggerInfo.Version = "1.0"
 	docs.SwaggerInfo.Schemes = []string{"http"}
-	docs.SwaggerInfo.Host = fmt.Sprintf("localhost:%s", port)
+	if cfg.Server.TLS.Enabled() {
+		docs.SwaggerInfo.Schemes = []string{"https"}
+	}
+	docs.SwaggerInfo.Host = fmt.Sprintf("localhost:%s", cfg.Server.Port)
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
-	server := servers.NewServer(postgresDB)
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"*"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"*"}
+	corsConfig := cfg.CORS.Build()
+	corsConfig.ExposeHeaders = append(corsConfig.ExposeHeaders, middlewares.RequestIDHeader)
 
-	server.Use(cors.New(config))
+	server.Use(cors.New(corsConfig))
 
-	routes.SetupRoutes(server)
+	routes.SetupRoutes(server, accessHandler, thanosHandler, auditHandler)
 
-	err = server.Start(port)
+	listener, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
+	if err != nil {
+		logger.Fatal("Failed to listen for gRPC", zap.Error(err))
+	}
+	var grpcOptions []grpc.ServerOption
+	if tls := cfg.Server.TLS; tls.Enabled() {
+		creds, err := credentials.NewServerTLSFromFile(tls.CertFile, tls.KeyFile)
+		if err != nil {
+			logger.Fatal("Failed to load the TLS certificate", zap.Error(err))
+		}
+		grpcOptions = append(grpcOptions, grpc.Creds(creds))
+	}
+	grpcServer := rpc.NewServer(
+		thanosHandler.ThanosDeploymentService,
+		accessHandler.AccessService,
+		auditHandler.AuditService,
+		grpcOptions...,
+	)
+	go func() {
+		logger.Infof("gRPC server listening on port %s", cfg.Server.GRPCPort)
+		if err := grpcServer.Serve(listener); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
+	err = server.Start()
 	if err != nil {
 		logger.Error("Failed to start server", zap.Error(err))
 		log.Fatal(err)
 	}
 }
+
+// migrate runs the migrate command: up (the default), down [steps] or status
+func migrate(db *gorm.DB, args []string) {
+	ctx := context.Background()
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, migration := range applied {
+			logger.Info("Applied migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+		}
+		if err != nil {
+			logger.Fatal("Failed to migrate the database", zap.Error(err))
+		}
+		logger.Info("The database schema is up to date", zap.Int("applied", len(applied)))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				logger.Fatalf("Invalid number of migrations to revert %s", args[1])
+			}
+		}
+		reverted, err := migrations.Down(ctx, db, steps)
+		for _, migration := range reverted {
+			logger.Info("Reverted migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+		}
+		if err != nil {
+			logger.Fatal("Failed to revert the migrations", zap.Error(err))
+		}
+	case "status":
+		status, err := migrations.GetStatus(ctx, db)
+		if err != nil {
+			logger.Fatal("Failed to get the migration status", zap.Error(err))
+		}
+		for _, migration := range status.Applied {
+			fmt.Printf("applied  %06d_%s  %s\n", migration.Version, migration.Name, migration.AppliedAt.Format(time.RFC3339))
+		}
+		for _, migration := range status.Pending {
+			fmt.Printf("pending  %06d_%s\n", migration.Version, migration.Name)
+		}
+	default:
+		logger.Fatalf("Unknown migrate action %s, expected up, down or status", action)
+	}
+}
+
+// requireCurrentSchema refuses to run against a database missing migrations of this release. A schema ahead of the
+// release, during a rolling upgrade, is only reported.
+func requireCurrentSchema(db *gorm.DB) {
+	status, err := migrations.Check(context.Background(), db)
+	if err != nil {
+		logger.Fatal("The database schema is not up to date", zap.Error(err))
+	}
+	if len(status.Unknown) > 0 {
+		logger.Warn("The database schema has migrations unknown to this release",
+			zap.Int64("version", status.Unknown[len(status.Unknown)-1].Version))
+	}
+}
@@ -0,0 +1,42 @@
+package dtos
+
+import (
+	"errors"
+	"net/mail"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type CreateUserRequest struct {
+	Name    string `json:"name"    binding:"required"`
+	Email   string `json:"email"   binding:"required"`
+	IsAdmin bool   `json:"isAdmin"`
+}
+
+func (r *CreateUserRequest) Validate() error {
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return errors.New("invalid email")
+	}
+	return nil
+}
+
+type CreateProjectRequest struct {
+	Name        string `json:"name"        binding:"required"`
+	Description string `json:"description"`
+}
+
+type SetProjectMemberRequest struct {
+	UserID string               `json:"userId" binding:"required"`
+	Role   entities.ProjectRole `json:"role"   binding:"required" validate:"oneof=viewer operator admin"`
+}
+
+func (r *SetProjectMemberRequest) Validate() error {
+	if _, err := uuid.Parse(r.UserID); err != nil {
+		return errors.New("invalid userId")
+	}
+	if !r.Role.IsValid() {
+		return errors.New("invalid role, role must be one of viewer, operator, admin")
+	}
+	return nil
+}
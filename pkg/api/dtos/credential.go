@@ -0,0 +1,150 @@
+package dtos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	trhSdkAws "github.com/tokamak-network/trh-sdk/pkg/cloud-provider/aws"
+	trhSdkUtils "github.com/tokamak-network/trh-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// The limits of the STS AssumeRole API
+const (
+	minRoleSessionDuration     = 900
+	maxRoleSessionDuration     = 43200
+	defaultRoleSessionDuration = 3600
+	minExternalIDLength        = 2
+	maxExternalIDLength        = 1224
+)
+
+var (
+	roleArnRegex    = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]{1,512}$`)
+	externalIDRegex = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+)
+
+type CreateCredentialRequest struct {
+	ProjectID          string `json:"projectId"          binding:"required"`
+	Name               string `json:"name"               binding:"required"`
+	AwsAccessKey       string `json:"awsAccessKey"       binding:"required"`
+	AwsSecretAccessKey string `json:"awsSecretAccessKey" binding:"required"`
+	AwsRegion          string `json:"awsRegion"          binding:"required"`
+}
+
+func (r *CreateCredentialRequest) Validate(ctx context.Context) error {
+	if err := ValidateStackName(r.Name); err != nil {
+		return err
+	}
+	return ValidateAwsCredentials(ctx, r.AwsAccessKey, r.AwsSecretAccessKey, r.AwsRegion)
+}
+
+// UpdateCredentialRequest rotates the keys of a credential, the region is kept when not set
+type UpdateCredentialRequest struct {
+	AwsAccessKey       string `json:"awsAccessKey"       binding:"required"`
+	AwsSecretAccessKey string `json:"awsSecretAccessKey" binding:"required"`
+	AwsRegion          string `json:"awsRegion"`
+}
+
+// ValidateAwsCredentials checks the format of the keys and that they can access the region
+func ValidateAwsCredentials(ctx context.Context, accessKey string, secretAccessKey string, region string) error {
+	if err := validateAwsKeys(ctx, accessKey, secretAccessKey); err != nil {
+		return err
+	}
+
+	// IsAvailableRegion uses the default AWS profile, which must not assume the role of another stack
+	if err := utils.ClearAWSAssumeRole(); err != nil {
+		logger.ErrorContext(ctx, "failed to clear the AWS role", zap.Error(err))
+		return errors.New("failed to validate the AWS credentials")
+	}
+
+	// Validate AWS Region
+	if !trhSdkAws.IsAvailableRegion(accessKey, secretAccessKey, region) {
+		logger.ErrorContext(ctx, "invalid awsRegion", zap.String("awsRegion", region))
+		return errors.New("invalid awsRegion")
+	}
+
+	return nil
+}
+
+// ValidateAwsAssumeRole checks the format of the keys and that they can assume the role in the region. The keys may
+// have no other permission.
+func ValidateAwsAssumeRole(
+	ctx context.Context,
+	accessKey string,
+	secretAccessKey string,
+	region string,
+	role *AwsAssumeRole,
+) error {
+	if err := validateAwsKeys(ctx, accessKey, secretAccessKey); err != nil {
+		return err
+	}
+
+	// The STS endpoint of the region is used, so an unavailable region fails as well
+	_, err := utils.AssumeAWSRole(ctx, accessKey, secretAccessKey, region, role.RoleArn, role.ExternalID, role.GetSessionDuration())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to assume AWS role",
+			zap.String("roleArn", role.RoleArn),
+			zap.String("awsRegion", region),
+			zap.Error(err),
+		)
+		return errors.New("invalid awsAssumeRole, the keys cannot assume the role in awsRegion")
+	}
+
+	return nil
+}
+
+func validateAwsKeys(ctx context.Context, accessKey string, secretAccessKey string) error {
+	// Validate AWS Access Key
+	if !trhSdkUtils.IsValidAWSAccessKey(accessKey) {
+		logger.ErrorContext(ctx, "invalid awsAccessKey", zap.String("awsAccessKey", accessKey))
+		return errors.New("invalid awsAccessKey")
+	}
+
+	// Validate AWS Secret Key
+	if !trhSdkUtils.IsValidAWSSecretKey(secretAccessKey) {
+		logger.ErrorContext(ctx, "invalid awsSecretKey")
+		return errors.New("invalid awsSecretKey")
+	}
+
+	return nil
+}
+
+// AwsAssumeRole is an IAM role assumed with the AWS keys, so that they only need the permission to assume it.
+// The temporary credentials of the role are refreshed during long-running operations.
+type AwsAssumeRole struct {
+	RoleArn         string `json:"roleArn"         binding:"required"`
+	ExternalID      string `json:"externalId"`
+	SessionDuration int    `json:"sessionDuration"` // seconds, from 900 to 43200, defaults to 3600
+}
+
+func (r *AwsAssumeRole) Validate() error {
+	if !roleArnRegex.MatchString(r.RoleArn) {
+		return errors.New("invalid awsAssumeRole.roleArn")
+	}
+	if r.ExternalID != "" && (len(r.ExternalID) < minExternalIDLength || len(r.ExternalID) > maxExternalIDLength ||
+		!externalIDRegex.MatchString(r.ExternalID)) {
+		return errors.New("invalid awsAssumeRole.externalId")
+	}
+	if r.SessionDuration != 0 && (r.SessionDuration < minRoleSessionDuration || r.SessionDuration > maxRoleSessionDuration) {
+		return fmt.Errorf("awsAssumeRole.sessionDuration must be between %d and %d seconds", minRoleSessionDuration, maxRoleSessionDuration)
+	}
+	return nil
+}
+
+// GetSessionDuration returns the duration of the role sessions in seconds
+func (r *AwsAssumeRole) GetSessionDuration() int {
+	if r.SessionDuration == 0 {
+		return defaultRoleSessionDuration
+	}
+	return r.SessionDuration
+}
+
+// UpdateStackCredentialsRequest replaces the AWS keys of a stack, its region cannot change since its resources live there
+type UpdateStackCredentialsRequest struct {
+	AwsAccessKey       string `json:"awsAccessKey"       binding:"required"`
+	AwsSecretAccessKey string `json:"awsSecretAccessKey" binding:"required"`
+}
@@ -0,0 +1,25 @@
+package dtos
+
+import (
+	"github.com/tokamak-network/trh-backend/internal/keystore"
+)
+
+// CreateOperatorKeyRequest imports the private key into the keystore of the project, or generates a new key when it
+// is not set
+type CreateOperatorKeyRequest struct {
+	ProjectID  string `json:"projectId"  binding:"required"`
+	Name       string `json:"name"       binding:"required"`
+	PrivateKey string `json:"privateKey"`
+}
+
+func (r *CreateOperatorKeyRequest) Validate() error {
+	if err := ValidateStackName(r.Name); err != nil {
+		return err
+	}
+	if r.PrivateKey != "" {
+		if _, err := keystore.ParsePrivateKey(r.PrivateKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
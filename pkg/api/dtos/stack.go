@@ -0,0 +1,211 @@
+package dtos
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+const (
+	maxStackNameLength        = 64
+	maxStackDescriptionLength = 1024
+	maxStackLabels            = 50 // AWS allows 50 tags per resource
+	maxLabelKeyLength         = 128
+	maxLabelValueLength       = 256
+)
+
+// Label keys and values are restricted to the characters AWS accepts in resource tags
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[a-zA-Z0-9 _.:/=+\-@]+$`)
+	labelValueRegex = regexp.MustCompile(`^[a-zA-Z0-9 _.:/=+\-@]*$`)
+)
+
+type UpdateStackRequest struct {
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	Labels      *map[string]string `json:"labels"`
+}
+
+func (r *UpdateStackRequest) Validate() error {
+	if r.Name == nil && r.Description == nil && r.Labels == nil {
+		return errors.New("nothing to update")
+	}
+	if r.Name != nil {
+		if err := ValidateStackName(*r.Name); err != nil {
+			return err
+		}
+	}
+	if r.Description != nil {
+		if err := ValidateStackDescription(*r.Description); err != nil {
+			return err
+		}
+	}
+	if r.Labels != nil {
+		if err := ValidateStackLabels(*r.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateStackName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("name must not be empty")
+	}
+	if len(name) > maxStackNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxStackNameLength)
+	}
+	return nil
+}
+
+func ValidateStackDescription(description string) error {
+	if len(description) > maxStackDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", maxStackDescriptionLength)
+	}
+	return nil
+}
+
+func ValidateStackLabels(labels map[string]string) error {
+	if len(labels) > maxStackLabels {
+		return fmt.Errorf("at most %d labels are allowed", maxStackLabels)
+	}
+	for key, value := range labels {
+		if len(key) > maxLabelKeyLength || !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return fmt.Errorf("invalid label key %q, the aws: prefix is reserved", key)
+		}
+		if len(value) > maxLabelValueLength || !labelValueRegex.MatchString(value) {
+			return fmt.Errorf("invalid value for label %q", key)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector parses a comma separated list of key=value pairs, e.g. "env=prod,team=core".
+// Stacks match the selector when they carry every listed label.
+func ParseLabelSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if strings.TrimSpace(selector) == "" {
+		return labels, nil
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, found := strings.Cut(requirement, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid label selector requirement %q", requirement)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// CloneThanosStackRequest overrides the configuration copied from the source stack, omitted fields keep their values
+type CloneThanosStackRequest struct {
+	ProjectID                *string                     `json:"projectId"`
+	Name                     *string                     `json:"name"`
+	Description              *string                     `json:"description"`
+	Labels                   *map[string]string          `json:"labels"`
+	Network                  *entities.DeploymentNetwork `json:"network"`
+	L1RpcUrl                 *string                     `json:"l1RpcUrl"`
+	L1BeaconUrl              *string                     `json:"l1BeaconUrl"`
+	L2BlockTime              *int                        `json:"l2BlockTime"`
+	BatchSubmissionFrequency *int                        `json:"batchSubmissionFrequency"`
+	OutputRootFrequency      *int                        `json:"outputRootFrequency"`
+	ChallengePeriod          *int                        `json:"challengePeriod"`
+	AdminAccount             *string                     `json:"adminAccount"`
+	SequencerAccount         *string                     `json:"sequencerAccount"`
+	BatcherAccount           *string                     `json:"batcherAccount"`
+	ProposerAccount          *string                     `json:"proposerAccount"`
+	AdminKeyID               *string                     `json:"adminKeyId"`
+	SequencerKeyID           *string                     `json:"sequencerKeyId"`
+	BatcherKeyID             *string                     `json:"batcherKeyId"`
+	ProposerKeyID            *string                     `json:"proposerKeyId"`
+	CredentialID             *string                     `json:"credentialId"`
+	AwsAccessKey             *string                     `json:"awsAccessKey"`
+	AwsSecretAccessKey       *string                     `json:"awsSecretAccessKey"`
+	AwsRegion                *string                     `json:"awsRegion"`
+	AwsAssumeRole            *AwsAssumeRole              `json:"awsAssumeRole"`
+	ChainName                *string                     `json:"chainName"`
+	RegisterCandidate        *bool                       `json:"registerCandidate"`
+	RegisterCandidateParams  *RegisterCandidateRequest   `json:"registerCandidateParams"`
+	// Secrets of the integrations installed on the source stack, they are not copied.
+	// An installed integration is only cloned when its secrets are provided.
+	BlockExplorer *CloneBlockExplorerSecrets `json:"blockExplorer"`
+	Monitoring    *InstallMonitoringRequest  `json:"monitoring"`
+}
+
+type CloneBlockExplorerSecrets struct {
+	DatabasePassword string `json:"databasePassword" binding:"required"`
+	CoinmarketcapKey string `json:"coinmarketcapKey" binding:"required"`
+}
+
+// Apply overrides the fields of the source stack configuration which are set in the request
+func (r *CloneThanosStackRequest) Apply(config *DeployThanosRequest) {
+	setIfPresent(&config.ProjectID, r.ProjectID)
+	setIfPresent(&config.Name, r.Name)
+	setIfPresent(&config.Description, r.Description)
+	setIfPresent(&config.Labels, r.Labels)
+	setIfPresent(&config.Network, r.Network)
+	setIfPresent(&config.L1RpcUrl, r.L1RpcUrl)
+	setIfPresent(&config.L1BeaconUrl, r.L1BeaconUrl)
+	setIfPresent(&config.L2BlockTime, r.L2BlockTime)
+	setIfPresent(&config.BatchSubmissionFrequency, r.BatchSubmissionFrequency)
+	setIfPresent(&config.OutputRootFrequency, r.OutputRootFrequency)
+	setIfPresent(&config.ChallengePeriod, r.ChallengePeriod)
+	// Each system account comes either from a private key or from a managed key, whichever the request sets
+	setOperatorKey(&config.AdminAccount, &config.AdminKeyID, r.AdminAccount, r.AdminKeyID)
+	setOperatorKey(&config.SequencerAccount, &config.SequencerKeyID, r.SequencerAccount, r.SequencerKeyID)
+	setOperatorKey(&config.BatcherAccount, &config.BatcherKeyID, r.BatcherAccount, r.BatcherKeyID)
+	setOperatorKey(&config.ProposerAccount, &config.ProposerKeyID, r.ProposerAccount, r.ProposerKeyID)
+	// The AWS settings come either from a credential profile or from the keys, whichever the request sets
+	if r.CredentialID != nil {
+		config.CredentialID = *r.CredentialID
+		config.AwsAccessKey, config.AwsSecretAccessKey, config.AwsRegion = "", "", ""
+	} else if r.AwsAccessKey != nil || r.AwsSecretAccessKey != nil || r.AwsRegion != nil {
+		if config.CredentialID != "" {
+			config.CredentialID = ""
+			config.AwsAccessKey, config.AwsSecretAccessKey, config.AwsRegion = "", "", ""
+		}
+		setIfPresent(&config.AwsAccessKey, r.AwsAccessKey)
+		setIfPresent(&config.AwsSecretAccessKey, r.AwsSecretAccessKey)
+		setIfPresent(&config.AwsRegion, r.AwsRegion)
+	}
+	if r.AwsAssumeRole != nil {
+		config.AwsAssumeRole = r.AwsAssumeRole
+	}
+	setIfPresent(&config.ChainName, r.ChainName)
+	setIfPresent(&config.RegisterCandidate, r.RegisterCandidate)
+	if r.RegisterCandidateParams != nil {
+		config.RegisterCandidateParams = r.RegisterCandidateParams
+	}
+}
+
+// ReplacesSecrets reports whether the request sets the AWS credentials and every system account, so that none of the
+// secrets of the source stack are copied
+func (r *CloneThanosStackRequest) ReplacesSecrets() bool {
+	hasAwsCredentials := r.CredentialID != nil || (r.AwsAccessKey != nil && r.AwsSecretAccessKey != nil)
+	return hasAwsCredentials &&
+		(r.AdminAccount != nil || r.AdminKeyID != nil) &&
+		(r.SequencerAccount != nil || r.SequencerKeyID != nil) &&
+		(r.BatcherAccount != nil || r.BatcherKeyID != nil) &&
+		(r.ProposerAccount != nil || r.ProposerKeyID != nil)
+}
+
+func setOperatorKey(account *string, keyID *string, newAccount *string, newKeyID *string) {
+	if newKeyID != nil {
+		*keyID, *account = *newKeyID, ""
+	} else if newAccount != nil {
+		*account, *keyID = *newAccount, ""
+	}
+}
+
+func setIfPresent[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -3,13 +3,15 @@ package dtos
 import (
 	"context"
 	"errors"
+	"fmt"
+	"path/filepath"
 	"regexp"
 
+	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/internal/consts"
 	"github.com/tokamak-network/trh-backend/internal/logger"
 	"github.com/tokamak-network/trh-backend/internal/utils"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
-	trhSdkAws "github.com/tokamak-network/trh-sdk/pkg/cloud-provider/aws"
 	thanosStack "github.com/tokamak-network/trh-sdk/pkg/stacks/thanos"
 	trhSdkTypes "github.com/tokamak-network/trh-sdk/pkg/types"
 	trhSdkUtils "github.com/tokamak-network/trh-sdk/pkg/utils"
@@ -40,6 +42,10 @@ func (r *RegisterCandidateRequest) Validate(ctx context.Context) error {
 }
 
 type DeployThanosRequest struct {
+	ProjectID                string                     `json:"projectId"                binding:"required"`
+	Name                     string                     `json:"name"`
+	Description              string                     `json:"description"`
+	Labels                   map[string]string          `json:"labels"`
 	Network                  entities.DeploymentNetwork `json:"network"                  binding:"required" validate:"oneof=Mainnet Testnet LocalDevnet"`
 	L1RpcUrl                 string                     `json:"l1RpcUrl"                 binding:"required" validate:"url"`
 	L1BeaconUrl              string                     `json:"l1BeaconUrl"              binding:"required" validate:"url"`
@@ -47,27 +53,45 @@ type DeployThanosRequest struct {
 	BatchSubmissionFrequency int                        `json:"batchSubmissionFrequency" binding:"required" validate:"min=1"` // seconds
 	OutputRootFrequency      int                        `json:"outputRootFrequency"      binding:"required" validate:"min=1"` // seconds
 	ChallengePeriod          int                        `json:"challengePeriod"          binding:"required" validate:"min=1"` // seconds
-	AdminAccount             string                     `json:"adminAccount"             binding:"required" validate:"eth_address"`
-	SequencerAccount         string                     `json:"sequencerAccount"         binding:"required" validate:"eth_address"`
-	BatcherAccount           string                     `json:"batcherAccount"           binding:"required" validate:"eth_address"`
-	ProposerAccount          string                     `json:"proposerAccount"          binding:"required" validate:"eth_address"`
-	AwsAccessKey             string                     `json:"awsAccessKey"             binding:"required"`
-	AwsSecretAccessKey       string                     `json:"awsSecretAccessKey"       binding:"required"`
-	AwsRegion                string                     `json:"awsRegion"                binding:"required"`
+	AdminAccount             string                     `json:"adminAccount"             binding:"required_without=AdminKeyID"`
+	SequencerAccount         string                     `json:"sequencerAccount"         binding:"required_without=SequencerKeyID"`
+	BatcherAccount           string                     `json:"batcherAccount"           binding:"required_without=BatcherKeyID"`
+	ProposerAccount          string                     `json:"proposerAccount"          binding:"required_without=ProposerKeyID"`
+	AdminKeyID               string                     `json:"adminKeyId,omitempty"` // managed key of the project, in place of the private key
+	SequencerKeyID           string                     `json:"sequencerKeyId,omitempty"`
+	BatcherKeyID             string                     `json:"batcherKeyId,omitempty"`
+	ProposerKeyID            string                     `json:"proposerKeyId,omitempty"`
+	CredentialID             string                     `json:"credentialId,omitempty"` // credential profile of the project, in place of the AWS keys and region
+	AwsAccessKey             string                     `json:"awsAccessKey"             binding:"required_without=CredentialID"`
+	AwsSecretAccessKey       string                     `json:"awsSecretAccessKey"       binding:"required_without=CredentialID"`
+	AwsRegion                string                     `json:"awsRegion"                binding:"required_without=CredentialID"`
+	AwsAssumeRole            *AwsAssumeRole             `json:"awsAssumeRole,omitempty"` // role assumed with the AWS keys or the credential profile
 	ChainName                string                     `json:"chainName"                binding:"required"`
 	DeploymentPath           string                     `json:"deploymentPath"`
 	RegisterCandidate        bool                       `json:"registerCandidate"`
 	RegisterCandidateParams  *RegisterCandidateRequest  `json:"registerCandidateParams,omitempty"`
 }
 
-func (request *DeployThanosRequest) Validate() error {
+func (request *DeployThanosRequest) Validate(ctx context.Context) error {
 	if request.Network == entities.DeploymentNetworkLocalDevnet {
 		return errors.New("local devnet is not supported yet")
 	}
 
+	if request.Name != "" {
+		if err := ValidateStackName(request.Name); err != nil {
+			return err
+		}
+	}
+	if err := ValidateStackDescription(request.Description); err != nil {
+		return err
+	}
+	if err := ValidateStackLabels(request.Labels); err != nil {
+		return err
+	}
+
 	// Validate Chain Name
 	if !chainNameRegex.MatchString(request.ChainName) {
-		logger.Error("invalid chainName", zap.String("chainName", request.ChainName))
+		logger.ErrorContext(ctx, "invalid chainName", zap.String("chainName", request.ChainName))
 		return errors.New(
 			"invalid chain name, chain name must contain only letters (a-z, A-Z), numbers (0-9), spaces. Special characters are not allowed",
 		)
@@ -75,45 +99,51 @@ func (request *DeployThanosRequest) Validate() error {
 
 	// Validate L1 RPC URL
 	if !trhSdkUtils.IsValidL1RPC(request.L1RpcUrl) {
-		logger.Error("invalid l1RpcUrl", zap.String("l1RpcUrl", request.L1RpcUrl))
+		logger.ErrorContext(ctx, "invalid l1RpcUrl", zap.String("l1RpcUrl", request.L1RpcUrl))
 		return errors.New("invalid l1RpcUrl")
 	}
 
 	// Validate L1 Beacon URL
 	if !trhSdkUtils.IsValidBeaconURL(request.L1BeaconUrl) {
-		logger.Error("invalid l1BeaconUrl", zap.String("l1BeaconUrl", request.L1BeaconUrl))
+		logger.ErrorContext(ctx, "invalid l1BeaconUrl", zap.String("l1BeaconUrl", request.L1BeaconUrl))
 		return errors.New("invalid l1BeaconUrl")
 	}
 
-	// Validate AWS Access Key
-	if !trhSdkUtils.IsValidAWSAccessKey(request.AwsAccessKey) {
-		logger.Error("invalid awsAccessKey", zap.String("awsAccessKey", request.AwsAccessKey))
-		return errors.New("invalid awsAccessKey")
+	if request.AwsAssumeRole != nil {
+		if err := request.AwsAssumeRole.Validate(); err != nil {
+			return err
+		}
 	}
 
-	// Validate AWS Secret Key
-	if !trhSdkUtils.IsValidAWSSecretKey(request.AwsSecretAccessKey) {
-		logger.Error(
-			"invalid awsSecretKey",
-			zap.String("awsSecretAccessKey", request.AwsSecretAccessKey),
-		)
-		return errors.New("invalid awsSecretKey")
+	// The keys of a credential profile are validated when the profile is saved, and with the role once it is loaded
+	if request.CredentialID != "" {
+		if _, err := uuid.Parse(request.CredentialID); err != nil {
+			return errors.New("invalid credentialId")
+		}
+		if request.AwsAccessKey != "" || request.AwsSecretAccessKey != "" || request.AwsRegion != "" {
+			return errors.New("the AWS keys and region must not be set along with credentialId")
+		}
+	} else if request.AwsAssumeRole != nil {
+		if err := ValidateAwsAssumeRole(ctx, request.AwsAccessKey, request.AwsSecretAccessKey, request.AwsRegion, request.AwsAssumeRole); err != nil {
+			return err
+		}
+	} else if err := ValidateAwsCredentials(ctx, request.AwsAccessKey, request.AwsSecretAccessKey, request.AwsRegion); err != nil {
+		return err
 	}
 
-	// Validate AWS Region
-	if !trhSdkAws.IsAvailableRegion(
-		request.AwsAccessKey,
-		request.AwsSecretAccessKey,
-		request.AwsRegion,
-	) {
-		logger.Error("invalid awsRegion", zap.String("awsRegion", request.AwsRegion))
-		return errors.New("invalid awsRegion")
+	if err := validateOperatorKeyIDs(request.OperatorKeyIDs(), map[string]string{
+		"adminAccount":     request.AdminAccount,
+		"sequencerAccount": request.SequencerAccount,
+		"batcherAccount":   request.BatcherAccount,
+		"proposerAccount":  request.ProposerAccount,
+	}); err != nil {
+		return err
 	}
 
 	// Validate Chain Config
 	chainID, err := utils.GetChainIDFromRPC(request.L1RpcUrl)
 	if err != nil {
-		logger.Error("invalid rpc", zap.String("chainId", err.Error()))
+		logger.ErrorContext(ctx, "invalid rpc", zap.String("chainId", err.Error()))
 		return errors.New("invalid rpc")
 	}
 	chainConfig := trhSdkTypes.ChainConfiguration{
@@ -126,25 +156,127 @@ func (request *DeployThanosRequest) Validate() error {
 
 	err = chainConfig.Validate(chainID)
 	if err != nil {
-		logger.Error("invalid chainConfig", zap.String("chainConfig", err.Error()))
+		logger.ErrorContext(ctx, "invalid chainConfig", zap.String("chainConfig", err.Error()))
 		return err
 	}
 
 	return nil
 }
 
+// Prepare validates the request, including the candidate registration, and normalizes the private keys
+func (request *DeployThanosRequest) Prepare(ctx context.Context) error {
+	if err := request.Validate(ctx); err != nil {
+		return err
+	}
+
+	if request.RegisterCandidate {
+		if request.RegisterCandidateParams == nil {
+			return errors.New("registerCandidateParams is required")
+		}
+		if err := request.RegisterCandidateParams.Validate(ctx); err != nil {
+			return err
+		}
+	} else {
+		request.RegisterCandidateParams = nil
+	}
+
+	request.AdminAccount = utils.TrimPrivateKey(request.AdminAccount)
+	request.SequencerAccount = utils.TrimPrivateKey(request.SequencerAccount)
+	request.BatcherAccount = utils.TrimPrivateKey(request.BatcherAccount)
+	request.ProposerAccount = utils.TrimPrivateKey(request.ProposerAccount)
+
+	return nil
+}
+
+// OperatorKeyIDs returns the managed keys used by the system accounts, by the name of the account field
+func (request *DeployThanosRequest) OperatorKeyIDs() map[string]string {
+	return map[string]string{
+		"adminAccount":     request.AdminKeyID,
+		"sequencerAccount": request.SequencerKeyID,
+		"batcherAccount":   request.BatcherKeyID,
+		"proposerAccount":  request.ProposerKeyID,
+	}
+}
+
+// validateOperatorKeyIDs checks that each system account is given either as a private key or as a managed key id
+func validateOperatorKeyIDs(keyIDs map[string]string, accounts map[string]string) error {
+	for _, account := range []string{"adminAccount", "sequencerAccount", "batcherAccount", "proposerAccount"} {
+		keyID := keyIDs[account]
+		if keyID == "" {
+			if accounts[account] == "" {
+				return fmt.Errorf("%s or its key id is required", account)
+			}
+			continue
+		}
+		if accounts[account] != "" {
+			return fmt.Errorf("%s must not be set along with its key id", account)
+		}
+		if _, err := uuid.Parse(keyID); err != nil {
+			return fmt.Errorf("invalid key id of %s", account)
+		}
+	}
+	return nil
+}
+
+// AdoptThanosStackRequest registers a chain deployed with the trh-sdk CLI, its configuration is read from the
+// settings.json of the deployment directory
+type AdoptThanosStackRequest struct {
+	ProjectID          string            `json:"projectId"      binding:"required"`
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Labels             map[string]string `json:"labels"`
+	DeploymentPath     string            `json:"deploymentPath" binding:"required"`
+	AwsAccessKey       string            `json:"awsAccessKey"`
+	AwsSecretAccessKey string            `json:"awsSecretAccessKey"`
+	AwsAssumeRole      *AwsAssumeRole    `json:"awsAssumeRole"`
+}
+
+func (r *AdoptThanosStackRequest) Validate() error {
+	if !filepath.IsAbs(r.DeploymentPath) {
+		return errors.New("deploymentPath must be an absolute path")
+	}
+	if (r.AwsAccessKey == "") != (r.AwsSecretAccessKey == "") {
+		return errors.New("awsAccessKey and awsSecretAccessKey must be provided together")
+	}
+	if r.AwsAccessKey != "" && !trhSdkUtils.IsValidAWSAccessKey(r.AwsAccessKey) {
+		return errors.New("invalid awsAccessKey")
+	}
+	if r.AwsSecretAccessKey != "" && !trhSdkUtils.IsValidAWSSecretKey(r.AwsSecretAccessKey) {
+		return errors.New("invalid awsSecretKey")
+	}
+	if r.AwsAssumeRole != nil {
+		if err := r.AwsAssumeRole.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Name != "" {
+		if err := ValidateStackName(r.Name); err != nil {
+			return err
+		}
+	}
+	if err := ValidateStackDescription(r.Description); err != nil {
+		return err
+	}
+	return ValidateStackLabels(r.Labels)
+}
+
 type DeployL1ContractsRequest struct {
-	L1RpcUrl                 string                    `json:"l1RpcUrl"                 binding:"required" validate:"url"`
-	L2BlockTime              int                       `json:"l2BlockTime"              binding:"required" validate:"min=1"` // seconds
-	BatchSubmissionFrequency int                       `json:"batchSubmissionFrequency" binding:"required" validate:"min=1"` // seconds
-	OutputRootFrequency      int                       `json:"outputRootFrequency"      binding:"required" validate:"min=1"` // seconds
-	ChallengePeriod          int                       `json:"challengePeriod"          binding:"required" validate:"min=1"` // seconds
-	AdminAccount             string                    `json:"adminAccount"             binding:"required" validate:"eth_address"`
-	SequencerAccount         string                    `json:"sequencerAccount"         binding:"required" validate:"eth_address"`
-	BatcherAccount           string                    `json:"batcherAccount"           binding:"required" validate:"eth_address"`
-	ProposerAccount          string                    `json:"proposerAccount"          binding:"required" validate:"eth_address"`
-	RegisterCandidate        bool                      `json:"registerCandidate"`
-	RegisterCandidateParams  *RegisterCandidateRequest `json:"registerCandidateParams,omitempty"`
+	L1RpcUrl                 string `json:"l1RpcUrl"                 binding:"required" validate:"url"`
+	L2BlockTime              int    `json:"l2BlockTime"              binding:"required" validate:"min=1"` // seconds
+	BatchSubmissionFrequency int    `json:"batchSubmissionFrequency" binding:"required" validate:"min=1"` // seconds
+	OutputRootFrequency      int    `json:"outputRootFrequency"      binding:"required" validate:"min=1"` // seconds
+	ChallengePeriod          int    `json:"challengePeriod"          binding:"required" validate:"min=1"` // seconds
+	AdminAccount             string `json:"adminAccount"             binding:"required_without=AdminKeyID"`
+	SequencerAccount         string `json:"sequencerAccount"         binding:"required_without=SequencerKeyID"`
+	BatcherAccount           string `json:"batcherAccount"           binding:"required_without=BatcherKeyID"`
+	ProposerAccount          string `json:"proposerAccount"          binding:"required_without=ProposerKeyID"`
+	// The managed keys are only referenced, they are decrypted when the contracts are deployed
+	AdminKeyID              string                    `json:"adminKeyId,omitempty"`
+	SequencerKeyID          string                    `json:"sequencerKeyId,omitempty"`
+	BatcherKeyID            string                    `json:"batcherKeyId,omitempty"`
+	ProposerKeyID           string                    `json:"proposerKeyId,omitempty"`
+	RegisterCandidate       bool                      `json:"registerCandidate"`
+	RegisterCandidateParams *RegisterCandidateRequest `json:"registerCandidateParams,omitempty"`
 }
 
 type DeployThanosAWSInfraRequest struct {
@@ -159,28 +291,28 @@ type InstallBlockExplorerRequest struct {
 	WalletConnectID  string `json:"walletConnectId"     binding:"required"`
 }
 
-func (r *InstallBlockExplorerRequest) Validate() error {
+func (r *InstallBlockExplorerRequest) Validate(ctx context.Context) error {
 	if err := trhSdkUtils.ValidatePostgresUsername(r.DatabaseUsername); err != nil {
-		logger.Error("invalid database username", zap.String("databaseUsername", r.DatabaseUsername))
+		logger.ErrorContext(ctx, "invalid database username", zap.String("databaseUsername", r.DatabaseUsername))
 		return errors.New("invalid database username")
 	}
 
 	if !trhSdkUtils.IsValidRDSUsername(r.DatabaseUsername) {
-		logger.Error("invalid database username", zap.String("databaseUsername", r.DatabaseUsername))
+		logger.ErrorContext(ctx, "invalid database username", zap.String("databaseUsername", r.DatabaseUsername))
 		return errors.New("invalid database username")
 	}
 
 	if !trhSdkUtils.IsValidRDSPassword(r.DatabasePassword) {
-		logger.Error("invalid database password", zap.String("databasePassword", r.DatabasePassword))
+		logger.ErrorContext(ctx, "invalid database password", zap.String("databasePassword", r.DatabasePassword))
 		return errors.New("invalid database password")
 	}
 
 	if r.CoinmarketcapKey == "" {
-		logger.Error("coinmarketcapKey is required")
+		logger.ErrorContext(ctx, "coinmarketcapKey is required")
 		return errors.New("coinmarketcapKey is required")
 	}
 	if r.WalletConnectID == "" {
-		logger.Error("walletConnectId is required")
+		logger.ErrorContext(ctx, "walletConnectId is required")
 		return errors.New("walletConnectId is required")
 	}
 
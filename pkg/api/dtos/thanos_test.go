@@ -0,0 +1,148 @@
+package dtos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observeLogs replaces the application logger for the duration of the test
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	previous := logger.Logger
+	logger.Logger = zap.New(logger.NewRedactingCore(core))
+	t.Cleanup(func() { logger.Logger = previous })
+	return logs
+}
+
+func assertNotLogged(t *testing.T, logs *observer.ObservedLogs, secret string) {
+	t.Helper()
+	if logs.Len() == 0 {
+		t.Fatal("expected the validation failure to be logged")
+	}
+	for _, entry := range logs.AllUntimed() {
+		if strings.Contains(entry.Message, secret) {
+			t.Errorf("secret leaked in message %q", entry.Message)
+		}
+		for key, value := range entry.ContextMap() {
+			if s, ok := value.(string); ok && strings.Contains(s, secret) {
+				t.Errorf("secret leaked in field %s=%q", key, s)
+			}
+		}
+	}
+}
+
+// newL1Server answers the block number and beacon genesis queries of the validation
+func newL1Server(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"data":{"genesis_time":"1695902400","genesis_validators_root":"0x01","genesis_fork_version":"0x01017000"}}`))
+			return
+		}
+		var call struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(call.ID) + `,"result":"0x10"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDeployThanosRequestValidateDoesNotLogAwsKeys(t *testing.T) {
+	server := newL1Server(t)
+	logs := observeLogs(t)
+
+	accessKey := "AKIA-not-a-valid-format-key"
+	request := DeployThanosRequest{
+		Network:            entities.DeploymentNetworkTestnet,
+		L1RpcUrl:           server.URL,
+		L1BeaconUrl:        server.URL,
+		ChainName:          "leaky",
+		AwsAccessKey:       accessKey,
+		AwsSecretAccessKey: "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+		AwsRegion:          "us-east-1",
+	}
+	if err := request.Validate(context.Background()); err == nil || err.Error() != "invalid awsAccessKey" {
+		t.Fatalf("Validate() error = %v, want invalid awsAccessKey", err)
+	}
+
+	assertNotLogged(t, logs, accessKey)
+}
+
+func TestValidateAwsAssumeRoleDoesNotLogAwsKeys(t *testing.T) {
+	logs := observeLogs(t)
+
+	accessKey := "ASIA-not-a-valid-format-key"
+	role := &AwsAssumeRole{RoleArn: "arn:aws:iam::123456789012:role/trh"}
+	err := ValidateAwsAssumeRole(context.Background(), accessKey, "secret", "us-east-1", role)
+	if err == nil {
+		t.Fatal("ValidateAwsAssumeRole() error = nil, want an error")
+	}
+
+	assertNotLogged(t, logs, accessKey)
+}
+
+func TestInstallBlockExplorerRequestValidateDoesNotLogPassword(t *testing.T) {
+	logs := observeLogs(t)
+
+	password := "leaked password"
+	request := InstallBlockExplorerRequest{
+		DatabaseUsername: "explorer",
+		DatabasePassword: password,
+		CoinmarketcapKey: "cmc",
+		WalletConnectID:  "walletconnect",
+	}
+	if err := request.Validate(context.Background()); err == nil || err.Error() != "invalid database password" {
+		t.Fatalf("Validate() error = %v, want invalid database password", err)
+	}
+
+	assertNotLogged(t, logs, password)
+}
+
+func TestCloneThanosStackRequestReplacesSecrets(t *testing.T) {
+	key, account, credentialID := "key", "account", "credential"
+	accounts := CloneThanosStackRequest{
+		AdminAccount:    &account,
+		SequencerKeyID:  &key,
+		BatcherAccount:  &account,
+		ProposerAccount: &account,
+		AwsRegion:       &key,
+	}
+
+	withKeys := accounts
+	withKeys.AwsAccessKey, withKeys.AwsSecretAccessKey = &key, &key
+	withCredential := accounts
+	withCredential.CredentialID = &credentialID
+	withAccessKeyOnly := accounts
+	withAccessKeyOnly.AwsAccessKey = &key
+	withoutProposer := withKeys
+	withoutProposer.ProposerAccount = nil
+
+	for name, tc := range map[string]struct {
+		request CloneThanosStackRequest
+		want    bool
+	}{
+		"keys":             {withKeys, true},
+		"credential":       {withCredential, true},
+		"access key only":  {withAccessKeyOnly, false},
+		"without proposer": {withoutProposer, false},
+		"accounts only":    {accounts, false},
+	} {
+		if got := tc.request.ReplacesSecrets(); got != tc.want {
+			t.Errorf("%s: ReplacesSecrets() = %v, want %v", name, got, tc.want)
+		}
+	}
+}
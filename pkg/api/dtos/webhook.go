@@ -0,0 +1,33 @@
+package dtos
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+const minWebhookSecretLength = 16
+
+type CreateWebhookRequest struct {
+	URL    string                      `json:"url"    binding:"required"`
+	Events []entities.WebhookEventType `json:"events"`
+	Secret string                      `json:"secret"`
+}
+
+func (r *CreateWebhookRequest) Validate() error {
+	u, err := url.ParseRequestURI(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid url")
+	}
+	for _, event := range r.Events {
+		if !event.IsValid() {
+			return fmt.Errorf("invalid event %q, event must be one of %v", event, entities.WebhookEventTypes)
+		}
+	}
+	if r.Secret != "" && len(r.Secret) < minWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+)
+
+type AccessHandler struct {
+	AccessService *services.AccessService
+}
+
+// @Summary      Get Current User
+// @Description  Get the user authenticated by the API key
+// @Tags         Access
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200      {object}  entities.Response
+// @Router       /users/me [get]
+func (h *AccessHandler) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"user": middlewares.CurrentUser(c)},
+	})
+}
+
+// @Summary      Get Users
+// @Description  Get all users, only allowed for system administrators
+// @Tags         Access
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200      {object}  entities.Response
+// @Router       /users [get]
+func (h *AccessHandler) GetUsers(c *gin.Context) {
+	response, err := h.AccessService.GetUsers(c, middlewares.CurrentUser(c))
+	if err != nil {
+		logger.ErrorContext(c, "failed to get users", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Create User
+// @Description  Create a user and return its API key, only allowed for system administrators
+// @Tags         Access
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      dtos.CreateUserRequest  true  "Create User Request"
+// @Success      200      {object}  entities.Response
+// @Router       /users [post]
+func (h *AccessHandler) CreateUser(c *gin.Context) {
+	var request dtos.CreateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.AccessService.CreateUser(c, middlewares.CurrentUser(c), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to create user", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Create Project
+// @Description  Create a project, the caller becomes its admin
+// @Tags         Access
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      dtos.CreateProjectRequest  true  "Create Project Request"
+// @Success      200      {object}  entities.Response
+// @Router       /projects [post]
+func (h *AccessHandler) CreateProject(c *gin.Context) {
+	var request dtos.CreateProjectRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.AccessService.CreateProject(c, middlewares.CurrentUser(c), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to create project", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Projects
+// @Description  Get the projects the caller is a member of
+// @Tags         Access
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200      {object}  entities.Response
+// @Router       /projects [get]
+func (h *AccessHandler) GetProjects(c *gin.Context) {
+	response, err := h.AccessService.GetProjects(c, middlewares.CurrentUser(c))
+	if err != nil {
+		logger.ErrorContext(c, "failed to get projects", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Project
+// @Description  Get a project and its members
+// @Tags         Access
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id} [get]
+func (h *AccessHandler) GetProject(c *gin.Context) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.AccessService.GetProject(c, middlewares.CurrentUser(c), projectId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get project", zap.Error(err), zap.String("id", projectId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Set Project Member
+// @Description  Add a member to the project or change the role of an existing member
+// @Tags         Access
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Param        request  body      dtos.SetProjectMemberRequest  true  "Set Project Member Request"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/members [put]
+func (h *AccessHandler) SetProjectMember(c *gin.Context) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return
+	}
+
+	var request dtos.SetProjectMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.AccessService.SetProjectMember(c, middlewares.CurrentUser(c), projectId, request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to set project member", zap.Error(err), zap.String("id", projectId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Remove Project Member
+// @Description  Remove a member from the project
+// @Tags         Access
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Param        userId   path      string  true  "User ID"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/members/{userId} [delete]
+func (h *AccessHandler) RemoveProjectMember(c *gin.Context) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return
+	}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid userId",
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.AccessService.RemoveProjectMember(c, middlewares.CurrentUser(c), projectId, userId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to remove project member", zap.Error(err), zap.String("id", projectId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+func NewAccessHandler(server *servers.Server) *AccessHandler {
+	userRepo := postgresRepositories.NewUserRepository(server.PostgresDB)
+	projectRepo := postgresRepositories.NewProjectRepository(server.PostgresDB)
+	stackRepo := postgresRepositories.NewStackRepository(server.PostgresDB, server.Keyring)
+
+	return &AccessHandler{
+		AccessService: services.NewAccessService(userRepo, projectRepo, stackRepo),
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAuditRecordsLimit = 100
+	maxAuditRecordsLimit     = 1000
+)
+
+type AuditHandler struct {
+	AuditService *services.AuditService
+}
+
+// @Summary      Get Audit Records
+// @Description  Get the most recent mutating API calls and status transitions, newest first. The records of a stack or a project require its admins, the whole trail requires system administrators.
+// @Tags         Audit
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        stackId   query      string  false  "Stack ID, also matches the calls which created the stack"
+// @Param        projectId   query      string  false  "Project ID"
+// @Param        actorId   query      string  false  "User ID of the caller"
+// @Param        since   query      string  false  "RFC 3339 time, inclusive"
+// @Param        until   query      string  false  "RFC 3339 time, exclusive"
+// @Param        limit   query      int  false  "Maximum number of records, defaults to 100"
+// @Success      200      {object}  entities.Response
+// @Router       /audit [get]
+func (h *AuditHandler) GetAuditRecords(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.AuditService.GetRecords(c, middlewares.CurrentUser(c), filter)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get audit records", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Export Audit Records
+// @Description  Download every record matching the filters as JSON Lines, oldest first. The limit is ignored.
+// @Tags         Audit
+// @Produce      application/x-ndjson
+// @Security     ApiKeyAuth
+// @Param        stackId   query      string  false  "Stack ID, also matches the calls which created the stack"
+// @Param        projectId   query      string  false  "Project ID"
+// @Param        actorId   query      string  false  "User ID of the caller"
+// @Param        since   query      string  false  "RFC 3339 time, inclusive"
+// @Param        until   query      string  false  "RFC 3339 time, exclusive"
+// @Success      200      {string}  string  "One JSON record per line"
+// @Router       /audit/export [get]
+func (h *AuditHandler) ExportAuditRecords(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	// The status is only known once the caller is authorized, the headers are written with the first record
+	started := false
+	start := func() {
+		if !started {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+			c.Status(http.StatusOK)
+			started = true
+		}
+	}
+	encoder := json.NewEncoder(c.Writer)
+	response, err := h.AuditService.ExportRecords(c, middlewares.CurrentUser(c), filter, func(record *entities.AuditRecordEntity) error {
+		start()
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		logger.ErrorContext(c, "failed to export audit records", zap.Error(err))
+	}
+	if response != nil && !started {
+		c.JSON(int(response.Status), response)
+		return
+	}
+	start()
+}
+
+// parseAuditFilter writes an error response and returns false when a query parameter is invalid
+func parseAuditFilter(c *gin.Context) (entities.AuditFilter, bool) {
+	filter := entities.AuditFilter{Limit: defaultAuditRecordsLimit}
+
+	ids := []struct {
+		name   string
+		target **uuid.UUID
+	}{
+		{"stackId", &filter.StackID},
+		{"projectId", &filter.ProjectID},
+		{"actorId", &filter.ActorID},
+	}
+	for _, param := range ids {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			writeBadRequest(c, "invalid "+param.name)
+			return filter, false
+		}
+		*param.target = &id
+	}
+
+	times := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	}
+	for _, param := range times {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeBadRequest(c, "invalid "+param.name+", expected an RFC 3339 time")
+			return filter, false
+		}
+		*param.target = &parsed
+	}
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxAuditRecordsLimit {
+			writeBadRequest(c, "invalid limit")
+			return filter, false
+		}
+		filter.Limit = parsed
+	}
+
+	return filter, true
+}
+
+func writeBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, &entities.Response{
+		Status:  http.StatusBadRequest,
+		Message: message,
+		Data:    nil,
+	})
+}
+
+func NewAuditHandler(server *servers.Server, accessService *services.AccessService) *AuditHandler {
+	auditRepo := postgresRepositories.NewAuditRepository(server.PostgresDB)
+	stackRepo := postgresRepositories.NewStackRepository(server.PostgresDB, server.Keyring)
+
+	return &AuditHandler{
+		AuditService: services.NewAuditService(auditRepo, stackRepo, accessService),
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+)
+
+type CredentialHandler struct {
+	CredentialService *services.CredentialService
+}
+
+// @Summary      Create Credential
+// @Description  Save a cloud credential profile of the project once its keys are validated. Stacks reference it by id in place of the AWS keys and region. The secret key is never returned.
+// @Tags         Credentials
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      dtos.CreateCredentialRequest  true  "Create Credential Request"
+// @Success      200      {object}  entities.Response
+// @Router       /credentials [post]
+func (h *CredentialHandler) CreateCredential(c *gin.Context) {
+	var request dtos.CreateCredentialRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.CredentialService.CreateCredential(c, middlewares.CurrentUser(c), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to create credential", zap.Error(err), zap.String("projectId", request.ProjectID))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Credentials
+// @Description  Get the credential profiles of the project, or of every project accessible to the caller
+// @Tags         Credentials
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        projectId   query      string  false  "Project ID"
+// @Success      200      {object}  entities.Response
+// @Router       /credentials [get]
+func (h *CredentialHandler) GetCredentials(c *gin.Context) {
+	var projectId *uuid.UUID
+	if value := c.Query("projectId"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: "invalid projectId",
+				Data:    nil,
+			})
+			return
+		}
+		projectId = &parsed
+	}
+
+	response, err := h.CredentialService.GetCredentials(c, middlewares.CurrentUser(c), projectId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get credentials", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Credential
+// @Description  Get a credential profile, without its secret key
+// @Tags         Credentials
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Credential ID"
+// @Success      200      {object}  entities.Response
+// @Router       /credentials/{id} [get]
+func (h *CredentialHandler) GetCredential(c *gin.Context) {
+	credentialId, ok := parseCredentialId(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.CredentialService.GetCredential(c, middlewares.CurrentUser(c), credentialId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get credential", zap.Error(err), zap.String("id", credentialId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Rotate Credential
+// @Description  Replace the keys of a credential profile once they are validated. The stacks using it are not redeployed, they pick up the new keys on their next operation.
+// @Tags         Credentials
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Credential ID"
+// @Param        request  body      dtos.UpdateCredentialRequest  true  "Update Credential Request"
+// @Success      200      {object}  entities.Response
+// @Router       /credentials/{id} [put]
+func (h *CredentialHandler) UpdateCredential(c *gin.Context) {
+	credentialId, ok := parseCredentialId(c)
+	if !ok {
+		return
+	}
+
+	var request dtos.UpdateCredentialRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.CredentialService.RotateCredential(c, middlewares.CurrentUser(c), credentialId, request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to rotate credential", zap.Error(err), zap.String("id", credentialId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Delete Credential
+// @Description  Delete a credential profile, it must not be used by any stack other than terminated ones
+// @Tags         Credentials
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Credential ID"
+// @Success      200      {object}  entities.Response
+// @Router       /credentials/{id} [delete]
+func (h *CredentialHandler) DeleteCredential(c *gin.Context) {
+	credentialId, ok := parseCredentialId(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.CredentialService.DeleteCredential(c, middlewares.CurrentUser(c), credentialId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to delete credential", zap.Error(err), zap.String("id", credentialId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+func parseCredentialId(c *gin.Context) (uuid.UUID, bool) {
+	credentialId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return uuid.Nil, false
+	}
+	return credentialId, true
+}
+
+func NewCredentialHandler(server *servers.Server, accessService *services.AccessService) *CredentialHandler {
+	credentialRepo := postgresRepositories.NewCredentialRepository(server.PostgresDB, server.Keyring)
+
+	return &CredentialHandler{
+		CredentialService: services.NewCredentialService(credentialRepo, accessService),
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+)
+
+type OperatorKeyHandler struct {
+	OperatorKeyService *services.OperatorKeyService
+}
+
+// @Summary      Create Key
+// @Description  Import a private key into the keystore of the project, or generate a new one when privateKey is not set. Stacks reference the key by id for their admin, sequencer, batcher and proposer accounts. Only the address of the key is returned.
+// @Tags         Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      dtos.CreateOperatorKeyRequest  true  "Create Key Request"
+// @Success      200      {object}  entities.Response
+// @Router       /keys [post]
+func (h *OperatorKeyHandler) CreateKey(c *gin.Context) {
+	var request dtos.CreateOperatorKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.OperatorKeyService.CreateKey(c, middlewares.CurrentUser(c), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to create key", zap.Error(err), zap.String("projectId", request.ProjectID))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Keys
+// @Description  Get the managed keys of the project, or of every project accessible to the caller
+// @Tags         Keys
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        projectId   query      string  false  "Project ID"
+// @Success      200      {object}  entities.Response
+// @Router       /keys [get]
+func (h *OperatorKeyHandler) GetKeys(c *gin.Context) {
+	var projectId *uuid.UUID
+	if value := c.Query("projectId"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: "invalid projectId",
+				Data:    nil,
+			})
+			return
+		}
+		projectId = &parsed
+	}
+
+	response, err := h.OperatorKeyService.GetKeys(c, middlewares.CurrentUser(c), projectId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get keys", zap.Error(err))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Key
+// @Description  Get a managed key, without its private key
+// @Tags         Keys
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Key ID"
+// @Success      200      {object}  entities.Response
+// @Router       /keys/{id} [get]
+func (h *OperatorKeyHandler) GetKey(c *gin.Context) {
+	keyId, ok := parseKeyId(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.OperatorKeyService.GetKey(c, middlewares.CurrentUser(c), keyId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get key", zap.Error(err), zap.String("id", keyId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Delete Key
+// @Description  Delete a managed key, it must not be used by any stack other than terminated ones
+// @Tags         Keys
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Key ID"
+// @Success      200      {object}  entities.Response
+// @Router       /keys/{id} [delete]
+func (h *OperatorKeyHandler) DeleteKey(c *gin.Context) {
+	keyId, ok := parseKeyId(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.OperatorKeyService.DeleteKey(c, middlewares.CurrentUser(c), keyId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to delete key", zap.Error(err), zap.String("id", keyId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+func parseKeyId(c *gin.Context) (uuid.UUID, bool) {
+	keyId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return uuid.Nil, false
+	}
+	return keyId, true
+}
+
+func NewOperatorKeyHandler(server *servers.Server, accessService *services.AccessService) *OperatorKeyHandler {
+	keyRepo := postgresRepositories.NewOperatorKeyRepository(server.PostgresDB)
+
+	return &OperatorKeyHandler{
+		OperatorKeyService: services.NewOperatorKeyService(keyRepo, server.Keystore, accessService),
+	}
+}
@@ -1,24 +1,30 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"strconv"
 
 	"github.com/tokamak-network/trh-backend/internal/logger"
-	"github.com/tokamak-network/trh-backend/internal/utils"
 	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
 	"github.com/tokamak-network/trh-backend/pkg/api/servers"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
 	"github.com/tokamak-network/trh-backend/pkg/services"
+	"github.com/tokamak-network/trh-backend/pkg/stacks/thanos"
 	"github.com/tokamak-network/trh-backend/pkg/taskmanager"
 )
 
 type ThanosDeploymentHandler struct {
 	ThanosDeploymentService *services.ThanosStackDeploymentService
+	AccessService           *services.AccessService
 }
 
 // @Summary      Deploy Thanos Stack
@@ -26,6 +32,7 @@ type ThanosDeploymentHandler struct {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        request  body      dtos.DeployThanosRequest  true  "Deploy Thanos Stack Request"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos [post]
@@ -40,7 +47,11 @@ func (h *ThanosDeploymentHandler) Deploy(c *gin.Context) {
 		return
 	}
 
-	if err := request.Validate(); err != nil {
+	if !h.authorizeProject(c, request.ProjectID, entities.ProjectRoleOperator) {
+		return
+	}
+
+	if err := request.Prepare(c.Request.Context()); err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: err.Error(),
@@ -49,35 +60,64 @@ func (h *ThanosDeploymentHandler) Deploy(c *gin.Context) {
 		return
 	}
 
-	if request.RegisterCandidate {
-		if request.RegisterCandidateParams == nil {
-			c.JSON(http.StatusBadRequest, &entities.Response{
-				Status:  http.StatusBadRequest,
-				Message: "registerCandidateParams is required",
-				Data:    nil,
-			})
-			return
-		}
+	response, err := h.ThanosDeploymentService.CreateThanosStack(c, request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to deploy thanos stack", zap.Error(err))
+	}
 
-		if err := request.RegisterCandidateParams.Validate(c.Request.Context()); err != nil {
-			c.JSON(http.StatusBadRequest, &entities.Response{
-				Status:  http.StatusBadRequest,
-				Message: err.Error(),
-			})
-			return
-		}
-	} else {
-		request.RegisterCandidateParams = nil
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Adopt Thanos Stack
+// @Description  Register a chain deployed with the trh-sdk CLI. Its configuration is read from the settings.json of the deployment directory, which must be accessible to the backend and is used in place. The AWS credentials of settings.json are used unless provided. When an adoption root is configured, the directory must be inside it and project operators may adopt it, otherwise only system administrators may adopt.
+// @Tags         Thanos Stack
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      dtos.AdoptThanosStackRequest  true  "Adopt Thanos Stack Request"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/thanos/adopt [post]
+func (h *ThanosDeploymentHandler) Adopt(c *gin.Context) {
+	var request dtos.AdoptThanosStackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	projectId, err := uuid.Parse(request.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid projectId",
+			Data:    nil,
+		})
+		return
+	}
+	response, err := h.AccessService.AuthorizeAdoption(c, middlewares.CurrentUser(c), projectId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to authorize adoption", zap.Error(err), zap.String("projectId", request.ProjectID))
+	}
+	if response != nil {
+		c.JSON(int(response.Status), response)
+		return
 	}
 
-	request.AdminAccount = utils.TrimPrivateKey(request.AdminAccount)
-	request.SequencerAccount = utils.TrimPrivateKey(request.SequencerAccount)
-	request.BatcherAccount = utils.TrimPrivateKey(request.BatcherAccount)
-	request.ProposerAccount = utils.TrimPrivateKey(request.ProposerAccount)
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
 
-	response, err := h.ThanosDeploymentService.CreateThanosStack(c, request)
+	response, err = h.ThanosDeploymentService.AdoptThanosStack(c, request)
 	if err != nil {
-		logger.Error("failed to deploy thanos stack", zap.Error(err))
+		logger.ErrorContext(c, "failed to adopt thanos stack", zap.Error(err))
 	}
 
 	c.JSON(int(response.Status), response)
@@ -88,6 +128,7 @@ func (h *ThanosDeploymentHandler) Deploy(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/stop [post]
@@ -101,9 +142,13 @@ func (h *ThanosDeploymentHandler) Stop(c *gin.Context) {
 		})
 		return
 	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
 	response, err := h.ThanosDeploymentService.StopDeployingThanosStack(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to stop thanos stack", zap.Error(err))
+		logger.ErrorContext(c, "failed to stop thanos stack", zap.Error(err))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -113,6 +158,7 @@ func (h *ThanosDeploymentHandler) Stop(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        request  body      dtos.UpdateNetworkRequest  true  "Update Network Request"
 // @Success      200      {object}  entities.Response
@@ -127,6 +173,10 @@ func (h *ThanosDeploymentHandler) UpdateNetwork(c *gin.Context) {
 		})
 		return
 	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
 	var request dtos.UpdateNetworkRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
@@ -139,7 +189,241 @@ func (h *ThanosDeploymentHandler) UpdateNetwork(c *gin.Context) {
 
 	response, err := h.ThanosDeploymentService.UpdateNetwork(c, uuid.MustParse(id), request)
 	if err != nil {
-		logger.Error("failed to update network", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to update network", zap.Error(err), zap.String("id", id))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Update Stack
+// @Description  Update the name, description and labels of the stack. Labels replace the existing ones.
+// @Tags         Thanos Stack
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        request  body      dtos.UpdateStackRequest  true  "Update Stack Request"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/thanos/{id} [patch]
+func (h *ThanosDeploymentHandler) UpdateStack(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+	var request dtos.UpdateStackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.ThanosDeploymentService.UpdateStack(c, uuid.MustParse(id), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to update stack", zap.Error(err), zap.String("id", id))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Update Stack Credentials
+// @Description  Replace the AWS keys of the stack after an IAM key rotation. The keys must be valid in the region of the stack and able to see its deployed resources. The config is stored as a new revision and nothing is redeployed. Stacks using a credential profile are updated by rotating the profile.
+// @Tags         Thanos Stack
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        request  body      dtos.UpdateStackCredentialsRequest  true  "Update Stack Credentials Request"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/thanos/{id}/credentials [put]
+func (h *ThanosDeploymentHandler) UpdateStackCredentials(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionUpdateCredentials) {
+		return
+	}
+	var request dtos.UpdateStackCredentialsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.ThanosDeploymentService.UpdateStackCredentials(c, uuid.MustParse(id), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to update stack credentials", zap.Error(err), zap.String("id", id))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Clone Thanos Stack
+// @Description  Create and deploy a new stack from the configuration of an existing one. Fields provided in the body override the copied configuration. The installed block explorer and monitoring are cloned when their secrets are provided, otherwise they are reported in skippedIntegrations. Cloning into another project requires new AWS credentials and system accounts, the secrets of the source stack are not copied.
+// @Tags         Thanos Stack
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        request  body      dtos.CloneThanosStackRequest  true  "Clone Thanos Stack Request"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/thanos/{id}/clone [post]
+func (h *ThanosDeploymentHandler) Clone(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+	var request dtos.CloneThanosStackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if request.ProjectID != nil && !h.authorizeProject(c, *request.ProjectID, entities.ProjectRoleOperator) {
+		return
+	}
+
+	response, err := h.ThanosDeploymentService.CloneThanosStack(c, uuid.MustParse(id), request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to clone thanos stack", zap.Error(err), zap.String("id", id))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Export Thanos Stack
+// @Description  Download a signed bundle of the stack: its database rows, its deployment directory and optionally its logs. The bundle contains the stack credentials.
+// @Tags         Thanos Stack
+// @Produce      application/gzip
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        includeLogs  query  bool  false  "Include the stack logs"
+// @Success      200      {file}  file
+// @Router       /stacks/thanos/{id}/export [get]
+func (h *ThanosDeploymentHandler) ExportStack(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionExport) {
+		return
+	}
+
+	includeLogs, ok := parseBoolQuery(c, "includeLogs")
+	if !ok {
+		return
+	}
+
+	archive, err := os.CreateTemp("", "stack-bundle-*.tar.gz")
+	if err != nil {
+		logger.ErrorContext(c, "failed to create stack bundle file", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+		return
+	}
+	defer os.Remove(archive.Name())
+
+	response, err := h.ThanosDeploymentService.ExportStack(c, uuid.MustParse(id), includeLogs, archive)
+	archive.Close()
+	if err != nil {
+		logger.ErrorContext(c, "failed to export stack", zap.Error(err), zap.String("id", id))
+	}
+	if response.Status != http.StatusOK {
+		c.JSON(int(response.Status), response)
+		return
+	}
+
+	c.FileAttachment(archive.Name(), fmt.Sprintf("stack-%s.tar.gz", id))
+}
+
+// @Summary      Import Stack
+// @Description  Restore a bundle exported by another instance into the project. The instances must share the same STACK_BUNDLE_SIGNING_KEY.
+// @Tags         Thanos Stack
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        projectId  formData  string  true  "Project ID"
+// @Param        bundle     formData  file    true  "Stack bundle"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/import [post]
+func (h *ThanosDeploymentHandler) ImportStack(c *gin.Context) {
+	projectId := c.PostForm("projectId")
+	if !h.authorizeProject(c, projectId, entities.ProjectRoleOperator) {
+		return
+	}
+
+	header, err := c.FormFile("bundle")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "bundle is required",
+			Data:    nil,
+		})
+		return
+	}
+	bundle, err := header.Open()
+	if err != nil {
+		logger.ErrorContext(c, "failed to open stack bundle", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+		return
+	}
+	defer bundle.Close()
+
+	response, err := h.ThanosDeploymentService.ImportStack(c, uuid.MustParse(projectId), bundle)
+	if err != nil {
+		logger.ErrorContext(c, "failed to import stack", zap.Error(err))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -149,7 +433,9 @@ func (h *ThanosDeploymentHandler) UpdateNetwork(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        purge   query      bool  false  "Archive a terminated stack and remove its deployment directory instead"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id} [delete]
 func (h *ThanosDeploymentHandler) Terminate(c *gin.Context) {
@@ -162,9 +448,28 @@ func (h *ThanosDeploymentHandler) Terminate(c *gin.Context) {
 		})
 		return
 	}
+
+	purge, ok := parseBoolQuery(c, "purge")
+	if !ok {
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionTerminate) {
+		return
+	}
+
+	if purge {
+		response, err := h.ThanosDeploymentService.PurgeStack(c, uuid.MustParse(id))
+		if err != nil {
+			logger.ErrorContext(c, "failed to purge thanos stack", zap.Error(err), zap.String("id", id))
+		}
+		c.JSON(int(response.Status), response)
+		return
+	}
+
 	response, err := h.ThanosDeploymentService.TerminateThanosStack(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to terminate thanos stack", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to terminate thanos stack", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -174,6 +479,7 @@ func (h *ThanosDeploymentHandler) Terminate(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/resume [post]
@@ -187,9 +493,13 @@ func (h *ThanosDeploymentHandler) Resume(c *gin.Context) {
 		})
 		return
 	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
 	response, err := h.ThanosDeploymentService.ResumeThanosStack(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to resume thanos stack", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to resume thanos stack", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -199,12 +509,46 @@ func (h *ThanosDeploymentHandler) Resume(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
+// @Param        labelSelector   query      string  false  "Comma separated key=value labels the stacks must carry, e.g. env=prod,team=core"
+// @Param        includeArchived   query      bool  false  "Include the purged stacks"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos [get]
 func (h *ThanosDeploymentHandler) GetAllStacks(c *gin.Context) {
-	response, err := h.ThanosDeploymentService.GetAllStacks()
+	labelSelector, err := dtos.ParseLabelSelector(c.Query("labelSelector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	includeArchived, ok := parseBoolQuery(c, "includeArchived")
+	if !ok {
+		return
+	}
+
+	projectIds, all, err := h.AccessService.GetAccessibleProjectIDs(middlewares.CurrentUser(c))
 	if err != nil {
-		logger.Error("failed to get all stacks", zap.Error(err))
+		logger.ErrorContext(c, "failed to get accessible projects", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+		return
+	}
+
+	var response *entities.Response
+	if all {
+		response, err = h.ThanosDeploymentService.GetAllStacks(c, labelSelector, includeArchived)
+	} else {
+		response, err = h.ThanosDeploymentService.GetStacksByProjectIDs(c, projectIds, labelSelector, includeArchived)
+	}
+	if err != nil {
+		logger.ErrorContext(c, "failed to get all stacks", zap.Error(err))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -214,6 +558,7 @@ func (h *ThanosDeploymentHandler) GetAllStacks(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/status [get]
@@ -227,9 +572,43 @@ func (h *ThanosDeploymentHandler) GetStackStatus(c *gin.Context) {
 		})
 		return
 	}
-	response, err := h.ThanosDeploymentService.GetStackStatus(uuid.MustParse(id))
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	response, err := h.ThanosDeploymentService.GetStackStatus(c, uuid.MustParse(id))
+	if err != nil {
+		logger.ErrorContext(c, "failed to get stack status", zap.Error(err), zap.String("id", id))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Stack History
+// @Description  Get the status transitions of the stack and of its deployments and integrations, oldest first
+// @Tags         Thanos Stack
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Success      200      {object}  entities.Response
+// @Router       /stacks/thanos/{id}/history [get]
+func (h *ThanosDeploymentHandler) GetStackHistory(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	response, err := h.ThanosDeploymentService.GetStackHistory(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to get stack status", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to get stack history", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -239,6 +618,7 @@ func (h *ThanosDeploymentHandler) GetStackStatus(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/deployments [get]
@@ -252,9 +632,13 @@ func (h *ThanosDeploymentHandler) GetDeployments(c *gin.Context) {
 		})
 		return
 	}
-	response, err := h.ThanosDeploymentService.GetDeployments(uuid.MustParse(id))
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	response, err := h.ThanosDeploymentService.GetDeployments(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to get deployments", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to get deployments", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -264,6 +648,7 @@ func (h *ThanosDeploymentHandler) GetDeployments(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/integrations [get]
@@ -277,9 +662,13 @@ func (h *ThanosDeploymentHandler) GetIntegrations(c *gin.Context) {
 		})
 		return
 	}
-	response, err := h.ThanosDeploymentService.GetIntegrations(uuid.MustParse(id))
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	response, err := h.ThanosDeploymentService.GetIntegrations(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to get integrations", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to get integrations", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -289,6 +678,7 @@ func (h *ThanosDeploymentHandler) GetIntegrations(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        integrationId   path      string  true  "Integration ID"
 // @Success      200      {object}  entities.Response
@@ -303,21 +693,26 @@ func (h *ThanosDeploymentHandler) GetIntegrationById(c *gin.Context) {
 		})
 		return
 	}
-	integrationId := c.Param("integrationId")
-	if integrationId == "" {
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	integrationId, err := uuid.Parse(c.Param("integrationId"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
 			Status:  http.StatusBadRequest,
-			Message: "integrationId is required",
+			Message: "invalid integrationId",
 			Data:    nil,
 		})
 		return
 	}
 	response, err := h.ThanosDeploymentService.GetIntegration(
+		c,
 		uuid.MustParse(id),
-		uuid.MustParse(integrationId),
+		integrationId,
 	)
 	if err != nil {
-		logger.Error("failed to get integration", zap.Error(err), zap.String("id", id), zap.String("integrationId", integrationId))
+		logger.ErrorContext(c, "failed to get integration", zap.Error(err), zap.String("id", id), zap.String("integrationId", integrationId.String()))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -327,6 +722,7 @@ func (h *ThanosDeploymentHandler) GetIntegrationById(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        deploymentId   path      string  true  "Deployment ID"
 // @Success      200      {object}  entities.Response
@@ -341,21 +737,26 @@ func (h *ThanosDeploymentHandler) GetStackDeployment(c *gin.Context) {
 		})
 		return
 	}
-	deploymentId := c.Param("deploymentId")
-	if deploymentId == "" {
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	deploymentId, err := uuid.Parse(c.Param("deploymentId"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
 			Status:  http.StatusBadRequest,
-			Message: "deploymentId is required",
+			Message: "invalid deploymentId",
 			Data:    nil,
 		})
 		return
 	}
 	response, err := h.ThanosDeploymentService.GetStackDeployment(
+		c,
 		uuid.MustParse(id),
-		uuid.MustParse(deploymentId),
+		deploymentId,
 	)
 	if err != nil {
-		logger.Error("failed to get stack deployment", zap.Error(err), zap.String("id", id), zap.String("deploymentId", deploymentId))
+		logger.ErrorContext(c, "failed to get stack deployment", zap.Error(err), zap.String("id", id), zap.String("deploymentId", deploymentId.String()))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -365,6 +766,7 @@ func (h *ThanosDeploymentHandler) GetStackDeployment(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        deploymentId   path      string  true  "Deployment ID"
 // @Success      200      {object}  entities.Response
@@ -379,27 +781,170 @@ func (h *ThanosDeploymentHandler) GetStackDeploymentStatus(c *gin.Context) {
 		})
 		return
 	}
-	deploymentId := c.Param("deploymentId")
-	if deploymentId == "" {
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	deploymentId, err := uuid.Parse(c.Param("deploymentId"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
 			Status:  http.StatusBadRequest,
-			Message: "deploymentId is required",
+			Message: "invalid deploymentId",
 			Data:    nil,
 		})
 		return
 	}
-	response, err := h.ThanosDeploymentService.GetStackDeploymentStatus(uuid.MustParse(deploymentId))
+	response, err := h.ThanosDeploymentService.GetStackDeploymentStatus(c, uuid.MustParse(id), deploymentId)
 	if err != nil {
-		logger.Error("failed to get stack deployment status", zap.Error(err), zap.String("id", id), zap.String("deploymentId", deploymentId))
+		logger.ErrorContext(c, "failed to get stack deployment status", zap.Error(err), zap.String("id", id), zap.String("deploymentId", deploymentId.String()))
 	}
 	c.JSON(int(response.Status), response)
 }
 
+// @Summary      Get Stack Deployment Logs
+// @Description  Stream the log of a deployment as plain text. With follow, the response stays open for new lines until the deployment ends.
+// @Tags         Thanos Stack
+// @Produce      plain
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        deploymentId   path      string  true  "Deployment ID"
+// @Param        follow  query  bool  false  "Follow the log while the deployment runs"
+// @Success      200      {string}  string
+// @Router       /stacks/thanos/{id}/deployments/{deploymentId}/logs [get]
+func (h *ThanosDeploymentHandler) GetStackDeploymentLogs(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	deploymentId, err := uuid.Parse(c.Param("deploymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid deploymentId",
+			Data:    nil,
+		})
+		return
+	}
+
+	follow, ok := parseFollow(c)
+	if !ok {
+		return
+	}
+	streamLog(c, "failed to stream deployment logs", func(send func(line string) error) (*entities.Response, error) {
+		return h.ThanosDeploymentService.StreamDeploymentLogs(c, uuid.MustParse(id), deploymentId, follow, send)
+	}, zap.String("id", id), zap.String("deploymentId", deploymentId.String()))
+}
+
+// @Summary      Get Stack Integration Logs
+// @Description  Stream the log of an integration as plain text. With follow, the response stays open for new lines until the integration is installed or uninstalled.
+// @Tags         Thanos Stack
+// @Produce      plain
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Thanos Stack ID"
+// @Param        integrationId   path      string  true  "Integration ID"
+// @Param        follow  query  bool  false  "Follow the log while the integration is installed or uninstalled"
+// @Success      200      {string}  string
+// @Router       /stacks/thanos/{id}/integrations/{integrationId}/logs [get]
+func (h *ThanosDeploymentHandler) GetStackIntegrationLogs(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "id is required",
+			Data:    nil,
+		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	integrationId, err := uuid.Parse(c.Param("integrationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid integrationId",
+			Data:    nil,
+		})
+		return
+	}
+
+	follow, ok := parseFollow(c)
+	if !ok {
+		return
+	}
+	streamLog(c, "failed to stream integration logs", func(send func(line string) error) (*entities.Response, error) {
+		return h.ThanosDeploymentService.StreamIntegrationLogs(c, uuid.MustParse(id), integrationId, follow, send)
+	}, zap.String("id", id), zap.String("integrationId", integrationId.String()))
+}
+
+// parseFollow parses the follow query parameter, it responds with a bad request when invalid
+func parseFollow(c *gin.Context) (bool, bool) {
+	value := c.Query("follow")
+	if value == "" {
+		return false, true
+	}
+	follow, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid follow",
+			Data:    nil,
+		})
+		return false, false
+	}
+	return follow, true
+}
+
+// streamLog writes the lines sent by stream as plain text, or the response of stream when the log cannot be streamed
+func streamLog(
+	c *gin.Context,
+	failure string,
+	stream func(send func(line string) error) (*entities.Response, error),
+	fields ...zap.Field,
+) {
+	// The status is only known once the log is opened, the headers are written with the first line
+	started := false
+	response, err := stream(func(line string) error {
+		if !started {
+			c.Header("Content-Type", "text/plain; charset=utf-8")
+			c.Status(http.StatusOK)
+			started = true
+		}
+		if _, err := io.WriteString(c.Writer, line+"\n"); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		logger.ErrorContext(c, failure, append(fields, zap.Error(err))...)
+	}
+	if response != nil && !started {
+		c.JSON(int(response.Status), response)
+		return
+	}
+	if !started {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+	}
+}
+
 // @Summary      Get Stack By ID
 // @Description  Get Stack By ID
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id} [get]
@@ -413,9 +958,13 @@ func (h *ThanosDeploymentHandler) GetStackByID(c *gin.Context) {
 		})
 		return
 	}
-	response, err := h.ThanosDeploymentService.GetStackByID(uuid.MustParse(id))
+
+	if !h.authorizeStack(c, id, services.StackActionView) {
+		return
+	}
+	response, err := h.ThanosDeploymentService.GetStackByID(c, uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to get stack by id", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to get stack by id", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -425,6 +974,7 @@ func (h *ThanosDeploymentHandler) GetStackByID(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/integrations/bridge [post]
@@ -439,9 +989,13 @@ func (h *ThanosDeploymentHandler) InstallBridge(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	response, err := h.ThanosDeploymentService.InstallBridge(c, id)
 	if err != nil {
-		logger.Error("failed to install bridge", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to install bridge", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -451,6 +1005,7 @@ func (h *ThanosDeploymentHandler) InstallBridge(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/integrations/bridge [delete]
@@ -465,9 +1020,13 @@ func (h *ThanosDeploymentHandler) UninstallBridge(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	response, err := h.ThanosDeploymentService.UninstallBridge(c, id)
 	if err != nil {
-		logger.Error("failed to uninstall bridge", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to uninstall bridge", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -477,6 +1036,7 @@ func (h *ThanosDeploymentHandler) UninstallBridge(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/register-candidates [post]
@@ -488,6 +1048,11 @@ func (h *ThanosDeploymentHandler) RegisterCandidates(c *gin.Context) {
 			Message: "id is required",
 			Data:    nil,
 		})
+		return
+	}
+
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
 	}
 
 	var request dtos.RegisterCandidateRequest
@@ -509,7 +1074,7 @@ func (h *ThanosDeploymentHandler) RegisterCandidates(c *gin.Context) {
 
 	response, err := h.ThanosDeploymentService.RegisterCandidate(c, uuid.MustParse(id), request)
 	if err != nil {
-		logger.Error("failed to register candidate", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to register candidate", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -519,6 +1084,7 @@ func (h *ThanosDeploymentHandler) RegisterCandidates(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/integrations/block-explorer [delete]
@@ -533,9 +1099,13 @@ func (h *ThanosDeploymentHandler) UninstallBlockExplorer(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	response, err := h.ThanosDeploymentService.UninstallBlockExplorer(c, id)
 	if err != nil {
-		logger.Error("failed to uninstall block explorer", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to uninstall block explorer", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -545,6 +1115,7 @@ func (h *ThanosDeploymentHandler) UninstallBlockExplorer(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        request  body      dtos.InstallBlockExplorerRequest  true  "Install Block Explorer Request"
 // @Success      200      {object}  entities.Response
@@ -560,6 +1131,10 @@ func (h *ThanosDeploymentHandler) InstallBlockExplorer(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	var request dtos.InstallBlockExplorerRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
@@ -572,7 +1147,7 @@ func (h *ThanosDeploymentHandler) InstallBlockExplorer(c *gin.Context) {
 
 	response, err := h.ThanosDeploymentService.InstallBlockExplorer(c, id, request)
 	if err != nil {
-		logger.Error("failed to install block explorer", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to install block explorer", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -582,6 +1157,7 @@ func (h *ThanosDeploymentHandler) InstallBlockExplorer(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Param        request  body      dtos.InstallMonitoringRequest  true  "Install Monitoring Request"
 // @Success      200      {object}  entities.Response
@@ -597,6 +1173,10 @@ func (h *ThanosDeploymentHandler) InstallMonitoring(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	var request dtos.InstallMonitoringRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, &entities.Response{
@@ -609,7 +1189,7 @@ func (h *ThanosDeploymentHandler) InstallMonitoring(c *gin.Context) {
 
 	response, err := h.ThanosDeploymentService.InstallMonitoring(c.Request.Context(), uuid.MustParse(id), request)
 	if err != nil {
-		logger.Error("failed to install monitoring", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to install monitoring", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
@@ -619,6 +1199,7 @@ func (h *ThanosDeploymentHandler) InstallMonitoring(c *gin.Context) {
 // @Tags         Thanos Stack
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Thanos Stack ID"
 // @Success      200      {object}  entities.Response
 // @Router       /stacks/thanos/{id}/integrations/monitoring [delete]
@@ -633,21 +1214,102 @@ func (h *ThanosDeploymentHandler) UninstallMonitoring(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeStack(c, id, services.StackActionOperate) {
+		return
+	}
+
 	response, err := h.ThanosDeploymentService.UninstallMonitoring(c.Request.Context(), uuid.MustParse(id))
 	if err != nil {
-		logger.Error("failed to uninstall monitoring", zap.Error(err), zap.String("id", id))
+		logger.ErrorContext(c, "failed to uninstall monitoring", zap.Error(err), zap.String("id", id))
 	}
 	c.JSON(int(response.Status), response)
 }
 
-func NewThanosHandler(server *servers.Server) *ThanosDeploymentHandler {
-	deploymentRepo := postgresRepositories.NewDeploymentRepository(server.PostgresDB)
-	stackRepo := postgresRepositories.NewStackRepository(server.PostgresDB)
-	integrationRepo := postgresRepositories.NewIntegrationRepository(server.PostgresDB)
+// authorizeStack writes an error response and returns false if the caller may not perform the action on the stack
+func (h *ThanosDeploymentHandler) authorizeStack(c *gin.Context, id string, action services.StackAction) bool {
+	stackId, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return false
+	}
+
+	response, err := h.AccessService.AuthorizeStack(c, middlewares.CurrentUser(c), stackId, action)
+	if err != nil {
+		logger.ErrorContext(c, "failed to authorize stack access", zap.Error(err), zap.String("id", id))
+	}
+	if response != nil {
+		c.JSON(int(response.Status), response)
+		return false
+	}
+	return true
+}
+
+// parseBoolQuery returns the boolean query parameter, false when absent. It writes an error response and returns
+// false as second value when the parameter is invalid.
+func parseBoolQuery(c *gin.Context, name string) (bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		writeBadRequest(c, "invalid "+name)
+		return false, false
+	}
+	return parsed, true
+}
+
+// authorizeProject writes an error response and returns false if the caller lacks the role in the project
+func (h *ThanosDeploymentHandler) authorizeProject(c *gin.Context, id string, role entities.ProjectRole) bool {
+	projectId, err := uuid.Parse(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid projectId",
+			Data:    nil,
+		})
+		return false
+	}
+
+	response, err := h.AccessService.AuthorizeProject(c, middlewares.CurrentUser(c), projectId, role)
+	if err != nil {
+		logger.ErrorContext(c, "failed to authorize project access", zap.Error(err), zap.String("projectId", id))
+	}
+	if response != nil {
+		c.JSON(int(response.Status), response)
+		return false
+	}
+	return true
+}
+
+func NewThanosHandler(server *servers.Server, accessService *services.AccessService) *ThanosDeploymentHandler {
+	deploymentRepo := postgresRepositories.NewDeploymentRepository(server.PostgresDB, server.Keyring)
+	stackRepo := postgresRepositories.NewStackRepository(server.PostgresDB, server.Keyring)
+	integrationRepo := postgresRepositories.NewIntegrationRepository(server.PostgresDB, server.Keyring)
+	credentialRepo := postgresRepositories.NewCredentialRepository(server.PostgresDB, server.Keyring)
+	operatorKeyRepo := postgresRepositories.NewOperatorKeyRepository(server.PostgresDB)
+	unitOfWork := postgresRepositories.NewUnitOfWork(server.PostgresDB, server.Keyring)
 
-	taskManager := taskmanager.NewTaskManager(5, 20)
+	taskManager := taskmanager.NewTaskManager(server.Config.TaskManager.Workers, server.Config.TaskManager.QueueSize)
 
 	return &ThanosDeploymentHandler{
-		ThanosDeploymentService: services.NewThanosService(deploymentRepo, stackRepo, integrationRepo, taskManager),
+		ThanosDeploymentService: services.NewThanosService(
+			deploymentRepo,
+			stackRepo,
+			integrationRepo,
+			credentialRepo,
+			operatorKeyRepo,
+			unitOfWork,
+			thanos.NewSDKDriver(server.LogSink),
+			server.Keystore,
+			taskManager,
+			server.LogSink,
+			[]byte(server.Config.Bundles.SigningKey),
+		),
+		AccessService: accessService,
 	}
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	postgresRepositories "github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWebhookDeliveriesLimit = 50
+	maxWebhookDeliveriesLimit     = 500
+)
+
+type WebhookHandler struct {
+	WebhookService *services.WebhookService
+}
+
+// @Summary      Create Webhook
+// @Description  Subscribe a URL to the lifecycle events of the project's stacks. The signing secret is only returned once.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Param        request  body      dtos.CreateWebhookRequest  true  "Create Webhook Request"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return
+	}
+
+	var request dtos.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.WebhookService.CreateSubscription(c, middlewares.CurrentUser(c), projectId, request)
+	if err != nil {
+		logger.ErrorContext(c, "failed to create webhook", zap.Error(err), zap.String("id", projectId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Webhooks
+// @Description  Get the webhook subscriptions of the project
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/webhooks [get]
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return
+	}
+
+	response, err := h.WebhookService.GetSubscriptions(c, middlewares.CurrentUser(c), projectId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get webhooks", zap.Error(err), zap.String("id", projectId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Delete Webhook
+// @Description  Delete a webhook subscription and its delivery log
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Param        webhookId   path      string  true  "Webhook ID"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/webhooks/{webhookId} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	projectId, webhookId, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.WebhookService.DeleteSubscription(c, middlewares.CurrentUser(c), projectId, webhookId)
+	if err != nil {
+		logger.ErrorContext(c, "failed to delete webhook", zap.Error(err), zap.String("webhookId", webhookId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+// @Summary      Get Webhook Deliveries
+// @Description  Get the most recent delivery attempts of a webhook subscription
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Project ID"
+// @Param        webhookId   path      string  true  "Webhook ID"
+// @Param        limit   query      int  false  "Maximum number of deliveries, defaults to 50"
+// @Success      200      {object}  entities.Response
+// @Router       /projects/{id}/webhooks/{webhookId}/deliveries [get]
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	projectId, webhookId, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultWebhookDeliveriesLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxWebhookDeliveriesLimit {
+			c.JSON(http.StatusBadRequest, &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: "invalid limit",
+				Data:    nil,
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	response, err := h.WebhookService.GetDeliveries(c, middlewares.CurrentUser(c), projectId, webhookId, limit)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get webhook deliveries", zap.Error(err), zap.String("webhookId", webhookId.String()))
+	}
+	c.JSON(int(response.Status), response)
+}
+
+func parseWebhookParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	projectId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid id",
+			Data:    nil,
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	webhookId, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "invalid webhookId",
+			Data:    nil,
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return projectId, webhookId, true
+}
+
+func NewWebhookHandler(server *servers.Server, accessService *services.AccessService) *WebhookHandler {
+	webhookRepo := postgresRepositories.NewWebhookRepository(server.PostgresDB, server.Keyring)
+
+	return &WebhookHandler{
+		WebhookService: services.NewWebhookService(webhookRepo, accessService),
+	}
+}
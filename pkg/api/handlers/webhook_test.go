@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/handlers"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/connection"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/migrations"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// apiKeys authenticates the callers by their API key
+type apiKeys map[string]*entities.UserEntity
+
+func (k apiKeys) Authenticate(apiKey string) (*entities.UserEntity, error) {
+	return k[apiKey], nil
+}
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := connection.Init(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, name string) *entities.UserEntity {
+	t.Helper()
+	users := repositories.NewUserRepository(db)
+	email := name + "@thanos.test"
+	if err := users.CreateUser(&entities.UserEntity{Name: name, Email: email}, "hash-"+name); err != nil {
+		t.Fatalf("failed to create the user: %v", err)
+	}
+	user, err := users.GetUserByEmail(email)
+	if err != nil || user == nil {
+		t.Fatalf("failed to get the user: %v", err)
+	}
+	return user
+}
+
+func createProject(t *testing.T, db *gorm.DB, owner *entities.UserEntity) uuid.UUID {
+	t.Helper()
+	projectID := uuid.New()
+	err := repositories.NewProjectRepository(db).CreateProject(
+		&entities.ProjectEntity{ID: projectID, Name: "thanos-" + projectID.String()[:8]},
+		&entities.ProjectMemberEntity{ProjectID: projectID, UserID: owner.ID, Role: entities.ProjectRoleAdmin},
+	)
+	if err != nil {
+		t.Fatalf("failed to create the project: %v", err)
+	}
+	return projectID
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	db := newSQLiteDB(t)
+	owner := createUser(t, db, "owner")
+	outsider := createUser(t, db, "outsider")
+	projectID := createProject(t, db, owner)
+	otherProjectID := createProject(t, db, owner)
+
+	access := services.NewAccessService(
+		repositories.NewUserRepository(db),
+		repositories.NewProjectRepository(db),
+		repositories.NewStackRepository(db, nil),
+	)
+	handler := handlers.NewWebhookHandler(&servers.Server{PostgresDB: db}, access)
+	router := gin.New()
+	router.Use(middlewares.Authenticate(apiKeys{"owner": owner, "outsider": outsider}))
+	router.GET("/projects/:id/webhooks/:webhookId/deliveries", handler.GetWebhookDeliveries)
+
+	response, err := handler.WebhookService.CreateSubscription(
+		context.Background(), owner, projectID, dtos.CreateWebhookRequest{URL: "https://hooks.thanos.test"},
+	)
+	if err != nil || response.Status != http.StatusOK {
+		t.Fatalf("failed to subscribe: %+v, %v", response, err)
+	}
+	webhookID := response.Data.(map[string]interface{})["webhook"].(*entities.WebhookSubscriptionEntity).ID
+
+	event := schemas.WebhookEvent{ID: uuid.New(), Type: entities.WebhookEventStackDeployed, ProjectID: &projectID, Data: []byte(`{}`)}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	older := time.Now().Add(-time.Hour)
+	deliveries := []schemas.WebhookDelivery{
+		{
+			ID: uuid.New(), SubscriptionID: webhookID, EventID: event.ID, EventType: event.Type,
+			Status: entities.WebhookDeliveryStatusFailed, Attempts: 8, ResponseStatus: http.StatusBadGateway,
+			Error: "unexpected status code 502", NextAttemptAt: older, CreatedAt: older,
+		},
+		{
+			ID: uuid.New(), SubscriptionID: webhookID, EventID: event.ID, EventType: event.Type,
+			Status: entities.WebhookDeliveryStatusSucceeded, Attempts: 1, ResponseStatus: http.StatusOK,
+			NextAttemptAt: time.Now(), CreatedAt: time.Now(),
+		},
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(apiKey string, path string) (int, []entities.WebhookDeliveryEntity) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		var body struct {
+			Data struct {
+				Deliveries []entities.WebhookDeliveryEntity `json:"deliveries"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response %s: %v", recorder.Body, err)
+		}
+		return recorder.Code, body.Data.Deliveries
+	}
+	path := "/projects/" + projectID.String() + "/webhooks/" + webhookID.String() + "/deliveries"
+
+	status, got := get("owner", path)
+	if status != http.StatusOK || len(got) != 2 || got[0].ID != deliveries[1].ID || got[1].ID != deliveries[0].ID {
+		t.Errorf("deliveries = %d %+v, want both, newest first", status, got)
+	}
+	if len(got) == 2 && (got[1].Status != entities.WebhookDeliveryStatusFailed || got[1].Attempts != 8 ||
+		got[1].ResponseStatus != http.StatusBadGateway || got[1].Error == "") {
+		t.Errorf("failed delivery = %+v, want its attempts and error", got[1])
+	}
+	if status, got := get("owner", path+"?limit=1"); status != http.StatusOK || len(got) != 1 || got[0].ID != deliveries[1].ID {
+		t.Errorf("limited deliveries = %d %+v, want the newest", status, got)
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		path   string
+		want   int
+	}{
+		{"invalid limit", "owner", path + "?limit=0", http.StatusBadRequest},
+		{"limit too large", "owner", path + "?limit=501", http.StatusBadRequest},
+		{"invalid webhook id", "owner", "/projects/" + projectID.String() + "/webhooks/abc/deliveries", http.StatusBadRequest},
+		{"webhook of another project", "owner", "/projects/" + otherProjectID.String() + "/webhooks/" + webhookID.String() + "/deliveries", http.StatusNotFound},
+		{"not a member", "outsider", path, http.StatusForbidden},
+		{"unauthenticated", "unknown", path, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := get(tt.apiKey, tt.path); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+const (
+	stackRoutePrefix   = "/api/v1/stacks/thanos/:id"
+	projectRoutePrefix = "/api/v1/projects/:id"
+)
+
+type AuditRecorder interface {
+	// GetStackStatus returns the status of the stack, or an empty status if it cannot be read
+	GetStackStatus(stackID string) string
+	Record(ctx context.Context, record *entities.AuditRecordEntity)
+}
+
+// Audit records the mutating requests of the authenticated caller once they are served, along with the status of the
+// stack they address before and after. It must be registered after Authenticate.
+func Audit(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsMutatingMethod(c.Request.Method) || c.FullPath() == "" {
+			c.Next()
+			return
+		}
+
+		var stackID, projectID *uuid.UUID
+		if id, err := uuid.Parse(c.Param("id")); err == nil {
+			switch {
+			case strings.HasPrefix(c.FullPath(), stackRoutePrefix):
+				stackID = &id
+			case strings.HasPrefix(c.FullPath(), projectRoutePrefix):
+				projectID = &id
+			}
+		}
+
+		var beforeStatus string
+		if stackID != nil {
+			beforeStatus = recorder.GetStackStatus(stackID.String())
+		}
+
+		c.Next()
+
+		record := &entities.AuditRecordEntity{
+			Source:       entities.AuditSourceAPI,
+			SourceIP:     c.ClientIP(),
+			ProjectID:    projectID,
+			StackID:      stackID,
+			Action:       c.Request.Method + " " + c.FullPath(),
+			BeforeStatus: beforeStatus,
+			Result:       strconv.Itoa(c.Writer.Status()),
+			RequestID:    logger.RequestIDFromContext(c),
+		}
+		if user := CurrentUser(c); user != nil {
+			record.ActorID = &user.ID
+			record.Actor = user.Email
+		}
+		if stackID != nil {
+			record.AfterStatus = recorder.GetStackStatus(stackID.String())
+		}
+		recorder.Record(c, record)
+	}
+}
+
+// IsMutatingMethod reports whether requests with the method change the state of the backend
+func IsMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+const (
+	currentUserKey = "currentUser"
+	apiKeyHeader   = "X-API-Key"
+)
+
+type Authenticator interface {
+	Authenticate(apiKey string) (*entities.UserEntity, error)
+}
+
+// Authenticate resolves the caller from the `Authorization: Bearer <key>` or `X-API-Key` header
+// and rejects the request if the key doesn't belong to any user
+func Authenticate(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := extractAPIKey(c.Request)
+		if apiKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, &entities.Response{
+				Status:  http.StatusUnauthorized,
+				Message: "API key is required",
+				Data:    nil,
+			})
+			return
+		}
+
+		user, err := authenticator.Authenticate(apiKey)
+		if err != nil {
+			logger.ErrorContext(c, "failed to authenticate user", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			})
+			return
+		}
+
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, &entities.Response{
+				Status:  http.StatusUnauthorized,
+				Message: "Invalid API key",
+				Data:    nil,
+			})
+			return
+		}
+
+		c.Set(currentUserKey, user)
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user authenticated by the Authenticate middleware
+func CurrentUser(c *gin.Context) *entities.UserEntity {
+	value, exists := c.Get(currentUserKey)
+	if !exists {
+		return nil
+	}
+	user, _ := value.(*entities.UserEntity)
+	return user
+}
+
+func extractAPIKey(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		if token, found := strings.CutPrefix(authorization, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(apiKeyHeader))
+}
@@ -4,25 +4,55 @@ import (
 	"github.com/gin-gonic/gin"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/tokamak-network/trh-backend/pkg/api/handlers"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
 	"github.com/tokamak-network/trh-backend/pkg/api/servers"
+	"github.com/tokamak-network/trh-backend/pkg/services"
 
 	swaggerFiles "github.com/swaggo/files"
 )
 
-func SetupRoutes(server *servers.Server) {
+// SetupRoutes registers the API routes. The handlers are built by the caller so that their services can be shared
+// with the gRPC server.
+func SetupRoutes(
+	server *servers.Server,
+	accessHandler *handlers.AccessHandler,
+	thanosHandler *handlers.ThanosDeploymentHandler,
+	auditHandler *handlers.AuditHandler,
+) {
 	apiV1 := server.Router.Group("/api/v1")
-	setupV1Routes(apiV1, server)
+	setupV1Routes(apiV1, server, accessHandler, thanosHandler, auditHandler)
 
 	server.Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
 
-func setupV1Routes(router *gin.RouterGroup, server *servers.Server) {
+func setupV1Routes(
+	router *gin.RouterGroup,
+	server *servers.Server,
+	accessHandler *handlers.AccessHandler,
+	thanosHandler *handlers.ThanosDeploymentHandler,
+	auditHandler *handlers.AuditHandler,
+) {
 	// Health routes
 	setupHealthRoutes(router.Group("/health"))
 
+	// Every other route requires an API key, and their mutating requests are audited
+	authenticated := router.Group("",
+		middlewares.Authenticate(accessHandler.AccessService),
+		middlewares.Audit(auditHandler.AuditService),
+	)
+
+	// User and project routes
+	setupUserRoutes(authenticated.Group("/users"), accessHandler)
+	setupProjectRoutes(authenticated.Group("/projects"), accessHandler)
+	setupWebhookRoutes(authenticated.Group("/projects/:id/webhooks"), server, accessHandler.AccessService)
+	setupCredentialRoutes(authenticated.Group("/credentials"), server, accessHandler.AccessService)
+	setupOperatorKeyRoutes(authenticated.Group("/keys"), server, accessHandler.AccessService)
+	setupAuditRoutes(authenticated.Group("/audit"), auditHandler)
+
 	// Stack routes
-	stacks := router.Group("/stacks")
-	setupThanosRoutes(stacks.Group("/thanos"), server)
+	stacks := authenticated.Group("/stacks")
+	stacks.POST("/import", thanosHandler.ImportStack)
+	setupThanosRoutes(stacks.Group("/thanos"), thanosHandler)
 }
 
 func setupHealthRoutes(router *gin.RouterGroup) {
@@ -30,15 +60,63 @@ func setupHealthRoutes(router *gin.RouterGroup) {
 	router.GET("", handler.GetHealth)
 }
 
-func setupThanosRoutes(router *gin.RouterGroup, server *servers.Server) {
-	handler := handlers.NewThanosHandler(server)
+func setupUserRoutes(router *gin.RouterGroup, handler *handlers.AccessHandler) {
+	router.GET("/me", handler.GetCurrentUser)
+	router.GET("", handler.GetUsers)
+	router.POST("", handler.CreateUser)
+}
+
+func setupProjectRoutes(router *gin.RouterGroup, handler *handlers.AccessHandler) {
+	router.POST("", handler.CreateProject)
+	router.GET("", handler.GetProjects)
+	router.GET("/:id", handler.GetProject)
+	router.PUT("/:id/members", handler.SetProjectMember)
+	router.DELETE("/:id/members/:userId", handler.RemoveProjectMember)
+}
+
+func setupWebhookRoutes(router *gin.RouterGroup, server *servers.Server, accessService *services.AccessService) {
+	handler := handlers.NewWebhookHandler(server, accessService)
+	router.POST("", handler.CreateWebhook)
+	router.GET("", handler.GetWebhooks)
+	router.DELETE("/:webhookId", handler.DeleteWebhook)
+	router.GET("/:webhookId/deliveries", handler.GetWebhookDeliveries)
+}
+
+func setupCredentialRoutes(router *gin.RouterGroup, server *servers.Server, accessService *services.AccessService) {
+	handler := handlers.NewCredentialHandler(server, accessService)
+	router.POST("", handler.CreateCredential)
+	router.GET("", handler.GetCredentials)
+	router.GET("/:id", handler.GetCredential)
+	router.PUT("/:id", handler.UpdateCredential)
+	router.DELETE("/:id", handler.DeleteCredential)
+}
+
+func setupOperatorKeyRoutes(router *gin.RouterGroup, server *servers.Server, accessService *services.AccessService) {
+	handler := handlers.NewOperatorKeyHandler(server, accessService)
+	router.POST("", handler.CreateKey)
+	router.GET("", handler.GetKeys)
+	router.GET("/:id", handler.GetKey)
+	router.DELETE("/:id", handler.DeleteKey)
+}
+
+func setupAuditRoutes(router *gin.RouterGroup, handler *handlers.AuditHandler) {
+	router.GET("", handler.GetAuditRecords)
+	router.GET("/export", handler.ExportAuditRecords)
+}
+
+func setupThanosRoutes(router *gin.RouterGroup, handler *handlers.ThanosDeploymentHandler) {
 	router.POST("", handler.Deploy)
+	router.POST("/adopt", handler.Adopt)
 	router.POST("/:id/resume", handler.Resume)
 	router.POST("/:id/stop", handler.Stop)
+	router.POST("/:id/clone", handler.Clone)
 	router.PUT("/:id", handler.UpdateNetwork)
+	router.PATCH("/:id", handler.UpdateStack)
+	router.PUT("/:id/credentials", handler.UpdateStackCredentials)
 	router.DELETE("/:id", handler.Terminate)
 	router.GET("", handler.GetAllStacks)
 	router.GET("/:id", handler.GetStackByID)
+	router.GET("/:id/export", handler.ExportStack)
 	router.POST("/:id/integrations/bridge", handler.InstallBridge)
 	router.POST("/:id/integrations/block-explorer", handler.InstallBlockExplorer)
 	router.POST("/:id/integrations/monitoring", handler.InstallMonitoring)
@@ -47,9 +125,12 @@ func setupThanosRoutes(router *gin.RouterGroup, server *servers.Server) {
 	router.DELETE("/:id/integrations/block-explorer", handler.UninstallBlockExplorer)
 	router.DELETE("/:id/integrations/monitoring", handler.UninstallMonitoring)
 	router.GET("/:id/status", handler.GetStackStatus)
+	router.GET("/:id/history", handler.GetStackHistory)
 	router.GET("/:id/deployments", handler.GetDeployments)
 	router.GET("/:id/integrations", handler.GetIntegrations)
 	router.GET("/:id/integrations/:integrationId", handler.GetIntegrationById)
+	router.GET("/:id/integrations/:integrationId/logs", handler.GetStackIntegrationLogs)
 	router.GET("/:id/deployments/:deploymentId", handler.GetStackDeployment)
 	router.GET("/:id/deployments/:deploymentId/status", handler.GetStackDeploymentStatus)
+	router.GET("/:id/deployments/:deploymentId/logs", handler.GetStackDeploymentLogs)
 }
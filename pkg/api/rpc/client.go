@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ThanosClient is a typed client of the Thanos stack gRPC service
+type ThanosClient struct {
+	conn grpc.ClientConnInterface
+}
+
+func NewThanosClient(conn grpc.ClientConnInterface) *ThanosClient {
+	return &ThanosClient{conn: conn}
+}
+
+// APIKeyCredentials sends the API key of the caller with every call, use it with grpc.WithPerRPCCredentials
+type APIKeyCredentials string
+
+var _ credentials.PerRPCCredentials = APIKeyCredentials("")
+
+func (c APIKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationMetadata: "Bearer " + string(c)}, nil
+}
+
+// RequireTransportSecurity is false as the server is usually reached on a private network without TLS
+func (c APIKeyCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (c *ThanosClient) invoke(ctx context.Context, method string, request interface{}, opts []grpc.CallOption) (*entities.Response, error) {
+	response := new(entities.Response)
+	opts = append([]grpc.CallOption{grpc.ForceCodec(Codec{})}, opts...)
+	if err := c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, request, response, opts...); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *ThanosClient) CreateStack(ctx context.Context, request *dtos.DeployThanosRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "CreateStack", request, opts)
+}
+
+func (c *ThanosClient) CloneStack(ctx context.Context, request *CloneStackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "CloneStack", request, opts)
+}
+
+func (c *ThanosClient) StopStack(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "StopStack", request, opts)
+}
+
+func (c *ThanosClient) ResumeStack(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "ResumeStack", request, opts)
+}
+
+func (c *ThanosClient) TerminateStack(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "TerminateStack", request, opts)
+}
+
+func (c *ThanosClient) PurgeStack(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "PurgeStack", request, opts)
+}
+
+func (c *ThanosClient) UpdateNetwork(ctx context.Context, request *UpdateNetworkRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "UpdateNetwork", request, opts)
+}
+
+func (c *ThanosClient) UpdateStack(ctx context.Context, request *UpdateStackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "UpdateStack", request, opts)
+}
+
+func (c *ThanosClient) GetStacks(ctx context.Context, request *GetStacksRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetStacks", request, opts)
+}
+
+func (c *ThanosClient) GetStack(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetStack", request, opts)
+}
+
+func (c *ThanosClient) GetStackStatus(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetStackStatus", request, opts)
+}
+
+func (c *ThanosClient) GetStackHistory(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetStackHistory", request, opts)
+}
+
+func (c *ThanosClient) GetDeployments(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetDeployments", request, opts)
+}
+
+func (c *ThanosClient) GetDeployment(ctx context.Context, request *DeploymentRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetDeployment", request, opts)
+}
+
+func (c *ThanosClient) GetIntegrations(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetIntegrations", request, opts)
+}
+
+func (c *ThanosClient) GetIntegration(ctx context.Context, request *IntegrationRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "GetIntegration", request, opts)
+}
+
+func (c *ThanosClient) InstallBridge(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "InstallBridge", request, opts)
+}
+
+func (c *ThanosClient) UninstallBridge(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "UninstallBridge", request, opts)
+}
+
+func (c *ThanosClient) InstallBlockExplorer(ctx context.Context, request *InstallBlockExplorerRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "InstallBlockExplorer", request, opts)
+}
+
+func (c *ThanosClient) UninstallBlockExplorer(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "UninstallBlockExplorer", request, opts)
+}
+
+func (c *ThanosClient) InstallMonitoring(ctx context.Context, request *InstallMonitoringRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "InstallMonitoring", request, opts)
+}
+
+func (c *ThanosClient) UninstallMonitoring(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "UninstallMonitoring", request, opts)
+}
+
+func (c *ThanosClient) RegisterCandidate(ctx context.Context, request *RegisterCandidateRequest, opts ...grpc.CallOption) (*entities.Response, error) {
+	return c.invoke(ctx, "RegisterCandidate", request, opts)
+}
+
+// StreamReceiver receives the messages of a server stream until io.EOF
+type StreamReceiver[Message any] struct {
+	stream grpc.ClientStream
+}
+
+func (r *StreamReceiver[Message]) Recv() (*Message, error) {
+	message := new(Message)
+	if err := r.stream.RecvMsg(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func newStream[Message any](
+	ctx context.Context,
+	conn grpc.ClientConnInterface,
+	index int,
+	request interface{},
+	opts []grpc.CallOption,
+) (*StreamReceiver[Message], error) {
+	desc := &ThanosServiceDesc.Streams[index]
+	opts = append([]grpc.CallOption{grpc.ForceCodec(Codec{})}, opts...)
+	stream, err := conn.NewStream(ctx, desc, "/"+ServiceName+"/"+desc.StreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(request); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &StreamReceiver[Message]{stream: stream}, nil
+}
+
+func (c *ThanosClient) WatchStackStatus(ctx context.Context, request *StackRequest, opts ...grpc.CallOption) (*StreamReceiver[StackStatusEvent], error) {
+	return newStream[StackStatusEvent](ctx, c.conn, 0, request, opts)
+}
+
+func (c *ThanosClient) StreamDeploymentLogs(ctx context.Context, request *DeploymentLogsRequest, opts ...grpc.CallOption) (*StreamReceiver[LogLine], error) {
+	return newStream[LogLine](ctx, c.conn, 1, request, opts)
+}
+
+func (c *ThanosClient) StreamIntegrationLogs(ctx context.Context, request *IntegrationLogsRequest, opts ...grpc.CallOption) (*StreamReceiver[LogLine], error) {
+	return newStream[LogLine](ctx, c.conn, 2, request, opts)
+}
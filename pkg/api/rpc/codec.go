@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"encoding/json"
+)
+
+// Codec marshals the messages as JSON, they are the DTOs and entities of the REST API so no protobuf schema
+// has to be kept in sync with them. Clients select it with the "json" content subtype, the server decodes every
+// call with it whatever the subtype, so a client in another language only has to plug in a JSON serializer.
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (Codec) Name() string {
+	return "json"
+}
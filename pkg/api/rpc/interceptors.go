@@ -0,0 +1,188 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	requestIDMetadata     = "x-request-id"
+	authorizationMetadata = "authorization"
+	apiKeyMetadata        = "x-api-key"
+)
+
+type currentUserKey struct{}
+
+// currentUser returns the user authenticated by the interceptors
+func currentUser(ctx context.Context) *entities.UserEntity {
+	user, _ := ctx.Value(currentUserKey{}).(*entities.UserEntity)
+	return user
+}
+
+// prepareContext attaches the request id and the authenticated caller to the context of a call,
+// the same way the REST middlewares do for a request
+func prepareContext(ctx context.Context, authenticator middlewares.Authenticator) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := firstMetadata(md, requestIDMetadata)
+	if !middlewares.IsValidRequestID(requestID) {
+		requestID = uuid.New().String()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+
+	apiKey := strings.TrimSpace(firstMetadata(md, apiKeyMetadata))
+	if token, found := strings.CutPrefix(firstMetadata(md, authorizationMetadata), "Bearer "); found {
+		apiKey = strings.TrimSpace(token)
+	}
+	if apiKey == "" {
+		return nil, status.Error(codes.Unauthenticated, "API key is required")
+	}
+
+	user, err := authenticator.Authenticate(apiKey)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to authenticate user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid API key")
+	}
+
+	return context.WithValue(ctx, currentUserKey{}, user), nil
+}
+
+func unaryInterceptor(authenticator middlewares.Authenticator, recorder middlewares.AuditRecorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := prepareContext(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		// The calls are audited like the mutating REST requests
+		stackID, audited := auditedStackID(info.FullMethod, req)
+		var beforeStatus string
+		if audited && stackID != nil {
+			beforeStatus = recorder.GetStackStatus(stackID.String())
+		}
+
+		response, err := handler(ctx, req)
+		logger.InfoContext(ctx, "[gRPC]", zap.String("method", info.FullMethod), zap.String("code", status.Code(err).String()))
+
+		if audited {
+			record := &entities.AuditRecordEntity{
+				Source:       entities.AuditSourceGRPC,
+				StackID:      stackID,
+				Action:       info.FullMethod,
+				BeforeStatus: beforeStatus,
+				Result:       status.Code(err).String(),
+				RequestID:    logger.RequestIDFromContext(ctx),
+			}
+			if user := currentUser(ctx); user != nil {
+				record.ActorID = &user.ID
+				record.Actor = user.Email
+			}
+			if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+				record.SourceIP = p.Addr.String()
+				if host, _, splitErr := net.SplitHostPort(record.SourceIP); splitErr == nil {
+					record.SourceIP = host
+				}
+			}
+			if stackID != nil {
+				record.AfterStatus = recorder.GetStackStatus(stackID.String())
+			}
+			recorder.Record(ctx, record)
+		}
+		return response, err
+	}
+}
+
+// auditedStackID reports whether the method changes the state of the backend and returns the stack it addresses,
+// nil for the calls creating stacks
+func auditedStackID(fullMethod string, req interface{}) (*uuid.UUID, bool) {
+	if !mutatingMethods[strings.TrimPrefix(fullMethod, "/"+ServiceName+"/")] {
+		return nil, false
+	}
+
+	var id string
+	switch request := req.(type) {
+	case *StackRequest:
+		id = request.StackID
+	case *CloneStackRequest:
+		id = request.StackID
+	case *UpdateNetworkRequest:
+		id = request.StackID
+	case *UpdateStackRequest:
+		id = request.StackID
+	case *InstallBlockExplorerRequest:
+		id = request.StackID
+	case *InstallMonitoringRequest:
+		id = request.StackID
+	case *RegisterCandidateRequest:
+		id = request.StackID
+	}
+	stackID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, true
+	}
+	return &stackID, true
+}
+
+// mutatingMethods are the methods of the service which change the state of the backend, the others only read it
+var mutatingMethods = map[string]bool{
+	"CreateStack":            true,
+	"CloneStack":             true,
+	"StopStack":              true,
+	"ResumeStack":            true,
+	"TerminateStack":         true,
+	"PurgeStack":             true,
+	"UpdateNetwork":          true,
+	"UpdateStack":            true,
+	"InstallBridge":          true,
+	"UninstallBridge":        true,
+	"InstallBlockExplorer":   true,
+	"UninstallBlockExplorer": true,
+	"InstallMonitoring":      true,
+	"UninstallMonitoring":    true,
+	"RegisterCandidate":      true,
+}
+
+func streamInterceptor(authenticator middlewares.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := prepareContext(stream.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+		err = handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+		logger.InfoContext(ctx, "[gRPC]", zap.String("method", info.FullMethod), zap.String("code", status.Code(err).String()))
+		return err
+	}
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
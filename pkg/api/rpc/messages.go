@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type StackRequest struct {
+	StackID string `json:"stackId"`
+}
+
+type GetStacksRequest struct {
+	LabelSelector   string `json:"labelSelector"`
+	IncludeArchived bool   `json:"includeArchived"`
+}
+
+type CloneStackRequest struct {
+	StackID string                       `json:"stackId"`
+	Request dtos.CloneThanosStackRequest `json:"request"`
+}
+
+type UpdateNetworkRequest struct {
+	StackID string                    `json:"stackId"`
+	Request dtos.UpdateNetworkRequest `json:"request"`
+}
+
+type UpdateStackRequest struct {
+	StackID string                  `json:"stackId"`
+	Request dtos.UpdateStackRequest `json:"request"`
+}
+
+type DeploymentRequest struct {
+	StackID      string `json:"stackId"`
+	DeploymentID string `json:"deploymentId"`
+}
+
+type IntegrationRequest struct {
+	StackID       string `json:"stackId"`
+	IntegrationID string `json:"integrationId"`
+}
+
+type InstallBlockExplorerRequest struct {
+	StackID string                           `json:"stackId"`
+	Request dtos.InstallBlockExplorerRequest `json:"request"`
+}
+
+type InstallMonitoringRequest struct {
+	StackID string                        `json:"stackId"`
+	Request dtos.InstallMonitoringRequest `json:"request"`
+}
+
+type RegisterCandidateRequest struct {
+	StackID string                        `json:"stackId"`
+	Request dtos.RegisterCandidateRequest `json:"request"`
+}
+
+type DeploymentLogsRequest struct {
+	StackID      string `json:"stackId"`
+	DeploymentID string `json:"deploymentId"`
+	// Follow keeps the stream open for new lines until the deployment finishes
+	Follow bool `json:"follow"`
+}
+
+type IntegrationLogsRequest struct {
+	StackID       string `json:"stackId"`
+	IntegrationID string `json:"integrationId"`
+	// Follow keeps the stream open for new lines until the integration is installed or uninstalled
+	Follow bool `json:"follow"`
+}
+
+type StackStatusEvent struct {
+	StackID string               `json:"stackId"`
+	Status  entities.StackStatus `json:"status"`
+	Time    time.Time            `json:"time"`
+}
+
+type LogLine struct {
+	Line string `json:"line"`
+}
@@ -0,0 +1,480 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ThanosServer exposes the operations of the REST Thanos stack handlers over gRPC, on the same services
+type ThanosServer struct {
+	thanosService *services.ThanosStackDeploymentService
+	accessService *services.AccessService
+}
+
+func NewThanosServer(
+	thanosService *services.ThanosStackDeploymentService,
+	accessService *services.AccessService,
+) *ThanosServer {
+	return &ThanosServer{
+		thanosService: thanosService,
+		accessService: accessService,
+	}
+}
+
+// NewServer returns a gRPC server serving the Thanos stack service with the JSON codec, the options such as the
+// transport credentials are added to the server's
+func NewServer(
+	thanosService *services.ThanosStackDeploymentService,
+	accessService *services.AccessService,
+	auditService *services.AuditService,
+	opts ...grpc.ServerOption,
+) *grpc.Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.ForceServerCodec(Codec{}),
+		grpc.UnaryInterceptor(unaryInterceptor(accessService, auditService)),
+		grpc.StreamInterceptor(streamInterceptor(accessService)),
+	}, opts...)...)
+	server.RegisterService(&ThanosServiceDesc, NewThanosServer(thanosService, accessService))
+	return server
+}
+
+func (s *ThanosServer) CreateStack(ctx context.Context, request *dtos.DeployThanosRequest) (*entities.Response, error) {
+	if err := validate(request); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeProject(ctx, request.ProjectID, entities.ProjectRoleOperator); err != nil {
+		return nil, err
+	}
+	if err := request.Prepare(ctx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.thanosService.CreateThanosStack(ctx, *request)
+	return result(ctx, response, err, "failed to deploy thanos stack")
+}
+
+func (s *ThanosServer) CloneStack(ctx context.Context, request *CloneStackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if request.Request.ProjectID != nil {
+		if err := s.authorizeProject(ctx, *request.Request.ProjectID, entities.ProjectRoleOperator); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := s.thanosService.CloneThanosStack(ctx, stackId, request.Request)
+	return result(ctx, response, err, "failed to clone thanos stack")
+}
+
+func (s *ThanosServer) StopStack(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.StopDeployingThanosStack(ctx, stackId)
+	return result(ctx, response, err, "failed to stop thanos stack")
+}
+
+func (s *ThanosServer) ResumeStack(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.ResumeThanosStack(ctx, stackId)
+	return result(ctx, response, err, "failed to resume thanos stack")
+}
+
+func (s *ThanosServer) TerminateStack(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionTerminate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.TerminateThanosStack(ctx, stackId)
+	return result(ctx, response, err, "failed to terminate thanos stack")
+}
+
+func (s *ThanosServer) PurgeStack(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionTerminate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.PurgeStack(ctx, stackId)
+	return result(ctx, response, err, "failed to purge thanos stack")
+}
+
+func (s *ThanosServer) UpdateNetwork(ctx context.Context, request *UpdateNetworkRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(&request.Request); err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.UpdateNetwork(ctx, stackId, request.Request)
+	return result(ctx, response, err, "failed to update network")
+}
+
+func (s *ThanosServer) UpdateStack(ctx context.Context, request *UpdateStackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Request.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.thanosService.UpdateStack(ctx, stackId, request.Request)
+	return result(ctx, response, err, "failed to update stack")
+}
+
+func (s *ThanosServer) GetStacks(ctx context.Context, request *GetStacksRequest) (*entities.Response, error) {
+	labelSelector, err := dtos.ParseLabelSelector(request.LabelSelector)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	projectIds, all, err := s.accessService.GetAccessibleProjectIDs(currentUser(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get accessible projects", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	var response *entities.Response
+	if all {
+		response, err = s.thanosService.GetAllStacks(ctx, labelSelector, request.IncludeArchived)
+	} else {
+		response, err = s.thanosService.GetStacksByProjectIDs(ctx, projectIds, labelSelector, request.IncludeArchived)
+	}
+	return result(ctx, response, err, "failed to get all stacks")
+}
+
+func (s *ThanosServer) GetStack(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetStackByID(ctx, stackId)
+	return result(ctx, response, err, "failed to get stack")
+}
+
+func (s *ThanosServer) GetStackStatus(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetStackStatus(ctx, stackId)
+	return result(ctx, response, err, "failed to get stack status")
+}
+
+func (s *ThanosServer) GetStackHistory(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetStackHistory(ctx, stackId)
+	return result(ctx, response, err, "failed to get stack history")
+}
+
+func (s *ThanosServer) GetDeployments(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetDeployments(ctx, stackId)
+	return result(ctx, response, err, "failed to get deployments")
+}
+
+func (s *ThanosServer) GetDeployment(ctx context.Context, request *DeploymentRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+	deploymentId, err := parseID(request.DeploymentID, "deploymentId")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetStackDeployment(ctx, stackId, deploymentId)
+	return result(ctx, response, err, "failed to get deployment")
+}
+
+func (s *ThanosServer) GetIntegrations(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetIntegrations(ctx, stackId)
+	return result(ctx, response, err, "failed to get integrations")
+}
+
+func (s *ThanosServer) GetIntegration(ctx context.Context, request *IntegrationRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return nil, err
+	}
+	integrationId, err := parseID(request.IntegrationID, "integrationId")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.GetIntegration(ctx, stackId, integrationId)
+	return result(ctx, response, err, "failed to get integration")
+}
+
+func (s *ThanosServer) InstallBridge(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.InstallBridge(ctx, stackId.String())
+	return result(ctx, response, err, "failed to install bridge")
+}
+
+func (s *ThanosServer) UninstallBridge(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.UninstallBridge(ctx, stackId.String())
+	return result(ctx, response, err, "failed to uninstall bridge")
+}
+
+func (s *ThanosServer) InstallBlockExplorer(ctx context.Context, request *InstallBlockExplorerRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(&request.Request); err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.InstallBlockExplorer(ctx, stackId.String(), request.Request)
+	return result(ctx, response, err, "failed to install block explorer")
+}
+
+func (s *ThanosServer) UninstallBlockExplorer(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.UninstallBlockExplorer(ctx, stackId.String())
+	return result(ctx, response, err, "failed to uninstall block explorer")
+}
+
+func (s *ThanosServer) InstallMonitoring(ctx context.Context, request *InstallMonitoringRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(&request.Request); err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.InstallMonitoring(ctx, stackId, request.Request)
+	return result(ctx, response, err, "failed to install monitoring")
+}
+
+func (s *ThanosServer) UninstallMonitoring(ctx context.Context, request *StackRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.thanosService.UninstallMonitoring(ctx, stackId)
+	return result(ctx, response, err, "failed to uninstall monitoring")
+}
+
+func (s *ThanosServer) RegisterCandidate(ctx context.Context, request *RegisterCandidateRequest) (*entities.Response, error) {
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionOperate)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(&request.Request); err != nil {
+		return nil, err
+	}
+	if err := request.Request.Validate(ctx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.thanosService.RegisterCandidate(ctx, stackId, request.Request)
+	return result(ctx, response, err, "failed to register candidate")
+}
+
+// WatchStackStatus streams the status of the stack and its changes until the client cancels the call
+func (s *ThanosServer) WatchStackStatus(request *StackRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.thanosService.WatchStackStatus(ctx, stackId, func(stackStatus entities.StackStatus) error {
+		return stream.SendMsg(&StackStatusEvent{
+			StackID: stackId.String(),
+			Status:  stackStatus,
+			Time:    time.Now().UTC(),
+		})
+	})
+	return streamResult(ctx, response, err, "failed to watch stack status")
+}
+
+// StreamDeploymentLogs streams the log of a deployment, following it while the deployment runs if requested
+func (s *ThanosServer) StreamDeploymentLogs(request *DeploymentLogsRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return err
+	}
+	deploymentId, err := parseID(request.DeploymentID, "deploymentId")
+	if err != nil {
+		return err
+	}
+
+	response, err := s.thanosService.StreamDeploymentLogs(ctx, stackId, deploymentId, request.Follow, func(line string) error {
+		return stream.SendMsg(&LogLine{Line: line})
+	})
+	return streamResult(ctx, response, err, "failed to stream deployment logs")
+}
+
+// StreamIntegrationLogs streams the log of an integration, following it while the integration is installed or
+// uninstalled if requested
+func (s *ThanosServer) StreamIntegrationLogs(request *IntegrationLogsRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	stackId, err := s.authorizeStack(ctx, request.StackID, services.StackActionView)
+	if err != nil {
+		return err
+	}
+	integrationId, err := parseID(request.IntegrationID, "integrationId")
+	if err != nil {
+		return err
+	}
+
+	response, err := s.thanosService.StreamIntegrationLogs(ctx, stackId, integrationId, request.Follow, func(line string) error {
+		return stream.SendMsg(&LogLine{Line: line})
+	})
+	return streamResult(ctx, response, err, "failed to stream integration logs")
+}
+
+// authorizeStack returns the parsed stack id, or a gRPC error if the caller lacks the permission
+func (s *ThanosServer) authorizeStack(ctx context.Context, id string, action services.StackAction) (uuid.UUID, error) {
+	stackId, err := parseID(id, "stackId")
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	response, err := s.accessService.AuthorizeStack(ctx, currentUser(ctx), stackId, action)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to authorize stack access", zap.Error(err), zap.String("id", id))
+	}
+	if response != nil {
+		return uuid.Nil, responseError(response)
+	}
+	return stackId, nil
+}
+
+// authorizeProject returns a gRPC error if the caller lacks the role in the project
+func (s *ThanosServer) authorizeProject(ctx context.Context, id string, role entities.ProjectRole) error {
+	projectId, err := parseID(id, "projectId")
+	if err != nil {
+		return err
+	}
+
+	response, err := s.accessService.AuthorizeProject(ctx, currentUser(ctx), projectId, role)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to authorize project access", zap.Error(err), zap.String("projectId", id))
+	}
+	if response != nil {
+		return responseError(response)
+	}
+	return nil
+}
+
+func parseID(id string, name string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s", name)
+	}
+	return parsed, nil
+}
+
+// validate applies the binding rules of the DTOs, as gin does for the REST handlers
+func validate(request interface{}) error {
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// result returns the response of a successful call, other responses are converted to gRPC errors
+func result(ctx context.Context, response *entities.Response, err error, message string) (*entities.Response, error) {
+	if err != nil {
+		logger.ErrorContext(ctx, message, zap.Error(err))
+	}
+	if response.Status != http.StatusOK {
+		return nil, responseError(response)
+	}
+	return response, nil
+}
+
+func streamResult(ctx context.Context, response *entities.Response, err error, message string) error {
+	if response != nil {
+		if err != nil {
+			logger.ErrorContext(ctx, message, zap.Error(err))
+		}
+		return responseError(response)
+	}
+	if err != nil && status.Code(err) != codes.Canceled {
+		logger.WarnContext(ctx, message, zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// responseError maps the HTTP status of a service response to the matching gRPC code
+func responseError(response *entities.Response) error {
+	code := codes.Unknown
+	switch response.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	}
+	return status.Error(code, response.Message)
+}
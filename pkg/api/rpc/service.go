@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"google.golang.org/grpc"
+)
+
+// ServiceName is the full name of the Thanos stack gRPC service
+const ServiceName = "trh.v1.ThanosStackService"
+
+// ThanosServiceDesc describes the Thanos stack service. Messages are JSON encoded with the codec of this package,
+// the unary methods reply with the same response envelope as the REST API. There is no .proto: the requests are
+// the REST DTOs, documented by the swagger spec, and the messages of this package wrap them with the stack id.
+var ThanosServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("CreateStack", (*ThanosServer).CreateStack),
+		unary("CloneStack", (*ThanosServer).CloneStack),
+		unary("StopStack", (*ThanosServer).StopStack),
+		unary("ResumeStack", (*ThanosServer).ResumeStack),
+		unary("TerminateStack", (*ThanosServer).TerminateStack),
+		unary("PurgeStack", (*ThanosServer).PurgeStack),
+		unary("UpdateNetwork", (*ThanosServer).UpdateNetwork),
+		unary("UpdateStack", (*ThanosServer).UpdateStack),
+		unary("GetStacks", (*ThanosServer).GetStacks),
+		unary("GetStack", (*ThanosServer).GetStack),
+		unary("GetStackStatus", (*ThanosServer).GetStackStatus),
+		unary("GetStackHistory", (*ThanosServer).GetStackHistory),
+		unary("GetDeployments", (*ThanosServer).GetDeployments),
+		unary("GetDeployment", (*ThanosServer).GetDeployment),
+		unary("GetIntegrations", (*ThanosServer).GetIntegrations),
+		unary("GetIntegration", (*ThanosServer).GetIntegration),
+		unary("InstallBridge", (*ThanosServer).InstallBridge),
+		unary("UninstallBridge", (*ThanosServer).UninstallBridge),
+		unary("InstallBlockExplorer", (*ThanosServer).InstallBlockExplorer),
+		unary("UninstallBlockExplorer", (*ThanosServer).UninstallBlockExplorer),
+		unary("InstallMonitoring", (*ThanosServer).InstallMonitoring),
+		unary("UninstallMonitoring", (*ThanosServer).UninstallMonitoring),
+		unary("RegisterCandidate", (*ThanosServer).RegisterCandidate),
+	},
+	Streams: []grpc.StreamDesc{
+		serverStream("WatchStackStatus", (*ThanosServer).WatchStackStatus),
+		serverStream("StreamDeploymentLogs", (*ThanosServer).StreamDeploymentLogs),
+		serverStream("StreamIntegrationLogs", (*ThanosServer).StreamIntegrationLogs),
+	},
+}
+
+func unary[Request any](
+	name string,
+	call func(*ThanosServer, context.Context, *Request) (*entities.Response, error),
+) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			request := new(Request)
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+				return call(srv.(*ThanosServer), ctx, request.(*Request))
+			}
+			if interceptor == nil {
+				return handler(ctx, request)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + ServiceName + "/" + name,
+			}
+			return interceptor(ctx, request, info, handler)
+		},
+	}
+}
+
+func serverStream[Request any](
+	name string,
+	call func(*ThanosServer, *Request, grpc.ServerStream) error,
+) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			request := new(Request)
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			return call(srv.(*ThanosServer), request, stream)
+		},
+	}
+}
@@ -2,27 +2,55 @@ package servers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/internal/keystore"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/logsink"
 	"gorm.io/gorm"
 )
 
 type Server struct {
 	Router     *gin.Engine
+	Config     *config.Config
 	PostgresDB *gorm.DB
+	// Keyring encrypts the secrets stored in the database, nil when encryption is not configured
+	Keyring *secrets.Keyring
+	// Keystore encrypts the managed operator keys, nil when no keystore passphrase is configured
+	Keystore *keystore.Keystore
+	// LogSink stores the logs of the SDK commands
+	LogSink logsink.Sink
 }
 
-func (s *Server) Start(port string) error {
-	return s.Router.Run(":" + port)
+// Start serves the API on the configured port, over TLS when a certificate is configured
+func (s *Server) Start() error {
+	address := ":" + s.Config.Server.Port
+	if tls := s.Config.Server.TLS; tls.Enabled() {
+		return s.Router.RunTLS(address, tls.CertFile, tls.KeyFile)
+	}
+	return s.Router.Run(address)
 }
 
 func (s *Server) Use(middleware gin.HandlerFunc) {
 	s.Router.Use(middleware)
 }
 
-func NewServer(db *gorm.DB) *Server {
-	app := gin.Default()
+func NewServer(cfg *config.Config, db *gorm.DB, keyring *secrets.Keyring, logSink logsink.Sink) *Server {
+	app := gin.New()
+	// Let *gin.Context resolve values, such as the request id, from the request context
+	app.ContextWithFallback = true
+	app.Use(
+		middlewares.RequestID(),
+		gin.LoggerWithFormatter(middlewares.AccessLogFormatter),
+		gin.Recovery(),
+	)
 
 	return &Server{
 		Router:     app,
+		Config:     cfg,
 		PostgresDB: db,
+		Keyring:    keyring,
+		Keystore:   keystore.New(cfg.Keystore.Passphrase),
+		LogSink:    logSink,
 	}
 }
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+)
+
+// Client calls the TRH backend REST API with an API key
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client, e.g. to reach a test server
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New returns a client of the backend listening at baseURL, e.g. http://localhost:8000
+func New(baseURL string, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/") + "/api/v1",
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when the API replies with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s (status %d, request id %s)", e.Message, e.StatusCode, e.RequestID)
+	}
+	return fmt.Sprintf("%s (status %d)", e.Message, e.StatusCode)
+}
+
+// response is the envelope of every JSON response of the API
+type response struct {
+	Status  uint64          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// do sends the request with body encoded as JSON and decodes the data of the response into data when not nil
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, data interface{}) error {
+	resp, err := c.send(ctx, c.httpClient, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope response
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("invalid response: %s", err),
+			RequestID:  resp.Header.Get(middlewares.RequestIDHeader),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    envelope.Message,
+			RequestID:  resp.Header.Get(middlewares.RequestIDHeader),
+		}
+	}
+	if data == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+func (c *Client) send(
+	ctx context.Context,
+	httpClient *http.Client,
+	method string,
+	path string,
+	query url.Values,
+	body interface{},
+) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	return httpClient.Do(req)
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/api/middlewares"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+const thanosStacksPath = "/stacks/thanos"
+
+func stackPath(stackId string) string {
+	return thanosStacksPath + "/" + url.PathEscape(stackId)
+}
+
+// CreateThanosStack queues the deployment of a stack and returns its id
+func (c *Client) CreateThanosStack(ctx context.Context, request dtos.DeployThanosRequest) (string, error) {
+	var data struct {
+		StackID string `json:"stackId"`
+	}
+	if err := c.do(ctx, http.MethodPost, thanosStacksPath, nil, request, &data); err != nil {
+		return "", err
+	}
+	return data.StackID, nil
+}
+
+// GetThanosStacks returns the stacks the caller can access, filtered by a label selector such as "env=prod". Purged
+// stacks are only returned with includeArchived.
+func (c *Client) GetThanosStacks(ctx context.Context, labelSelector string, includeArchived bool) ([]*entities.StackEntity, error) {
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+	if includeArchived {
+		query.Set("includeArchived", "true")
+	}
+	var data struct {
+		Stacks []*entities.StackEntity `json:"stacks"`
+	}
+	if err := c.do(ctx, http.MethodGet, thanosStacksPath, query, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Stacks, nil
+}
+
+func (c *Client) GetThanosStack(ctx context.Context, stackId string) (*entities.StackEntity, error) {
+	var data struct {
+		Stack *entities.StackEntity `json:"stack"`
+	}
+	if err := c.do(ctx, http.MethodGet, stackPath(stackId), nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Stack, nil
+}
+
+func (c *Client) GetThanosStackStatus(ctx context.Context, stackId string) (entities.StackStatus, error) {
+	var data struct {
+		Status entities.StackStatus `json:"status"`
+	}
+	if err := c.do(ctx, http.MethodGet, stackPath(stackId)+"/status", nil, nil, &data); err != nil {
+		return "", err
+	}
+	return data.Status, nil
+}
+
+// GetThanosStackHistory returns the status transitions of the stack and of its deployments and integrations
+func (c *Client) GetThanosStackHistory(ctx context.Context, stackId string) ([]*entities.StatusTransitionEntity, error) {
+	var data struct {
+		Transitions []*entities.StatusTransitionEntity `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, stackPath(stackId)+"/history", nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Transitions, nil
+}
+
+func (c *Client) StopThanosStack(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodPost, stackPath(stackId)+"/stop", nil, nil, nil)
+}
+
+func (c *Client) ResumeThanosStack(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodPost, stackPath(stackId)+"/resume", nil, nil, nil)
+}
+
+func (c *Client) TerminateThanosStack(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodDelete, stackPath(stackId), nil, nil, nil)
+}
+
+// PurgeThanosStack archives a terminated stack and removes its deployment directory
+func (c *Client) PurgeThanosStack(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodDelete, stackPath(stackId), url.Values{"purge": {"true"}}, nil, nil)
+}
+
+func (c *Client) GetDeployments(ctx context.Context, stackId string) ([]*entities.DeploymentEntity, error) {
+	var data struct {
+		Deployments []*entities.DeploymentEntity `json:"deployments"`
+	}
+	if err := c.do(ctx, http.MethodGet, stackPath(stackId)+"/deployments", nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Deployments, nil
+}
+
+func (c *Client) GetIntegrations(ctx context.Context, stackId string) ([]*entities.IntegrationEntity, error) {
+	var data struct {
+		Integrations []*entities.IntegrationEntity `json:"integrations"`
+	}
+	if err := c.do(ctx, http.MethodGet, stackPath(stackId)+"/integrations", nil, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Integrations, nil
+}
+
+func (c *Client) InstallBridge(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodPost, stackPath(stackId)+"/integrations/bridge", nil, nil, nil)
+}
+
+func (c *Client) UninstallBridge(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodDelete, stackPath(stackId)+"/integrations/bridge", nil, nil, nil)
+}
+
+func (c *Client) InstallBlockExplorer(ctx context.Context, stackId string, request dtos.InstallBlockExplorerRequest) error {
+	return c.do(ctx, http.MethodPost, stackPath(stackId)+"/integrations/block-explorer", nil, request, nil)
+}
+
+func (c *Client) UninstallBlockExplorer(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodDelete, stackPath(stackId)+"/integrations/block-explorer", nil, nil, nil)
+}
+
+func (c *Client) InstallMonitoring(ctx context.Context, stackId string, request dtos.InstallMonitoringRequest) error {
+	return c.do(ctx, http.MethodPost, stackPath(stackId)+"/integrations/monitoring", nil, request, nil)
+}
+
+func (c *Client) UninstallMonitoring(ctx context.Context, stackId string) error {
+	return c.do(ctx, http.MethodDelete, stackPath(stackId)+"/integrations/monitoring", nil, nil, nil)
+}
+
+// StreamDeploymentLogs writes the log of a deployment to w. When following, it returns once the deployment ends.
+func (c *Client) StreamDeploymentLogs(ctx context.Context, stackId string, deploymentId string, follow bool, w io.Writer) error {
+	return c.streamLog(ctx, stackPath(stackId)+"/deployments/"+url.PathEscape(deploymentId)+"/logs", follow, w)
+}
+
+// StreamIntegrationLogs writes the log of an integration to w. When following, it returns once the integration is
+// installed or uninstalled.
+func (c *Client) StreamIntegrationLogs(ctx context.Context, stackId string, integrationId string, follow bool, w io.Writer) error {
+	return c.streamLog(ctx, stackPath(stackId)+"/integrations/"+url.PathEscape(integrationId)+"/logs", follow, w)
+}
+
+func (c *Client) streamLog(ctx context.Context, path string, follow bool, w io.Writer) error {
+	query := url.Values{}
+	query.Set("follow", strconv.FormatBool(follow))
+
+	// The stream lasts as long as the deployment or the integration, only the context bounds it
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := c.send(
+		ctx,
+		&httpClient,
+		http.MethodGet,
+		path,
+		query,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var envelope response
+		_ = json.NewDecoder(resp.Body).Decode(&envelope)
+		if envelope.Message == "" {
+			envelope.Message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    envelope.Message,
+			RequestID:  resp.Header.Get(middlewares.RequestIDHeader),
+		}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if _, err := io.WriteString(w, scanner.Text()+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// WaitForThanosStack polls the status of the stack until it is no longer in progress and returns it
+func (c *Client) WaitForThanosStack(ctx context.Context, stackId string, interval time.Duration) (entities.StackStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.GetThanosStackStatus(ctx, stackId)
+		if err != nil {
+			return "", err
+		}
+		if !status.IsInProgress() {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditSource string
+
+const (
+	AuditSourceAPI  AuditSource = "api"
+	AuditSourceGRPC AuditSource = "grpc"
+	// AuditSourceTask records the status transitions, whether made by a request or by the tasks it started
+	AuditSourceTask AuditSource = "task"
+)
+
+const (
+	AuditActionStackStatus       = "stack.status"
+	AuditActionIntegrationStatus = "integration.status"
+	AuditActionStackArchived     = "stack.archived"
+	// AuditActorSystem is the actor of the status transitions, the caller is found on the record of the same request
+	AuditActorSystem = "system"
+)
+
+// AuditRecordEntity is a mutating call of the APIs or a status transition of a stack or an integration
+type AuditRecordEntity struct {
+	ID            uuid.UUID   `json:"id"`
+	Source        AuditSource `json:"source"`
+	ActorID       *uuid.UUID  `json:"actor_id"`
+	Actor         string      `json:"actor"`
+	SourceIP      string      `json:"source_ip"`
+	ProjectID     *uuid.UUID  `json:"project_id"`
+	StackID       *uuid.UUID  `json:"stack_id"`
+	IntegrationID *uuid.UUID  `json:"integration_id"`
+	// Action is the method and route of a call, such as DELETE /api/v1/stacks/thanos/:id, or the transition kind
+	Action       string    `json:"action"`
+	BeforeStatus string    `json:"before_status"`
+	AfterStatus  string    `json:"after_status"`
+	Result       string    `json:"result"` // HTTP status or gRPC code of a call
+	Reason       string    `json:"reason"`
+	RequestID    string    `json:"request_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuditFilter selects the audit records, empty fields match every record.
+// The records of a stack include the calls which made its transitions, such as the deployment creating it.
+type AuditFilter struct {
+	StackID   *uuid.UUID
+	ProjectID *uuid.UUID
+	ActorID   *uuid.UUID
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const StackBundleVersion = 1
+
+// StackBundleFile describes a file of a stack bundle, symlinks carry their target instead of a digest
+type StackBundleFile struct {
+	Path       string `json:"path"`
+	Mode       int64  `json:"mode"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256,omitempty"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// StackBundleManifest lists the content of a stack bundle, it is signed so that tampered bundles are rejected
+type StackBundleManifest struct {
+	Version        int               `json:"version"`
+	StackID        uuid.UUID         `json:"stack_id"`
+	Network        DeploymentNetwork `json:"network"`
+	DeploymentPath string            `json:"deployment_path"`
+	// LogDir is the directory of the logs in the log sink, the bundles of the former versions hold its absolute path
+	LogDir       string            `json:"log_dir"`
+	IncludesLogs bool              `json:"includes_logs"`
+	ExportedAt   time.Time         `json:"exported_at"`
+	Files        []StackBundleFile `json:"files"`
+}
+
+// StackBundleRecords holds the database rows of an exported stack
+type StackBundleRecords struct {
+	Stack        *StackEntity         `json:"stack"`
+	Deployments  []*DeploymentEntity  `json:"deployments"`
+	Integrations []*IntegrationEntity `json:"integrations"`
+	// OperatorKeys are the managed keys referenced by the stack, the bundles of the former versions embed the
+	// private keys in the configs instead
+	OperatorKeys []*StackBundleOperatorKey `json:"operator_keys,omitempty"`
+}
+
+// StackBundleOperatorKey is a managed key of an exported stack, carried as its keystore encrypted with the keystore
+// passphrase of the exporting instance
+type StackBundleOperatorKey struct {
+	ID       uuid.UUID       `json:"id"`
+	Name     string          `json:"name"`
+	Address  string          `json:"address"`
+	Keystore json.RawMessage `json:"keystore"`
+}
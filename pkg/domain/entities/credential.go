@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CredentialEntity is a cloud credential profile of a project, the stacks referencing it use its current keys
+type CredentialEntity struct {
+	ID                 uuid.UUID `json:"id"`
+	ProjectID          uuid.UUID `json:"project_id"`
+	Name               string    `json:"name"`
+	Provider           string    `json:"provider"`
+	AwsAccessKey       string    `json:"aws_access_key"`
+	AwsSecretAccessKey string    `json:"-"`
+	AwsRegion          string    `json:"aws_region"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
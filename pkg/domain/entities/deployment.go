@@ -7,15 +7,11 @@ import (
 )
 
 type DeploymentEntity struct {
-	ID      uuid.UUID        `json:"id"`
-	StackID *uuid.UUID       `json:"stack_id,omitempty"`
-	Step    int              `json:"step"`
-	Status  DeploymentStatus `json:"status"`
-	LogPath string           `json:"log_path"`
-	Config  json.RawMessage  `json:"config"`
-}
-
-type DeploymentStatusWithID struct {
-	DeploymentID uuid.UUID
-	Status       DeploymentStatus
+	ID        uuid.UUID        `json:"id"`
+	StackID   *uuid.UUID       `json:"stack_id,omitempty"`
+	Step      int              `json:"step"`
+	Status    DeploymentStatus `json:"status"`
+	LogPath   string           `json:"log_path"`
+	Config    json.RawMessage  `json:"config"`
+	RequestID string           `json:"request_id,omitempty"`
 }
@@ -24,6 +24,15 @@ const (
 	StackStatusUnknown           StackStatus = "Unknown"
 )
 
+// IsInProgress reports whether the stack is queued or being deployed, updated or terminated
+func (s StackStatus) IsInProgress() bool {
+	switch s {
+	case StackStatusPending, StackStatusDeploying, StackStatusUpdating, StackStatusTerminating:
+		return true
+	}
+	return false
+}
+
 type DeploymentStatus string
 
 const (
@@ -36,3 +45,33 @@ const (
 	DeploymentStatusTerminated  DeploymentStatus = "Terminated"
 	DeploymentStatusUnknown     DeploymentStatus = "Unknown"
 )
+
+type ProjectRole string
+
+const (
+	ProjectRoleViewer   ProjectRole = "viewer"
+	ProjectRoleOperator ProjectRole = "operator"
+	ProjectRoleAdmin    ProjectRole = "admin"
+)
+
+func (r ProjectRole) IsValid() bool {
+	return r.level() > 0
+}
+
+// Allows reports whether the role grants at least the permissions of the required role
+func (r ProjectRole) Allows(required ProjectRole) bool {
+	return r.IsValid() && r.level() >= required.level()
+}
+
+func (r ProjectRole) level() int {
+	switch r {
+	case ProjectRoleViewer:
+		return 1
+	case ProjectRoleOperator:
+		return 2
+	case ProjectRoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -16,12 +16,15 @@ func (info IntegrationInfo) ToJson() (json.RawMessage, error) {
 }
 
 type IntegrationEntity struct {
-	ID      uuid.UUID       `json:"id"`
-	StackID *uuid.UUID      `json:"stack_id"`
-	Type    string          `json:"type"`
-	Status  string          `json:"status"`
-	Config  json.RawMessage `json:"config"`
-	Info    json.RawMessage `json:"info"`
-	LogPath string          `json:"log_path"`
-	Reason  string          `json:"reason"`
+	ID        uuid.UUID       `json:"id"`
+	StackID   *uuid.UUID      `json:"stack_id"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Config    json.RawMessage `json:"config"`
+	Info      json.RawMessage `json:"info"`
+	LogPath   string          `json:"log_path"`
+	Reason    string          `json:"reason"`
+	RequestID string          `json:"request_id,omitempty"`
+	// Version is incremented by every update of the integration
+	Version int64 `json:"version"`
 }
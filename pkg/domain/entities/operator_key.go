@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OperatorKeyOrigin string
+
+const (
+	OperatorKeyOriginImported  OperatorKeyOrigin = "imported"
+	OperatorKeyOriginGenerated OperatorKeyOrigin = "generated"
+)
+
+// OperatorKeyEntity is a managed private key of a project, the stacks reference it by id for their system accounts.
+// Only its encrypted keystore is stored and the key is never returned.
+type OperatorKeyEntity struct {
+	ID        uuid.UUID         `json:"id"`
+	ProjectID uuid.UUID         `json:"project_id"`
+	Name      string            `json:"name"`
+	Address   string            `json:"address"`
+	Origin    OperatorKeyOrigin `json:"origin"`
+	Keystore  json.RawMessage   `json:"-"`
+	CreatedAt time.Time         `json:"created_at"`
+}
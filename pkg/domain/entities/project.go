@@ -0,0 +1,15 @@
+package entities
+
+import "github.com/google/uuid"
+
+type ProjectEntity struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+type ProjectMemberEntity struct {
+	ProjectID uuid.UUID   `json:"project_id"`
+	UserID    uuid.UUID   `json:"user_id"`
+	Role      ProjectRole `json:"role"`
+}
@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// SecretConfigFields are the fields of the stack, deployment and integration configs holding secrets. They are
+// encrypted at rest and left out of the API responses.
+var SecretConfigFields = []string{
+	"adminAccount",
+	"sequencerAccount",
+	"batcherAccount",
+	"proposerAccount",
+	"awsSecretAccessKey",
+	"databasePassword",
+	"coinmarketcapKey",
+	"grafanaPassword",
+}
+
+// WithoutSecrets returns a copy of the stack without the secret fields of its config, to be sent to the API clients
+func (s *StackEntity) WithoutSecrets() *StackEntity {
+	stack := *s
+	stack.Config = configWithoutSecrets(s.Config)
+	return &stack
+}
+
+func (d *DeploymentEntity) WithoutSecrets() *DeploymentEntity {
+	deployment := *d
+	deployment.Config = configWithoutSecrets(d.Config)
+	return &deployment
+}
+
+func (i *IntegrationEntity) WithoutSecrets() *IntegrationEntity {
+	integration := *i
+	integration.Config = configWithoutSecrets(i.Config)
+	return &integration
+}
+
+// StacksWithoutSecrets, DeploymentsWithoutSecrets and IntegrationsWithoutSecrets apply WithoutSecrets to every entity
+func StacksWithoutSecrets(stacks []*StackEntity) []*StackEntity {
+	result := make([]*StackEntity, len(stacks))
+	for i, stack := range stacks {
+		result[i] = stack.WithoutSecrets()
+	}
+	return result
+}
+
+func DeploymentsWithoutSecrets(deployments []*DeploymentEntity) []*DeploymentEntity {
+	result := make([]*DeploymentEntity, len(deployments))
+	for i, deployment := range deployments {
+		result[i] = deployment.WithoutSecrets()
+	}
+	return result
+}
+
+func IntegrationsWithoutSecrets(integrations []*IntegrationEntity) []*IntegrationEntity {
+	result := make([]*IntegrationEntity, len(integrations))
+	for i, integration := range integrations {
+		result[i] = integration.WithoutSecrets()
+	}
+	return result
+}
+
+// configWithoutSecrets removes the secret fields of a JSON object. A config which cannot be parsed is left out
+// entirely rather than risking to send its secrets.
+func configWithoutSecrets(config json.RawMessage) json.RawMessage {
+	if trimmed := bytes.TrimSpace(config); len(trimmed) == 0 || trimmed[0] != '{' {
+		return config
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(config, &object); err != nil {
+		return nil
+	}
+	removed := false
+	for _, field := range SecretConfigFields {
+		if _, ok := object[field]; ok {
+			delete(object, field)
+			removed = true
+		}
+	}
+	if !removed {
+		return config
+	}
+	stripped, err := json.Marshal(object)
+	if err != nil {
+		return nil
+	}
+	return stripped
+}
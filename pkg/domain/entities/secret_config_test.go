@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithoutSecrets(t *testing.T) {
+	config := json.RawMessage(`{"adminAccount":"0x01","awsAccessKey":"AKIA","awsSecretAccessKey":"secret","chainName":"thanos"}`)
+	stack := &StackEntity{Name: "thanos", Config: config}
+
+	stripped := stack.WithoutSecrets()
+	var got map[string]string
+	if err := json.Unmarshal(stripped.Config, &got); err != nil {
+		t.Fatalf("invalid config %s: %v", stripped.Config, err)
+	}
+	if len(got) != 2 || got["awsAccessKey"] != "AKIA" || got["chainName"] != "thanos" {
+		t.Errorf("config = %v, want the access key and the chain name only", got)
+	}
+	if string(stack.Config) != string(config) || stripped.Name != "thanos" {
+		t.Errorf("the stack was modified: %+v", stack)
+	}
+
+	integration := (&IntegrationEntity{Config: json.RawMessage(`{"databasePassword":`)}).WithoutSecrets()
+	if integration.Config != nil {
+		t.Errorf("config = %s, want the unparsable config left out", integration.Config)
+	}
+	deployment := (&DeploymentEntity{Config: json.RawMessage(`null`)}).WithoutSecrets()
+	if string(deployment.Config) != "null" {
+		t.Errorf("config = %s, want null", deployment.Config)
+	}
+}
@@ -2,10 +2,15 @@ package entities
 
 import (
 	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrVersionConflict is returned by the conditional updates of a stack or an integration changed since it was read
+var ErrVersionConflict = errors.New("version conflict")
+
 type StackMetadata struct {
 	L2Url            string `json:"l2_url"`
 	BridgeUrl        string `json:"bridge_url,omitempty"`
@@ -35,10 +40,16 @@ func FromJSONToStackMetadata(data json.RawMessage) (*StackMetadata, error) {
 
 type StackEntity struct {
 	ID             uuid.UUID         `json:"id"`
+	ProjectID      *uuid.UUID        `json:"project_id,omitempty"`
 	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Labels         map[string]string `json:"labels"`
 	Network        DeploymentNetwork `json:"network"`
 	Config         json.RawMessage   `json:"config"`
 	DeploymentPath string            `json:"deployment_path"`
 	Metadata       *StackMetadata    `json:"metadata"`
 	Status         StackStatus       `json:"status"`
+	ArchivedAt     *time.Time        `json:"archived_at,omitempty"`
+	// Version is incremented by every update of the stack
+	Version int64 `json:"version"`
 }
@@ -0,0 +1,86 @@
+package entities
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// StatusSubject is the kind of row whose status changed
+type StatusSubject string
+
+const (
+	StatusSubjectStack       StatusSubject = "stack"
+	StatusSubjectDeployment  StatusSubject = "deployment"
+	StatusSubjectIntegration StatusSubject = "integration"
+)
+
+// stackTransitions lists the statuses each stack status can move to. Terminated stacks can be deployed again by a
+// resume.
+var stackTransitions = map[StackStatus][]StackStatus{
+	StackStatusPending:           {StackStatusDeploying, StackStatusTerminating, StackStatusFailedToTerminate},
+	StackStatusDeploying:         {StackStatusDeployed, StackStatusFailedToDeploy, StackStatusStopped},
+	StackStatusDeployed:          {StackStatusUpdating, StackStatusTerminating, StackStatusFailedToTerminate},
+	StackStatusStopped:           {StackStatusDeploying, StackStatusTerminating, StackStatusFailedToTerminate},
+	StackStatusUpdating:          {StackStatusDeployed, StackStatusFailedToUpdate},
+	StackStatusFailedToDeploy:    {StackStatusDeploying, StackStatusTerminating, StackStatusFailedToTerminate},
+	StackStatusFailedToUpdate:    {StackStatusUpdating, StackStatusDeployed, StackStatusTerminating, StackStatusFailedToTerminate},
+	StackStatusTerminating:       {StackStatusTerminated, StackStatusFailedToTerminate},
+	StackStatusFailedToTerminate: {StackStatusTerminating},
+	StackStatusTerminated:        {StackStatusDeploying},
+}
+
+// deploymentTransitions lists the statuses each deployment status can move to. A deployment fails before it starts
+// when its SDK client cannot be created, and every deployment runs again when a terminated stack is resumed.
+var deploymentTransitions = map[DeploymentStatus][]DeploymentStatus{
+	DeploymentStatusPending:     {DeploymentStatusInProgress, DeploymentStatusFailed, DeploymentStatusTerminated},
+	DeploymentStatusInProgress:  {DeploymentStatusCompleted, DeploymentStatusFailed, DeploymentStatusStopped, DeploymentStatusTerminated},
+	DeploymentStatusFailed:      {DeploymentStatusInProgress, DeploymentStatusTerminated},
+	DeploymentStatusStopped:     {DeploymentStatusInProgress, DeploymentStatusFailed, DeploymentStatusTerminated},
+	DeploymentStatusCompleted:   {DeploymentStatusTerminated},
+	DeploymentStatusTerminating: {DeploymentStatusTerminated, DeploymentStatusFailed},
+	DeploymentStatusTerminated:  {DeploymentStatusInProgress, DeploymentStatusFailed},
+}
+
+// integrationTransitions lists the statuses each integration status can move to. Integrations are installed again
+// as new rows, so terminated integrations are final.
+var integrationTransitions = map[DeploymentStatus][]DeploymentStatus{
+	DeploymentStatusPending:     {DeploymentStatusInProgress, DeploymentStatusCompleted, DeploymentStatusFailed, DeploymentStatusTerminated},
+	DeploymentStatusInProgress:  {DeploymentStatusCompleted, DeploymentStatusFailed, DeploymentStatusTerminated},
+	DeploymentStatusCompleted:   {DeploymentStatusTerminating, DeploymentStatusTerminated},
+	DeploymentStatusFailed:      {DeploymentStatusTerminated},
+	DeploymentStatusTerminating: {DeploymentStatusTerminated, DeploymentStatusFailed},
+}
+
+// CanTransitionTo reports whether the stack can move to the status. Keeping the same status is always allowed.
+func (s StackStatus) CanTransitionTo(next StackStatus) bool {
+	return s == next || slices.Contains(stackTransitions[s], next)
+}
+
+// CanDeploymentTransition reports whether a deployment can move between the statuses
+func CanDeploymentTransition(from DeploymentStatus, to DeploymentStatus) bool {
+	return from == to || slices.Contains(deploymentTransitions[from], to)
+}
+
+// CanIntegrationTransition reports whether an integration can move between the statuses
+func CanIntegrationTransition(from DeploymentStatus, to DeploymentStatus) bool {
+	return from == to || slices.Contains(integrationTransitions[from], to)
+}
+
+// StatusTransitionEntity is an accepted status change of a stack or of one of its deployments or integrations. The
+// creation of a stack or an integration is recorded with an empty FromStatus.
+type StatusTransitionEntity struct {
+	ID         uuid.UUID     `json:"id"`
+	StackID    uuid.UUID     `json:"stack_id"`
+	Subject    StatusSubject `json:"subject"`
+	SubjectID  uuid.UUID     `json:"subject_id"`
+	FromStatus string        `json:"from_status"`
+	ToStatus   string        `json:"to_status"`
+	Reason     string        `json:"reason"`
+	RequestID  string        `json:"request_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package entities
+
+import "testing"
+
+func TestStackStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from StackStatus
+		to   StackStatus
+		want bool
+	}{
+		{StackStatusPending, StackStatusDeploying, true},
+		{StackStatusDeploying, StackStatusDeployed, true},
+		{StackStatusDeployed, StackStatusUpdating, true},
+		{StackStatusUpdating, StackStatusDeployed, true},
+		{StackStatusTerminated, StackStatusDeploying, true},
+		{StackStatusDeployed, StackStatusDeployed, true},
+		{StackStatusTerminating, StackStatusDeployed, false},
+		{StackStatusTerminated, StackStatusTerminating, false},
+		{StackStatusUpdating, StackStatusTerminating, false},
+		{StackStatusDeployed, StackStatusStopped, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCanIntegrationTransition(t *testing.T) {
+	if !CanIntegrationTransition(DeploymentStatusCompleted, DeploymentStatusTerminating) {
+		t.Error("a completed integration must be able to terminate")
+	}
+	if CanIntegrationTransition(DeploymentStatusTerminated, DeploymentStatusCompleted) {
+		t.Error("a terminated integration must stay terminated")
+	}
+	if CanDeploymentTransition(DeploymentStatusCompleted, DeploymentStatusInProgress) {
+		t.Error("a completed deployment must not run again before its stack is terminated")
+	}
+}
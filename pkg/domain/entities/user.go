@@ -0,0 +1,10 @@
+package entities
+
+import "github.com/google/uuid"
+
+type UserEntity struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	IsAdmin bool      `json:"is_admin"`
+}
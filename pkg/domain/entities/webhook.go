@@ -0,0 +1,114 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventStackDeployed              WebhookEventType = "stack.deployed"
+	WebhookEventStackFailedToDeploy        WebhookEventType = "stack.failed_to_deploy"
+	WebhookEventStackTerminated            WebhookEventType = "stack.terminated"
+	WebhookEventIntegrationInstalled       WebhookEventType = "integration.installed"
+	WebhookEventIntegrationFailedToInstall WebhookEventType = "integration.failed_to_install"
+)
+
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventStackDeployed,
+	WebhookEventStackFailedToDeploy,
+	WebhookEventStackTerminated,
+	WebhookEventIntegrationInstalled,
+	WebhookEventIntegrationFailedToInstall,
+}
+
+func (t WebhookEventType) IsValid() bool {
+	for _, eventType := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "Pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "Succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "Failed"
+)
+
+type WebhookSubscriptionEntity struct {
+	ID        uuid.UUID          `json:"id"`
+	ProjectID uuid.UUID          `json:"project_id"`
+	URL       string             `json:"url"`
+	Events    []WebhookEventType `json:"events"`
+	Secret    string             `json:"-"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants to receive the event type.
+// An empty event filter subscribes to every event.
+func (s *WebhookSubscriptionEntity) Matches(eventType WebhookEventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, event := range s.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEventEntity is a lifecycle event written to the outbox in the same transaction as the state change
+type WebhookEventEntity struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	ProjectID *uuid.UUID       `json:"project_id,omitempty"`
+	StackID   *uuid.UUID       `json:"stack_id,omitempty"`
+	Data      json.RawMessage  `json:"data"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type WebhookDeliveryEntity struct {
+	ID             uuid.UUID             `json:"id"`
+	SubscriptionID uuid.UUID             `json:"subscription_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+type StackEventData struct {
+	StackID   uuid.UUID   `json:"stack_id"`
+	ProjectID *uuid.UUID  `json:"project_id,omitempty"`
+	Status    StackStatus `json:"status"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+type IntegrationEventData struct {
+	IntegrationID uuid.UUID        `json:"integration_id"`
+	StackID       *uuid.UUID       `json:"stack_id,omitempty"`
+	ProjectID     *uuid.UUID       `json:"project_id,omitempty"`
+	Type          string           `json:"type"`
+	Status        DeploymentStatus `json:"status"`
+	Reason        string           `json:"reason,omitempty"`
+	Info          json.RawMessage  `json:"info,omitempty"`
+}
+
+// WebhookDeliveryJob is a due delivery together with the subscription and event needed to send it
+type WebhookDeliveryJob struct {
+	Delivery     *WebhookDeliveryEntity
+	Subscription *WebhookSubscriptionEntity
+	Event        *WebhookEventEntity
+}
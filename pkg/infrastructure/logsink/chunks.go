@@ -0,0 +1,197 @@
+package logsink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// chunkSize is the size from which the writes are stored without waiting for the flush interval
+	chunkSize = 64 << 10
+	// flushInterval bounds how late the writes are visible to the readers
+	flushInterval = time.Second
+	// maxBufferedSize bounds the writes kept while the chunks fail to be stored, the writes fail beyond it
+	maxBufferedSize = 16 * chunkSize
+)
+
+// chunk is a part of a log, starting at the offset start
+type chunk struct {
+	start int64
+	size  int64
+}
+
+// chunkStore stores the logs of the database and of the object store sinks. A log is stored as the chunks written
+// to it, a write never updates the stored chunks.
+type chunkStore interface {
+	// chunks returns the chunks of the log, ordered by offset
+	chunks(ctx context.Context, name string) ([]chunk, error)
+	readChunk(ctx context.Context, name string, start int64) ([]byte, error)
+	writeChunk(ctx context.Context, name string, start int64, data []byte) error
+}
+
+// appendChunks opens the log for writing after its last chunk
+func appendChunks(ctx context.Context, store chunkStore, open *openLogs, name string) (io.WriteCloser, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := store.chunks(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if len(chunks) > 0 {
+		last := chunks[len(chunks)-1]
+		offset = last.start + last.size
+	}
+
+	open.add(name)
+	writer := &chunkWriter{
+		// The writer outlives the request which opened it, such as the task of a deployment, and flushes on close
+		ctx:    context.WithoutCancel(ctx),
+		store:  store,
+		name:   name,
+		offset: offset,
+		done:   make(chan struct{}),
+		closed: func() { open.remove(name) },
+	}
+	go writer.flushPeriodically()
+	return writer, nil
+}
+
+// openChunks reads the log from the offset, up to the chunks stored when opened
+func openChunks(ctx context.Context, store chunkStore, name string, offset int64) (io.ReadCloser, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := store.chunks(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, notExist(name)
+	}
+	return &chunkReader{ctx: ctx, store: store, name: name, chunks: chunks, offset: offset}, nil
+}
+
+// chunkWriter buffers the writes into chunks, which are stored when large enough, every flushInterval and on Close
+type chunkWriter struct {
+	ctx    context.Context
+	store  chunkStore
+	name   string
+	done   chan struct{}
+	closed func()
+
+	mu       sync.Mutex
+	buffer   []byte
+	offset   int64
+	isClosed bool
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.isClosed {
+		return 0, errors.New("log writer is closed")
+	}
+	if len(w.buffer) >= maxBufferedSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	w.buffer = append(w.buffer, p...)
+	if len(w.buffer) >= chunkSize {
+		// A failed flush is retried by the next one
+		_ = w.flush()
+	}
+	return len(p), nil
+}
+
+func (w *chunkWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.isClosed {
+		return nil
+	}
+	w.isClosed = true
+	close(w.done)
+	defer w.closed()
+	return w.flush()
+}
+
+func (w *chunkWriter) flushPeriodically() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		w.mu.Lock()
+		if !w.isClosed {
+			_ = w.flush()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// flush stores the buffer as a chunk, the buffer is kept when it fails. The caller holds mu.
+func (w *chunkWriter) flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	if err := w.store.writeChunk(w.ctx, w.name, w.offset, w.buffer); err != nil {
+		return err
+	}
+	w.offset += int64(len(w.buffer))
+	w.buffer = nil
+	return nil
+}
+
+type chunkReader struct {
+	ctx    context.Context
+	store  chunkStore
+	name   string
+	chunks []chunk
+	offset int64
+	// data is the rest of the current chunk
+	data []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		next := r.chunks[0]
+		r.chunks = r.chunks[1:]
+		if next.start+next.size <= r.offset {
+			continue
+		}
+		data, err := r.store.readChunk(r.ctx, r.name, next.start)
+		if err != nil {
+			return 0, err
+		}
+		if skip := r.offset - next.start; skip > 0 {
+			data = data[min(skip, int64(len(data))):]
+		}
+		r.data = data
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	return nil
+}
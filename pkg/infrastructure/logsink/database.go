@@ -0,0 +1,164 @@
+package logsink
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// logChunk is a row of the log_chunks table
+type logChunk struct {
+	Name      string    `gorm:"column:name;primaryKey"`
+	Start     int64     `gorm:"column:start;primaryKey"`
+	Data      []byte    `gorm:"column:data"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (logChunk) TableName() string {
+	return "log_chunks"
+}
+
+// DatabaseSink stores the logs in the log_chunks table, so that they are kept and backed up with the database
+type DatabaseSink struct {
+	db        *gorm.DB
+	retention Retention
+	open      openLogs
+}
+
+func NewDatabaseSink(db *gorm.DB, retention Retention) *DatabaseSink {
+	return &DatabaseSink{db: db, retention: retention}
+}
+
+func (s *DatabaseSink) Append(ctx context.Context, name string) (io.WriteCloser, error) {
+	return appendChunks(ctx, s, &s.open, name)
+}
+
+func (s *DatabaseSink) Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error) {
+	return openChunks(ctx, s, name, offset)
+}
+
+func (s *DatabaseSink) List(ctx context.Context, dir string) ([]string, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	err = s.db.WithContext(ctx).Model(&logChunk{}).
+		Distinct("name").
+		Where("name LIKE ? ESCAPE '\\'", escapeLike(dir)+"%").
+		Order("name").
+		Pluck("name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (s *DatabaseSink) Delete(ctx context.Context, name string) error {
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Where("name = ?", name).Delete(&logChunk{}).Error
+}
+
+func (s *DatabaseSink) Prune(ctx context.Context) error {
+	if s.retention == (Retention{}) {
+		return nil
+	}
+
+	var rows []struct {
+		Name    string
+		Size    int64
+		ModTime string
+	}
+	// The time is scanned as text, the drivers of SQLite do not parse the result of MAX
+	err := s.db.WithContext(ctx).Model(&logChunk{}).
+		Select("name, SUM(length(data)) AS size, MAX(created_at) AS mod_time").
+		Group("name").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	logs := make([]logInfo, 0, len(rows))
+	for _, row := range rows {
+		if s.open.has(row.Name) {
+			continue
+		}
+		modTime, err := parseDatabaseTime(row.ModTime)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, logInfo{name: row.Name, size: row.Size, modTime: modTime})
+	}
+
+	for _, name := range s.retention.expired(logs, time.Now()) {
+		if s.open.has(name) {
+			continue
+		}
+		if err := s.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DatabaseSink) chunks(ctx context.Context, name string) ([]chunk, error) {
+	var rows []struct {
+		Start int64
+		Size  int64
+	}
+	err := s.db.WithContext(ctx).Model(&logChunk{}).
+		Select("start, length(data) AS size").
+		Where("name = ?", name).
+		Order("start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]chunk, len(rows))
+	for i, row := range rows {
+		chunks[i] = chunk{start: row.Start, size: row.Size}
+	}
+	return chunks, nil
+}
+
+func (s *DatabaseSink) readChunk(ctx context.Context, name string, start int64) ([]byte, error) {
+	var row logChunk
+	err := s.db.WithContext(ctx).Where("name = ? AND start = ?", name, start).Take(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	return row.Data, nil
+}
+
+func (s *DatabaseSink) writeChunk(ctx context.Context, name string, start int64, data []byte) error {
+	// A chunk stored by a flush reported as failed is stored again by the next flush
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&logChunk{Name: name, Start: start, Data: data, CreatedAt: time.Now().UTC()}).Error
+}
+
+// escapeLike escapes the wildcards of LIKE
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// parseDatabaseTime parses the times formatted by Postgres and by the SQLite driver
+func parseDatabaseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999Z07:00"} {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
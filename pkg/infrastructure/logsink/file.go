@@ -0,0 +1,320 @@
+package logsink
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	compressedSuffix = ".gz"
+	// compressIdleTime is how long a log is not written to before it is compressed. The commands of the SDK may not
+	// log for a while, such as when waiting for terraform.
+	compressIdleTime = time.Hour
+)
+
+// FileSink stores the logs in files under its root directory. The logs are compressed once they are not written to
+// anymore, and decompressed if written to again.
+type FileSink struct {
+	root      string
+	retention Retention
+	compress  bool
+	open      openLogs
+	// mu orders the compression of the logs with the appends
+	mu sync.Mutex
+}
+
+func NewFileSink(root string, retention Retention, compress bool) (*FileSink, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the log directory: %w", err)
+	}
+	return &FileSink{root: root, retention: retention, compress: compress}, nil
+}
+
+// resolve returns the name of the log and its file. The logs were formerly named after their absolute path, those
+// inside the root are still found.
+func (s *FileSink) resolve(name string) (string, string, error) {
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %q", errInvalidName, name)
+		}
+		name = filepath.ToSlash(rel)
+	}
+	name, err := cleanName(name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, filepath.Join(s.root, filepath.FromSlash(name)), nil
+}
+
+func (s *FileSink) Append(ctx context.Context, name string) (io.WriteCloser, error) {
+	name, file, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	if err := decompressFile(file); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.open.add(name)
+	return &fileWriter{File: f, close: func() { s.open.remove(name) }}, nil
+}
+
+func (s *FileSink) Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error) {
+	_, file, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err == nil {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err = os.Open(file + compressedSuffix)
+	if err != nil {
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, gzipReader, offset); err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFileReader{Reader: gzipReader, file: f}, nil
+}
+
+func (s *FileSink) List(ctx context.Context, dir string) ([]string, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	err = s.walk(ctx, filepath.Join(s.root, filepath.FromSlash(dir)), func(name string, compressed bool, info fs.FileInfo) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+func (s *FileSink) Delete(ctx context.Context, name string) error {
+	_, file, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []string{file, file + compressedSuffix} {
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	// The directory of the stack is removed with its last log
+	if dir := filepath.Dir(file); dir != s.root {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
+
+func (s *FileSink) Prune(ctx context.Context) error {
+	var logs []logInfo
+	err := s.walk(ctx, s.root, func(name string, compressed bool, info fs.FileInfo) error {
+		if s.open.has(name) {
+			return nil
+		}
+		if s.compress && !compressed && time.Since(info.ModTime()) > compressIdleTime {
+			compressedInfo, err := s.compressLog(name)
+			if err != nil {
+				return err
+			}
+			if compressedInfo != nil {
+				info = compressedInfo
+			}
+		}
+		logs = append(logs, logInfo{name: name, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range s.retention.expired(logs, time.Now()) {
+		if s.open.has(name) {
+			continue
+		}
+		if err := s.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk calls fn with the logs under the directory
+func (s *FileSink) walk(
+	ctx context.Context,
+	dir string,
+	fn func(name string, compressed bool, info fs.FileInfo) error,
+) error {
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Skips the compressions left over by a crash
+		if !entry.Type().IsRegular() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		name, compressed := strings.CutSuffix(filepath.ToSlash(rel), compressedSuffix)
+		return fn(name, compressed, info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// compressLog replaces the log with its gzip, keeping its modification time. It returns the gzip, nil if the log is
+// now written to.
+func (s *FileSink) compressLog(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.open.has(name) {
+		return nil, nil
+	}
+	file := filepath.Join(s.root, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	temp := file + compressedSuffix + ".tmp"
+	target, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	gzipWriter := gzip.NewWriter(target)
+	_, err = io.Copy(gzipWriter, source)
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(temp, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(temp, file+compressedSuffix)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return nil, err
+	}
+	if err := os.Remove(file); err != nil {
+		return nil, err
+	}
+	return os.Stat(file + compressedSuffix)
+}
+
+// decompressFile restores the compressed log, if any, so that it can be appended to
+func decompressFile(file string) error {
+	source, err := os.Open(file + compressedSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	gzipReader, err := gzip.NewReader(source)
+	if err != nil {
+		return err
+	}
+	target, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, gzipReader)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return err
+	}
+	return os.Remove(file + compressedSuffix)
+}
+
+type fileWriter struct {
+	*os.File
+	once  sync.Once
+	close func()
+}
+
+func (w *fileWriter) Close() error {
+	err := w.File.Close()
+	w.once.Do(w.close)
+	return err
+}
+
+type gzipFileReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipFileReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
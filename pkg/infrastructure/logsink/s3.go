@@ -0,0 +1,244 @@
+package logsink
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/tokamak-network/trh-backend/internal/config"
+)
+
+// maxDeletedObjects is the limit of DeleteObjects
+const maxDeletedObjects = 1000
+
+// ObjectSink stores the logs in an S3-compatible bucket. The chunks of a log are the objects
+// <prefix><name>/<offset>, the offset padded so that the keys are listed in order.
+type ObjectSink struct {
+	client    *s3.Client
+	bucket    string
+	prefix    string
+	retention Retention
+	open      openLogs
+}
+
+func NewObjectSink(ctx context.Context, cfg config.LogStorageS3Config, retention Retention) (*ObjectSink, error) {
+	options := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(cfg.Region)}
+	if cfg.AccessKey != "" {
+		options = append(options, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+		))
+	}
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+	return &ObjectSink{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix, retention: retention}, nil
+}
+
+func (s *ObjectSink) Append(ctx context.Context, name string) (io.WriteCloser, error) {
+	return appendChunks(ctx, s, &s.open, name)
+}
+
+func (s *ObjectSink) Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error) {
+	return openChunks(ctx, s, name, offset)
+}
+
+func (s *ObjectSink) List(ctx context.Context, dir string) ([]string, error) {
+	dir, err := cleanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.listLogs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(logs))
+	for i, log := range logs {
+		names[i] = log.name
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *ObjectSink) Delete(ctx context.Context, name string) error {
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	objects, err := s.listObjects(ctx, s.key(name)+"/")
+	if err != nil {
+		return err
+	}
+
+	for len(objects) > 0 {
+		batch := objects[:min(len(objects), maxDeletedObjects)]
+		objects = objects[len(batch):]
+		identifiers := make([]s3Types.ObjectIdentifier, len(batch))
+		for i, object := range batch {
+			identifiers[i] = s3Types.ObjectIdentifier{Key: object.Key}
+		}
+		output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3Types.Delete{Objects: identifiers, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("failed to delete %s: %s", aws.ToString(output.Errors[0].Key), aws.ToString(output.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+func (s *ObjectSink) Prune(ctx context.Context) error {
+	if s.retention == (Retention{}) {
+		return nil
+	}
+
+	logs, err := s.listLogs(ctx, "")
+	if err != nil {
+		return err
+	}
+	logs = slices.DeleteFunc(logs, func(log logInfo) bool {
+		return s.open.has(log.name)
+	})
+
+	for _, name := range s.retention.expired(logs, time.Now()) {
+		if s.open.has(name) {
+			continue
+		}
+		if err := s.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ObjectSink) chunks(ctx context.Context, name string) ([]chunk, error) {
+	objects, err := s.listObjects(ctx, s.key(name)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]chunk, 0, len(objects))
+	for _, object := range objects {
+		_, start, ok := s.parseKey(aws.ToString(object.Key))
+		if !ok {
+			continue
+		}
+		chunks = append(chunks, chunk{start: start, size: aws.ToInt64(object.Size)})
+	}
+	slices.SortFunc(chunks, func(a, b chunk) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	return chunks, nil
+}
+
+func (s *ObjectSink) readChunk(ctx context.Context, name string, start int64) ([]byte, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.chunkKey(name, start)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+func (s *ObjectSink) writeChunk(ctx context.Context, name string, start int64, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.chunkKey(name, start)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("text/plain; charset=utf-8"),
+	})
+	return err
+}
+
+// listLogs returns the logs under the directory, with the size and the time of their last chunk
+func (s *ObjectSink) listLogs(ctx context.Context, dir string) ([]logInfo, error) {
+	objects, err := s.listObjects(ctx, s.key(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []logInfo
+	indexes := make(map[string]int)
+	for _, object := range objects {
+		name, _, ok := s.parseKey(aws.ToString(object.Key))
+		if !ok {
+			continue
+		}
+		index, exists := indexes[name]
+		if !exists {
+			index = len(logs)
+			indexes[name] = index
+			logs = append(logs, logInfo{name: name})
+		}
+		logs[index].size += aws.ToInt64(object.Size)
+		if modTime := aws.ToTime(object.LastModified); modTime.After(logs[index].modTime) {
+			logs[index].modTime = modTime
+		}
+	}
+	return logs, nil
+}
+
+func (s *ObjectSink) listObjects(ctx context.Context, prefix string) ([]s3Types.Object, error) {
+	var objects []s3Types.Object
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Contents...)
+	}
+	return objects, nil
+}
+
+func (s *ObjectSink) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *ObjectSink) chunkKey(name string, start int64) string {
+	return fmt.Sprintf("%s/%020d", s.key(name), start)
+}
+
+// parseKey returns the log and the offset of the chunk, ok is false for the objects which are not chunks
+func (s *ObjectSink) parseKey(key string) (string, int64, bool) {
+	rest, found := strings.CutPrefix(key, s.prefix)
+	if !found {
+		return "", 0, false
+	}
+	name, offset := path.Split(rest)
+	start, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || name == "" {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(name, "/"), start, true
+}
@@ -0,0 +1,173 @@
+// Package logsink stores the logs of the SDK commands run on the stacks. A log is named after the stack and the
+// command, such as <stackId>/<timestamp>_<plugin>_logs.txt, and is stored either in files, in the database or in an
+// S3-compatible object store. The logs are read back the same way whatever the sink, and are deleted by the
+// retention once too old or too many.
+package logsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Sink stores the logs
+type Sink interface {
+	// Append opens the log for writing at its end, creating it if missing. The writes are visible to Open once
+	// flushed, at the latest a second after them, and the writer must be closed.
+	Append(ctx context.Context, name string) (io.WriteCloser, error)
+	// Open reads the log from the offset, it returns an error wrapping fs.ErrNotExist when the log is missing. The
+	// reader ends at the end of the log when opened, the log is opened again to read what is written later.
+	Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error)
+	// List returns the names of the logs in the directory and its subdirectories, sorted
+	List(ctx context.Context, dir string) ([]string, error)
+	// Delete deletes the log, a missing log is not an error
+	Delete(ctx context.Context, name string) error
+	// Prune applies the retention to the logs which are not being written to
+	Prune(ctx context.Context) error
+}
+
+// New returns the sink of the configuration, the database sink stores the logs in the db
+func New(ctx context.Context, cfg config.LogStorageConfig, db *gorm.DB) (Sink, error) {
+	retention := Retention{MaxAge: cfg.MaxAge, MaxSize: int64(cfg.MaxSizeMB) << 20}
+	switch cfg.Sink {
+	case config.LogSinkFile:
+		return NewFileSink(cfg.Dir, retention, cfg.Compress)
+	case config.LogSinkDatabase:
+		return NewDatabaseSink(db, retention), nil
+	case config.LogSinkS3:
+		return NewObjectSink(ctx, cfg.S3, retention)
+	default:
+		return nil, fmt.Errorf("unknown log sink %q", cfg.Sink)
+	}
+}
+
+// RunRetention prunes the logs every interval, until the context is cancelled
+func RunRetention(ctx context.Context, sink Sink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sink.Prune(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "Failed to prune the logs", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var errInvalidName = errors.New("invalid log name")
+
+// cleanName rejects the names escaping the sink, such as ../secrets
+func cleanName(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(name, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %q", errInvalidName, name)
+	}
+	return cleaned, nil
+}
+
+// cleanDir is cleanName for the directories, the root of the sink is the empty dir
+func cleanDir(dir string) (string, error) {
+	if strings.Trim(dir, "/") == "" || dir == "." {
+		return "", nil
+	}
+	cleaned, err := cleanName(dir)
+	if err != nil {
+		return "", err
+	}
+	return cleaned + "/", nil
+}
+
+func notExist(name string) error {
+	return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// logInfo describes a stored log for the retention
+type logInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// activeLogGrace is how long a log is not written to before it can be deleted for the size. The sinks only track their
+// own writers, the replicas sharing a database or a bucket may still append to the recent logs. The commands of the
+// SDK may not log for a while, such as when waiting for terraform.
+const activeLogGrace = time.Hour
+
+// Retention deletes the logs not written to for MaxAge, then the oldest logs until they take at most MaxSize bytes.
+// The logs written to within activeLogGrace are not deleted for the size. A zero value disables either limit.
+type Retention struct {
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+// expired returns the names of the logs to delete
+func (r Retention) expired(logs []logInfo, now time.Time) []string {
+	logs = slices.Clone(logs)
+	slices.SortFunc(logs, func(a, b logInfo) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	var total int64
+	for _, log := range logs {
+		total += log.size
+	}
+
+	var names []string
+	for _, log := range logs {
+		tooOld := r.MaxAge > 0 && now.Sub(log.modTime) > r.MaxAge
+		tooLarge := r.MaxSize > 0 && total > r.MaxSize && now.Sub(log.modTime) > activeLogGrace
+		if !tooOld && !tooLarge {
+			break
+		}
+		names = append(names, log.name)
+		total -= log.size
+	}
+	return names
+}
+
+// openLogs tracks the logs being written to, the retention leaves them alone
+type openLogs struct {
+	mu    sync.Mutex
+	names map[string]int
+}
+
+func (o *openLogs) add(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.names == nil {
+		o.names = make(map[string]int)
+	}
+	o.names[name]++
+}
+
+func (o *openLogs) remove(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.names[name]--; o.names[name] <= 0 {
+		delete(o.names, name)
+	}
+}
+
+func (o *openLogs) has(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.names[name] > 0
+}
@@ -0,0 +1,291 @@
+package logsink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/connection"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/migrations"
+)
+
+func writeLog(t *testing.T, sink Sink, name string, lines ...string) {
+	t.Helper()
+	writer, err := sink.Append(context.Background(), name)
+	if err != nil {
+		t.Fatalf("failed to append to %s: %v", name, err)
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(writer, line); err != nil {
+			t.Fatalf("failed to write to %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close %s: %v", name, err)
+	}
+}
+
+func readLog(t *testing.T, sink Sink, name string, offset int64) string {
+	t.Helper()
+	reader, err := sink.Open(context.Background(), name, offset)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+// testSink checks the behaviour shared by the sinks
+func testSink(t *testing.T, sink Sink) {
+	ctx := context.Background()
+
+	writeLog(t, sink, "stack-1/deploy_logs.txt", "first\n", "second\n")
+	writeLog(t, sink, "stack-1/deploy_logs.txt", "third\n")
+	writeLog(t, sink, "stack-2/destroy_logs.txt", "destroyed\n")
+
+	if got := readLog(t, sink, "stack-1/deploy_logs.txt", 0); got != "first\nsecond\nthird\n" {
+		t.Errorf("log = %q", got)
+	}
+	if got := readLog(t, sink, "stack-1/deploy_logs.txt", 6); got != "second\nthird\n" {
+		t.Errorf("log from 6 = %q", got)
+	}
+	if got := readLog(t, sink, "stack-1/deploy_logs.txt", 100); got != "" {
+		t.Errorf("log past its end = %q", got)
+	}
+
+	_, err := sink.Open(ctx, "stack-1/missing_logs.txt", 0)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the missing log not to exist, got %v", err)
+	}
+	if _, err := sink.Append(ctx, "../secrets"); !errors.Is(err, errInvalidName) {
+		t.Errorf("expected the name escaping the sink to be rejected, got %v", err)
+	}
+
+	names, err := sink.List(ctx, "stack-1")
+	if err != nil || !slices.Equal(names, []string{"stack-1/deploy_logs.txt"}) {
+		t.Errorf("logs of stack-1 = %v, %v", names, err)
+	}
+	names, err = sink.List(ctx, "")
+	if err != nil || !slices.Equal(names, []string{"stack-1/deploy_logs.txt", "stack-2/destroy_logs.txt"}) {
+		t.Errorf("logs = %v, %v", names, err)
+	}
+
+	if err := sink.Delete(ctx, "stack-2/destroy_logs.txt"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := sink.Delete(ctx, "stack-2/destroy_logs.txt"); err != nil {
+		t.Errorf("failed to delete the missing log: %v", err)
+	}
+	names, err = sink.List(ctx, "stack-2")
+	if err != nil || len(names) != 0 {
+		t.Errorf("logs of stack-2 = %v, %v", names, err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	logs := []logInfo{
+		{name: "recent", size: 10, modTime: now.Add(-time.Minute)},
+		{name: "old", size: 10, modTime: now.Add(-48 * time.Hour)},
+		{name: "older", size: 10, modTime: now.Add(-72 * time.Hour)},
+		{name: "yesterday", size: 10, modTime: now.Add(-20 * time.Hour)},
+	}
+
+	for _, tc := range []struct {
+		retention Retention
+		want      []string
+	}{
+		{Retention{}, nil},
+		{Retention{MaxAge: 24 * time.Hour}, []string{"older", "old"}},
+		{Retention{MaxSize: 25}, []string{"older", "old"}},
+		{Retention{MaxSize: 15}, []string{"older", "old", "yesterday"}},
+		// The recent log may be written to by another replica
+		{Retention{MaxSize: 5}, []string{"older", "old", "yesterday"}},
+		{Retention{MaxAge: 60 * time.Hour, MaxSize: 30}, []string{"older"}},
+	} {
+		if got := tc.retention.expired(logs, now); !slices.Equal(got, tc.want) {
+			t.Errorf("%+v expired %v, want %v", tc.retention, got, tc.want)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	root := t.TempDir()
+	sink, err := NewFileSink(root, Retention{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSink(t, sink)
+
+	// The logs formerly named after their path are still read
+	legacy := filepath.Join(root, "stack-1", "deploy_logs.txt")
+	if got := readLog(t, sink, legacy, 0); got != "first\nsecond\nthird\n" {
+		t.Errorf("legacy log = %q", got)
+	}
+	if _, err := sink.Open(context.Background(), "/etc/passwd", 0); err == nil {
+		t.Error("expected the path outside the root to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(root, "stack-2")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the directory of the deleted log to be removed, got %v", err)
+	}
+}
+
+func TestFileSinkCompressesIdleLogs(t *testing.T) {
+	root := t.TempDir()
+	sink, err := NewFileSink(root, Retention{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	writeLog(t, sink, "stack/idle_logs.txt", "idle\n")
+	writeLog(t, sink, "stack/recent_logs.txt", "recent\n")
+	idle := filepath.Join(root, "stack", "idle_logs.txt")
+	past := time.Now().Add(-2 * compressIdleTime)
+	if err := os.Chtimes(idle, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	// Open writers are left alone
+	writer, err := sink.Append(ctx, "stack/open_logs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	open := filepath.Join(root, "stack", "open_logs.txt")
+	if err := os.Chtimes(open, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Prune(ctx); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	for file, compressed := range map[string]bool{
+		idle: true,
+		open: false,
+		filepath.Join(root, "stack", "recent_logs.txt"): false,
+	} {
+		if _, err := os.Stat(file + compressedSuffix); (err == nil) != compressed {
+			t.Errorf("%s compressed: %v, want %v", file, err == nil, compressed)
+		}
+	}
+
+	if got := readLog(t, sink, "stack/idle_logs.txt", 2); got != "le\n" {
+		t.Errorf("compressed log from 2 = %q", got)
+	}
+	names, err := sink.List(ctx, "stack")
+	if err != nil || !slices.Contains(names, "stack/idle_logs.txt") {
+		t.Errorf("logs = %v, %v", names, err)
+	}
+
+	// Appending to a compressed log restores it
+	writeLog(t, sink, "stack/idle_logs.txt", "again\n")
+	if got := readLog(t, sink, "stack/idle_logs.txt", 0); got != "idle\nagain\n" {
+		t.Errorf("log = %q", got)
+	}
+	if _, err := os.Stat(idle + compressedSuffix); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the compressed log to be replaced, got %v", err)
+	}
+}
+
+func TestFileSinkRetention(t *testing.T) {
+	root := t.TempDir()
+	sink, err := NewFileSink(root, Retention{MaxAge: 24 * time.Hour}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLog(t, sink, "stack/old_logs.txt", "old\n")
+	writeLog(t, sink, "stack/new_logs.txt", "new\n")
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "stack", "old_logs.txt"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Prune(context.Background()); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	names, err := sink.List(context.Background(), "")
+	if err != nil || !slices.Equal(names, []string{"stack/new_logs.txt"}) {
+		t.Errorf("logs = %v, %v", names, err)
+	}
+}
+
+func newDatabaseSink(t *testing.T, retention Retention) *DatabaseSink {
+	t.Helper()
+	db, err := connection.Init(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewDatabaseSink(db, retention)
+}
+
+func TestDatabaseSinkOnSQLite(t *testing.T) {
+	testSink(t, newDatabaseSink(t, Retention{}))
+}
+
+func TestDatabaseSinkFlushesLargeAndPeriodicWrites(t *testing.T) {
+	sink := newDatabaseSink(t, Retention{})
+	writer, err := sink.Append(context.Background(), "stack/deploy_logs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	large := strings.Repeat("x", chunkSize)
+	if _, err := io.WriteString(writer, large); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLog(t, sink, "stack/deploy_logs.txt", 0); got != large {
+		t.Errorf("expected the large write to be stored at once, read %d bytes", len(got))
+	}
+
+	if _, err := io.WriteString(writer, "tail\n"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * flushInterval)
+	for readLog(t, sink, "stack/deploy_logs.txt", chunkSize) != "tail\n" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the write to be flushed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestDatabaseSinkRetention(t *testing.T) {
+	sink := newDatabaseSink(t, Retention{MaxAge: 24 * time.Hour})
+	writeLog(t, sink, "stack/old_logs.txt", "old\n")
+	writeLog(t, sink, "stack/new_logs.txt", "new\n")
+	err := sink.db.Model(&logChunk{}).
+		Where("name = ?", "stack/old_logs.txt").
+		Update("created_at", time.Now().Add(-48*time.Hour).UTC()).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Prune(context.Background()); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	names, err := sink.List(context.Background(), "")
+	if err != nil || !slices.Equal(names, []string{"stack/new_logs.txt"}) {
+		t.Errorf("logs = %v, %v", names, err)
+	}
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type DeploymentRepository struct {
+	store *Store
+}
+
+func NewDeploymentRepository(store *Store) *DeploymentRepository {
+	return &DeploymentRepository{store: store}
+}
+
+// UpdateDeploymentStatus moves the deployment to the status, it fails with entities.ErrInvalidStatusTransition when
+// the transition is not allowed from the current status
+func (r *DeploymentRepository) UpdateDeploymentStatus(
+	id string,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deployment := r.findDeployment(id)
+	if deployment == nil {
+		return nil
+	}
+	if !entities.CanDeploymentTransition(deployment.Status, status) {
+		return invalidTransitionError(entities.StatusSubjectDeployment, deployment.ID, string(deployment.Status), string(status))
+	}
+	r.setStatus(deployment, status, requestID)
+	return nil
+}
+
+// UpdateStatusesByStackId moves the deployments of the stack to the status, the deployments which cannot reach it
+// keep their status
+func (r *DeploymentRepository) UpdateStatusesByStackId(
+	stackID string,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, deployment := range r.store.deployments {
+		if belongsTo(deployment.StackID, stackID) && entities.CanDeploymentTransition(deployment.Status, status) {
+			r.setStatus(deployment, status, requestID)
+		}
+	}
+	return nil
+}
+
+func (r *DeploymentRepository) setStatus(
+	deployment *entities.DeploymentEntity,
+	status entities.DeploymentStatus,
+	requestID string,
+) {
+	r.store.recordTransition(
+		deployment.StackID, entities.StatusSubjectDeployment, deployment.ID,
+		string(deployment.Status), string(status), "", requestID,
+	)
+	deployment.Status = status
+}
+
+func (r *DeploymentRepository) GetDeploymentByID(id string) (*entities.DeploymentEntity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deployment := r.findDeployment(id)
+	if deployment == nil {
+		return nil, fmt.Errorf("deployment with id %s not found", id)
+	}
+	return cloneDeployment(deployment), nil
+}
+
+func (r *DeploymentRepository) GetDeploymentsByStackID(
+	stackID string,
+) ([]*entities.DeploymentEntity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deployments := make([]*entities.DeploymentEntity, 0)
+	for _, deployment := range r.store.deployments {
+		if belongsTo(deployment.StackID, stackID) {
+			deployments = append(deployments, cloneDeployment(deployment))
+		}
+	}
+	slices.SortStableFunc(deployments, func(a, b *entities.DeploymentEntity) int {
+		return a.Step - b.Step
+	})
+	return deployments, nil
+}
+
+func (r *DeploymentRepository) GetDeploymentStatus(id string) (entities.DeploymentStatus, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deployment := r.findDeployment(id)
+	if deployment == nil {
+		return entities.DeploymentStatusUnknown, fmt.Errorf("deployment with id %s not found", id)
+	}
+	return deployment.Status, nil
+}
+
+// findDeployment must be called with the lock of the store held
+func (r *DeploymentRepository) findDeployment(id string) *entities.DeploymentEntity {
+	for _, deployment := range r.store.deployments {
+		if deployment.ID.String() == id {
+			return deployment
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type IntegrationRepository struct {
+	store *Store
+}
+
+func NewIntegrationRepository(store *Store) *IntegrationRepository {
+	return &IntegrationRepository{store: store}
+}
+
+// CreateIntegration adds the integration to its stack read at the version, and increments the version of the stack. It
+// fails with entities.ErrVersionConflict when the stack changed since it was read, such as by a concurrent install.
+func (r *IntegrationRepository) CreateIntegration(
+	integration *entities.IntegrationEntity,
+	stackVersion int64,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if integration.StackID != nil {
+		stackID := integration.StackID.String()
+		stack := r.store.findStack(stackID)
+		if stack == nil || stack.Version != stackVersion {
+			return versionConflictError("stack", stackID, stackVersion)
+		}
+		stack.Version++
+	}
+
+	for _, existing := range r.store.integrations {
+		if existing.ID == integration.ID {
+			return fmt.Errorf("integration with id %s already exists", integration.ID)
+		}
+	}
+
+	created := cloneIntegration(integration)
+	created.Version = 0
+	r.store.integrations = append(r.store.integrations, created)
+	r.store.recordTransition(
+		integration.StackID, entities.StatusSubjectIntegration, integration.ID,
+		"", integration.Status, "created", integration.RequestID,
+	)
+	return nil
+}
+
+func (r *IntegrationRepository) UpdateIntegrationStatus(
+	id string,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	return r.updateIntegrationStatus(id, nil, status, func(*entities.IntegrationEntity) {}, "", requestID)
+}
+
+// UpdateIntegrationStatusAtVersion is UpdateIntegrationStatus for an integration read at the version, it fails with
+// entities.ErrVersionConflict when the integration changed since
+func (r *IntegrationRepository) UpdateIntegrationStatusAtVersion(
+	id string,
+	version int64,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	return r.updateIntegrationStatus(id, &version, status, func(*entities.IntegrationEntity) {}, "", requestID)
+}
+
+func (r *IntegrationRepository) UpdateIntegrationStatusWithReason(
+	id string,
+	status entities.DeploymentStatus,
+	reason string,
+	requestID string,
+) error {
+	return r.updateIntegrationStatus(id, nil, status, func(integration *entities.IntegrationEntity) {
+		integration.Reason = reason
+	}, reason, requestID)
+}
+
+func (r *IntegrationRepository) UpdateMetadataAfterInstalled(
+	id string,
+	metadata entities.IntegrationInfo,
+	requestID string,
+) error {
+	return r.updateIntegrationStatus(id, nil, entities.DeploymentStatusCompleted, func(integration *entities.IntegrationEntity) {
+		if metadata != nil {
+			integration.Info = slices.Clone(json.RawMessage(metadata))
+		}
+	}, "", requestID)
+}
+
+// updateIntegrationStatus moves the integration to the status along with the other updates, reason is the reason of
+// the transition
+func (r *IntegrationRepository) updateIntegrationStatus(
+	id string,
+	version *int64,
+	status entities.DeploymentStatus,
+	update func(integration *entities.IntegrationEntity),
+	reason string,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integration := r.findIntegration(id)
+	if integration == nil {
+		if version != nil {
+			return versionConflictError("integration", id, *version)
+		}
+		return nil
+	}
+	if !entities.CanIntegrationTransition(entities.DeploymentStatus(integration.Status), status) {
+		return invalidTransitionError(entities.StatusSubjectIntegration, integration.ID, integration.Status, string(status))
+	}
+	if version != nil && integration.Version != *version {
+		return versionConflictError("integration", id, *version)
+	}
+
+	update(integration)
+	r.setStatus(integration, status, reason, requestID)
+	return nil
+}
+
+func (r *IntegrationRepository) UpdateConfig(
+	id string,
+	config json.RawMessage,
+) error {
+	if config == nil {
+		return nil // No metadata to update
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if integration := r.findIntegration(id); integration != nil {
+		integration.Config = slices.Clone(config)
+		integration.Version++
+	}
+	return nil
+}
+
+func (r *IntegrationRepository) UpdateIntegrationsStatusByStackID(
+	stackID string,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, integration := range r.store.integrations {
+		if belongsTo(integration.StackID, stackID) &&
+			entities.CanIntegrationTransition(entities.DeploymentStatus(integration.Status), status) {
+			r.setStatus(integration, status, "", requestID)
+		}
+	}
+	return nil
+}
+
+func (r *IntegrationRepository) setStatus(
+	integration *entities.IntegrationEntity,
+	status entities.DeploymentStatus,
+	reason string,
+	requestID string,
+) {
+	r.store.recordTransition(
+		integration.StackID, entities.StatusSubjectIntegration, integration.ID,
+		integration.Status, string(status), reason, requestID,
+	)
+	integration.Status = string(status)
+	integration.Version++
+}
+
+func (r *IntegrationRepository) GetInstalledIntegration(
+	stackId string,
+	integrationType string,
+) (*entities.IntegrationEntity, error) {
+	return r.findFirst(func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, stackId) && integration.Type == integrationType &&
+			integration.Status == string(entities.DeploymentStatusCompleted)
+	}), nil
+}
+
+func (r *IntegrationRepository) GetActiveIntegrations(
+	stackId string,
+	integrationType string,
+) ([]*entities.IntegrationEntity, error) {
+	return r.findAll(func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, stackId) && integration.Type == integrationType &&
+			integration.Status != string(entities.DeploymentStatusTerminated)
+	}), nil
+}
+
+func (r *IntegrationRepository) GetIntegration(
+	stackId string,
+	integrationType string,
+) (*entities.IntegrationEntity, error) {
+	return r.findFirst(func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, stackId) && integration.Type == integrationType
+	}), nil
+}
+
+func (r *IntegrationRepository) GetIntegrationById(
+	id string,
+) (*entities.IntegrationEntity, error) {
+	return r.findFirst(func(integration *entities.IntegrationEntity) bool {
+		return integration.ID.String() == id
+	}), nil
+}
+
+func (r *IntegrationRepository) GetIntegrationsByStackID(
+	stackID string,
+) ([]*entities.IntegrationEntity, error) {
+	return r.findAll(func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, stackID)
+	}), nil
+}
+
+func (r *IntegrationRepository) GetActiveIntegrationsByStackID(
+	stackId string,
+) ([]*entities.IntegrationEntity, error) {
+	return r.findAll(func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, stackId) &&
+			integration.Status != string(entities.DeploymentStatusTerminated)
+	}), nil
+}
+
+// findIntegration must be called with the lock of the store held
+func (r *IntegrationRepository) findIntegration(id string) *entities.IntegrationEntity {
+	for _, integration := range r.store.integrations {
+		if integration.ID.String() == id {
+			return integration
+		}
+	}
+	return nil
+}
+
+// findFirst returns the first integration created matching, nil if there is none
+func (r *IntegrationRepository) findFirst(match func(*entities.IntegrationEntity) bool) *entities.IntegrationEntity {
+	integrations := r.findAll(match)
+	if len(integrations) == 0 {
+		return nil
+	}
+	return integrations[0]
+}
+
+// findAll returns the integrations matching in the order they were created
+func (r *IntegrationRepository) findAll(match func(*entities.IntegrationEntity) bool) []*entities.IntegrationEntity {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integrations := make([]*entities.IntegrationEntity, 0)
+	for _, integration := range r.store.integrations {
+		if match(integration) {
+			integrations = append(integrations, cloneIntegration(integration))
+		}
+	}
+	return integrations
+}
@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type StackRepository struct {
+	store *Store
+}
+
+func NewStackRepository(store *Store) *StackRepository {
+	return &StackRepository{store: store}
+}
+
+func (r *StackRepository) CreateStackByTx(
+	stack *entities.StackEntity,
+	deployments []*entities.DeploymentEntity,
+	integrations []*entities.IntegrationEntity,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.stacks {
+		if existing.ID == stack.ID {
+			return fmt.Errorf("stack with id %s already exists", stack.ID)
+		}
+	}
+
+	r.store.stacks = append(r.store.stacks, cloneStack(stack))
+	r.store.recordTransition(&stack.ID, entities.StatusSubjectStack, stack.ID, "", string(stack.Status), "created", requestID)
+	r.store.deployments = append(r.store.deployments, cloneAll(deployments, cloneDeployment)...)
+	r.store.integrations = append(r.store.integrations, cloneAll(integrations, cloneIntegration)...)
+	return nil
+}
+
+// ArchiveStack hides the stack from the queries unless they include the archived stacks. Its deployments and
+// integrations are removed.
+func (r *StackRepository) ArchiveStack(
+	id string,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil {
+		return fmt.Errorf("stack with id %s not found", id)
+	}
+	archivedAt := time.Now()
+	stack.ArchivedAt = &archivedAt
+	r.store.deployments = slices.DeleteFunc(r.store.deployments, func(deployment *entities.DeploymentEntity) bool {
+		return belongsTo(deployment.StackID, id)
+	})
+	r.store.integrations = slices.DeleteFunc(r.store.integrations, func(integration *entities.IntegrationEntity) bool {
+		return belongsTo(integration.StackID, id)
+	})
+	return nil
+}
+
+// UpdateStatus moves the stack to the status, it fails with entities.ErrInvalidStatusTransition when the transition is
+// not allowed from the current status
+func (r *StackRepository) UpdateStatus(
+	id string,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	return r.updateStatus(id, nil, status, reason, requestID)
+}
+
+// UpdateStatusAtVersion is UpdateStatus for a stack read at the version, it fails with entities.ErrVersionConflict when
+// the stack changed since
+func (r *StackRepository) UpdateStatusAtVersion(
+	id string,
+	version int64,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	return r.updateStatus(id, &version, status, reason, requestID)
+}
+
+func (r *StackRepository) updateStatus(
+	id string,
+	version *int64,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil {
+		if version != nil {
+			return versionConflictError("stack", id, *version)
+		}
+		return nil
+	}
+	if !stack.Status.CanTransitionTo(status) {
+		return invalidTransitionError(entities.StatusSubjectStack, stack.ID, string(stack.Status), string(status))
+	}
+	if version != nil && stack.Version != *version {
+		return versionConflictError("stack", id, *version)
+	}
+
+	r.store.recordTransition(&stack.ID, entities.StatusSubjectStack, stack.ID, string(stack.Status), string(status), reason, requestID)
+	stack.Status = status
+	stack.Version++
+	return nil
+}
+
+func (r *StackRepository) UpdateMetadata(
+	id string,
+	metadata *entities.StackMetadata,
+) error {
+	if metadata == nil {
+		return fmt.Errorf("metadata cannot be nil")
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if stack := r.store.findStack(id); stack != nil {
+		stack.Metadata = clonePointer(metadata)
+		stack.Version++
+	}
+	return nil
+}
+
+// UpdateConfig replaces the config of the stack read at the version, it fails with entities.ErrVersionConflict when the
+// stack changed since
+func (r *StackRepository) UpdateConfig(
+	id string,
+	version int64,
+	config json.RawMessage,
+	reason string,
+	requestID string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil {
+		return fmt.Errorf("stack with id %s not found", id)
+	}
+	if stack.Version != version {
+		return versionConflictError("stack", id, version)
+	}
+	stack.Config = slices.Clone(config)
+	stack.Version++
+	return nil
+}
+
+// GetStackByID returns the stack, nil if there is none
+func (r *StackRepository) GetStackByID(
+	id string,
+) (*entities.StackEntity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil {
+		return nil, nil
+	}
+	return cloneStack(stack), nil
+}
+
+// StackExists reports whether a stack has the id, archived stacks included
+func (r *StackRepository) StackExists(
+	id string,
+) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, stack := range r.store.stacks {
+		if stack.ID.String() == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetStackByDeploymentPath returns the stack deployed in the directory, nil if there is none
+func (r *StackRepository) GetStackByDeploymentPath(
+	deploymentPath string,
+) (*entities.StackEntity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, stack := range r.store.stacks {
+		if stack.DeploymentPath == deploymentPath && stack.ArchivedAt == nil {
+			return cloneStack(stack), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAllStacks returns the stacks carrying every label of the selector, an empty selector matches all stacks.
+// Archived stacks are only returned with includeArchived.
+func (r *StackRepository) GetAllStacks(
+	labelSelector map[string]string,
+	includeArchived bool,
+) ([]*entities.StackEntity, error) {
+	return r.findStacks(labelSelector, includeArchived, func(*entities.StackEntity) bool { return true }), nil
+}
+
+func (r *StackRepository) GetStacksByProjectIDs(
+	projectIDs []string,
+	labelSelector map[string]string,
+	includeArchived bool,
+) ([]*entities.StackEntity, error) {
+	return r.findStacks(labelSelector, includeArchived, func(stack *entities.StackEntity) bool {
+		return stack.ProjectID != nil && slices.Contains(projectIDs, stack.ProjectID.String())
+	}), nil
+}
+
+func (r *StackRepository) findStacks(
+	labelSelector map[string]string,
+	includeArchived bool,
+	match func(*entities.StackEntity) bool,
+) []*entities.StackEntity {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stacks := make([]*entities.StackEntity, 0)
+	for _, stack := range r.store.stacks {
+		if stack.ArchivedAt != nil && !includeArchived {
+			continue
+		}
+		if !hasLabels(stack.Labels, labelSelector) || !match(stack) {
+			continue
+		}
+		stacks = append(stacks, cloneStack(stack))
+	}
+	return stacks
+}
+
+func hasLabels(labels map[string]string, labelSelector map[string]string) bool {
+	for key, value := range labelSelector {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateDetails replaces the name, description and labels of the stack read at the version, it fails with
+// entities.ErrVersionConflict when the stack changed since
+func (r *StackRepository) UpdateDetails(
+	id string,
+	version int64,
+	name string,
+	description string,
+	labels map[string]string,
+) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil || stack.Version != version {
+		return versionConflictError("stack", id, version)
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	stack.Name = name
+	stack.Description = description
+	stack.Labels = labels
+	stack.Version++
+	return nil
+}
+
+func (r *StackRepository) GetStackStatus(
+	id string,
+) (entities.StackStatus, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stack := r.store.findStack(id)
+	if stack == nil {
+		return entities.StackStatusUnknown, fmt.Errorf("stack with id %s not found", id)
+	}
+	return stack.Status, nil
+}
+
+// GetStatusTransitions returns the history of the stack and of its deployments and integrations, oldest first
+func (r *StackRepository) GetStatusTransitions(
+	id string,
+) ([]*entities.StatusTransitionEntity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	transitions := make([]*entities.StatusTransitionEntity, 0)
+	for _, transition := range r.store.transitions {
+		if transition.StackID.String() == id {
+			clone := *transition
+			transitions = append(transitions, &clone)
+		}
+	}
+	return transitions, nil
+}
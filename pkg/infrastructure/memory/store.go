@@ -0,0 +1,149 @@
+// Package memory implements the repositories of the services in memory, for the tests of the services to run without
+// a database. The repositories enforce the status transitions and the versions like the Postgres ones, but keep no
+// audit trail, no config revisions and no events.
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+// Store holds the rows shared by the repositories created from it
+type Store struct {
+	mu           sync.Mutex
+	stacks       []*entities.StackEntity
+	deployments  []*entities.DeploymentEntity
+	integrations []*entities.IntegrationEntity
+	transitions  []*entities.StatusTransitionEntity
+	// work serializes the units of work
+	work sync.Mutex
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+type snapshot struct {
+	stacks       []*entities.StackEntity
+	deployments  []*entities.DeploymentEntity
+	integrations []*entities.IntegrationEntity
+	transitions  []*entities.StatusTransitionEntity
+}
+
+func (s *Store) snapshot() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &snapshot{
+		stacks:       cloneAll(s.stacks, cloneStack),
+		deployments:  cloneAll(s.deployments, cloneDeployment),
+		integrations: cloneAll(s.integrations, cloneIntegration),
+		transitions:  slices.Clone(s.transitions),
+	}
+}
+
+func (s *Store) restore(snapshot *snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stacks = snapshot.stacks
+	s.deployments = snapshot.deployments
+	s.integrations = snapshot.integrations
+	s.transitions = snapshot.transitions
+}
+
+// findStack returns the stack, nil when it does not exist or is archived. It must be called with the lock held.
+func (s *Store) findStack(id string) *entities.StackEntity {
+	for _, stack := range s.stacks {
+		if stack.ID.String() == id && stack.ArchivedAt == nil {
+			return stack
+		}
+	}
+	return nil
+}
+
+// recordTransition appends the transition to the history, the transitions keeping the same status are skipped. It
+// must be called with the lock held.
+func (s *Store) recordTransition(
+	stackID *uuid.UUID,
+	subject entities.StatusSubject,
+	subjectID uuid.UUID,
+	from string,
+	to string,
+	reason string,
+	requestID string,
+) {
+	if stackID == nil || from == to {
+		return
+	}
+	s.transitions = append(s.transitions, &entities.StatusTransitionEntity{
+		ID:         uuid.New(),
+		StackID:    *stackID,
+		Subject:    subject,
+		SubjectID:  subjectID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		RequestID:  requestID,
+		CreatedAt:  time.Now(),
+	})
+}
+
+func invalidTransitionError(subject entities.StatusSubject, id uuid.UUID, from string, to string) error {
+	return fmt.Errorf("%w: %s %s cannot move from %s to %s", entities.ErrInvalidStatusTransition, subject, id, from, to)
+}
+
+func versionConflictError(table string, id string, version int64) error {
+	return fmt.Errorf("%w: %s %s is no longer at version %d", entities.ErrVersionConflict, table, id, version)
+}
+
+// The rows are copied in and out of the store, so that the entities held by the callers never alias them
+
+func cloneAll[T any](rows []*T, clone func(*T) *T) []*T {
+	clones := make([]*T, len(rows))
+	for i, row := range rows {
+		clones[i] = clone(row)
+	}
+	return clones
+}
+
+func cloneStack(stack *entities.StackEntity) *entities.StackEntity {
+	clone := *stack
+	clone.ProjectID = clonePointer(stack.ProjectID)
+	clone.Labels = maps.Clone(stack.Labels)
+	clone.Config = slices.Clone(stack.Config)
+	clone.Metadata = clonePointer(stack.Metadata)
+	clone.ArchivedAt = clonePointer(stack.ArchivedAt)
+	return &clone
+}
+
+func cloneDeployment(deployment *entities.DeploymentEntity) *entities.DeploymentEntity {
+	clone := *deployment
+	clone.StackID = clonePointer(deployment.StackID)
+	clone.Config = slices.Clone(deployment.Config)
+	return &clone
+}
+
+func cloneIntegration(integration *entities.IntegrationEntity) *entities.IntegrationEntity {
+	clone := *integration
+	clone.StackID = clonePointer(integration.StackID)
+	clone.Config = slices.Clone(integration.Config)
+	clone.Info = slices.Clone(integration.Info)
+	return &clone
+}
+
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
+
+func belongsTo(stackID *uuid.UUID, id string) bool {
+	return stackID != nil && stackID.String() == id
+}
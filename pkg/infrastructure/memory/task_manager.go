@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type runningTask struct {
+	cancel context.CancelFunc
+}
+
+// TaskManager runs every task in its own goroutine as soon as it is added, without a queue. Wait returns once all the
+// tasks added are done, so that the tests can check what the tasks did.
+type TaskManager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	tasks  map[string]*runningTask
+}
+
+func NewTaskManager() *TaskManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TaskManager{
+		ctx:    ctx,
+		cancel: cancel,
+		tasks:  make(map[string]*runningTask),
+	}
+}
+
+// Start does nothing, the tasks are started when they are added
+func (tm *TaskManager) Start() {}
+
+// AddTask runs the task with the lifetime of the task manager, keeping the request id of the parent context
+func (tm *TaskManager) AddTask(parent context.Context, id string, task entities.Task) {
+	ctx, cancel := context.WithCancel(logger.WithRequestID(tm.ctx, logger.RequestIDFromContext(parent)))
+	running := &runningTask{cancel: cancel}
+
+	tm.mu.Lock()
+	tm.tasks[id] = running
+	tm.mu.Unlock()
+
+	tm.wg.Add(1)
+	go func() {
+		defer tm.wg.Done()
+		defer cancel()
+		task(ctx)
+
+		tm.mu.Lock()
+		if tm.tasks[id] == running {
+			delete(tm.tasks, id)
+		}
+		tm.mu.Unlock()
+	}()
+}
+
+// StopTask cancels the context of the task, if it is still running
+func (tm *TaskManager) StopTask(id string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if running, exists := tm.tasks[id]; exists {
+		running.cancel()
+		delete(tm.tasks, id)
+	}
+}
+
+// Stop cancels the running tasks and waits for them to return
+func (tm *TaskManager) Stop() {
+	tm.cancel()
+	tm.wg.Wait()
+}
+
+// Wait blocks until all the tasks added, including those added by the tasks themselves, are done
+func (tm *TaskManager) Wait() {
+	tm.wg.Wait()
+}
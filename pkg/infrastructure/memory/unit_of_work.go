@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/tokamak-network/trh-backend/pkg/services"
+)
+
+type UnitOfWork struct {
+	store *Store
+}
+
+func NewUnitOfWork(store *Store) *UnitOfWork {
+	return &UnitOfWork{store: store}
+}
+
+// Do runs fn with the repositories of the store and restores the store as it was before when fn fails. The units of
+// work are serialized, but are not isolated from the updates made outside of them meanwhile.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(repos services.Repositories) error) error {
+	u.store.work.Lock()
+	defer u.store.work.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	snapshot := u.store.snapshot()
+	err := fn(services.Repositories{
+		Deployments:  NewDeploymentRepository(u.store),
+		Stacks:       NewStackRepository(u.store),
+		Integrations: NewIntegrationRepository(u.store),
+	})
+	if err != nil {
+		u.store.restore(snapshot)
+		return err
+	}
+	return nil
+}
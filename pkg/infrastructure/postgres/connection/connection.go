@@ -2,40 +2,75 @@ package connection
 
 import (
 	"fmt"
+	"net/url"
 
+	"github.com/glebarez/sqlite"
+	"github.com/tokamak-network/trh-backend/internal/config"
 	"github.com/tokamak-network/trh-backend/internal/logger"
-	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	gormLogger "gorm.io/gorm/logger"
 )
 
-func Init(
-	postgresUser string,
-	postgresHost string,
-	postgresPassword string,
-	postgresDatabase string,
-	postgresPort string,
-) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s TimeZone=UTC",
-		postgresHost,
-		postgresUser,
-		postgresPassword,
-		postgresDatabase,
-		postgresPort)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+// Init connects to the database of the configured driver, with the pool settings of the configuration. The schema is
+// migrated by the migrate command, see the migrations package.
+func Init(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DatabaseDriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.Path))
+	default:
+		dialector = postgres.Open(postgresDSN(cfg))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger.Default.LogMode(gormLogger.Warn),
 	})
 	if err != nil {
-		logger.Errorf("Failed to connect to postgres database", "err", err)
+		logger.Errorf("Failed to connect to the %s database: %s", cfg.Driver, err)
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&schemas.Stack{}, &schemas.Deployment{}, &schemas.Integration{})
+	sqlDB, err := db.DB()
 	if err != nil {
-		logger.Errorf("Failed to auto migrate DB schemas", "err", err.Error())
 		return nil, err
 	}
+	if cfg.Driver == config.DatabaseDriverSQLite {
+		// SQLite has a single writer, the statements share one connection rather than waiting on the lock of the
+		// database file. The connection is kept open, an in-memory database lives as long as it.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		return db, nil
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	return db, nil
 }
+
+func postgresDSN(cfg config.DatabaseConfig) string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s TimeZone=UTC",
+		cfg.Host,
+		cfg.User,
+		cfg.Password,
+		cfg.Name,
+		cfg.Port)
+	if cfg.SSLMode != "" {
+		dsn += " sslmode=" + cfg.SSLMode
+	}
+	return dsn
+}
+
+// sqliteDSN enforces the foreign keys like Postgres, and journals to a write-ahead log so that the file is readable
+// while written
+func sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	if path != ":memory:" {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+	return "file:" + path + "?" + query.Encode()
+}
@@ -0,0 +1,268 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql
+var files embed.FS
+
+// The migrations of each dialect are in the sql/<dialect> directory, named after the gorm dialector
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+// lockID is the key of the advisory lock serializing the migrations of the replicas booting together
+const lockID int64 = 0x7472685f6d6967
+
+const versionTable = "schema_migrations"
+
+var fileNameRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migration is a version of the schema, read from the sql/<dialect>/<version>_<name>.up.sql and .down.sql files. The
+// dialects share the versions, a migration is written for each of them.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a row of the version table
+type AppliedMigration struct {
+	Version   int64     `gorm:"primaryKey;column:version"`
+	Name      string    `gorm:"column:name;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (AppliedMigration) TableName() string {
+	return versionTable
+}
+
+// Status compares the migrations applied to the database with the migrations embedded in the binary
+type Status struct {
+	Applied []AppliedMigration
+	Pending []Migration
+	// Unknown are the applied versions missing from the binary, applied by a newer release
+	Unknown []AppliedMigration
+}
+
+// Load returns the embedded migrations of the dialect ordered by version
+func Load(dialect string) ([]Migration, error) {
+	dir := "sql/" + dialect
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for the %s dialect: %w", dialect, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileNameRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(files, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// GetStatus returns the applied and pending migrations, without changing the database
+func GetStatus(ctx context.Context, db *gorm.DB) (*Status, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+
+	var applied []AppliedMigration
+	if db.Migrator().HasTable(versionTable) {
+		if err := db.Order("version asc").Find(&applied).Error; err != nil {
+			return nil, err
+		}
+	}
+	return newStatus(migrations, applied), nil
+}
+
+// Check returns ErrSchemaBehind when migrations embedded in the binary are not applied to the database
+func Check(ctx context.Context, db *gorm.DB) (*Status, error) {
+	status, err := GetStatus(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Pending) > 0 {
+		return status, fmt.Errorf("%w, %d migrations are pending from version %d, run the migrate command",
+			ErrSchemaBehind, len(status.Pending), status.Pending[0].Version)
+	}
+	return status, nil
+}
+
+// Up applies the pending migrations in order, each in its own transaction. The replicas migrating a Postgres database
+// at the same time wait for each other on an advisory lock.
+func Up(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *gorm.DB) error {
+		applied, err := getApplied(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range newStatus(migrations, applied).Pending {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&AppliedMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, in reverse order
+func Down(ctx context.Context, db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *gorm.DB) error {
+		applied, err := getApplied(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+			migration, ok := byVersion[applied[i].Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is not known to this release", applied[i].Version, applied[i].Name)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&AppliedMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// withLock runs fn on a single connection holding the migration lock, once the version table exists. A SQLite
+// database has no replicas to wait for, its connection is not shared.
+func withLock(ctx context.Context, db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		timeType := "DATETIME"
+		if conn.Dialector.Name() == dialectPostgres {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+				return fmt.Errorf("failed to acquire the migration lock: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+			timeType = "timestamptz"
+		}
+
+		err := conn.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+			"version" bigint PRIMARY KEY,
+			"name" text NOT NULL,
+			"applied_at" ` + timeType + ` NOT NULL
+		)`).Error
+		if err != nil {
+			return fmt.Errorf("failed to create the version table: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+func getApplied(db *gorm.DB) ([]AppliedMigration, error) {
+	var applied []AppliedMigration
+	if err := db.Order("version asc").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func newStatus(migrations []Migration, applied []AppliedMigration) *Status {
+	known := make(map[int64]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	appliedVersions := make(map[int64]bool, len(applied))
+	status := &Status{Applied: applied}
+	for _, migration := range applied {
+		appliedVersions[migration.Version] = true
+		if !known[migration.Version] {
+			status.Unknown = append(status.Unknown, migration)
+		}
+	}
+	for _, migration := range migrations {
+		if !appliedVersions[migration.Version] {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status
+}
@@ -0,0 +1,249 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/connection"
+	"gorm.io/datatypes"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLoad(t *testing.T) {
+	for _, dialect := range []string{dialectPostgres, dialectSQLite} {
+		migrations, err := Load(dialect)
+		if err != nil {
+			t.Fatalf("Load %s: %v", dialect, err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Fatalf("Load %s returned no baseline migration: %+v", dialect, migrations)
+		}
+		for i, migration := range migrations {
+			if i > 0 && migration.Version <= migrations[i-1].Version {
+				t.Errorf("%s migration %d is not ordered after %d", dialect, migration.Version, migrations[i-1].Version)
+			}
+			if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+				t.Errorf("%s migration %d_%s has an empty file", dialect, migration.Version, migration.Name)
+			}
+		}
+	}
+}
+
+// The dialects must share the versions, a database would otherwise miss a change made to the other one
+func TestDialectsShareVersions(t *testing.T) {
+	postgres, err := Load(dialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := Load(dialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("%d postgres migrations but %d sqlite migrations", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("postgres migration %d_%s differs from sqlite migration %d_%s",
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestUpAndDownSQLite(t *testing.T) {
+	db, err := connection.Init(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	applied, err := Up(ctx, db)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := Check(ctx, db); err != nil {
+		t.Fatalf("Check after applying %d migrations: %v", len(applied), err)
+	}
+	if !db.Migrator().HasColumn("stacks", "version") {
+		t.Error("the last migration is not applied")
+	}
+
+	reverted, err := Down(ctx, db, len(applied))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(reverted) != len(applied) || db.Migrator().HasTable("stacks") {
+		t.Errorf("reverted %d of %d migrations", len(reverted), len(applied))
+	}
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+}
+
+// The tables as the earlier releases migrated them with AutoMigrate
+type legacyStack struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	Name           string         `gorm:"column:name"`
+	Status         string         `gorm:"not null;column:status"`
+	Reason         string         `gorm:"column:reason"`
+	Network        string         `gorm:"not null;column:network"`
+	DeploymentPath string         `gorm:"not null;column:deployment_path"`
+	Config         datatypes.JSON `gorm:"type:jsonb;not null;column:config"`
+	Metadata       datatypes.JSON `gorm:"type:jsonb;column:metadata"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime;column:updated_at"`
+	DeletedAt      time.Time      `gorm:"autoUpdateTime;column:deleted_at"`
+}
+
+func (legacyStack) TableName() string {
+	return "stacks"
+}
+
+type legacyDeployment struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	StackID   *uuid.UUID     `gorm:"column:stack_id;nullable;references:ID"`
+	Stack     legacyStack    `gorm:"foreignKey:StackID"`
+	Step      int            `gorm:"column:step;not null"`
+	Status    string         `gorm:"column:status;not null"`
+	Config    datatypes.JSON `gorm:"type:jsonb;not null;column:config"`
+	LogPath   string         `gorm:"column:log_path"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime;column:updated_at"`
+	DeletedAt time.Time      `gorm:"autoUpdateTime;column:deleted_at"`
+}
+
+func (legacyDeployment) TableName() string {
+	return "deployments"
+}
+
+type legacyIntegration struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	StackID   *uuid.UUID     `gorm:"column:stack_id;not null;references:ID"`
+	Stack     *legacyStack   `gorm:"foreignKey:StackID"`
+	Type      string         `gorm:"column:type;not null"`
+	LogPath   string         `gorm:"column:log_path"`
+	Status    string         `gorm:"column:status;not null"`
+	Config    datatypes.JSON `gorm:"column:config;type:jsonb;default:null"`
+	Info      datatypes.JSON `gorm:"column:info;type:jsonb;default:null"`
+	Reason    string         `gorm:"column:reason;default:null"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime;column:updated_at"`
+	DeletedAt time.Time      `gorm:"autoUpdateTime;column:deleted_at"`
+}
+
+func (legacyIntegration) TableName() string {
+	return "integrations"
+}
+
+// newPostgresSchema returns a connection to a new schema of the database of TRH_TEST_POSTGRES_DSN, dropped after the
+// test. The test is skipped without a database.
+func newPostgresSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TRH_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TRH_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The search path is set on the single connection of the pool
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	schema := fmt.Sprintf("trh_test_%d", time.Now().UnixNano())
+	if err := db.Exec(`CREATE SCHEMA "` + schema + `"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DROP SCHEMA "` + schema + `" CASCADE`) })
+	if err := db.Exec(`SET search_path TO "` + schema + `"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUpgradeAutoMigratedPostgres(t *testing.T) {
+	db := newPostgresSchema(t)
+	ctx := context.Background()
+
+	if err := db.AutoMigrate(&legacyStack{}, &legacyDeployment{}, &legacyIntegration{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	stack := legacyStack{
+		Name:           "legacy",
+		Status:         "Deployed",
+		Network:        "Testnet",
+		DeploymentPath: "storage/deployments/Thanos/Testnet/legacy",
+		Config:         datatypes.JSON(`{}`),
+	}
+	if err := db.Create(&stack).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := db.Create(&legacyDeployment{StackID: &stack.ID, Step: 1, Status: "Completed", Config: datatypes.JSON(`{}`)}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := Check(ctx, db); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	for table, columns := range map[string][]string{
+		"stacks":       {"project_id", "description", "labels", "version"},
+		"deployments":  {"request_id"},
+		"integrations": {"request_id", "version"},
+	} {
+		for _, column := range columns {
+			if !db.Migrator().HasColumn(table, column) {
+				t.Errorf("column %s.%s is missing", table, column)
+			}
+		}
+	}
+	if !db.Migrator().HasIndex("stacks", "idx_stacks_labels") {
+		t.Error("index idx_stacks_labels is missing")
+	}
+	var constraints int64
+	err = db.Raw(`SELECT COUNT(*) FROM pg_constraint WHERE conname = 'fk_stacks_project' AND conrelid = '"stacks"'::regclass`).
+		Scan(&constraints).Error
+	if err != nil || constraints != 1 {
+		t.Errorf("found %d fk_stacks_project constraints, %v", constraints, err)
+	}
+
+	// The rows of the earlier release are kept, and not archived by the deleted_at it set on every update
+	var labels string
+	err = db.Raw(`SELECT labels::text FROM "stacks" WHERE id = ? AND deleted_at IS NULL`, stack.ID).Scan(&labels).Error
+	if err != nil || labels != "{}" {
+		t.Errorf("stack labels = %q, %v, want an active stack without labels", labels, err)
+	}
+	var deployments int64
+	if err := db.Table("deployments").Where("deleted_at IS NULL").Count(&deployments).Error; err != nil || deployments != 1 {
+		t.Errorf("%d active deployments, %v, want 1", deployments, err)
+	}
+}
+
+func TestNewStatus(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "init"}, {Version: 2, Name: "second"}, {Version: 3, Name: "third"}}
+	applied := []AppliedMigration{{Version: 1, Name: "init"}, {Version: 3, Name: "third"}, {Version: 4, Name: "newer"}}
+
+	status := newStatus(migrations, applied)
+	if len(status.Pending) != 1 || status.Pending[0].Version != 2 {
+		t.Errorf("Pending = %+v, want version 2", status.Pending)
+	}
+	if len(status.Unknown) != 1 || status.Unknown[0].Version != 4 {
+		t.Errorf("Unknown = %+v, want version 4", status.Unknown)
+	}
+}
@@ -0,0 +1,216 @@
+-- Baseline schema. The tables are created only if missing, so that the databases created by the former
+-- AutoMigrate adopt this version. Their stacks, deployments and integrations tables get the columns and the foreign
+-- key added since, before the indexes reading them are created.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "name" text NOT NULL,
+    "email" text NOT NULL,
+    "api_key_hash" text NOT NULL,
+    "is_admin" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_api_key_hash" ON "users" ("api_key_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_projects_name" ON "projects" ("name");
+
+CREATE TABLE IF NOT EXISTS "project_members" (
+    "project_id" uuid,
+    "user_id" uuid,
+    "role" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("project_id","user_id"),
+    CONSTRAINT "fk_project_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_project_members_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_project_members_user_id" ON "project_members" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "stacks" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "project_id" uuid,
+    "name" text,
+    "description" text,
+    "labels" JSONB NOT NULL DEFAULT '{}',
+    "status" text NOT NULL,
+    "reason" text,
+    "network" text NOT NULL,
+    "deployment_path" text NOT NULL,
+    "config" JSONB NOT NULL,
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_stacks_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id")
+);
+ALTER TABLE "stacks" ADD COLUMN IF NOT EXISTS "project_id" uuid;
+ALTER TABLE "stacks" ADD COLUMN IF NOT EXISTS "description" text;
+ALTER TABLE "stacks" ADD COLUMN IF NOT EXISTS "labels" JSONB NOT NULL DEFAULT '{}';
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'fk_stacks_project' AND conrelid = '"stacks"'::regclass
+    ) THEN
+        ALTER TABLE "stacks" ADD CONSTRAINT "fk_stacks_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id");
+    END IF;
+END
+$$;
+CREATE INDEX IF NOT EXISTS "idx_stacks_labels" ON "stacks" USING gin("labels");
+CREATE INDEX IF NOT EXISTS "idx_stacks_project_id" ON "stacks" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "stack_config_revisions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "stack_id" uuid NOT NULL,
+    "revision" bigint NOT NULL,
+    "config" JSONB NOT NULL,
+    "reason" text,
+    "request_id" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_stack_config_revisions_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_stack_config_revisions_revision" ON "stack_config_revisions" ("stack_id","revision");
+
+CREATE TABLE IF NOT EXISTS "deployments" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "stack_id" uuid,
+    "step" bigint NOT NULL,
+    "status" text NOT NULL,
+    "config" JSONB NOT NULL,
+    "log_path" text,
+    "request_id" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_deployments_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id")
+);
+ALTER TABLE "deployments" ADD COLUMN IF NOT EXISTS "request_id" text;
+CREATE INDEX IF NOT EXISTS "idx_deployments_request_id" ON "deployments" ("request_id");
+
+CREATE TABLE IF NOT EXISTS "integrations" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "stack_id" uuid NOT NULL,
+    "type" text NOT NULL,
+    "log_path" text,
+    "status" text NOT NULL,
+    "config" JSONB DEFAULT null,
+    "info" JSONB DEFAULT null,
+    "reason" text DEFAULT null,
+    "request_id" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_integrations_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id")
+);
+ALTER TABLE "integrations" ADD COLUMN IF NOT EXISTS "request_id" text;
+CREATE INDEX IF NOT EXISTS "idx_integrations_request_id" ON "integrations" ("request_id");
+
+CREATE TABLE IF NOT EXISTS "credentials" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "project_id" uuid NOT NULL,
+    "name" text NOT NULL,
+    "provider" text NOT NULL,
+    "config" JSONB NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credentials_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_credentials_project_name" ON "credentials" ("project_id","name");
+
+CREATE TABLE IF NOT EXISTS "operator_keys" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "project_id" uuid NOT NULL,
+    "name" text NOT NULL,
+    "address" text NOT NULL,
+    "origin" text NOT NULL,
+    "keystore" JSONB NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_operator_keys_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_operator_keys_address" ON "operator_keys" ("address");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_operator_keys_project_name" ON "operator_keys" ("project_id","name");
+
+CREATE TABLE IF NOT EXISTS "webhook_subscriptions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "project_id" uuid NOT NULL,
+    "url" text NOT NULL,
+    "events" JSONB NOT NULL,
+    "secret" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_subscriptions_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_project_id" ON "webhook_subscriptions" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_events" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "type" text NOT NULL,
+    "project_id" uuid,
+    "stack_id" uuid,
+    "data" JSONB NOT NULL,
+    "dispatched_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_events_dispatched_at" ON "webhook_events" ("dispatched_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "subscription_id" uuid NOT NULL,
+    "event_id" uuid NOT NULL,
+    "event_type" text NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "response_status" bigint,
+    "error" text,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_subscription" FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_webhook_deliveries_event" FOREIGN KEY ("event_id") REFERENCES "webhook_events"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_pending" ON "webhook_deliveries" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_subscription_id" ON "webhook_deliveries" ("subscription_id");
+
+CREATE TABLE IF NOT EXISTS "audit_records" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "source" text NOT NULL,
+    "actor_id" uuid,
+    "actor" text NOT NULL,
+    "source_ip" text,
+    "project_id" uuid,
+    "stack_id" uuid,
+    "integration_id" uuid,
+    "action" text NOT NULL,
+    "before_status" text,
+    "after_status" text,
+    "result" text,
+    "reason" text,
+    "request_id" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_records_created_at" ON "audit_records" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_request_id" ON "audit_records" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_stack_id" ON "audit_records" ("stack_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_project_id" ON "audit_records" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_actor_id" ON "audit_records" ("actor_id");
@@ -0,0 +1,8 @@
+-- deleted_at was set on every update, it now marks the archived rows
+UPDATE "stacks" SET "deleted_at" = NULL;
+UPDATE "deployments" SET "deleted_at" = NULL;
+UPDATE "integrations" SET "deleted_at" = NULL;
+
+CREATE INDEX IF NOT EXISTS "idx_stacks_deleted_at" ON "stacks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_deployments_deleted_at" ON "deployments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_integrations_deleted_at" ON "integrations" ("deleted_at");
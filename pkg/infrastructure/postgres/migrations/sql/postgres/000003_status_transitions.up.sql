@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "status_transitions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "stack_id" uuid NOT NULL,
+    "subject" text NOT NULL,
+    "subject_id" uuid NOT NULL,
+    "from_status" text,
+    "to_status" text NOT NULL,
+    "reason" text,
+    "request_id" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_status_transitions_stack" ON "status_transitions" ("stack_id","created_at");
@@ -0,0 +1,2 @@
+ALTER TABLE "stacks" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "integrations" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 0;
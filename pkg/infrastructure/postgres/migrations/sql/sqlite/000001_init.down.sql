@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "audit_records";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhook_events";
+DROP TABLE IF EXISTS "webhook_subscriptions";
+DROP TABLE IF EXISTS "operator_keys";
+DROP TABLE IF EXISTS "credentials";
+DROP TABLE IF EXISTS "integrations";
+DROP TABLE IF EXISTS "deployments";
+DROP TABLE IF EXISTS "stack_config_revisions";
+DROP TABLE IF EXISTS "stacks";
+DROP TABLE IF EXISTS "project_members";
+DROP TABLE IF EXISTS "projects";
+DROP TABLE IF EXISTS "users";
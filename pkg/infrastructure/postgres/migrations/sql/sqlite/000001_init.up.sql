@@ -0,0 +1,201 @@
+-- Baseline schema of SQLite. The uuids are stored as text, generated as version 4 uuids like gen_random_uuid(), and
+-- the JSON documents as text, queried with the JSON functions of SQLite. The times are DATETIME columns, which the
+-- driver reads back as times.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "name" text NOT NULL,
+    "email" text NOT NULL,
+    "api_key_hash" text NOT NULL,
+    "is_admin" boolean NOT NULL DEFAULT false,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_api_key_hash" ON "users" ("api_key_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_projects_name" ON "projects" ("name");
+
+CREATE TABLE IF NOT EXISTS "project_members" (
+    "project_id" text,
+    "user_id" text,
+    "role" text NOT NULL,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("project_id","user_id"),
+    CONSTRAINT "fk_project_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_project_members_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_project_members_user_id" ON "project_members" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "stacks" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "project_id" text,
+    "name" text,
+    "description" text,
+    "labels" text NOT NULL DEFAULT '{}',
+    "status" text NOT NULL,
+    "reason" text,
+    "network" text NOT NULL,
+    "deployment_path" text NOT NULL,
+    "config" text NOT NULL,
+    "metadata" text,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    "deleted_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_stacks_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_stacks_project_id" ON "stacks" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "stack_config_revisions" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "stack_id" text NOT NULL,
+    "revision" bigint NOT NULL,
+    "config" text NOT NULL,
+    "reason" text,
+    "request_id" text,
+    "created_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_stack_config_revisions_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_stack_config_revisions_revision" ON "stack_config_revisions" ("stack_id","revision");
+
+CREATE TABLE IF NOT EXISTS "deployments" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "stack_id" text,
+    "step" bigint NOT NULL,
+    "status" text NOT NULL,
+    "config" text NOT NULL,
+    "log_path" text,
+    "request_id" text,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    "deleted_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_deployments_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_deployments_request_id" ON "deployments" ("request_id");
+
+CREATE TABLE IF NOT EXISTS "integrations" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "stack_id" text NOT NULL,
+    "type" text NOT NULL,
+    "log_path" text,
+    "status" text NOT NULL,
+    "config" text DEFAULT null,
+    "info" text DEFAULT null,
+    "reason" text DEFAULT null,
+    "request_id" text,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    "deleted_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_integrations_stack" FOREIGN KEY ("stack_id") REFERENCES "stacks"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_integrations_request_id" ON "integrations" ("request_id");
+
+CREATE TABLE IF NOT EXISTS "credentials" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "project_id" text NOT NULL,
+    "name" text NOT NULL,
+    "provider" text NOT NULL,
+    "config" text NOT NULL,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credentials_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_credentials_project_name" ON "credentials" ("project_id","name");
+
+CREATE TABLE IF NOT EXISTS "operator_keys" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "project_id" text NOT NULL,
+    "name" text NOT NULL,
+    "address" text NOT NULL,
+    "origin" text NOT NULL,
+    "keystore" text NOT NULL,
+    "created_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_operator_keys_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_operator_keys_address" ON "operator_keys" ("address");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_operator_keys_project_name" ON "operator_keys" ("project_id","name");
+
+CREATE TABLE IF NOT EXISTS "webhook_subscriptions" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "project_id" text NOT NULL,
+    "url" text NOT NULL,
+    "events" text NOT NULL,
+    "secret" text NOT NULL,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_subscriptions_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_project_id" ON "webhook_subscriptions" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_events" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "type" text NOT NULL,
+    "project_id" text,
+    "stack_id" text,
+    "data" text NOT NULL,
+    "dispatched_at" DATETIME,
+    "created_at" DATETIME,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_events_dispatched_at" ON "webhook_events" ("dispatched_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "subscription_id" text NOT NULL,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" DATETIME NOT NULL,
+    "response_status" bigint,
+    "error" text,
+    "delivered_at" DATETIME,
+    "created_at" DATETIME,
+    "updated_at" DATETIME,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_subscription" FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_webhook_deliveries_event" FOREIGN KEY ("event_id") REFERENCES "webhook_events"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_pending" ON "webhook_deliveries" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_subscription_id" ON "webhook_deliveries" ("subscription_id");
+
+CREATE TABLE IF NOT EXISTS "audit_records" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "source" text NOT NULL,
+    "actor_id" text,
+    "actor" text NOT NULL,
+    "source_ip" text,
+    "project_id" text,
+    "stack_id" text,
+    "integration_id" text,
+    "action" text NOT NULL,
+    "before_status" text,
+    "after_status" text,
+    "result" text,
+    "reason" text,
+    "request_id" text,
+    "created_at" DATETIME,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_records_created_at" ON "audit_records" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_request_id" ON "audit_records" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_stack_id" ON "audit_records" ("stack_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_project_id" ON "audit_records" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_records_actor_id" ON "audit_records" ("actor_id");
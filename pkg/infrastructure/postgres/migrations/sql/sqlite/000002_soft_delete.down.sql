@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_integrations_deleted_at";
+DROP INDEX IF EXISTS "idx_deployments_deleted_at";
+DROP INDEX IF EXISTS "idx_stacks_deleted_at";
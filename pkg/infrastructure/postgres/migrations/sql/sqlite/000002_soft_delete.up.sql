@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS "idx_stacks_deleted_at" ON "stacks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_deployments_deleted_at" ON "deployments" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_integrations_deleted_at" ON "integrations" ("deleted_at");
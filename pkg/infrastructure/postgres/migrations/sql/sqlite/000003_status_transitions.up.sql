@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "status_transitions" (
+    "id" text DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    "stack_id" text NOT NULL,
+    "subject" text NOT NULL,
+    "subject_id" text NOT NULL,
+    "from_status" text,
+    "to_status" text NOT NULL,
+    "reason" text,
+    "request_id" text,
+    "created_at" DATETIME,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_status_transitions_stack" ON "status_transitions" ("stack_id","created_at");
@@ -0,0 +1,2 @@
+ALTER TABLE "integrations" DROP COLUMN "version";
+ALTER TABLE "stacks" DROP COLUMN "version";
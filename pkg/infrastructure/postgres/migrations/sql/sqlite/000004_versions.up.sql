@@ -0,0 +1,2 @@
+ALTER TABLE "stacks" ADD COLUMN "version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "integrations" ADD COLUMN "version" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- Logs of the database sink, see the logsink package. A log is the concatenation of its chunks, ordered by the offset
+-- of their first byte.
+CREATE TABLE IF NOT EXISTS "log_chunks" (
+    "name" text NOT NULL,
+    "start" bigint NOT NULL,
+    "data" blob NOT NULL,
+    "created_at" DATETIME NOT NULL,
+    PRIMARY KEY ("name", "start")
+);
+CREATE INDEX IF NOT EXISTS "idx_log_chunks_created_at" ON "log_chunks" ("created_at");
@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) CreateRecord(
+	record *entities.AuditRecordEntity,
+) error {
+	return r.db.Create(ToAuditRecordSchema(record)).Error
+}
+
+// GetRecords returns the records matching the filter, newest first
+func (r *AuditRepository) GetRecords(
+	filter entities.AuditFilter,
+) ([]*entities.AuditRecordEntity, error) {
+	var records []schemas.AuditRecord
+	query := withAuditFilter(r.db, filter).Order("created_at desc")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return toAuditRecordEntities(records), nil
+}
+
+// ForEachRecord calls fn with the records matching the filter, oldest first. The rows are streamed so that exports do
+// not hold the whole trail in memory.
+func (r *AuditRepository) ForEachRecord(
+	filter entities.AuditFilter,
+	fn func(record *entities.AuditRecordEntity) error,
+) error {
+	rows, err := withAuditFilter(r.db, filter).Order("created_at asc, id asc").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record schemas.AuditRecord
+		if err := r.db.ScanRows(rows, &record); err != nil {
+			return err
+		}
+		if err := fn(ToAuditRecordEntity(&record)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func withAuditFilter(db *gorm.DB, filter entities.AuditFilter) *gorm.DB {
+	query := db.Model(&schemas.AuditRecord{})
+	if filter.StackID != nil {
+		// The calls creating a stack only know its id once they are done, they are matched by their request id
+		query = query.Where(
+			"stack_id = ? OR (request_id <> '' AND request_id IN (?))",
+			*filter.StackID,
+			db.Model(&schemas.AuditRecord{}).Select("request_id").Where("stack_id = ?", *filter.StackID),
+		)
+	}
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", *filter.ProjectID)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	return query
+}
+
+// recordStackTransition writes the status transition of a stack to the audit trail.
+// It must be called with the transaction updating the status, stack holding the row as it was before the update.
+func recordStackTransition(
+	tx *gorm.DB,
+	stack *schemas.Stack,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	if stack.Status == status {
+		return nil
+	}
+	return tx.Create(&schemas.AuditRecord{
+		ID:           uuid.New(),
+		Source:       entities.AuditSourceTask,
+		Actor:        entities.AuditActorSystem,
+		ProjectID:    stack.ProjectID,
+		StackID:      &stack.ID,
+		Action:       entities.AuditActionStackStatus,
+		BeforeStatus: string(stack.Status),
+		AfterStatus:  string(status),
+		Reason:       reason,
+		RequestID:    requestID,
+	}).Error
+}
+
+// recordStackArchival writes the archival of the stack to the audit trail, with the transaction archiving it
+func recordStackArchival(tx *gorm.DB, stack *schemas.Stack, requestID string) error {
+	return tx.Create(&schemas.AuditRecord{
+		ID:           uuid.New(),
+		Source:       entities.AuditSourceTask,
+		Actor:        entities.AuditActorSystem,
+		ProjectID:    stack.ProjectID,
+		StackID:      &stack.ID,
+		Action:       entities.AuditActionStackArchived,
+		BeforeStatus: string(stack.Status),
+		AfterStatus:  string(stack.Status),
+		RequestID:    requestID,
+	}).Error
+}
+
+// recordIntegrationTransitions writes the status transitions of integrations to the audit trail.
+// It must be called with the transaction updating the status, integrations holding the rows as they were before the
+// update along with their stack.
+func recordIntegrationTransitions(
+	tx *gorm.DB,
+	integrations []schemas.Integration,
+	status entities.DeploymentStatus,
+	reason string,
+	requestID string,
+) error {
+	records := make([]*schemas.AuditRecord, 0, len(integrations))
+	for i := range integrations {
+		integration := &integrations[i]
+		if integration.Status == status {
+			continue
+		}
+		var projectID *uuid.UUID
+		if integration.Stack != nil {
+			projectID = integration.Stack.ProjectID
+		}
+		records = append(records, &schemas.AuditRecord{
+			ID:            uuid.New(),
+			Source:        entities.AuditSourceTask,
+			Actor:         entities.AuditActorSystem,
+			ProjectID:     projectID,
+			StackID:       integration.StackID,
+			IntegrationID: &integration.ID,
+			Action:        entities.AuditActionIntegrationStatus,
+			BeforeStatus:  string(integration.Status),
+			AfterStatus:   string(status),
+			Reason:        reason,
+			RequestID:     requestID,
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return tx.Create(records).Error
+}
+
+func ToAuditRecordSchema(record *entities.AuditRecordEntity) *schemas.AuditRecord {
+	return &schemas.AuditRecord{
+		ID:            record.ID,
+		Source:        record.Source,
+		ActorID:       record.ActorID,
+		Actor:         record.Actor,
+		SourceIP:      record.SourceIP,
+		ProjectID:     record.ProjectID,
+		StackID:       record.StackID,
+		IntegrationID: record.IntegrationID,
+		Action:        record.Action,
+		BeforeStatus:  record.BeforeStatus,
+		AfterStatus:   record.AfterStatus,
+		Result:        record.Result,
+		Reason:        record.Reason,
+		RequestID:     record.RequestID,
+		CreatedAt:     record.CreatedAt,
+	}
+}
+
+func ToAuditRecordEntity(record *schemas.AuditRecord) *entities.AuditRecordEntity {
+	return &entities.AuditRecordEntity{
+		ID:            record.ID,
+		Source:        record.Source,
+		ActorID:       record.ActorID,
+		Actor:         record.Actor,
+		SourceIP:      record.SourceIP,
+		ProjectID:     record.ProjectID,
+		StackID:       record.StackID,
+		IntegrationID: record.IntegrationID,
+		Action:        record.Action,
+		BeforeStatus:  record.BeforeStatus,
+		AfterStatus:   record.AfterStatus,
+		Result:        record.Result,
+		Reason:        record.Reason,
+		RequestID:     record.RequestID,
+		CreatedAt:     record.CreatedAt,
+	}
+}
+
+func toAuditRecordEntities(records []schemas.AuditRecord) []*entities.AuditRecordEntity {
+	auditRecords := make([]*entities.AuditRecordEntity, len(records))
+	for i := range records {
+		auditRecords[i] = ToAuditRecordEntity(&records[i])
+	}
+	return auditRecords
+}
@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/gorm"
+)
+
+type CredentialRepository struct {
+	db      *gorm.DB
+	keyring *secrets.Keyring
+}
+
+// NewCredentialRepository returns a repository encrypting the secret keys with the keyring, when not nil
+func NewCredentialRepository(db *gorm.DB, keyring *secrets.Keyring) *CredentialRepository {
+	return &CredentialRepository{db: db, keyring: keyring}
+}
+
+// credentialConfig is the content of the config column of a credential
+type credentialConfig struct {
+	AwsAccessKey       string `json:"awsAccessKey"`
+	AwsSecretAccessKey string `json:"awsSecretAccessKey"`
+	AwsRegion          string `json:"awsRegion"`
+}
+
+func (r *CredentialRepository) CreateCredential(
+	credential *entities.CredentialEntity,
+) error {
+	newCredential, err := r.toCredentialSchema(credential)
+	if err != nil {
+		return err
+	}
+	return r.db.Create(newCredential).Error
+}
+
+func (r *CredentialRepository) GetCredentialByID(
+	id string,
+) (*entities.CredentialEntity, error) {
+	var credential schemas.Credential
+	if err := r.db.Where("id = ?", id).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No credential found
+		}
+		return nil, err
+	}
+	return r.toCredentialEntity(&credential)
+}
+
+func (r *CredentialRepository) GetCredentialByName(
+	projectID string,
+	name string,
+) (*entities.CredentialEntity, error) {
+	var credential schemas.Credential
+	if err := r.db.Where("project_id = ?", projectID).Where("name = ?", name).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No credential found
+		}
+		return nil, err
+	}
+	return r.toCredentialEntity(&credential)
+}
+
+func (r *CredentialRepository) GetCredentialsByProjectIDs(
+	projectIDs []string,
+) ([]*entities.CredentialEntity, error) {
+	if len(projectIDs) == 0 {
+		return []*entities.CredentialEntity{}, nil
+	}
+	var credentials []schemas.Credential
+	if err := r.db.Where("project_id IN ?", projectIDs).Order("created_at asc").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return r.toCredentialEntities(credentials)
+}
+
+func (r *CredentialRepository) GetAllCredentials() ([]*entities.CredentialEntity, error) {
+	var credentials []schemas.Credential
+	if err := r.db.Order("created_at asc").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return r.toCredentialEntities(credentials)
+}
+
+// UpdateKeys replaces the keys of the credential, the stacks referencing it use them from their next operation
+func (r *CredentialRepository) UpdateKeys(
+	credential *entities.CredentialEntity,
+) error {
+	schema, err := r.toCredentialSchema(credential)
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&schemas.Credential{}).Where("id = ?", credential.ID).Update("config", schema.Config).Error
+}
+
+func (r *CredentialRepository) DeleteCredential(
+	id string,
+) error {
+	return r.db.Where("id = ?", id).Delete(&schemas.Credential{}).Error
+}
+
+// GetStackIDsByCredentialID returns the ids of the stacks, other than terminated ones, referencing the credential
+func (r *CredentialRepository) GetStackIDsByCredentialID(
+	id string,
+) ([]string, error) {
+	var stackIDs []string
+	err := r.db.Model(&schemas.Stack{}).
+		Where("config->>'credentialId' = ?", id).
+		Where("status != ?", entities.StackStatusTerminated).
+		Pluck("id", &stackIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return stackIDs, nil
+}
+
+func (r *CredentialRepository) toCredentialSchema(
+	credential *entities.CredentialEntity,
+) (*schemas.Credential, error) {
+	config, err := json.Marshal(credentialConfig{
+		AwsAccessKey:       credential.AwsAccessKey,
+		AwsSecretAccessKey: credential.AwsSecretAccessKey,
+		AwsRegion:          credential.AwsRegion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptConfig(r.keyring, credential.ID.String(), config)
+	if err != nil {
+		return nil, err
+	}
+	return &schemas.Credential{
+		ID:        credential.ID,
+		ProjectID: credential.ProjectID,
+		Name:      credential.Name,
+		Provider:  credential.Provider,
+		Config:    encrypted,
+		CreatedAt: credential.CreatedAt,
+	}, nil
+}
+
+func (r *CredentialRepository) toCredentialEntity(
+	credential *schemas.Credential,
+) (*entities.CredentialEntity, error) {
+	decrypted, err := decryptConfig(r.keyring, credential.ID.String(), credential.Config)
+	if err != nil {
+		return nil, err
+	}
+	var config credentialConfig
+	if err := json.Unmarshal(decrypted, &config); err != nil {
+		return nil, err
+	}
+	return &entities.CredentialEntity{
+		ID:                 credential.ID,
+		ProjectID:          credential.ProjectID,
+		Name:               credential.Name,
+		Provider:           credential.Provider,
+		AwsAccessKey:       config.AwsAccessKey,
+		AwsSecretAccessKey: config.AwsSecretAccessKey,
+		AwsRegion:          config.AwsRegion,
+		CreatedAt:          credential.CreatedAt,
+		UpdatedAt:          credential.UpdatedAt,
+	}, nil
+}
+
+func (r *CredentialRepository) toCredentialEntities(
+	credentials []schemas.Credential,
+) ([]*entities.CredentialEntity, error) {
+	credentialEntities := make([]*entities.CredentialEntity, len(credentials))
+	for i := range credentials {
+		credential, err := r.toCredentialEntity(&credentials[i])
+		if err != nil {
+			return nil, err
+		}
+		credentialEntities[i] = credential
+	}
+	return credentialEntities, nil
+}
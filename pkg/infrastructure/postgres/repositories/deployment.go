@@ -1,39 +1,121 @@
 package repositories
 
 import (
-	"encoding/json"
 	"errors"
 
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DeploymentRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *secrets.Keyring
 }
 
-func NewDeploymentRepository(db *gorm.DB) *DeploymentRepository {
-	return &DeploymentRepository{db: db}
+// NewDeploymentRepository returns a repository encrypting the secrets of the configs with the keyring, when not nil
+func NewDeploymentRepository(db *gorm.DB, keyring *secrets.Keyring) *DeploymentRepository {
+	return &DeploymentRepository{db: db, keyring: keyring}
 }
 
 func (r *DeploymentRepository) CreateDeployment(deployment *entities.DeploymentEntity) error {
-	return r.db.Create(ToDeploymentSchema(deployment)).Error
+	schema := ToDeploymentSchema(deployment)
+	config, err := encryptConfig(r.keyring, schema.ID.String(), deployment.Config)
+	if err != nil {
+		return err
+	}
+	schema.Config = config
+	return r.db.Create(schema).Error
 }
 
+// UpdateDeploymentStatus moves the deployment to the status, it fails with entities.ErrInvalidStatusTransition when
+// the transition is not allowed from the current status
 func (r *DeploymentRepository) UpdateDeploymentStatus(
 	id string,
 	status entities.DeploymentStatus,
+	requestID string,
 ) error {
-	return r.db.Model(&schemas.Deployment{}).Where("id = ?", id).Update("status", status).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		deployments, err := lockDeployments(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, deployment := range deployments {
+			if !entities.CanDeploymentTransition(deployment.Status, status) {
+				return invalidTransitionError(entities.StatusSubjectDeployment, deployment.ID, string(deployment.Status), string(status))
+			}
+		}
+		if err := tx.Model(&schemas.Deployment{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+			return err
+		}
+		return recordDeploymentTransitions(tx, deployments, status, requestID)
+	})
 }
 
+// UpdateStatusesByStackId moves the deployments of the stack to the status, the deployments which cannot reach it
+// keep their status
 func (r *DeploymentRepository) UpdateStatusesByStackId(
 	stackID string,
 	status entities.DeploymentStatus,
+	requestID string,
 ) error {
-	return r.db.Model(&schemas.Deployment{}).Where("stack_id = ?", stackID).Update("status", status).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		deployments, err := lockDeployments(tx, "stack_id = ?", stackID)
+		if err != nil {
+			return err
+		}
+		var updated []schemas.Deployment
+		var ids []uuid.UUID
+		for _, deployment := range deployments {
+			if entities.CanDeploymentTransition(deployment.Status, status) {
+				updated = append(updated, deployment)
+				ids = append(ids, deployment.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&schemas.Deployment{}).Where("id IN ?", ids).Update("status", status).Error; err != nil {
+			return err
+		}
+		return recordDeploymentTransitions(tx, updated, status, requestID)
+	})
+}
+
+// lockDeployments returns the deployments about to be updated as they are before the update
+func lockDeployments(tx *gorm.DB, query string, args ...interface{}) ([]schemas.Deployment, error) {
+	var deployments []schemas.Deployment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "stack_id", "status").
+		Where(query, args...).
+		Find(&deployments).Error
+	if err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+func recordDeploymentTransitions(
+	tx *gorm.DB,
+	deployments []schemas.Deployment,
+	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	transitions := make([]*schemas.StatusTransition, 0, len(deployments))
+	for _, deployment := range deployments {
+		if deployment.StackID == nil {
+			continue
+		}
+		transitions = append(transitions, newStatusTransition(
+			*deployment.StackID, entities.StatusSubjectDeployment, deployment.ID,
+			string(deployment.Status), string(status), "", requestID,
+		))
+	}
+	return recordStatusTransitions(tx, transitions...)
 }
 
 func (r *DeploymentRepository) DeleteDeployment(id string) error {
@@ -45,14 +127,7 @@ func (r *DeploymentRepository) GetDeploymentByID(id string) (*entities.Deploymen
 	if err := r.db.Where("id = ?", id).First(&deployment).Error; err != nil {
 		return nil, err
 	}
-	return &entities.DeploymentEntity{
-		ID:      deployment.ID,
-		StackID: deployment.StackID,
-		Step:    deployment.Step,
-		Status:  deployment.Status,
-		LogPath: deployment.LogPath,
-		Config:  json.RawMessage(deployment.Config),
-	}, nil
+	return r.toDeploymentEntity(&deployment)
 }
 
 func (r *DeploymentRepository) GetDeploymentsByStackID(
@@ -66,15 +141,12 @@ func (r *DeploymentRepository) GetDeploymentsByStackID(
 		return nil, err
 	}
 	deploymentsEntities := make([]*entities.DeploymentEntity, len(deployments))
-	for i, deployment := range deployments {
-		deploymentsEntities[i] = &entities.DeploymentEntity{
-			ID:      deployment.ID,
-			StackID: deployment.StackID,
-			Step:    deployment.Step,
-			Status:  deployment.Status,
-			LogPath: deployment.LogPath,
-			Config:  json.RawMessage(deployment.Config),
+	for i := range deployments {
+		deployment, err := r.toDeploymentEntity(&deployments[i])
+		if err != nil {
+			return nil, err
 		}
+		deploymentsEntities[i] = deployment
 	}
 	return deploymentsEntities, nil
 }
@@ -89,11 +161,29 @@ func (r *DeploymentRepository) GetDeploymentStatus(id string) (entities.Deployme
 
 func ToDeploymentSchema(d *entities.DeploymentEntity) *schemas.Deployment {
 	return &schemas.Deployment{
-		ID:      d.ID,
-		StackID: d.StackID,
-		Step:    d.Step,
-		Status:  d.Status,
-		LogPath: d.LogPath,
-		Config:  datatypes.JSON(d.Config),
+		ID:        d.ID,
+		StackID:   d.StackID,
+		Step:      d.Step,
+		Status:    d.Status,
+		LogPath:   d.LogPath,
+		Config:    datatypes.JSON(d.Config),
+		RequestID: d.RequestID,
 	}
 }
+
+// toDeploymentEntity maps the row to its deployment, with the secrets of its config decrypted
+func (r *DeploymentRepository) toDeploymentEntity(deployment *schemas.Deployment) (*entities.DeploymentEntity, error) {
+	config, err := decryptConfig(r.keyring, deployment.ID.String(), deployment.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.DeploymentEntity{
+		ID:        deployment.ID,
+		StackID:   deployment.StackID,
+		Step:      deployment.Step,
+		Status:    deployment.Status,
+		LogPath:   deployment.LogPath,
+		Config:    config,
+		RequestID: deployment.RequestID,
+	}, nil
+}
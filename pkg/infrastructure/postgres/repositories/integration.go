@@ -4,61 +4,132 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IntegrationRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *secrets.Keyring
 }
 
-func NewIntegrationRepository(db *gorm.DB) *IntegrationRepository {
-	return &IntegrationRepository{db: db}
+// NewIntegrationRepository returns a repository encrypting the secrets of the configs with the keyring, when not nil
+func NewIntegrationRepository(db *gorm.DB, keyring *secrets.Keyring) *IntegrationRepository {
+	return &IntegrationRepository{db: db, keyring: keyring}
 }
 
+// CreateIntegration adds the integration to its stack read at the version, and increments the version of the stack. It
+// fails with entities.ErrVersionConflict when the stack changed since it was read, such as by a concurrent install.
 func (r *IntegrationRepository) CreateIntegration(
 	integration *entities.IntegrationEntity,
+	stackVersion int64,
 ) error {
 	newIntegration := ToIntegrationSchema(integration)
-	err := r.db.Create(newIntegration).Error
+	config, err := encryptConfig(r.keyring, newIntegration.ID.String(), integration.Config)
 	if err != nil {
 		return err
 	}
-	return nil
+	newIntegration.Config = config
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		created := *newIntegration
+		created.Status = ""
+		if created.StackID != nil {
+			stackID := created.StackID.String()
+			result := tx.Model(&schemas.Stack{}).
+				Where("id = ? AND version = ?", stackID, stackVersion).
+				Update("version", nextVersion())
+			if err := checkVersion(result, "stack", stackID, &stackVersion); err != nil {
+				return err
+			}
+
+			var stack schemas.Stack
+			if err := tx.Select("id", "project_id").Where("id = ?", stackID).First(&stack).Error; err != nil {
+				return err
+			}
+			created.Stack = &stack
+		}
+
+		if err := tx.Create(newIntegration).Error; err != nil {
+			return err
+		}
+		return recordIntegrationStatus(tx, []schemas.Integration{created}, newIntegration.Status, "created", integration.RequestID)
+	})
 }
 
 func (r *IntegrationRepository) UpdateIntegrationStatus(
 	id string,
 	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	return r.updateIntegrationStatus(id, nil, status, map[string]interface{}{}, "", requestID)
+}
+
+// UpdateIntegrationStatusAtVersion is UpdateIntegrationStatus for an integration read at the version, it fails with
+// entities.ErrVersionConflict when the integration changed since
+func (r *IntegrationRepository) UpdateIntegrationStatusAtVersion(
+	id string,
+	version int64,
+	status entities.DeploymentStatus,
+	requestID string,
 ) error {
-	return r.db.Model(&schemas.Integration{}).Where("id = ?", id).Update("status", status).Error
+	return r.updateIntegrationStatus(id, &version, status, map[string]interface{}{}, "", requestID)
 }
 
 func (r *IntegrationRepository) UpdateIntegrationStatusWithReason(
 	id string,
 	status entities.DeploymentStatus,
 	reason string,
+	requestID string,
 ) error {
-	return r.db.Model(&schemas.Integration{}).Where("id = ?", id).Update("status", status).Update("reason", reason).Error
+	return r.updateIntegrationStatus(id, nil, status, map[string]interface{}{"reason": reason}, reason, requestID)
+}
+
+// updateIntegrationStatus moves the integration to the status along with the other updates, reason is the reason of
+// the transition
+func (r *IntegrationRepository) updateIntegrationStatus(
+	id string,
+	version *int64,
+	status entities.DeploymentStatus,
+	updates map[string]interface{},
+	reason string,
+	requestID string,
+) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		integrations, err := lockIntegrations(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		if err := checkIntegrationTransitions(integrations, status); err != nil {
+			return err
+		}
+		updates["status"] = status
+		updates["version"] = nextVersion()
+		result := whereVersion(tx.Model(&schemas.Integration{}).Where("id = ?", id), version).Updates(updates)
+		if err := checkVersion(result, "integration", id, version); err != nil {
+			return err
+		}
+		if err := recordIntegrationStatus(tx, integrations, status, reason, requestID); err != nil {
+			return err
+		}
+		return enqueueIntegrationEvent(tx, id, status, reason)
+	})
 }
 
 func (r *IntegrationRepository) UpdateMetadataAfterInstalled(
 	id string,
 	metadata entities.IntegrationInfo,
+	requestID string,
 ) error {
-	if metadata == nil {
-		return r.db.Model(&schemas.Integration{}).
-			Where("id = ?", id).
-			Update("status", entities.DeploymentStatusCompleted).
-			Error
+	updates := map[string]interface{}{}
+	if metadata != nil {
+		updates["info"] = metadata
 	}
-	return r.db.Model(&schemas.Integration{}).
-		Where("id = ?", id).
-		Update("info", metadata).
-		Update("status", entities.DeploymentStatusCompleted).
-		Error
+	return r.updateIntegrationStatus(id, nil, entities.DeploymentStatusCompleted, updates, "", requestID)
 }
 
 func (r *IntegrationRepository) UpdateConfig(
@@ -68,17 +139,94 @@ func (r *IntegrationRepository) UpdateConfig(
 	if config == nil {
 		return nil // No metadata to update
 	}
+	encrypted, err := encryptConfig(r.keyring, id, config)
+	if err != nil {
+		return err
+	}
 	return r.db.Model(&schemas.Integration{}).
 		Where("id = ?", id).
-		Update("config", config).
+		Updates(map[string]interface{}{"config": encrypted, "version": nextVersion()}).
 		Error
 }
 
 func (r *IntegrationRepository) UpdateIntegrationsStatusByStackID(
 	stackID string,
 	status entities.DeploymentStatus,
+	requestID string,
+) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		integrations, err := lockIntegrations(tx, "stack_id = ?", stackID)
+		if err != nil {
+			return err
+		}
+		var updated []schemas.Integration
+		var ids []uuid.UUID
+		for _, integration := range integrations {
+			if entities.CanIntegrationTransition(entities.DeploymentStatus(integration.Status), status) {
+				updated = append(updated, integration)
+				ids = append(ids, integration.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		err = tx.Model(&schemas.Integration{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{"status": status, "version": nextVersion()}).
+			Error
+		if err != nil {
+			return err
+		}
+		return recordIntegrationStatus(tx, updated, status, "", requestID)
+	})
+}
+
+func checkIntegrationTransitions(integrations []schemas.Integration, status entities.DeploymentStatus) error {
+	for _, integration := range integrations {
+		if !entities.CanIntegrationTransition(entities.DeploymentStatus(integration.Status), status) {
+			return invalidTransitionError(entities.StatusSubjectIntegration, integration.ID, string(integration.Status), string(status))
+		}
+	}
+	return nil
+}
+
+// recordIntegrationStatus writes the status transitions of the integrations to the audit trail and to the history of
+// their stack
+func recordIntegrationStatus(
+	tx *gorm.DB,
+	integrations []schemas.Integration,
+	status entities.DeploymentStatus,
+	reason string,
+	requestID string,
 ) error {
-	return r.db.Model(&schemas.Integration{}).Where("stack_id = ?", stackID).Update("status", status).Error
+	if err := recordIntegrationTransitions(tx, integrations, status, reason, requestID); err != nil {
+		return err
+	}
+	transitions := make([]*schemas.StatusTransition, 0, len(integrations))
+	for _, integration := range integrations {
+		if integration.StackID == nil {
+			continue
+		}
+		transitions = append(transitions, newStatusTransition(
+			*integration.StackID, entities.StatusSubjectIntegration, integration.ID,
+			string(integration.Status), string(status), reason, requestID,
+		))
+	}
+	return recordStatusTransitions(tx, transitions...)
+}
+
+// lockIntegrations returns the integrations about to be updated, with their stack, as they are before the update
+func lockIntegrations(tx *gorm.DB, query string, args ...interface{}) ([]schemas.Integration, error) {
+	var integrations []schemas.Integration
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}}).
+		Select("id", "stack_id", "status").
+		Preload("Stack", func(db *gorm.DB) *gorm.DB { return db.Select("id", "project_id") }).
+		Where(query, args...).
+		Find(&integrations).Error
+	if err != nil {
+		return nil, err
+	}
+	return integrations, nil
 }
 
 func (r *IntegrationRepository) GetInstalledIntegration(
@@ -92,7 +240,7 @@ func (r *IntegrationRepository) GetInstalledIntegration(
 		}
 		return nil, err
 	}
-	return ToIntegrationEntity(&integration), nil
+	return r.toIntegrationEntity(&integration)
 }
 
 func (r *IntegrationRepository) GetActiveIntegrations(
@@ -103,11 +251,7 @@ func (r *IntegrationRepository) GetActiveIntegrations(
 	if err := r.db.Where("stack_id = ?", stackId).Where("type = ?", integrationType).Where("status != ?", entities.DeploymentStatusTerminated).Order("created_at asc").Find(&integrations).Error; err != nil {
 		return nil, err
 	}
-	integrationEntities := make([]*entities.IntegrationEntity, len(integrations))
-	for i, integration := range integrations {
-		integrationEntities[i] = ToIntegrationEntity(&integration)
-	}
-	return integrationEntities, nil
+	return r.toIntegrationEntities(integrations)
 }
 
 func (r *IntegrationRepository) GetIntegration(
@@ -121,7 +265,7 @@ func (r *IntegrationRepository) GetIntegration(
 		}
 		return nil, err
 	}
-	return ToIntegrationEntity(&integration), nil
+	return r.toIntegrationEntity(&integration)
 }
 
 func (r *IntegrationRepository) GetIntegrationById(
@@ -134,7 +278,7 @@ func (r *IntegrationRepository) GetIntegrationById(
 		}
 		return nil, err
 	}
-	return ToIntegrationEntity(&integration), nil
+	return r.toIntegrationEntity(&integration)
 }
 
 func (r *IntegrationRepository) GetIntegrationsByStackID(
@@ -147,11 +291,7 @@ func (r *IntegrationRepository) GetIntegrationsByStackID(
 		}
 		return nil, err
 	}
-	integrationEntities := make([]*entities.IntegrationEntity, len(integrations))
-	for i, integration := range integrations {
-		integrationEntities[i] = ToIntegrationEntity(&integration)
-	}
-	return integrationEntities, nil
+	return r.toIntegrationEntities(integrations)
 }
 
 func (r *IntegrationRepository) GetActiveIntegrationsByStackID(
@@ -161,24 +301,21 @@ func (r *IntegrationRepository) GetActiveIntegrationsByStackID(
 	if err := r.db.Where("stack_id = ?", stackId).Where("status != ?", entities.DeploymentStatusTerminated).Order("created_at asc").Find(&integrations).Error; err != nil {
 		return nil, err
 	}
-	integrationEntities := make([]*entities.IntegrationEntity, len(integrations))
-	for i, integration := range integrations {
-		integrationEntities[i] = ToIntegrationEntity(&integration)
-	}
-	return integrationEntities, nil
+	return r.toIntegrationEntities(integrations)
 }
 
 func ToIntegrationSchema(
 	integration *entities.IntegrationEntity,
 ) *schemas.Integration {
 	return &schemas.Integration{
-		ID:      integration.ID,
-		StackID: integration.StackID,
-		Type:    integration.Type,
-		Status:  entities.DeploymentStatus(integration.Status),
-		Config:  datatypes.JSON(integration.Config),
-		Info:    datatypes.JSON(integration.Info),
-		LogPath: integration.LogPath,
+		ID:        integration.ID,
+		StackID:   integration.StackID,
+		Type:      integration.Type,
+		Status:    entities.DeploymentStatus(integration.Status),
+		Config:    datatypes.JSON(integration.Config),
+		Info:      datatypes.JSON(integration.Info),
+		LogPath:   integration.LogPath,
+		RequestID: integration.RequestID,
 	}
 }
 
@@ -186,12 +323,41 @@ func ToIntegrationEntity(
 	integration *schemas.Integration,
 ) *entities.IntegrationEntity {
 	return &entities.IntegrationEntity{
-		ID:      integration.ID,
-		StackID: integration.StackID,
-		Type:    integration.Type,
-		Status:  string(integration.Status),
-		Config:  json.RawMessage(integration.Config),
-		Info:    json.RawMessage(integration.Info),
-		LogPath: integration.LogPath,
+		ID:        integration.ID,
+		StackID:   integration.StackID,
+		Type:      integration.Type,
+		Status:    string(integration.Status),
+		Config:    json.RawMessage(integration.Config),
+		Info:      json.RawMessage(integration.Info),
+		LogPath:   integration.LogPath,
+		RequestID: integration.RequestID,
+		Version:   integration.Version,
+	}
+}
+
+// toIntegrationEntity maps the row to its integration, with the secrets of its config decrypted
+func (r *IntegrationRepository) toIntegrationEntity(
+	schema *schemas.Integration,
+) (*entities.IntegrationEntity, error) {
+	integration := ToIntegrationEntity(schema)
+	config, err := decryptConfig(r.keyring, schema.ID.String(), schema.Config)
+	if err != nil {
+		return nil, err
 	}
+	integration.Config = config
+	return integration, nil
+}
+
+func (r *IntegrationRepository) toIntegrationEntities(
+	integrations []schemas.Integration,
+) ([]*entities.IntegrationEntity, error) {
+	integrationEntities := make([]*entities.IntegrationEntity, len(integrations))
+	for i := range integrations {
+		integration, err := r.toIntegrationEntity(&integrations[i])
+		if err != nil {
+			return nil, err
+		}
+		integrationEntities[i] = integration
+	}
+	return integrationEntities, nil
 }
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// operatorKeyConfigFields are the fields of the stack configs referencing an operator key
+var operatorKeyConfigFields = []string{"adminKeyId", "sequencerKeyId", "batcherKeyId", "proposerKeyId"}
+
+type OperatorKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewOperatorKeyRepository(db *gorm.DB) *OperatorKeyRepository {
+	return &OperatorKeyRepository{db: db}
+}
+
+func (r *OperatorKeyRepository) CreateKey(
+	key *entities.OperatorKeyEntity,
+) error {
+	return r.db.Create(ToOperatorKeySchema(key)).Error
+}
+
+func (r *OperatorKeyRepository) GetKeyByID(
+	id string,
+) (*entities.OperatorKeyEntity, error) {
+	var key schemas.OperatorKey
+	if err := r.db.Where("id = ?", id).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No key found
+		}
+		return nil, err
+	}
+	return ToOperatorKeyEntity(&key), nil
+}
+
+func (r *OperatorKeyRepository) GetKeyByName(
+	projectID string,
+	name string,
+) (*entities.OperatorKeyEntity, error) {
+	var key schemas.OperatorKey
+	if err := r.db.Where("project_id = ?", projectID).Where("name = ?", name).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No key found
+		}
+		return nil, err
+	}
+	return ToOperatorKeyEntity(&key), nil
+}
+
+func (r *OperatorKeyRepository) GetKeysByProjectIDs(
+	projectIDs []string,
+) ([]*entities.OperatorKeyEntity, error) {
+	if len(projectIDs) == 0 {
+		return []*entities.OperatorKeyEntity{}, nil
+	}
+	var keys []schemas.OperatorKey
+	if err := r.db.Where("project_id IN ?", projectIDs).Order("created_at asc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return toOperatorKeyEntities(keys), nil
+}
+
+func (r *OperatorKeyRepository) GetAllKeys() ([]*entities.OperatorKeyEntity, error) {
+	var keys []schemas.OperatorKey
+	if err := r.db.Order("created_at asc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return toOperatorKeyEntities(keys), nil
+}
+
+func (r *OperatorKeyRepository) DeleteKey(
+	id string,
+) error {
+	return r.db.Where("id = ?", id).Delete(&schemas.OperatorKey{}).Error
+}
+
+// GetStackIDsByKeyID returns the ids of the stacks, other than terminated ones, using the key for a system account
+func (r *OperatorKeyRepository) GetStackIDsByKeyID(
+	id string,
+) ([]string, error) {
+	references := r.db.Where("config->>? = ?", operatorKeyConfigFields[0], id)
+	for _, field := range operatorKeyConfigFields[1:] {
+		references = references.Or("config->>? = ?", field, id)
+	}
+
+	var stackIDs []string
+	err := r.db.Model(&schemas.Stack{}).
+		Where(references).
+		Where("status != ?", entities.StackStatusTerminated).
+		Pluck("id", &stackIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return stackIDs, nil
+}
+
+func ToOperatorKeySchema(key *entities.OperatorKeyEntity) *schemas.OperatorKey {
+	return &schemas.OperatorKey{
+		ID:        key.ID,
+		ProjectID: key.ProjectID,
+		Name:      key.Name,
+		Address:   key.Address,
+		Origin:    string(key.Origin),
+		Keystore:  datatypes.JSON(key.Keystore),
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+func ToOperatorKeyEntity(key *schemas.OperatorKey) *entities.OperatorKeyEntity {
+	return &entities.OperatorKeyEntity{
+		ID:        key.ID,
+		ProjectID: key.ProjectID,
+		Name:      key.Name,
+		Address:   key.Address,
+		Origin:    entities.OperatorKeyOrigin(key.Origin),
+		Keystore:  json.RawMessage(key.Keystore),
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+func toOperatorKeyEntities(keys []schemas.OperatorKey) []*entities.OperatorKeyEntity {
+	keyEntities := make([]*entities.OperatorKeyEntity, len(keys))
+	for i := range keys {
+		keyEntities[i] = ToOperatorKeyEntity(&keys[i])
+	}
+	return keyEntities
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) *ProjectRepository {
+	return &ProjectRepository{db: db}
+}
+
+// CreateProject creates the project and registers its creator as the first admin
+func (r *ProjectRepository) CreateProject(
+	project *entities.ProjectEntity,
+	owner *entities.ProjectMemberEntity,
+) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ToProjectSchema(project)).Error; err != nil {
+			return err
+		}
+		return tx.Create(ToProjectMemberSchema(owner)).Error
+	})
+}
+
+func (r *ProjectRepository) GetProjectByID(
+	id string,
+) (*entities.ProjectEntity, error) {
+	var project schemas.Project
+	if err := r.db.Where("id = ?", id).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No project found
+		}
+		return nil, err
+	}
+	return ToProjectEntity(&project), nil
+}
+
+func (r *ProjectRepository) GetAllProjects() ([]*entities.ProjectEntity, error) {
+	var projects []schemas.Project
+	if err := r.db.Order("created_at asc").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return toProjectEntities(projects), nil
+}
+
+func (r *ProjectRepository) GetProjectsByUserID(
+	userID string,
+) ([]*entities.ProjectEntity, error) {
+	var projects []schemas.Project
+	if err := r.db.
+		Joins("JOIN project_members ON project_members.project_id = projects.id").
+		Where("project_members.user_id = ?", userID).
+		Order("projects.created_at asc").
+		Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return toProjectEntities(projects), nil
+}
+
+func (r *ProjectRepository) GetProjectIDsByUserID(
+	userID string,
+) ([]string, error) {
+	var projectIDs []string
+	if err := r.db.Model(&schemas.ProjectMember{}).
+		Where("user_id = ?", userID).
+		Pluck("project_id", &projectIDs).Error; err != nil {
+		return nil, err
+	}
+	return projectIDs, nil
+}
+
+// UpsertMember adds the user to the project or updates the role of an existing member
+func (r *ProjectRepository) UpsertMember(
+	member *entities.ProjectMemberEntity,
+) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(ToProjectMemberSchema(member)).Error
+}
+
+func (r *ProjectRepository) DeleteMember(
+	projectID string,
+	userID string,
+) error {
+	return r.db.Where("project_id = ?", projectID).Where("user_id = ?", userID).Delete(&schemas.ProjectMember{}).Error
+}
+
+func (r *ProjectRepository) GetMember(
+	projectID string,
+	userID string,
+) (*entities.ProjectMemberEntity, error) {
+	var member schemas.ProjectMember
+	if err := r.db.Where("project_id = ?", projectID).Where("user_id = ?", userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // User is not a member of the project
+		}
+		return nil, err
+	}
+	return ToProjectMemberEntity(&member), nil
+}
+
+func (r *ProjectRepository) GetMembers(
+	projectID string,
+) ([]*entities.ProjectMemberEntity, error) {
+	var members []schemas.ProjectMember
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	memberEntities := make([]*entities.ProjectMemberEntity, len(members))
+	for i, member := range members {
+		memberEntities[i] = ToProjectMemberEntity(&member)
+	}
+	return memberEntities, nil
+}
+
+func ToProjectSchema(project *entities.ProjectEntity) *schemas.Project {
+	return &schemas.Project{
+		ID:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+	}
+}
+
+func ToProjectEntity(project *schemas.Project) *entities.ProjectEntity {
+	return &entities.ProjectEntity{
+		ID:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+	}
+}
+
+func ToProjectMemberSchema(member *entities.ProjectMemberEntity) *schemas.ProjectMember {
+	return &schemas.ProjectMember{
+		ProjectID: member.ProjectID,
+		UserID:    member.UserID,
+		Role:      member.Role,
+	}
+}
+
+func ToProjectMemberEntity(member *schemas.ProjectMember) *entities.ProjectMemberEntity {
+	return &entities.ProjectMemberEntity{
+		ProjectID: member.ProjectID,
+		UserID:    member.UserID,
+		Role:      member.Role,
+	}
+}
+
+func toProjectEntities(projects []schemas.Project) []*entities.ProjectEntity {
+	projectEntities := make([]*entities.ProjectEntity, len(projects))
+	for i, project := range projects {
+		projectEntities[i] = ToProjectEntity(&project)
+	}
+	return projectEntities
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const reencryptBatchSize = 100
+
+// encryptConfig encrypts the secret fields of the config held by the row, see secrets.Keyring.EncryptJSONFields
+func encryptConfig(keyring *secrets.Keyring, rowID string, config json.RawMessage) (datatypes.JSON, error) {
+	if keyring != nil && (rowID == "" || rowID == uuid.Nil.String()) {
+		return nil, errors.New("the row id is required to encrypt its config")
+	}
+	encrypted, err := keyring.EncryptJSONFields(config, entities.SecretConfigFields, rowID)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(encrypted), nil
+}
+
+func decryptConfig(keyring *secrets.Keyring, rowID string, config datatypes.JSON) (json.RawMessage, error) {
+	decrypted, err := keyring.DecryptJSONFields(config, rowID)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(decrypted), nil
+}
+
+// ReencryptSecrets encrypts the secret config fields of every stack, stack config revision, deployment, integration and
+// credential, and the secrets of the webhook subscriptions, with the current key of the keyring, including the
+// plaintext secrets stored before encryption was enabled and the secrets not bound to their row yet. It returns the
+// number of updated rows. Once it succeeds the previous keys can be removed from the keyring.
+func ReencryptSecrets(db *gorm.DB, keyring *secrets.Keyring) (int, error) {
+	updated := 0
+	for _, table := range []struct {
+		model interface{}
+		// rowColumn is the column of the id the secrets are bound to, the revisions hold the config of their stack
+		rowColumn string
+	}{
+		{&schemas.Stack{}, "id"},
+		{&schemas.StackConfigRevision{}, "stack_id"},
+		{&schemas.Deployment{}, "id"},
+		{&schemas.Integration{}, "id"},
+		{&schemas.Credential{}, "id"},
+	} {
+		count, err := reencryptConfigs(db, keyring, table.model, table.rowColumn)
+		updated += count
+		if err != nil {
+			return updated, err
+		}
+	}
+	count, err := reencryptWebhookSecrets(db, keyring)
+	return updated + count, err
+}
+
+// reencryptConfigs re-encrypts the config column of a table, in batches ordered by id
+func reencryptConfigs(db *gorm.DB, keyring *secrets.Keyring, model interface{}, rowColumn string) (int, error) {
+	type row struct {
+		ID     string
+		RowID  string
+		Config datatypes.JSON
+	}
+
+	// Archived rows are re-encrypted as well, the old key could not read them once removed. The session starts every
+	// query below from the unscoped statement, rather than adding to the previous one.
+	db = db.Unscoped().Session(&gorm.Session{})
+
+	updated := 0
+	lastID := ""
+	for {
+		var rows []row
+		err := db.Model(model).
+			Select("id", rowColumn+" AS row_id", "config").
+			Where("CAST(id AS text) > ?", lastID).
+			Order("CAST(id AS text) asc").
+			Limit(reencryptBatchSize).
+			Find(&rows).Error
+		if err != nil {
+			return updated, err
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, r := range rows {
+			lastID = r.ID
+			config, err := encryptConfig(keyring, r.RowID, json.RawMessage(r.Config))
+			if err != nil {
+				return updated, err
+			}
+			if string(config) == string(r.Config) {
+				continue
+			}
+			if err := db.Model(model).Where("id = ?", r.ID).Update("config", config).Error; err != nil {
+				return updated, err
+			}
+			updated++
+			logger.Debug("Re-encrypted config", zap.String("id", r.ID))
+		}
+	}
+}
+
+// reencryptWebhookSecrets re-encrypts the secrets of the webhook subscriptions, in batches ordered by id
+func reencryptWebhookSecrets(db *gorm.DB, keyring *secrets.Keyring) (int, error) {
+	updated := 0
+	lastID := ""
+	for {
+		var subscriptions []schemas.WebhookSubscription
+		err := db.Select("id", "secret").
+			Where("CAST(id AS text) > ?", lastID).
+			Order("CAST(id AS text) asc").
+			Limit(reencryptBatchSize).
+			Find(&subscriptions).Error
+		if err != nil {
+			return updated, err
+		}
+		if len(subscriptions) == 0 {
+			return updated, nil
+		}
+
+		for _, subscription := range subscriptions {
+			lastID = subscription.ID.String()
+			secret, err := encryptWebhookSecret(keyring, lastID, subscription.Secret)
+			if err != nil {
+				return updated, err
+			}
+			if secret == subscription.Secret {
+				continue
+			}
+			err = db.Model(&schemas.WebhookSubscription{}).Where("id = ?", subscription.ID).Update("secret", secret).Error
+			if err != nil {
+				return updated, err
+			}
+			updated++
+			logger.Debug("Re-encrypted webhook secret", zap.String("id", lastID))
+		}
+	}
+}
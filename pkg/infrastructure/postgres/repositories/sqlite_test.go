@@ -0,0 +1,331 @@
+package repositories_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/config"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/connection"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/migrations"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// The repositories are tested on SQLite, which needs no server. The queries written for each dialect, such as the
+// label selector, are only covered for SQLite.
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := connection.Init(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// createProject creates a project owned by a new user, and returns its id
+func createProject(t *testing.T, db *gorm.DB) uuid.UUID {
+	t.Helper()
+	users := repositories.NewUserRepository(db)
+	if err := users.CreateUser(&entities.UserEntity{Name: "owner", Email: "owner@thanos.test"}, "hash"); err != nil {
+		t.Fatalf("failed to create the user: %v", err)
+	}
+	// The id of the user is generated by the database
+	owner, err := users.GetUserByEmail("owner@thanos.test")
+	if err != nil || owner == nil || owner.ID == uuid.Nil {
+		t.Fatalf("failed to get the user: %+v, %v", owner, err)
+	}
+
+	projectID := uuid.New()
+	err = repositories.NewProjectRepository(db).CreateProject(
+		&entities.ProjectEntity{ID: projectID, Name: "thanos"},
+		&entities.ProjectMemberEntity{ProjectID: projectID, UserID: owner.ID, Role: entities.ProjectRoleAdmin},
+	)
+	if err != nil {
+		t.Fatalf("failed to create the project: %v", err)
+	}
+	return projectID
+}
+
+func createStack(
+	t *testing.T,
+	stacks *repositories.StackRepository,
+	projectID uuid.UUID,
+	labels map[string]string,
+	config map[string]string,
+) *entities.StackEntity {
+	t.Helper()
+	rawConfig, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stackID := uuid.New()
+	stack := &entities.StackEntity{
+		ID:             stackID,
+		ProjectID:      &projectID,
+		Name:           "stack-" + stackID.String()[:8],
+		Labels:         labels,
+		Network:        entities.DeploymentNetworkTestnet,
+		Config:         rawConfig,
+		DeploymentPath: "storage/deployments/" + stackID.String(),
+		Status:         entities.StackStatusPending,
+	}
+	deployment := &entities.DeploymentEntity{
+		ID:      uuid.New(),
+		StackID: &stackID,
+		Step:    1,
+		Status:  entities.DeploymentStatusPending,
+		Config:  json.RawMessage(`{}`),
+	}
+	if err := stacks.CreateStackByTx(stack, []*entities.DeploymentEntity{deployment}, nil, "request"); err != nil {
+		t.Fatalf("failed to create the stack: %v", err)
+	}
+	return stack
+}
+
+func stackIDs(stacks []*entities.StackEntity) []string {
+	ids := make([]string, len(stacks))
+	for i, stack := range stacks {
+		ids[i] = stack.ID.String()
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestStacksOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	projectID := createProject(t, db)
+	stacks := repositories.NewStackRepository(db, nil)
+
+	production := createStack(t, stacks, projectID, map[string]string{"env": "production", "team.name": "core"}, nil)
+	staging := createStack(t, stacks, projectID, map[string]string{"env": "staging"}, nil)
+
+	stack, err := stacks.GetStackByID(production.ID.String())
+	if err != nil {
+		t.Fatalf("failed to get the stack: %v", err)
+	}
+	if stack.Labels["team.name"] != "core" || stack.Status != entities.StackStatusPending || stack.Version != 0 {
+		t.Errorf("unexpected stack %+v", stack)
+	}
+
+	for _, tc := range []struct {
+		selector map[string]string
+		want     []*entities.StackEntity
+	}{
+		{nil, []*entities.StackEntity{production, staging}},
+		{map[string]string{"env": "production"}, []*entities.StackEntity{production}},
+		{map[string]string{"env": "production", "team.name": "core"}, []*entities.StackEntity{production}},
+		{map[string]string{"env": "staging", "team.name": "core"}, nil},
+		{map[string]string{"env": "prod"}, nil},
+	} {
+		got, err := stacks.GetStacksByProjectIDs([]string{projectID.String()}, tc.selector, false)
+		if err != nil {
+			t.Fatalf("failed to select %v: %v", tc.selector, err)
+		}
+		if !slices.Equal(stackIDs(got), stackIDs(tc.want)) {
+			t.Errorf("selector %v matched %v, want %v", tc.selector, stackIDs(got), stackIDs(tc.want))
+		}
+	}
+
+	err = stacks.UpdateStatus(production.ID.String(), entities.StackStatusDeploying, "", "request")
+	if err != nil {
+		t.Fatalf("failed to update the status: %v", err)
+	}
+	err = stacks.UpdateStatusAtVersion(production.ID.String(), 0, entities.StackStatusStopped, "", "request")
+	if !errors.Is(err, entities.ErrVersionConflict) {
+		t.Errorf("expected a version conflict, got %v", err)
+	}
+	err = stacks.UpdateStatus(production.ID.String(), entities.StackStatusTerminated, "", "request")
+	if !errors.Is(err, entities.ErrInvalidStatusTransition) {
+		t.Errorf("expected an invalid transition, got %v", err)
+	}
+
+	transitions, err := stacks.GetStatusTransitions(production.ID.String())
+	if err != nil {
+		t.Fatalf("failed to get the transitions: %v", err)
+	}
+	if len(transitions) != 2 || transitions[1].ToStatus != string(entities.StackStatusDeploying) {
+		t.Errorf("unexpected transitions %+v", transitions)
+	}
+
+	if err := stacks.ArchiveStack(staging.ID.String(), "request"); err != nil {
+		t.Fatalf("failed to archive the stack: %v", err)
+	}
+	if stack, err := stacks.GetStackByID(staging.ID.String()); err != nil || stack != nil {
+		t.Errorf("got the archived stack %+v, %v", stack, err)
+	}
+	if exists, err := stacks.StackExists(staging.ID.String()); err != nil || !exists {
+		t.Errorf("StackExists() = %v, %v for the archived stack", exists, err)
+	}
+	if exists, err := stacks.StackExists(uuid.NewString()); err != nil || exists {
+		t.Errorf("StackExists() = %v, %v for an unknown stack", exists, err)
+	}
+}
+
+func TestUnitOfWorkOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	stacks := repositories.NewStackRepository(db, nil)
+	stack := createStack(t, stacks, createProject(t, db), nil, nil)
+	failure := errors.New("failure")
+
+	err := repositories.NewUnitOfWork(db, nil).Do(context.Background(), func(repos services.Repositories) error {
+		err := repos.Stacks.UpdateStatus(stack.ID.String(), entities.StackStatusDeploying, "", "request")
+		if err != nil {
+			return err
+		}
+		err = repos.Deployments.UpdateStatusesByStackId(stack.ID.String(), entities.DeploymentStatusInProgress, "request")
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the failure, got %v", err)
+	}
+
+	status, err := stacks.GetStackStatus(stack.ID.String())
+	if err != nil || status != entities.StackStatusPending {
+		t.Errorf("stack status = %s, %v, want the status before the unit of work", status, err)
+	}
+	deployments, err := repositories.NewDeploymentRepository(db, nil).GetDeploymentsByStackID(stack.ID.String())
+	if err != nil || len(deployments) != 1 || deployments[0].Status != entities.DeploymentStatusPending {
+		t.Errorf("unexpected deployments %+v, %v", deployments, err)
+	}
+}
+
+func TestConfigReferencesOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	stacks := repositories.NewStackRepository(db, nil)
+	projectID := createProject(t, db)
+
+	credentialID := uuid.NewString()
+	keyID := uuid.NewString()
+	referencing := createStack(t, stacks, projectID, nil, map[string]string{
+		"credentialId":       credentialID,
+		"batcherKeyId":       keyID,
+		"awsSecretAccessKey": "secret",
+	})
+	createStack(t, stacks, projectID, nil, map[string]string{"credentialId": uuid.NewString()})
+
+	ids, err := repositories.NewCredentialRepository(db, nil).GetStackIDsByCredentialID(credentialID)
+	if err != nil || !slices.Equal(ids, []string{referencing.ID.String()}) {
+		t.Errorf("stacks referencing the credential = %v, %v", ids, err)
+	}
+	ids, err = repositories.NewOperatorKeyRepository(db).GetStackIDsByKeyID(keyID)
+	if err != nil || !slices.Equal(ids, []string{referencing.ID.String()}) {
+		t.Errorf("stacks referencing the key = %v, %v", ids, err)
+	}
+
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	keyring, err := secrets.LoadKeyring("test:"+key, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repositories.ReencryptSecrets(db, keyring)
+	if err != nil || updated != 1 {
+		t.Fatalf("re-encrypted %d rows, %v, want the config of the stack with a secret", updated, err)
+	}
+	stack, err := repositories.NewStackRepository(db, keyring).GetStackByID(referencing.ID.String())
+	if err != nil {
+		t.Fatalf("failed to get the stack: %v", err)
+	}
+	var config map[string]string
+	if err := json.Unmarshal(stack.Config, &config); err != nil || config["awsSecretAccessKey"] != "secret" {
+		t.Errorf("unexpected config %s, %v", stack.Config, err)
+	}
+}
+
+func TestSecretsBoundToTheirRowOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	projectID := createProject(t, db)
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	keyring, err := secrets.LoadKeyring("test:"+key, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stacks := repositories.NewStackRepository(db, keyring)
+	source := createStack(t, stacks, projectID, nil, map[string]string{"awsSecretAccessKey": "source"})
+	target := createStack(t, stacks, projectID, nil, map[string]string{"awsSecretAccessKey": "target"})
+
+	rawConfig := func(id uuid.UUID) string {
+		t.Helper()
+		var config string
+		if err := db.Table("stacks").Select("config").Where("id = ?", id).Scan(&config).Error; err != nil {
+			t.Fatalf("failed to read the config: %v", err)
+		}
+		return config
+	}
+	setRawConfig := func(id uuid.UUID, config string) {
+		t.Helper()
+		if err := db.Table("stacks").Where("id = ?", id).Update("config", config).Error; err != nil {
+			t.Fatalf("failed to write the config: %v", err)
+		}
+	}
+
+	// A secret copied to another row cannot be decrypted there
+	setRawConfig(target.ID, rawConfig(source.ID))
+	if _, err := stacks.GetStackByID(target.ID.String()); err == nil {
+		t.Error("decrypted the secret of another stack")
+	}
+
+	// The secrets encrypted before they were bound to their row are still read, and bound by the re-encryption
+	legacy, err := keyring.Encrypt([]byte("legacy"), []byte("awsSecretAccessKey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyConfig := `{"awsSecretAccessKey":"` + legacy + `"}`
+	setRawConfig(target.ID, legacyConfig)
+	readSecret := func() string {
+		t.Helper()
+		stack, err := stacks.GetStackByID(target.ID.String())
+		if err != nil {
+			t.Fatalf("failed to get the stack: %v", err)
+		}
+		var config map[string]string
+		if err := json.Unmarshal(stack.Config, &config); err != nil {
+			t.Fatal(err)
+		}
+		return config["awsSecretAccessKey"]
+	}
+	if secret := readSecret(); secret != "legacy" {
+		t.Errorf("secret = %q, want legacy", secret)
+	}
+	if _, err := repositories.ReencryptSecrets(db, keyring); err != nil {
+		t.Fatalf("failed to re-encrypt: %v", err)
+	}
+	if rawConfig(target.ID) == legacyConfig {
+		t.Error("the secret not bound to its row was not re-encrypted")
+	}
+	if secret := readSecret(); secret != "legacy" {
+		t.Errorf("secret = %q after the re-encryption, want legacy", secret)
+	}
+	updated, err := repositories.ReencryptSecrets(db, keyring)
+	if err != nil || updated != 0 {
+		t.Errorf("re-encrypted %d rows again, %v, want none", updated, err)
+	}
+}
@@ -4,26 +4,34 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/tokamak-network/trh-backend/internal/secrets"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type StackRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *secrets.Keyring
 }
 
-func NewStackRepository(db *gorm.DB) *StackRepository {
-	return &StackRepository{db: db}
+// NewStackRepository returns a repository encrypting the secrets of the configs with the keyring, when not nil
+func NewStackRepository(db *gorm.DB, keyring *secrets.Keyring) *StackRepository {
+	return &StackRepository{db: db, keyring: keyring}
 }
 
 func (r *StackRepository) CreateStack(
 	stack *entities.StackEntity,
 ) error {
-	newStack := ToStackEntity(stack)
-	err := r.db.Create(&newStack).Error
+	newStack, err := r.toStackSchema(stack)
+	if err != nil {
+		return err
+	}
+	err = r.db.Create(&newStack).Error
 	if err != nil {
 		return err
 	}
@@ -34,53 +42,154 @@ func (r *StackRepository) CreateStackByTx(
 	stack *entities.StackEntity,
 	deployments []*entities.DeploymentEntity,
 	integrations []*entities.IntegrationEntity,
+	requestID string,
 ) error {
-	tx := r.db.Begin()
-	err := tx.Create(ToStackEntity(stack)).Error
+	newStack, err := r.toStackSchema(stack)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	deploymentsSchema := make([]*schemas.Deployment, 0)
-	for _, deployment := range deployments {
-		deploymentsSchema = append(deploymentsSchema, ToDeploymentSchema(deployment))
-	}
-	err = tx.Create(deploymentsSchema).Error
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newStack).Error; err != nil {
+			return err
+		}
 
-	integrationsSchema := make([]*schemas.Integration, 0)
-	for _, integration := range integrations {
-		integrationsSchema = append(integrationsSchema, ToIntegrationSchema(integration))
-	}
-	err = tx.Create(integrationsSchema).Error
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+		err := recordStackTransition(tx, &schemas.Stack{ID: newStack.ID, ProjectID: newStack.ProjectID}, newStack.Status, "created", requestID)
+		if err != nil {
+			return err
+		}
+		err = recordStatusTransitions(tx, newStatusTransition(
+			newStack.ID, entities.StatusSubjectStack, newStack.ID, "", string(newStack.Status), "created", requestID,
+		))
+		if err != nil {
+			return err
+		}
+
+		if len(deployments) > 0 {
+			deploymentsSchema := make([]*schemas.Deployment, 0)
+			for _, deployment := range deployments {
+				deploymentSchema := ToDeploymentSchema(deployment)
+				if deploymentSchema.Config, err = encryptConfig(r.keyring, deploymentSchema.ID.String(), deployment.Config); err != nil {
+					return err
+				}
+				deploymentsSchema = append(deploymentsSchema, deploymentSchema)
+			}
+			if err := tx.Create(deploymentsSchema).Error; err != nil {
+				return err
+			}
+		}
 
-	return tx.Commit().Error
+		if len(integrations) > 0 {
+			integrationsSchema := make([]*schemas.Integration, 0)
+			for _, integration := range integrations {
+				integrationSchema := ToIntegrationSchema(integration)
+				if integrationSchema.Config, err = encryptConfig(r.keyring, integrationSchema.ID.String(), integration.Config); err != nil {
+					return err
+				}
+				integrationsSchema = append(integrationsSchema, integrationSchema)
+			}
+			if err := tx.Create(integrationsSchema).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (r *StackRepository) DeleteStack(
+// ArchiveStack soft deletes the stack along with its deployments and integrations. The archived rows are hidden from
+// the queries but kept, with the audit trail and the config revisions of the stack.
+func (r *StackRepository) ArchiveStack(
 	id string,
+	requestID string,
 ) error {
-	return r.db.Delete(&schemas.Stack{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stack schemas.Stack
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "project_id", "status").
+			Where("id = ?", id).
+			First(&stack).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("stack_id = ?", id).Delete(&schemas.Deployment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("stack_id = ?", id).Delete(&schemas.Integration{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).Delete(&schemas.Stack{}).Error; err != nil {
+			return err
+		}
+		return recordStackArchival(tx, &stack, requestID)
+	})
 }
 
+// UpdateStatus moves the stack to the status, it fails with entities.ErrInvalidStatusTransition when the transition is
+// not allowed from the current status
 func (r *StackRepository) UpdateStatus(
 	id string,
 	status entities.StackStatus,
 	reason string,
+	requestID string,
 ) error {
-	if reason == "" {
-		return r.db.Model(&schemas.Stack{}).Where("id = ?", id).Update("status", status).Error
-	} else {
-		return r.db.Model(&schemas.Stack{}).Where("id = ?", id).Update("status", status).Update("reason", reason).Error
-	}
+	return r.updateStatus(id, nil, status, reason, requestID)
+}
+
+// UpdateStatusAtVersion is UpdateStatus for a stack read at the version, it fails with entities.ErrVersionConflict when
+// the stack changed since
+func (r *StackRepository) UpdateStatusAtVersion(
+	id string,
+	version int64,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	return r.updateStatus(id, &version, status, reason, requestID)
+}
+
+func (r *StackRepository) updateStatus(
+	id string,
+	version *int64,
+	status entities.StackStatus,
+	reason string,
+	requestID string,
+) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stacks []schemas.Stack
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "project_id", "status").
+			Where("id = ?", id).
+			Limit(1).
+			Find(&stacks).Error
+		if err != nil {
+			return err
+		}
+		if len(stacks) > 0 && !stacks[0].Status.CanTransitionTo(status) {
+			return invalidTransitionError(entities.StatusSubjectStack, stacks[0].ID, string(stacks[0].Status), string(status))
+		}
+
+		updates := map[string]interface{}{"status": status, "version": nextVersion()}
+		if reason != "" {
+			updates["reason"] = reason
+		}
+		result := whereVersion(tx.Model(&schemas.Stack{}).Where("id = ?", id), version).Updates(updates)
+		if err := checkVersion(result, "stack", id, version); err != nil {
+			return err
+		}
+		if len(stacks) > 0 {
+			if err := recordStackTransition(tx, &stacks[0], status, reason, requestID); err != nil {
+				return err
+			}
+			err := recordStatusTransitions(tx, newStatusTransition(
+				stacks[0].ID, entities.StatusSubjectStack, stacks[0].ID, string(stacks[0].Status), string(status), reason, requestID,
+			))
+			if err != nil {
+				return err
+			}
+		}
+		return enqueueStackEvent(tx, id, status, reason)
+	})
 }
 
 func (r *StackRepository) UpdateMetadata(
@@ -94,9 +203,73 @@ func (r *StackRepository) UpdateMetadata(
 	if err != nil {
 		return err
 	}
-	return r.db.Model(&schemas.Stack{}).Where("id = ?", id).Update("metadata", b).Error
+	return r.db.Model(&schemas.Stack{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"metadata": b,
+		"version":  nextVersion(),
+	}).Error
 }
 
+// UpdateConfig replaces the config of the stack read at the version and records it as a new revision. The config the
+// stack was created with is recorded as the first revision when the stack has none yet. It fails with
+// entities.ErrVersionConflict when the stack changed since it was read.
+func (r *StackRepository) UpdateConfig(
+	id string,
+	version int64,
+	config json.RawMessage,
+	reason string,
+	requestID string,
+) error {
+	encrypted, err := encryptConfig(r.keyring, id, config)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stack schemas.Stack
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&stack).Error; err != nil {
+			return err
+		}
+		if stack.Version != version {
+			return versionConflictError("stack", id, version)
+		}
+
+		var revision int
+		err := tx.Model(&schemas.StackConfigRevision{}).
+			Where("stack_id = ?", stack.ID).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&revision).Error
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			revision++
+			if err := tx.Create(&schemas.StackConfigRevision{
+				StackID:  stack.ID,
+				Revision: revision,
+				Config:   stack.Config,
+				Reason:   "created",
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&schemas.StackConfigRevision{
+			StackID:   stack.ID,
+			Revision:  revision + 1,
+			Config:    encrypted,
+			Reason:    reason,
+			RequestID: requestID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&schemas.Stack{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"config":  encrypted,
+			"version": nextVersion(),
+		}).Error
+	})
+}
+
+// GetStackByID returns the stack, nil if there is none
 func (r *StackRepository) GetStackByID(
 	id string,
 ) (*entities.StackEntity, error) {
@@ -104,51 +277,96 @@ func (r *StackRepository) GetStackByID(
 	err := r.db.Where("id = ?", id).First(&stack).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("stack with id %s not found", id)
+			return nil, nil
 		}
 		return nil, err
 	}
 
-	metadata, err := entities.FromJSONToStackMetadata(json.RawMessage(stack.Metadata))
+	return r.fromStackSchema(&stack)
+}
+
+// StackExists reports whether a stack has the id, archived stacks included
+func (r *StackRepository) StackExists(
+	id string,
+) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&schemas.Stack{}).Where("id = ?", id).Count(&count).Error
 	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetStackByDeploymentPath returns the stack deployed in the directory, nil if there is none
+func (r *StackRepository) GetStackByDeploymentPath(
+	deploymentPath string,
+) (*entities.StackEntity, error) {
+	var stack schemas.Stack
+	err := r.db.Where("deployment_path = ?", deploymentPath).First(&stack).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return &entities.StackEntity{
-		ID:             stack.ID,
-		Name:           stack.Name,
-		Network:        stack.Network,
-		Config:         json.RawMessage(stack.Config),
-		Metadata:       metadata,
-		DeploymentPath: stack.DeploymentPath,
-		Status:         stack.Status,
-	}, nil
+	return r.fromStackSchema(&stack)
 }
 
-func (r *StackRepository) GetAllStacks() ([]*entities.StackEntity, error) {
+// GetAllStacks returns the stacks carrying every label of the selector, an empty selector matches all stacks.
+// Archived stacks are only returned with includeArchived.
+func (r *StackRepository) GetAllStacks(
+	labelSelector map[string]string,
+	includeArchived bool,
+) ([]*entities.StackEntity, error) {
 	var stacks []schemas.Stack
-	err := r.db.Find(&stacks).Error
+	query, err := withLabelSelector(withArchived(r.db, includeArchived), labelSelector)
 	if err != nil {
 		return nil, err
 	}
-	stacksEntities := make([]*entities.StackEntity, len(stacks))
-	for i, stack := range stacks {
-		metadata, err := entities.FromJSONToStackMetadata(json.RawMessage(stack.Metadata))
-		if err != nil {
-			return nil, err
-		}
+	err = query.Find(&stacks).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.fromStackSchemas(stacks)
+}
 
-		stacksEntities[i] = &entities.StackEntity{
-			ID:             stack.ID,
-			Name:           stack.Name,
-			Network:        stack.Network,
-			Config:         json.RawMessage(stack.Config),
-			Metadata:       metadata,
-			DeploymentPath: stack.DeploymentPath,
-			Status:         stack.Status,
-		}
+func (r *StackRepository) GetStacksByProjectIDs(
+	projectIDs []string,
+	labelSelector map[string]string,
+	includeArchived bool,
+) ([]*entities.StackEntity, error) {
+	if len(projectIDs) == 0 {
+		return []*entities.StackEntity{}, nil
 	}
-	return stacksEntities, nil
+	var stacks []schemas.Stack
+	query, err := withLabelSelector(withArchived(r.db, includeArchived), labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	err = query.Where("project_id IN ?", projectIDs).Find(&stacks).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.fromStackSchemas(stacks)
+}
+
+// UpdateDetails replaces the name, description and labels of the stack read at the version, it fails with
+// entities.ErrVersionConflict when the stack changed since
+func (r *StackRepository) UpdateDetails(
+	id string,
+	version int64,
+	name string,
+	description string,
+	labels map[string]string,
+) error {
+	result := r.db.Model(&schemas.Stack{}).Where("id = ? AND version = ?", id, version).Updates(map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"labels":      toLabelsJSON(labels),
+		"version":     nextVersion(),
+	})
+	return checkVersion(result, "stack", id, &version)
 }
 
 func (r *StackRepository) GetStackStatus(
@@ -165,10 +383,114 @@ func (r *StackRepository) GetStackStatus(
 func ToStackEntity(s *entities.StackEntity) *schemas.Stack {
 	return &schemas.Stack{
 		ID:             s.ID,
+		ProjectID:      s.ProjectID,
 		Name:           s.Name,
+		Description:    s.Description,
+		Labels:         toLabelsJSON(s.Labels),
 		Network:        s.Network,
 		Config:         datatypes.JSON(s.Config),
 		DeploymentPath: s.DeploymentPath,
 		Status:         s.Status,
 	}
 }
+
+func FromStackSchema(stack *schemas.Stack) (*entities.StackEntity, error) {
+	metadata, err := entities.FromJSONToStackMetadata(json.RawMessage(stack.Metadata))
+	if err != nil {
+		return nil, err
+	}
+
+	var archivedAt *time.Time
+	if stack.DeletedAt.Valid {
+		archivedAt = &stack.DeletedAt.Time
+	}
+
+	return &entities.StackEntity{
+		ID:             stack.ID,
+		ProjectID:      stack.ProjectID,
+		Name:           stack.Name,
+		Description:    stack.Description,
+		Labels:         stack.Labels.Data(),
+		Network:        stack.Network,
+		Config:         json.RawMessage(stack.Config),
+		Metadata:       metadata,
+		DeploymentPath: stack.DeploymentPath,
+		Status:         stack.Status,
+		ArchivedAt:     archivedAt,
+		Version:        stack.Version,
+	}, nil
+}
+
+// withArchived lifts the soft delete scope of the query to include the archived rows
+func withArchived(db *gorm.DB, includeArchived bool) *gorm.DB {
+	if includeArchived {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// withLabelSelector restricts the query to the stacks whose labels contain the selector. SQLite has no containment
+// operator, each label of the selector is matched on its own.
+func withLabelSelector(db *gorm.DB, labelSelector map[string]string) (*gorm.DB, error) {
+	if len(labelSelector) == 0 {
+		return db, nil
+	}
+	if db.Dialector.Name() == "sqlite" {
+		for key, value := range labelSelector {
+			path, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			// The labels are written as a blob, which the JSON functions of SQLite only read as text
+			db = db.Where("json_extract(CAST(labels AS text), ?) = ?", "$."+string(path), value)
+		}
+		return db, nil
+	}
+	b, err := json.Marshal(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	return db.Where("labels @> ?::jsonb", string(b)), nil
+}
+
+func toLabelsJSON(labels map[string]string) datatypes.JSONType[map[string]string] {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return datatypes.NewJSONType(labels)
+}
+
+// toStackSchema maps the stack to its row, with the secrets of its config encrypted
+func (r *StackRepository) toStackSchema(stack *entities.StackEntity) (*schemas.Stack, error) {
+	schema := ToStackEntity(stack)
+	config, err := encryptConfig(r.keyring, schema.ID.String(), stack.Config)
+	if err != nil {
+		return nil, err
+	}
+	schema.Config = config
+	return schema, nil
+}
+
+// fromStackSchema maps the row to its stack, with the secrets of its config decrypted
+func (r *StackRepository) fromStackSchema(schema *schemas.Stack) (*entities.StackEntity, error) {
+	stack, err := FromStackSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if stack.Config, err = decryptConfig(r.keyring, schema.ID.String(), schema.Config); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func (r *StackRepository) fromStackSchemas(stacks []schemas.Stack) ([]*entities.StackEntity, error) {
+	stacksEntities := make([]*entities.StackEntity, len(stacks))
+	for i := range stacks {
+		stack, err := r.fromStackSchema(&stacks[i])
+		if err != nil {
+			return nil, err
+		}
+		stacksEntities[i] = stack
+	}
+	return stacksEntities, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/gorm"
+)
+
+// GetStatusTransitions returns the history of the stack and of its deployments and integrations, oldest first
+func (r *StackRepository) GetStatusTransitions(
+	id string,
+) ([]*entities.StatusTransitionEntity, error) {
+	var transitions []schemas.StatusTransition
+	if err := r.db.Where("stack_id = ?", id).Order("created_at asc").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	transitionEntities := make([]*entities.StatusTransitionEntity, len(transitions))
+	for i := range transitions {
+		transitionEntities[i] = ToStatusTransitionEntity(&transitions[i])
+	}
+	return transitionEntities, nil
+}
+
+// recordStatusTransitions appends the transitions to the history. It must be called with the transaction updating the
+// statuses, the transitions keeping the same status are skipped.
+func recordStatusTransitions(tx *gorm.DB, transitions ...*schemas.StatusTransition) error {
+	records := make([]*schemas.StatusTransition, 0, len(transitions))
+	for _, transition := range transitions {
+		if transition.FromStatus != transition.ToStatus {
+			records = append(records, transition)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return tx.Create(records).Error
+}
+
+func newStatusTransition(
+	stackID uuid.UUID,
+	subject entities.StatusSubject,
+	subjectID uuid.UUID,
+	from string,
+	to string,
+	reason string,
+	requestID string,
+) *schemas.StatusTransition {
+	return &schemas.StatusTransition{
+		ID:         uuid.New(),
+		StackID:    stackID,
+		Subject:    subject,
+		SubjectID:  subjectID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		RequestID:  requestID,
+	}
+}
+
+func invalidTransitionError(subject entities.StatusSubject, id uuid.UUID, from string, to string) error {
+	return fmt.Errorf("%w: %s %s cannot move from %s to %s", entities.ErrInvalidStatusTransition, subject, id, from, to)
+}
+
+func ToStatusTransitionEntity(transition *schemas.StatusTransition) *entities.StatusTransitionEntity {
+	return &entities.StatusTransitionEntity{
+		ID:         transition.ID,
+		StackID:    transition.StackID,
+		Subject:    transition.Subject,
+		SubjectID:  transition.SubjectID,
+		FromStatus: transition.FromStatus,
+		ToStatus:   transition.ToStatus,
+		Reason:     transition.Reason,
+		RequestID:  transition.RequestID,
+		CreatedAt:  transition.CreatedAt,
+	}
+}
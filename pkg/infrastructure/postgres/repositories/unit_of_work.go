@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"gorm.io/gorm"
+)
+
+type UnitOfWork struct {
+	db      *gorm.DB
+	keyring *secrets.Keyring
+}
+
+// NewUnitOfWork returns a unit of work whose repositories encrypt the secrets of the configs with the keyring, when
+// not nil
+func NewUnitOfWork(db *gorm.DB, keyring *secrets.Keyring) *UnitOfWork {
+	return &UnitOfWork{db: db, keyring: keyring}
+}
+
+// Do runs fn in a transaction. The transactions opened by the repository methods become savepoints of it.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(repos services.Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(services.Repositories{
+			Deployments:  NewDeploymentRepository(tx, u.keyring),
+			Stacks:       NewStackRepository(tx, u.keyring),
+			Integrations: NewIntegrationRepository(tx, u.keyring),
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) CreateUser(
+	user *entities.UserEntity,
+	apiKeyHash string,
+) error {
+	return r.db.Create(ToUserSchema(user, apiKeyHash)).Error
+}
+
+func (r *UserRepository) UpdateAPIKeyHash(
+	id string,
+	apiKeyHash string,
+) error {
+	return r.db.Model(&schemas.User{}).Where("id = ?", id).Update("api_key_hash", apiKeyHash).Error
+}
+
+func (r *UserRepository) GetUserByID(
+	id string,
+) (*entities.UserEntity, error) {
+	var user schemas.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No user found
+		}
+		return nil, err
+	}
+	return ToUserEntity(&user), nil
+}
+
+func (r *UserRepository) GetUserByEmail(
+	email string,
+) (*entities.UserEntity, error) {
+	var user schemas.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No user found
+		}
+		return nil, err
+	}
+	return ToUserEntity(&user), nil
+}
+
+func (r *UserRepository) GetUserByAPIKeyHash(
+	apiKeyHash string,
+) (*entities.UserEntity, error) {
+	var user schemas.User
+	if err := r.db.Where("api_key_hash = ?", apiKeyHash).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No user found
+		}
+		return nil, err
+	}
+	return ToUserEntity(&user), nil
+}
+
+func (r *UserRepository) GetAllUsers() ([]*entities.UserEntity, error) {
+	var users []schemas.User
+	if err := r.db.Order("created_at asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userEntities := make([]*entities.UserEntity, len(users))
+	for i, user := range users {
+		userEntities[i] = ToUserEntity(&user)
+	}
+	return userEntities, nil
+}
+
+func ToUserSchema(user *entities.UserEntity, apiKeyHash string) *schemas.User {
+	return &schemas.User{
+		ID:         user.ID,
+		Name:       user.Name,
+		Email:      user.Email,
+		APIKeyHash: apiKeyHash,
+		IsAdmin:    user.IsAdmin,
+	}
+}
+
+func ToUserEntity(user *schemas.User) *entities.UserEntity {
+	return &entities.UserEntity{
+		ID:      user.ID,
+		Name:    user.Name,
+		Email:   user.Email,
+		IsAdmin: user.IsAdmin,
+	}
+}
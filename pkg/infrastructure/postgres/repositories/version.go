@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// nextVersion increments the version of the updated rows. Every update of a stack or an integration goes through it,
+// so that the conditional updates fail when the row changed since it was read.
+func nextVersion() clause.Expr {
+	return gorm.Expr("version + 1")
+}
+
+// whereVersion restricts the update to the row still at the version, when given
+func whereVersion(db *gorm.DB, version *int64) *gorm.DB {
+	if version == nil {
+		return db
+	}
+	return db.Where("version = ?", *version)
+}
+
+// checkVersion returns entities.ErrVersionConflict when a conditional update matched no row
+func checkVersion(result *gorm.DB, table string, id string, version *int64) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if version != nil && result.RowsAffected == 0 {
+		return versionConflictError(table, id, *version)
+	}
+	return nil
+}
+
+func versionConflictError(table string, id string, version int64) error {
+	return fmt.Errorf("%w: %s %s is no longer at version %d", entities.ErrVersionConflict, table, id, version)
+}
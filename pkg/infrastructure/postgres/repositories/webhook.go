@@ -0,0 +1,419 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/schemas"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deliveryLease is how long a claimed delivery is hidden from other dispatchers while it is being sent
+const deliveryLease = time.Minute
+
+var stackStatusEvents = map[entities.StackStatus]entities.WebhookEventType{
+	entities.StackStatusDeployed:       entities.WebhookEventStackDeployed,
+	entities.StackStatusFailedToDeploy: entities.WebhookEventStackFailedToDeploy,
+	entities.StackStatusTerminated:     entities.WebhookEventStackTerminated,
+}
+
+var integrationStatusEvents = map[entities.DeploymentStatus]entities.WebhookEventType{
+	entities.DeploymentStatusCompleted: entities.WebhookEventIntegrationInstalled,
+	entities.DeploymentStatusFailed:    entities.WebhookEventIntegrationFailedToInstall,
+}
+
+// webhookSecretField is the field the secret of a subscription is bound to when encrypted
+const webhookSecretField = "secret"
+
+type WebhookRepository struct {
+	db      *gorm.DB
+	keyring *secrets.Keyring
+}
+
+// NewWebhookRepository returns a repository encrypting the secrets of the subscriptions with the keyring, when not nil
+func NewWebhookRepository(db *gorm.DB, keyring *secrets.Keyring) *WebhookRepository {
+	return &WebhookRepository{db: db, keyring: keyring}
+}
+
+func (r *WebhookRepository) CreateSubscription(
+	subscription *entities.WebhookSubscriptionEntity,
+) error {
+	newSubscription, err := ToWebhookSubscriptionSchema(subscription)
+	if err != nil {
+		return err
+	}
+	if newSubscription.Secret, err = encryptWebhookSecret(r.keyring, newSubscription.ID.String(), subscription.Secret); err != nil {
+		return err
+	}
+	return r.db.Create(newSubscription).Error
+}
+
+func (r *WebhookRepository) GetSubscriptionByID(
+	id string,
+) (*entities.WebhookSubscriptionEntity, error) {
+	var subscription schemas.WebhookSubscription
+	if err := r.db.Where("id = ?", id).First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No subscription found
+		}
+		return nil, err
+	}
+	return r.toSubscriptionEntity(&subscription)
+}
+
+func (r *WebhookRepository) GetSubscriptionsByProjectID(
+	projectID string,
+) ([]*entities.WebhookSubscriptionEntity, error) {
+	var subscriptions []schemas.WebhookSubscription
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at asc").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	subscriptionEntities := make([]*entities.WebhookSubscriptionEntity, len(subscriptions))
+	for i := range subscriptions {
+		subscription, err := r.toSubscriptionEntity(&subscriptions[i])
+		if err != nil {
+			return nil, err
+		}
+		subscriptionEntities[i] = subscription
+	}
+	return subscriptionEntities, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(
+	id string,
+) error {
+	return r.db.Where("id = ?", id).Delete(&schemas.WebhookSubscription{}).Error
+}
+
+func (r *WebhookRepository) GetDeliveriesBySubscriptionID(
+	subscriptionID string,
+	limit int,
+) ([]*entities.WebhookDeliveryEntity, error) {
+	var deliveries []schemas.WebhookDelivery
+	if err := r.db.
+		Where("subscription_id = ?", subscriptionID).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	deliveryEntities := make([]*entities.WebhookDeliveryEntity, len(deliveries))
+	for i := range deliveries {
+		deliveryEntities[i] = ToWebhookDeliveryEntity(&deliveries[i])
+	}
+	return deliveryEntities, nil
+}
+
+// DispatchEvents fans pending outbox events out to a delivery per matching subscription and marks them dispatched.
+// It returns the number of dispatched events.
+func (r *WebhookRepository) DispatchEvents(limit int) (int, error) {
+	dispatched := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var events []schemas.WebhookEvent
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL").
+			Order("created_at asc").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, event := range events {
+			if event.ProjectID != nil {
+				var subscriptions []schemas.WebhookSubscription
+				if err := tx.Where("project_id = ?", *event.ProjectID).Find(&subscriptions).Error; err != nil {
+					return err
+				}
+				for i := range subscriptions {
+					subscription, err := ToWebhookSubscriptionEntity(&subscriptions[i])
+					if err != nil {
+						return err
+					}
+					if !subscription.Matches(event.Type) {
+						continue
+					}
+					if err := tx.Create(&schemas.WebhookDelivery{
+						ID:             uuid.New(),
+						SubscriptionID: subscription.ID,
+						EventID:        event.ID,
+						EventType:      event.Type,
+						Status:         entities.WebhookDeliveryStatusPending,
+						NextAttemptAt:  now,
+					}).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := tx.Model(&schemas.WebhookEvent{}).Where("id = ?", event.ID).Update("dispatched_at", now).Error; err != nil {
+				return err
+			}
+			dispatched++
+		}
+		return nil
+	})
+	return dispatched, err
+}
+
+// ClaimDueDeliveries returns the pending deliveries whose next attempt is due and leases them,
+// so concurrent dispatchers do not send the same delivery twice
+func (r *WebhookRepository) ClaimDueDeliveries(limit int) ([]*entities.WebhookDeliveryJob, error) {
+	var jobs []*entities.WebhookDeliveryJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var deliveries []schemas.WebhookDelivery
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", entities.WebhookDeliveryStatusPending).
+			Where("next_attempt_at <= ?", time.Now()).
+			Order("next_attempt_at asc").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		if err := tx.Model(&schemas.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(deliveryLease)).Error; err != nil {
+			return err
+		}
+
+		for i := range deliveries {
+			var subscription schemas.WebhookSubscription
+			if err := tx.Where("id = ?", deliveries[i].SubscriptionID).First(&subscription).Error; err != nil {
+				return err
+			}
+			var event schemas.WebhookEvent
+			if err := tx.Where("id = ?", deliveries[i].EventID).First(&event).Error; err != nil {
+				return err
+			}
+			subscriptionEntity, err := r.toSubscriptionEntity(&subscription)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, &entities.WebhookDeliveryJob{
+				Delivery:     ToWebhookDeliveryEntity(&deliveries[i]),
+				Subscription: subscriptionEntity,
+				Event:        ToWebhookEventEntity(&event),
+			})
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func (r *WebhookRepository) MarkDeliverySucceeded(
+	id string,
+	attempts int,
+	responseStatus int,
+) error {
+	return r.db.Model(&schemas.WebhookDelivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          entities.WebhookDeliveryStatusSucceeded,
+		"attempts":        attempts,
+		"response_status": responseStatus,
+		"error":           "",
+		"delivered_at":    time.Now(),
+	}).Error
+}
+
+// MarkDeliveryFailed records a failed attempt. A nil nextAttemptAt gives up on the delivery.
+func (r *WebhookRepository) MarkDeliveryFailed(
+	id string,
+	attempts int,
+	responseStatus int,
+	reason string,
+	nextAttemptAt *time.Time,
+) error {
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": responseStatus,
+		"error":           reason,
+	}
+	if nextAttemptAt == nil {
+		updates["status"] = entities.WebhookDeliveryStatusFailed
+	} else {
+		updates["next_attempt_at"] = *nextAttemptAt
+	}
+	return r.db.Model(&schemas.WebhookDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// enqueueStackEvent writes the webhook event of a stack status change to the outbox.
+// It must be called with the transaction updating the status.
+func enqueueStackEvent(
+	tx *gorm.DB,
+	stackID string,
+	status entities.StackStatus,
+	reason string,
+) error {
+	eventType, ok := stackStatusEvents[status]
+	if !ok {
+		return nil
+	}
+
+	var stack schemas.Stack
+	if err := tx.Select("id", "project_id").Where("id = ?", stackID).First(&stack).Error; err != nil {
+		return err
+	}
+
+	return createWebhookEvent(tx, eventType, stack.ProjectID, &stack.ID, entities.StackEventData{
+		StackID:   stack.ID,
+		ProjectID: stack.ProjectID,
+		Status:    status,
+		Reason:    reason,
+	})
+}
+
+// enqueueIntegrationEvent writes the webhook event of a finished integration install to the outbox.
+// It must be called with the transaction updating the status.
+func enqueueIntegrationEvent(
+	tx *gorm.DB,
+	integrationID string,
+	status entities.DeploymentStatus,
+	reason string,
+) error {
+	eventType, ok := integrationStatusEvents[status]
+	if !ok {
+		return nil
+	}
+
+	var integration schemas.Integration
+	if err := tx.Preload("Stack").Where("id = ?", integrationID).First(&integration).Error; err != nil {
+		return err
+	}
+
+	var projectID *uuid.UUID
+	if integration.Stack != nil {
+		projectID = integration.Stack.ProjectID
+	}
+
+	return createWebhookEvent(tx, eventType, projectID, integration.StackID, entities.IntegrationEventData{
+		IntegrationID: integration.ID,
+		StackID:       integration.StackID,
+		ProjectID:     projectID,
+		Type:          integration.Type,
+		Status:        status,
+		Reason:        reason,
+		Info:          json.RawMessage(integration.Info),
+	})
+}
+
+func createWebhookEvent(
+	tx *gorm.DB,
+	eventType entities.WebhookEventType,
+	projectID *uuid.UUID,
+	stackID *uuid.UUID,
+	data interface{},
+) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&schemas.WebhookEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		ProjectID: projectID,
+		StackID:   stackID,
+		Data:      datatypes.JSON(b),
+	}).Error
+}
+
+func ToWebhookSubscriptionSchema(
+	subscription *entities.WebhookSubscriptionEntity,
+) (*schemas.WebhookSubscription, error) {
+	events := subscription.Events
+	if events == nil {
+		events = []entities.WebhookEventType{}
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	return &schemas.WebhookSubscription{
+		ID:        subscription.ID,
+		ProjectID: subscription.ProjectID,
+		URL:       subscription.URL,
+		Events:    datatypes.JSON(b),
+		Secret:    subscription.Secret,
+	}, nil
+}
+
+func ToWebhookSubscriptionEntity(
+	subscription *schemas.WebhookSubscription,
+) (*entities.WebhookSubscriptionEntity, error) {
+	var events []entities.WebhookEventType
+	if len(subscription.Events) > 0 {
+		if err := json.Unmarshal(subscription.Events, &events); err != nil {
+			return nil, err
+		}
+	}
+	return &entities.WebhookSubscriptionEntity{
+		ID:        subscription.ID,
+		ProjectID: subscription.ProjectID,
+		URL:       subscription.URL,
+		Events:    events,
+		Secret:    subscription.Secret,
+		CreatedAt: subscription.CreatedAt,
+	}, nil
+}
+
+// toSubscriptionEntity maps the row to its subscription, with its secret decrypted
+func (r *WebhookRepository) toSubscriptionEntity(
+	schema *schemas.WebhookSubscription,
+) (*entities.WebhookSubscriptionEntity, error) {
+	subscription, err := ToWebhookSubscriptionEntity(schema)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Secret, err = r.keyring.DecryptField(schema.Secret, schema.ID.String(), webhookSecretField); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func encryptWebhookSecret(keyring *secrets.Keyring, subscriptionID string, secret string) (string, error) {
+	if keyring != nil && (subscriptionID == "" || subscriptionID == uuid.Nil.String()) {
+		return "", errors.New("the subscription id is required to encrypt its secret")
+	}
+	return keyring.EncryptField(secret, subscriptionID, webhookSecretField)
+}
+
+func ToWebhookEventEntity(event *schemas.WebhookEvent) *entities.WebhookEventEntity {
+	return &entities.WebhookEventEntity{
+		ID:        event.ID,
+		Type:      event.Type,
+		ProjectID: event.ProjectID,
+		StackID:   event.StackID,
+		Data:      json.RawMessage(event.Data),
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+func ToWebhookDeliveryEntity(delivery *schemas.WebhookDelivery) *entities.WebhookDeliveryEntity {
+	return &entities.WebhookDeliveryEntity{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
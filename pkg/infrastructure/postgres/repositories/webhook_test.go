@@ -0,0 +1,263 @@
+package repositories_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/secrets"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/postgres/repositories"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"gorm.io/gorm"
+)
+
+// webhookReceiver is a webhook endpoint answering the scripted status codes, then 200
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.requests = append(receiver.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+// subscribe subscribes the URL to the events of the project as its owner, and returns the subscription and its secret
+func subscribe(
+	t *testing.T,
+	db *gorm.DB,
+	webhooks *repositories.WebhookRepository,
+	projectID uuid.UUID,
+	url string,
+) (*entities.WebhookSubscriptionEntity, string) {
+	t.Helper()
+	owner, err := repositories.NewUserRepository(db).GetUserByEmail("owner@thanos.test")
+	if err != nil || owner == nil {
+		t.Fatalf("failed to get the owner: %v", err)
+	}
+	access := services.NewAccessService(
+		repositories.NewUserRepository(db),
+		repositories.NewProjectRepository(db),
+		repositories.NewStackRepository(db, nil),
+	)
+	response, err := services.NewWebhookService(webhooks, access).CreateSubscription(
+		context.Background(), owner, projectID, dtos.CreateWebhookRequest{URL: url},
+	)
+	if err != nil || response.Status != http.StatusOK {
+		t.Fatalf("failed to subscribe: %+v, %v", response, err)
+	}
+	data := response.Data.(map[string]interface{})
+	return data["webhook"].(*entities.WebhookSubscriptionEntity), data["secret"].(string)
+}
+
+// deployStack moves the stack to Deployed, which enqueues a stack.deployed event
+func deployStack(t *testing.T, stacks *repositories.StackRepository, stackID uuid.UUID) {
+	t.Helper()
+	for _, status := range []entities.StackStatus{entities.StackStatusDeploying, entities.StackStatusDeployed} {
+		if err := stacks.UpdateStatus(stackID.String(), status, "", "request"); err != nil {
+			t.Fatalf("failed to move the stack to %s: %v", status, err)
+		}
+	}
+}
+
+func getDelivery(
+	t *testing.T,
+	webhooks *repositories.WebhookRepository,
+	subscriptionID uuid.UUID,
+) *entities.WebhookDeliveryEntity {
+	t.Helper()
+	deliveries, err := webhooks.GetDeliveriesBySubscriptionID(subscriptionID.String(), 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("deliveries = %+v, %v, want one", deliveries, err)
+	}
+	return deliveries[0]
+}
+
+func TestWebhookDeliveryOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	projectID := createProject(t, db)
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	keyring, err := secrets.LoadKeyring("test:"+key, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks := repositories.NewWebhookRepository(db, keyring)
+	stacks := repositories.NewStackRepository(db, keyring)
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	subscription, secret := subscribe(t, db, webhooks, projectID, receiver.URL)
+
+	var storedSecret string
+	if err := db.Table("webhook_subscriptions").Select("secret").Where("id = ?", subscription.ID).Scan(&storedSecret).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !secrets.IsEncrypted(storedSecret) {
+		t.Errorf("stored secret = %q, want it encrypted", storedSecret)
+	}
+
+	stack := createStack(t, stacks, projectID, nil, nil)
+	deployStack(t, stacks, stack.ID)
+	dispatcher := services.NewWebhookDispatcher(webhooks)
+
+	// The first attempt fails with a 5xx and is retried after the backoff
+	dispatcher.Dispatch(context.Background())
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	delivery := getDelivery(t, webhooks, subscription.ID)
+	if delivery.Status != entities.WebhookDeliveryStatusPending || delivery.Attempts != 1 ||
+		delivery.ResponseStatus != http.StatusServiceUnavailable || delivery.Error == "" {
+		t.Errorf("delivery = %+v, want a pending delivery after a failed attempt", delivery)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < 25*time.Second || wait > 35*time.Second {
+		t.Errorf("next attempt in %s, want the 30s backoff", wait)
+	}
+	dispatcher.Dispatch(context.Background())
+	if len(receiver.received()) != 1 {
+		t.Error("retried the delivery before its backoff")
+	}
+
+	// The receiver verifies the signature of "<timestamp>.<body>" with the secret
+	request := requests[0]
+	if request.header.Get(services.WebhookEventHeader) != string(entities.WebhookEventStackDeployed) ||
+		request.header.Get(services.WebhookDeliveryHeader) != delivery.ID.String() {
+		t.Errorf("headers = %v, want the event and the delivery", request.header)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(request.header.Get(services.WebhookTimestampHeader) + "."))
+	mac.Write(request.body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := request.header.Get(services.WebhookSignatureHeader); got != signature {
+		t.Errorf("signature = %s, want %s", got, signature)
+	}
+	if !strings.Contains(string(request.body), stack.ID.String()) {
+		t.Errorf("body = %s, want the event of the stack", request.body)
+	}
+
+	// Once due, the delivery is retried and succeeds
+	if err := db.Table("webhook_deliveries").Where("id = ?", delivery.ID).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Dispatch(context.Background())
+	requests = receiver.received()
+	if len(requests) != 2 || requests[1].header.Get(services.WebhookDeliveryHeader) != delivery.ID.String() {
+		t.Fatalf("received %d requests, want the delivery retried", len(requests))
+	}
+	delivery = getDelivery(t, webhooks, subscription.ID)
+	if delivery.Status != entities.WebhookDeliveryStatusSucceeded || delivery.Attempts != 2 ||
+		delivery.ResponseStatus != http.StatusOK || delivery.DeliveredAt == nil {
+		t.Errorf("delivery = %+v, want it delivered on the second attempt", delivery)
+	}
+}
+
+func TestWebhookOutboxOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	projectID := createProject(t, db)
+	stacks := repositories.NewStackRepository(db, nil)
+	stack := createStack(t, stacks, projectID, nil, nil)
+
+	countEvents := func() int64 {
+		t.Helper()
+		var count int64
+		if err := db.Table("webhook_events").Where("stack_id = ?", stack.ID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	deployStack(t, stacks, stack.ID)
+	if count := countEvents(); count != 1 {
+		t.Errorf("%d events after the deployment, want 1", count)
+	}
+
+	// A rejected status update writes no event
+	if err := stacks.UpdateStatus(stack.ID.String(), entities.StackStatusPending, "", "request"); err == nil {
+		t.Fatal("moved the deployed stack back to Pending")
+	}
+	if count := countEvents(); count != 1 {
+		t.Errorf("%d events after a rejected update, want 1", count)
+	}
+
+	// The status is not updated when the event cannot be written, both are in the same transaction
+	if err := stacks.UpdateStatus(stack.ID.String(), entities.StackStatusTerminating, "", "request"); err != nil {
+		t.Fatalf("failed to move the stack to Terminating: %v", err)
+	}
+	if err := db.Migrator().DropTable("webhook_events"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stacks.UpdateStatus(stack.ID.String(), entities.StackStatusTerminated, "", "request"); err == nil {
+		t.Fatal("updated the status without writing its event")
+	}
+	current, err := stacks.GetStackByID(stack.ID.String())
+	if err != nil || current.Status != entities.StackStatusTerminating {
+		t.Errorf("stack = %+v, %v, want it still Terminating", current, err)
+	}
+}
+
+func TestReencryptWebhookSecretsOnSQLite(t *testing.T) {
+	db := newSQLiteDB(t)
+	projectID := createProject(t, db)
+	// The subscription is created before encryption was enabled
+	subscription, secret := subscribe(t, db, repositories.NewWebhookRepository(db, nil), projectID, "https://hooks.thanos.test")
+
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	keyring, err := secrets.LoadKeyring("test:"+key, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repositories.ReencryptSecrets(db, keyring)
+	if err != nil || updated != 1 {
+		t.Fatalf("re-encrypted %d rows, %v, want the webhook secret", updated, err)
+	}
+
+	var storedSecret string
+	if err := db.Table("webhook_subscriptions").Select("secret").Where("id = ?", subscription.ID).Scan(&storedSecret).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !secrets.IsEncrypted(storedSecret) {
+		t.Errorf("stored secret = %q, want it encrypted", storedSecret)
+	}
+	read, err := repositories.NewWebhookRepository(db, keyring).GetSubscriptionByID(subscription.ID.String())
+	if err != nil || read == nil || read.Secret != secret {
+		t.Errorf("subscription = %+v, %v, want the secret decrypted", read, err)
+	}
+}
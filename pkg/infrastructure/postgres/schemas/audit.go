@@ -0,0 +1,31 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+// AuditRecord is append-only, the rows outlive the stacks and users they refer to
+type AuditRecord struct {
+	ID            uuid.UUID            `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	Source        entities.AuditSource `gorm:"column:source;not null"`
+	ActorID       *uuid.UUID           `gorm:"type:uuid;column:actor_id;index"`
+	Actor         string               `gorm:"column:actor;not null"`
+	SourceIP      string               `gorm:"column:source_ip"`
+	ProjectID     *uuid.UUID           `gorm:"type:uuid;column:project_id;index"`
+	StackID       *uuid.UUID           `gorm:"type:uuid;column:stack_id;index"`
+	IntegrationID *uuid.UUID           `gorm:"type:uuid;column:integration_id"`
+	Action        string               `gorm:"column:action;not null"`
+	BeforeStatus  string               `gorm:"column:before_status"`
+	AfterStatus   string               `gorm:"column:after_status"`
+	Result        string               `gorm:"column:result"`
+	Reason        string               `gorm:"column:reason"`
+	RequestID     string               `gorm:"column:request_id;index"`
+	CreatedAt     time.Time            `gorm:"autoCreateTime;column:created_at;index"`
+}
+
+func (AuditRecord) TableName() string {
+	return "audit_records"
+}
@@ -0,0 +1,24 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+type Credential struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	ProjectID uuid.UUID `gorm:"type:uuid;column:project_id;not null;uniqueIndex:idx_credentials_project_name"`
+	Project   *Project  `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	Name      string    `gorm:"column:name;not null;uniqueIndex:idx_credentials_project_name"`
+	Provider  string    `gorm:"column:provider;not null"`
+	// Config holds the keys, with the secret encrypted like the stack configs
+	Config    datatypes.JSON `gorm:"type:jsonb;not null;column:config"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (Credential) TableName() string {
+	return "credentials"
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type Deployment struct {
@@ -16,9 +17,10 @@ type Deployment struct {
 	Status    entities.DeploymentStatus `gorm:"column:status;not null"`
 	Config    datatypes.JSON            `gorm:"type:jsonb;not null;column:config"`
 	LogPath   string                    `gorm:"column:log_path"`
+	RequestID string                    `gorm:"column:request_id;index"`
 	CreatedAt time.Time                 `gorm:"autoCreateTime;column:created_at"`
 	UpdatedAt time.Time                 `gorm:"autoUpdateTime;column:updated_at"`
-	DeletedAt time.Time                 `gorm:"autoUpdateTime;column:deleted_at"`
+	DeletedAt gorm.DeletedAt            `gorm:"index;column:deleted_at"`
 }
 
 func (Deployment) TableName() string {
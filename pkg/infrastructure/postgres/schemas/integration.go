@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type Integration struct {
@@ -18,9 +19,11 @@ type Integration struct {
 	Config    datatypes.JSON            `gorm:"column:config;type:jsonb;default:null"`
 	Info      datatypes.JSON            `gorm:"column:info;type:jsonb;default:null"`
 	Reason    string                    `gorm:"column:reason;default:null"`
+	RequestID string                    `gorm:"column:request_id;index"`
+	Version   int64                     `gorm:"column:version;not null;default:0"`
 	CreatedAt time.Time                 `gorm:"autoCreateTime;column:created_at"`
 	UpdatedAt time.Time                 `gorm:"autoUpdateTime;column:updated_at"`
-	DeletedAt time.Time                 `gorm:"autoUpdateTime;column:deleted_at"`
+	DeletedAt gorm.DeletedAt            `gorm:"index;column:deleted_at"`
 }
 
 func (Integration) TableName() string {
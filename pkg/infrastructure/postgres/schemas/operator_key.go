@@ -0,0 +1,24 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+type OperatorKey struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	ProjectID uuid.UUID `gorm:"type:uuid;column:project_id;not null;uniqueIndex:idx_operator_keys_project_name"`
+	Project   *Project  `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	Name      string    `gorm:"column:name;not null;uniqueIndex:idx_operator_keys_project_name"`
+	Address   string    `gorm:"column:address;not null;index"`
+	Origin    string    `gorm:"column:origin;not null"`
+	// Keystore is the Web3 Secret Storage JSON of the key, encrypted with the keystore passphrase
+	Keystore  datatypes.JSON `gorm:"type:jsonb;not null;column:keystore"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+}
+
+func (OperatorKey) TableName() string {
+	return "operator_keys"
+}
@@ -0,0 +1,34 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+type Project struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	Name        string    `gorm:"column:name;not null;uniqueIndex"`
+	Description string    `gorm:"column:description"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (Project) TableName() string {
+	return "projects"
+}
+
+type ProjectMember struct {
+	ProjectID uuid.UUID            `gorm:"type:uuid;primaryKey;column:project_id"`
+	Project   *Project             `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	UserID    uuid.UUID            `gorm:"type:uuid;primaryKey;column:user_id;index"`
+	User      *User                `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Role      entities.ProjectRole `gorm:"column:role;not null"`
+	CreatedAt time.Time            `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time            `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (ProjectMember) TableName() string {
+	return "project_members"
+}
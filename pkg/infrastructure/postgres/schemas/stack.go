@@ -6,22 +6,44 @@ import (
 	"github.com/google/uuid"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type Stack struct {
-	ID             uuid.UUID                  `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
-	Name           string                     `gorm:"column:name"`
-	Status         entities.StackStatus       `gorm:"not null;column:status"`
-	Reason         string                     `gorm:"column:reason"`
-	Network        entities.DeploymentNetwork `gorm:"not null;column:network"`
-	DeploymentPath string                     `gorm:"not null;column:deployment_path"`
-	Config         datatypes.JSON             `gorm:"type:jsonb;not null;column:config"`
-	Metadata       datatypes.JSON             `gorm:"type:jsonb;column:metadata"`
-	CreatedAt      time.Time                  `gorm:"autoCreateTime;column:created_at"`
-	UpdatedAt      time.Time                  `gorm:"autoUpdateTime;column:updated_at"`
-	DeletedAt      time.Time                  `gorm:"autoUpdateTime;column:deleted_at"`
+	ID             uuid.UUID                             `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	ProjectID      *uuid.UUID                            `gorm:"type:uuid;column:project_id;index"`
+	Project        *Project                              `gorm:"foreignKey:ProjectID"`
+	Name           string                                `gorm:"column:name"`
+	Description    string                                `gorm:"column:description"`
+	Labels         datatypes.JSONType[map[string]string] `gorm:"type:jsonb;not null;default:'{}';column:labels;index:idx_stacks_labels,type:gin"`
+	Status         entities.StackStatus                  `gorm:"not null;column:status"`
+	Reason         string                                `gorm:"column:reason"`
+	Network        entities.DeploymentNetwork            `gorm:"not null;column:network"`
+	DeploymentPath string                                `gorm:"not null;column:deployment_path"`
+	Config         datatypes.JSON                        `gorm:"type:jsonb;not null;column:config"`
+	Metadata       datatypes.JSON                        `gorm:"type:jsonb;column:metadata"`
+	Version        int64                                 `gorm:"not null;default:0;column:version"`
+	CreatedAt      time.Time                             `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt      time.Time                             `gorm:"autoUpdateTime;column:updated_at"`
+	DeletedAt      gorm.DeletedAt                        `gorm:"index;column:deleted_at"`
 }
 
 func (Stack) TableName() string {
 	return "stacks"
 }
+
+// StackConfigRevision keeps every version of the config of a stack, the current one is also stored on the stack
+type StackConfigRevision struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	StackID   uuid.UUID      `gorm:"type:uuid;column:stack_id;not null;uniqueIndex:idx_stack_config_revisions_revision,priority:1"`
+	Stack     *Stack         `gorm:"foreignKey:StackID;constraint:OnDelete:CASCADE"`
+	Revision  int            `gorm:"column:revision;not null;uniqueIndex:idx_stack_config_revisions_revision,priority:2"`
+	Config    datatypes.JSON `gorm:"type:jsonb;not null;column:config"`
+	Reason    string         `gorm:"column:reason"`
+	RequestID string         `gorm:"column:request_id"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+}
+
+func (StackConfigRevision) TableName() string {
+	return "stack_config_revisions"
+}
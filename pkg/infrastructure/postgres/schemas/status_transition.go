@@ -0,0 +1,25 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+)
+
+// StatusTransition is append-only, the history of a stack is kept after the stack is archived
+type StatusTransition struct {
+	ID         uuid.UUID              `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	StackID    uuid.UUID              `gorm:"type:uuid;column:stack_id;not null;index:idx_status_transitions_stack,priority:1"`
+	Subject    entities.StatusSubject `gorm:"column:subject;not null"`
+	SubjectID  uuid.UUID              `gorm:"type:uuid;column:subject_id;not null"`
+	FromStatus string                 `gorm:"column:from_status"`
+	ToStatus   string                 `gorm:"column:to_status;not null"`
+	Reason     string                 `gorm:"column:reason"`
+	RequestID  string                 `gorm:"column:request_id"`
+	CreatedAt  time.Time              `gorm:"autoCreateTime;column:created_at;index:idx_status_transitions_stack,priority:2"`
+}
+
+func (StatusTransition) TableName() string {
+	return "status_transitions"
+}
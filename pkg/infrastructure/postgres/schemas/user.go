@@ -0,0 +1,21 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	Name       string    `gorm:"column:name;not null"`
+	Email      string    `gorm:"column:email;not null;uniqueIndex"`
+	APIKeyHash string    `gorm:"column:api_key_hash;not null;uniqueIndex"`
+	IsAdmin    bool      `gorm:"column:is_admin;not null;default:false"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,60 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"gorm.io/datatypes"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	ProjectID uuid.UUID      `gorm:"type:uuid;column:project_id;not null;index"`
+	Project   *Project       `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	URL       string         `gorm:"column:url;not null"`
+	Events    datatypes.JSON `gorm:"column:events;type:jsonb;not null"`
+	Secret    string         `gorm:"column:secret;not null"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookEvent is the transactional outbox of lifecycle events, rows are fanned out to deliveries by the dispatcher
+type WebhookEvent struct {
+	ID           uuid.UUID                 `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	Type         entities.WebhookEventType `gorm:"column:type;not null"`
+	ProjectID    *uuid.UUID                `gorm:"type:uuid;column:project_id"`
+	StackID      *uuid.UUID                `gorm:"type:uuid;column:stack_id"`
+	Data         datatypes.JSON            `gorm:"column:data;type:jsonb;not null"`
+	DispatchedAt *time.Time                `gorm:"column:dispatched_at;index"`
+	CreatedAt    time.Time                 `gorm:"autoCreateTime;column:created_at"`
+}
+
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID                      `gorm:"type:uuid;primaryKey;default:gen_random_uuid();column:id"`
+	SubscriptionID uuid.UUID                      `gorm:"type:uuid;column:subscription_id;not null;index"`
+	Subscription   *WebhookSubscription           `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
+	EventID        uuid.UUID                      `gorm:"type:uuid;column:event_id;not null"`
+	Event          *WebhookEvent                  `gorm:"foreignKey:EventID;constraint:OnDelete:CASCADE"`
+	EventType      entities.WebhookEventType      `gorm:"column:event_type;not null"`
+	Status         entities.WebhookDeliveryStatus `gorm:"column:status;not null;index:idx_webhook_deliveries_pending,priority:1"`
+	Attempts       int                            `gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt  time.Time                      `gorm:"column:next_attempt_at;not null;index:idx_webhook_deliveries_pending,priority:2"`
+	ResponseStatus int                            `gorm:"column:response_status"`
+	Error          string                         `gorm:"column:error"`
+	DeliveredAt    *time.Time                     `gorm:"column:delivered_at"`
+	CreatedAt      time.Time                      `gorm:"autoCreateTime;column:created_at"`
+	UpdatedAt      time.Time                      `gorm:"autoUpdateTime;column:updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
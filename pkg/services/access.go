@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+type UserRepository interface {
+	CreateUser(user *entities.UserEntity, apiKeyHash string) error
+	UpdateAPIKeyHash(id string, apiKeyHash string) error
+	GetUserByID(id string) (*entities.UserEntity, error)
+	GetUserByEmail(email string) (*entities.UserEntity, error)
+	GetUserByAPIKeyHash(apiKeyHash string) (*entities.UserEntity, error)
+	GetAllUsers() ([]*entities.UserEntity, error)
+}
+
+type ProjectRepository interface {
+	CreateProject(
+		project *entities.ProjectEntity,
+		owner *entities.ProjectMemberEntity,
+	) error
+	GetProjectByID(id string) (*entities.ProjectEntity, error)
+	GetAllProjects() ([]*entities.ProjectEntity, error)
+	GetProjectsByUserID(userID string) ([]*entities.ProjectEntity, error)
+	GetProjectIDsByUserID(userID string) ([]string, error)
+	UpsertMember(member *entities.ProjectMemberEntity) error
+	DeleteMember(projectID string, userID string) error
+	GetMember(projectID string, userID string) (*entities.ProjectMemberEntity, error)
+	GetMembers(projectID string) ([]*entities.ProjectMemberEntity, error)
+}
+
+// StackAction is an operation on a stack that is subject to the project role checks
+type StackAction string
+
+const (
+	StackActionView      StackAction = "view"
+	StackActionOperate   StackAction = "operate"
+	StackActionTerminate StackAction = "terminate"
+	// StackActionExport requires admins, exported bundles contain the stack credentials
+	StackActionExport StackAction = "export"
+	// StackActionUpdateCredentials requires admins, like the management of the credential profiles
+	StackActionUpdateCredentials StackAction = "update-credentials"
+	// StackActionAudit requires admins, the audit trail holds the callers and their addresses
+	StackActionAudit StackAction = "audit"
+)
+
+// requiredStackRole returns the minimum project role needed to perform the action on the stack
+func requiredStackRole(stack *entities.StackEntity, action StackAction) entities.ProjectRole {
+	switch action {
+	case StackActionView:
+		return entities.ProjectRoleViewer
+	case StackActionExport, StackActionUpdateCredentials, StackActionAudit:
+		return entities.ProjectRoleAdmin
+	case StackActionTerminate:
+		if stack.Network == entities.DeploymentNetworkMainnet {
+			return entities.ProjectRoleAdmin
+		}
+		return entities.ProjectRoleOperator
+	default:
+		return entities.ProjectRoleOperator
+	}
+}
+
+type AccessService struct {
+	userRepo    UserRepository
+	projectRepo ProjectRepository
+	stackRepo   StackRepository
+}
+
+func NewAccessService(
+	userRepo UserRepository,
+	projectRepo ProjectRepository,
+	stackRepo StackRepository,
+) *AccessService {
+	return &AccessService{
+		userRepo:    userRepo,
+		projectRepo: projectRepo,
+		stackRepo:   stackRepo,
+	}
+}
+
+// Authenticate returns the user owning the API key, or nil if the key is unknown
+func (s *AccessService) Authenticate(apiKey string) (*entities.UserEntity, error) {
+	if apiKey == "" {
+		return nil, nil
+	}
+	return s.userRepo.GetUserByAPIKeyHash(utils.HashAPIKey(apiKey))
+}
+
+// EnsureAdminUser makes sure a system administrator authenticated by the given API key exists.
+// It is used to bootstrap the first user of a fresh installation.
+func (s *AccessService) EnsureAdminUser(ctx context.Context, apiKey string) error {
+	user, err := s.Authenticate(apiKey)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		return nil
+	}
+
+	admin := &entities.UserEntity{
+		ID:      uuid.New(),
+		Name:    "admin",
+		Email:   "admin@localhost",
+		IsAdmin: true,
+	}
+	existing, err := s.userRepo.GetUserByEmail(admin.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		logger.InfoContext(ctx, "Rotating bootstrap admin API key", zap.String("userId", existing.ID.String()))
+		return s.userRepo.UpdateAPIKeyHash(existing.ID.String(), utils.HashAPIKey(apiKey))
+	}
+
+	logger.InfoContext(ctx, "Creating bootstrap admin user", zap.String("userId", admin.ID.String()))
+	return s.userRepo.CreateUser(admin, utils.HashAPIKey(apiKey))
+}
+
+func (s *AccessService) CreateUser(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	request dtos.CreateUserRequest,
+) (*entities.Response, error) {
+	if !caller.IsAdmin {
+		return forbiddenResponse(), nil
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(request.Email)
+	if err != nil {
+		return internalServerErrorResponse(), err
+	}
+	if existing != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "User with this email already exists",
+			Data:    nil,
+		}, nil
+	}
+
+	apiKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return internalServerErrorResponse(), err
+	}
+
+	user := &entities.UserEntity{
+		ID:      uuid.New(),
+		Name:    request.Name,
+		Email:   request.Email,
+		IsAdmin: request.IsAdmin,
+	}
+	if err := s.userRepo.CreateUser(user, utils.HashAPIKey(apiKey)); err != nil {
+		logger.ErrorContext(ctx, "failed to create user", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "User created", zap.String("userId", user.ID.String()), zap.String("createdBy", caller.ID.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		// The API key is only returned once, we only store its hash
+		Data: map[string]interface{}{"user": user, "apiKey": apiKey},
+	}, nil
+}
+
+func (s *AccessService) GetUsers(ctx context.Context, caller *entities.UserEntity) (*entities.Response, error) {
+	if !caller.IsAdmin {
+		return forbiddenResponse(), nil
+	}
+
+	users, err := s.userRepo.GetAllUsers()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get users", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"users": users},
+	}, nil
+}
+
+func (s *AccessService) CreateProject(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	request dtos.CreateProjectRequest,
+) (*entities.Response, error) {
+	project := &entities.ProjectEntity{
+		ID:          uuid.New(),
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	owner := &entities.ProjectMemberEntity{
+		ProjectID: project.ID,
+		UserID:    caller.ID,
+		Role:      entities.ProjectRoleAdmin,
+	}
+
+	if err := s.projectRepo.CreateProject(project, owner); err != nil {
+		logger.ErrorContext(ctx, "failed to create project", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Project created", zap.String("projectId", project.ID.String()), zap.String("userId", caller.ID.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"project": project},
+	}, nil
+}
+
+func (s *AccessService) GetProjects(ctx context.Context, caller *entities.UserEntity) (*entities.Response, error) {
+	var (
+		projects []*entities.ProjectEntity
+		err      error
+	)
+	if caller.IsAdmin {
+		projects, err = s.projectRepo.GetAllProjects()
+	} else {
+		projects, err = s.projectRepo.GetProjectsByUserID(caller.ID.String())
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get projects", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"projects": projects},
+	}, nil
+}
+
+func (s *AccessService) GetProject(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+) (*entities.Response, error) {
+	if response, err := s.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleViewer); response != nil {
+		return response, err
+	}
+
+	project, err := s.projectRepo.GetProjectByID(projectId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get project", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	members, err := s.projectRepo.GetMembers(projectId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get project members", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"project": project, "members": members},
+	}, nil
+}
+
+func (s *AccessService) SetProjectMember(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	request dtos.SetProjectMemberRequest,
+) (*entities.Response, error) {
+	if response, err := s.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	user, err := s.userRepo.GetUserByID(request.UserID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", zap.String("userId", request.UserID), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	if user == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "User not found",
+			Data:    nil,
+		}, nil
+	}
+
+	member := &entities.ProjectMemberEntity{
+		ProjectID: projectId,
+		UserID:    user.ID,
+		Role:      request.Role,
+	}
+	if err := s.projectRepo.UpsertMember(member); err != nil {
+		logger.ErrorContext(ctx, "failed to set project member", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"member": member},
+	}, nil
+}
+
+func (s *AccessService) RemoveProjectMember(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	userId uuid.UUID,
+) (*entities.Response, error) {
+	if response, err := s.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	if err := s.projectRepo.DeleteMember(projectId.String(), userId.String()); err != nil {
+		logger.ErrorContext(ctx, "failed to remove project member", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+// GetProjectRole returns the caller's role in the project, or an empty role if the caller is not a member.
+// System administrators are treated as admins of every project.
+func (s *AccessService) GetProjectRole(
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+) (entities.ProjectRole, error) {
+	if caller.IsAdmin {
+		return entities.ProjectRoleAdmin, nil
+	}
+
+	member, err := s.projectRepo.GetMember(projectId.String(), caller.ID.String())
+	if err != nil {
+		return "", err
+	}
+	if member == nil {
+		return "", nil
+	}
+	return member.Role, nil
+}
+
+// AuthorizeProject returns a non-nil response when the caller lacks the required role in the project
+func (s *AccessService) AuthorizeProject(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	required entities.ProjectRole,
+) (*entities.Response, error) {
+	if caller == nil {
+		return unauthorizedResponse(), nil
+	}
+
+	role, err := s.GetProjectRole(caller, projectId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get project role", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if !role.Allows(required) {
+		return forbiddenResponse(), nil
+	}
+
+	return nil, nil
+}
+
+// AuthorizeStack returns a non-nil response when the caller is not allowed to perform the action on the stack.
+// Stacks which are not owned by any project are only accessible to system administrators.
+func (s *AccessService) AuthorizeStack(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	stackId uuid.UUID,
+	action StackAction,
+) (*entities.Response, error) {
+	if caller == nil {
+		return unauthorizedResponse(), nil
+	}
+
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if caller.IsAdmin {
+		return nil, nil
+	}
+
+	if stack.ProjectID == nil {
+		return forbiddenResponse(), nil
+	}
+
+	return s.AuthorizeProject(ctx, caller, *stack.ProjectID, requiredStackRole(stack, action))
+}
+
+// AuthorizeAdoption returns an error response unless the caller may adopt a CLI deployment into the project. Without
+// an adoption root, the deployment can be any directory of the server, so only the system administrators may adopt.
+func (s *AccessService) AuthorizeAdoption(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+) (*entities.Response, error) {
+	if caller != nil && !caller.IsAdmin && utils.GetAdoptionRoot() == "" {
+		return forbiddenResponse(), nil
+	}
+	return s.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleOperator)
+}
+
+// GetAccessibleProjectIDs returns the ids of the projects the caller is a member of.
+// The boolean result is true when the caller can access every stack, regardless of its project.
+func (s *AccessService) GetAccessibleProjectIDs(caller *entities.UserEntity) ([]string, bool, error) {
+	if caller.IsAdmin {
+		return nil, true, nil
+	}
+
+	projectIds, err := s.projectRepo.GetProjectIDsByUserID(caller.ID.String())
+	if err != nil {
+		return nil, false, err
+	}
+	return projectIds, false, nil
+}
+
+func internalServerErrorResponse() *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusInternalServerError,
+		Message: "Internal server error",
+		Data:    nil,
+	}
+}
+
+func unauthorizedResponse() *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusUnauthorized,
+		Message: "Unauthorized",
+		Data:    nil,
+	}
+}
+
+func forbiddenResponse() *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusForbidden,
+		Message: "You don't have permission to perform this action",
+		Data:    nil,
+	}
+}
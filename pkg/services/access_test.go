@@ -0,0 +1,165 @@
+package services_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/memory"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+)
+
+// memberRepository holds the roles of the project members, the other methods are not used by the authorization
+type memberRepository struct {
+	services.ProjectRepository
+	members map[uuid.UUID]*entities.ProjectMemberEntity
+}
+
+func (r *memberRepository) GetMember(projectID string, userID string) (*entities.ProjectMemberEntity, error) {
+	for _, member := range r.members {
+		if member.ProjectID.String() == projectID && member.UserID.String() == userID {
+			return member, nil
+		}
+	}
+	return nil, nil
+}
+
+// newMember returns a user with the role in the project
+func (r *memberRepository) newMember(projectID uuid.UUID, role entities.ProjectRole) *entities.UserEntity {
+	user := &entities.UserEntity{ID: uuid.New()}
+	r.members[user.ID] = &entities.ProjectMemberEntity{ProjectID: projectID, UserID: user.ID, Role: role}
+	return user
+}
+
+func assertAuthorized(t *testing.T, response *entities.Response, err error) {
+	t.Helper()
+	if err != nil || response != nil {
+		t.Fatalf("response = %+v (%v), want authorized", response, err)
+	}
+}
+
+func TestAuthorizeAdoption(t *testing.T) {
+	projects := &memberRepository{members: map[uuid.UUID]*entities.ProjectMemberEntity{}}
+	access := services.NewAccessService(nil, projects, nil)
+	projectID := uuid.New()
+	operator := projects.newMember(projectID, entities.ProjectRoleOperator)
+	viewer := projects.newMember(projectID, entities.ProjectRoleViewer)
+	admin := &entities.UserEntity{ID: uuid.New(), IsAdmin: true}
+
+	response, err := access.AuthorizeAdoption(context.Background(), operator, projectID)
+	assertResponse(t, response, err, http.StatusForbidden)
+	response, err = access.AuthorizeAdoption(context.Background(), admin, projectID)
+	assertAuthorized(t, response, err)
+
+	utils.SetAdoptionRoot(t.TempDir())
+	t.Cleanup(func() {
+		utils.SetAdoptionRoot("")
+	})
+	response, err = access.AuthorizeAdoption(context.Background(), operator, projectID)
+	assertAuthorized(t, response, err)
+	response, err = access.AuthorizeAdoption(context.Background(), viewer, projectID)
+	assertResponse(t, response, err, http.StatusForbidden)
+	response, err = access.AuthorizeAdoption(context.Background(), nil, projectID)
+	assertResponse(t, response, err, http.StatusUnauthorized)
+}
+
+func TestAuthorizeProject(t *testing.T) {
+	projects := &memberRepository{members: map[uuid.UUID]*entities.ProjectMemberEntity{}}
+	access := services.NewAccessService(nil, projects, nil)
+	projectID := uuid.New()
+	users := map[string]*entities.UserEntity{
+		"viewer":   projects.newMember(projectID, entities.ProjectRoleViewer),
+		"operator": projects.newMember(projectID, entities.ProjectRoleOperator),
+		"admin":    projects.newMember(projectID, entities.ProjectRoleAdmin),
+		// A member of another project has no role in this one
+		"outsider":     projects.newMember(uuid.New(), entities.ProjectRoleAdmin),
+		"system admin": {ID: uuid.New(), IsAdmin: true},
+	}
+
+	tests := []struct {
+		required entities.ProjectRole
+		allowed  []string
+	}{
+		{entities.ProjectRoleViewer, []string{"viewer", "operator", "admin", "system admin"}},
+		{entities.ProjectRoleOperator, []string{"operator", "admin", "system admin"}},
+		{entities.ProjectRoleAdmin, []string{"admin", "system admin"}},
+	}
+	for _, tt := range tests {
+		for name, user := range users {
+			response, err := access.AuthorizeProject(context.Background(), user, projectID, tt.required)
+			if slices.Contains(tt.allowed, name) {
+				assertAuthorized(t, response, err)
+			} else {
+				assertResponse(t, response, err, http.StatusForbidden)
+			}
+		}
+		response, err := access.AuthorizeProject(context.Background(), nil, projectID, tt.required)
+		assertResponse(t, response, err, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthorizeStack(t *testing.T) {
+	projects := &memberRepository{members: map[uuid.UUID]*entities.ProjectMemberEntity{}}
+	stacks := memory.NewStackRepository(memory.NewStore())
+	access := services.NewAccessService(nil, projects, stacks)
+	projectID := uuid.New()
+	viewer := projects.newMember(projectID, entities.ProjectRoleViewer)
+	operator := projects.newMember(projectID, entities.ProjectRoleOperator)
+	admin := projects.newMember(projectID, entities.ProjectRoleAdmin)
+	outsider := projects.newMember(uuid.New(), entities.ProjectRoleAdmin)
+	systemAdmin := &entities.UserEntity{ID: uuid.New(), IsAdmin: true}
+
+	createStack := func(projectID *uuid.UUID, network entities.DeploymentNetwork) uuid.UUID {
+		t.Helper()
+		stack := &entities.StackEntity{ID: uuid.New(), ProjectID: projectID, Network: network}
+		if err := stacks.CreateStackByTx(stack, nil, nil, ""); err != nil {
+			t.Fatalf("failed to create the stack: %v", err)
+		}
+		return stack.ID
+	}
+	testnet := createStack(&projectID, entities.DeploymentNetworkTestnet)
+	mainnet := createStack(&projectID, entities.DeploymentNetworkMainnet)
+	unowned := createStack(nil, entities.DeploymentNetworkTestnet)
+
+	tests := []struct {
+		name    string
+		caller  *entities.UserEntity
+		stackID uuid.UUID
+		action  services.StackAction
+		want    uint64
+	}{
+		{"viewer views", viewer, testnet, services.StackActionView, http.StatusOK},
+		{"viewer operates", viewer, testnet, services.StackActionOperate, http.StatusForbidden},
+		{"operator operates", operator, testnet, services.StackActionOperate, http.StatusOK},
+		{"operator terminates a testnet stack", operator, testnet, services.StackActionTerminate, http.StatusOK},
+		{"operator terminates a mainnet stack", operator, mainnet, services.StackActionTerminate, http.StatusForbidden},
+		{"admin terminates a mainnet stack", admin, mainnet, services.StackActionTerminate, http.StatusOK},
+		{"operator operates a mainnet stack", operator, mainnet, services.StackActionOperate, http.StatusOK},
+		{"operator exports", operator, testnet, services.StackActionExport, http.StatusForbidden},
+		{"admin exports", admin, testnet, services.StackActionExport, http.StatusOK},
+		{"operator updates the credentials", operator, testnet, services.StackActionUpdateCredentials, http.StatusForbidden},
+		{"admin updates the credentials", admin, testnet, services.StackActionUpdateCredentials, http.StatusOK},
+		{"operator reads the audit trail", operator, testnet, services.StackActionAudit, http.StatusForbidden},
+		{"admin reads the audit trail", admin, testnet, services.StackActionAudit, http.StatusOK},
+		{"member of another project", outsider, testnet, services.StackActionView, http.StatusForbidden},
+		{"admin views a stack without project", admin, unowned, services.StackActionView, http.StatusForbidden},
+		{"system admin views a stack without project", systemAdmin, unowned, services.StackActionView, http.StatusOK},
+		{"system admin terminates a mainnet stack", systemAdmin, mainnet, services.StackActionTerminate, http.StatusOK},
+		{"unknown stack", admin, uuid.New(), services.StackActionView, http.StatusNotFound},
+		{"unauthenticated", nil, testnet, services.StackActionView, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := access.AuthorizeStack(context.Background(), tt.caller, tt.stackID, tt.action)
+			if tt.want == http.StatusOK {
+				assertAuthorized(t, response, err)
+				return
+			}
+			assertResponse(t, response, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+type AuditRepository interface {
+	CreateRecord(record *entities.AuditRecordEntity) error
+	GetRecords(filter entities.AuditFilter) ([]*entities.AuditRecordEntity, error)
+	ForEachRecord(filter entities.AuditFilter, fn func(record *entities.AuditRecordEntity) error) error
+}
+
+type AuditService struct {
+	auditRepo     AuditRepository
+	stackRepo     StackRepository
+	accessService *AccessService
+}
+
+func NewAuditService(
+	auditRepo AuditRepository,
+	stackRepo StackRepository,
+	accessService *AccessService,
+) *AuditService {
+	return &AuditService{
+		auditRepo:     auditRepo,
+		stackRepo:     stackRepo,
+		accessService: accessService,
+	}
+}
+
+// Record writes the record of an API call, filling in the project of its stack. A failure is logged and does not fail
+// the call, which has already been served.
+func (s *AuditService) Record(ctx context.Context, record *entities.AuditRecordEntity) {
+	if record.ID == uuid.Nil {
+		record.ID = uuid.New()
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	if record.ProjectID == nil && record.StackID != nil {
+		if stack, err := s.stackRepo.GetStackByID(record.StackID.String()); err == nil && stack != nil {
+			record.ProjectID = stack.ProjectID
+		}
+	}
+
+	if err := s.auditRepo.CreateRecord(record); err != nil {
+		logger.ErrorContext(ctx, "failed to write audit record", zap.String("action", record.Action), zap.Error(err))
+	}
+}
+
+// GetStackStatus returns the status of the stack, or an empty status if it cannot be read
+func (s *AuditService) GetStackStatus(stackId string) string {
+	status, err := s.stackRepo.GetStackStatus(stackId)
+	if err != nil {
+		return ""
+	}
+	return string(status)
+}
+
+// GetRecords returns the most recent records matching the filter, newest first
+func (s *AuditService) GetRecords(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	filter entities.AuditFilter,
+) (*entities.Response, error) {
+	if response, err := s.authorize(ctx, caller, filter); response != nil {
+		return response, err
+	}
+
+	records, err := s.auditRepo.GetRecords(filter)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get audit records", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"records": records},
+	}, nil
+}
+
+// ExportRecords sends every record matching the filter, oldest first, the limit of the filter is ignored.
+// The response is only set when the records cannot be exported.
+func (s *AuditService) ExportRecords(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	filter entities.AuditFilter,
+	send func(record *entities.AuditRecordEntity) error,
+) (*entities.Response, error) {
+	if response, err := s.authorize(ctx, caller, filter); response != nil {
+		return response, err
+	}
+
+	filter.Limit = 0
+	return nil, s.auditRepo.ForEachRecord(filter, func(record *entities.AuditRecordEntity) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return send(record)
+	})
+}
+
+// authorize returns a non-nil response when the caller cannot read the records of the filter. The records of a stack
+// or a project require its admins, the whole trail requires system administrators.
+func (s *AuditService) authorize(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	filter entities.AuditFilter,
+) (*entities.Response, error) {
+	if caller == nil {
+		return unauthorizedResponse(), nil
+	}
+
+	if filter.StackID == nil && filter.ProjectID == nil {
+		if !caller.IsAdmin {
+			return forbiddenResponse(), nil
+		}
+		return nil, nil
+	}
+
+	if filter.StackID != nil {
+		if response, err := s.accessService.AuthorizeStack(ctx, caller, *filter.StackID, StackActionAudit); response != nil {
+			return response, err
+		}
+	}
+	if filter.ProjectID != nil {
+		if response, err := s.accessService.AuthorizeProject(ctx, caller, *filter.ProjectID, entities.ProjectRoleAdmin); response != nil {
+			return response, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/consts"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+type CredentialRepository interface {
+	CreateCredential(credential *entities.CredentialEntity) error
+	GetCredentialByID(id string) (*entities.CredentialEntity, error)
+	GetCredentialByName(projectID string, name string) (*entities.CredentialEntity, error)
+	GetCredentialsByProjectIDs(projectIDs []string) ([]*entities.CredentialEntity, error)
+	GetAllCredentials() ([]*entities.CredentialEntity, error)
+	UpdateKeys(credential *entities.CredentialEntity) error
+	DeleteCredential(id string) error
+	GetStackIDsByCredentialID(id string) ([]string, error)
+}
+
+type CredentialService struct {
+	credentialRepo CredentialRepository
+	accessService  *AccessService
+}
+
+func NewCredentialService(
+	credentialRepo CredentialRepository,
+	accessService *AccessService,
+) *CredentialService {
+	return &CredentialService{
+		credentialRepo: credentialRepo,
+		accessService:  accessService,
+	}
+}
+
+// CreateCredential saves a credential profile once its keys are validated, the secret key is never returned
+func (s *CredentialService) CreateCredential(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	request dtos.CreateCredentialRequest,
+) (*entities.Response, error) {
+	projectId, err := uuid.Parse(request.ProjectID)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid projectId",
+			Data:    nil,
+		}, nil
+	}
+
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	existing, err := s.credentialRepo.GetCredentialByName(projectId.String(), request.Name)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get credential", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	if existing != nil {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "A credential with this name already exists in the project",
+			Data:    nil,
+		}, nil
+	}
+
+	if err := request.Validate(ctx); err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	now := time.Now()
+	credential := &entities.CredentialEntity{
+		ID:                 uuid.New(),
+		ProjectID:          projectId,
+		Name:               request.Name,
+		Provider:           consts.AWS,
+		AwsAccessKey:       request.AwsAccessKey,
+		AwsSecretAccessKey: request.AwsSecretAccessKey,
+		AwsRegion:          request.AwsRegion,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.credentialRepo.CreateCredential(credential); err != nil {
+		logger.ErrorContext(ctx, "failed to create credential", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Credential created",
+		zap.String("projectId", projectId.String()),
+		zap.String("credentialId", credential.ID.String()),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"credential": credential},
+	}, nil
+}
+
+// GetCredentials returns the credentials of the project, or of every project accessible to the caller when projectId
+// is nil
+func (s *CredentialService) GetCredentials(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId *uuid.UUID,
+) (*entities.Response, error) {
+	var credentials []*entities.CredentialEntity
+	var err error
+	if projectId != nil {
+		if response, err := s.accessService.AuthorizeProject(ctx, caller, *projectId, entities.ProjectRoleViewer); response != nil {
+			return response, err
+		}
+		credentials, err = s.credentialRepo.GetCredentialsByProjectIDs([]string{projectId.String()})
+	} else {
+		projectIds, all, accessErr := s.accessService.GetAccessibleProjectIDs(caller)
+		if accessErr != nil {
+			logger.ErrorContext(ctx, "failed to get accessible projects", zap.Error(accessErr))
+			return internalServerErrorResponse(), accessErr
+		}
+		if all {
+			credentials, err = s.credentialRepo.GetAllCredentials()
+		} else {
+			credentials, err = s.credentialRepo.GetCredentialsByProjectIDs(projectIds)
+		}
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get credentials", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"credentials": credentials},
+	}, nil
+}
+
+func (s *CredentialService) GetCredential(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	credentialId uuid.UUID,
+) (*entities.Response, error) {
+	credential, response, err := s.getAuthorizedCredential(ctx, caller, credentialId, entities.ProjectRoleViewer)
+	if response != nil {
+		return response, err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"credential": credential},
+	}, nil
+}
+
+// RotateCredential replaces the keys of the credential once they are validated. The stacks referencing it are not
+// redeployed, they use the new keys from their next operation.
+func (s *CredentialService) RotateCredential(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	credentialId uuid.UUID,
+	request dtos.UpdateCredentialRequest,
+) (*entities.Response, error) {
+	credential, response, err := s.getAuthorizedCredential(ctx, caller, credentialId, entities.ProjectRoleAdmin)
+	if response != nil {
+		return response, err
+	}
+
+	region := credential.AwsRegion
+	if request.AwsRegion != "" {
+		region = request.AwsRegion
+	}
+	if err := dtos.ValidateAwsCredentials(ctx, request.AwsAccessKey, request.AwsSecretAccessKey, region); err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	credential.AwsAccessKey = request.AwsAccessKey
+	credential.AwsSecretAccessKey = request.AwsSecretAccessKey
+	credential.AwsRegion = region
+	if err := s.credentialRepo.UpdateKeys(credential); err != nil {
+		logger.ErrorContext(ctx, "failed to update credential", zap.String("credentialId", credentialId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	credential.UpdatedAt = time.Now()
+
+	logger.InfoContext(ctx, "Credential rotated", zap.String("credentialId", credentialId.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"credential": credential},
+	}, nil
+}
+
+// DeleteCredential deletes the credential unless stacks, other than terminated ones, still reference it
+func (s *CredentialService) DeleteCredential(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	credentialId uuid.UUID,
+) (*entities.Response, error) {
+	if _, response, err := s.getAuthorizedCredential(ctx, caller, credentialId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	stackIds, err := s.credentialRepo.GetStackIDsByCredentialID(credentialId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stacks of credential", zap.String("credentialId", credentialId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	if len(stackIds) > 0 {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "The credential is used by stacks",
+			Data:    map[string]interface{}{"stackIds": stackIds},
+		}, nil
+	}
+
+	if err := s.credentialRepo.DeleteCredential(credentialId.String()); err != nil {
+		logger.ErrorContext(ctx, "failed to delete credential", zap.String("credentialId", credentialId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Credential deleted", zap.String("credentialId", credentialId.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+func (s *CredentialService) getAuthorizedCredential(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	credentialId uuid.UUID,
+	required entities.ProjectRole,
+) (*entities.CredentialEntity, *entities.Response, error) {
+	credential, err := s.credentialRepo.GetCredentialByID(credentialId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get credential", zap.String("credentialId", credentialId.String()), zap.Error(err))
+		return nil, internalServerErrorResponse(), err
+	}
+
+	if credential == nil {
+		return nil, &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Credential not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, credential.ProjectID, required); response != nil {
+		return nil, response, err
+	}
+	return credential, nil, nil
+}
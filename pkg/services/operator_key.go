@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/keystore"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+type OperatorKeyRepository interface {
+	CreateKey(key *entities.OperatorKeyEntity) error
+	GetKeyByID(id string) (*entities.OperatorKeyEntity, error)
+	GetKeyByName(projectID string, name string) (*entities.OperatorKeyEntity, error)
+	GetKeysByProjectIDs(projectIDs []string) ([]*entities.OperatorKeyEntity, error)
+	GetAllKeys() ([]*entities.OperatorKeyEntity, error)
+	DeleteKey(id string) error
+	GetStackIDsByKeyID(id string) ([]string, error)
+}
+
+type OperatorKeyService struct {
+	keyRepo       OperatorKeyRepository
+	keystore      *keystore.Keystore
+	accessService *AccessService
+}
+
+func NewOperatorKeyService(
+	keyRepo OperatorKeyRepository,
+	keystore *keystore.Keystore,
+	accessService *AccessService,
+) *OperatorKeyService {
+	return &OperatorKeyService{
+		keyRepo:       keyRepo,
+		keystore:      keystore,
+		accessService: accessService,
+	}
+}
+
+// CreateKey imports the private key of the request, or generates a new one, into the keystore of the project. Only
+// the address of the key is returned.
+func (s *OperatorKeyService) CreateKey(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	request dtos.CreateOperatorKeyRequest,
+) (*entities.Response, error) {
+	if s.keystore == nil {
+		return keystoreDisabledResponse(), nil
+	}
+
+	projectId, err := uuid.Parse(request.ProjectID)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid projectId",
+			Data:    nil,
+		}, nil
+	}
+
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	if err := request.Validate(); err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	existing, err := s.keyRepo.GetKeyByName(projectId.String(), request.Name)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get operator key", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	if existing != nil {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "A key with this name already exists in the project",
+			Data:    nil,
+		}, nil
+	}
+
+	var privateKey *ecdsa.PrivateKey
+	origin := entities.OperatorKeyOriginImported
+	if request.PrivateKey != "" {
+		privateKey, err = keystore.ParsePrivateKey(request.PrivateKey)
+	} else {
+		privateKey, err = keystore.Generate()
+		origin = entities.OperatorKeyOriginGenerated
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get private key", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	keyJSON, err := s.keystore.Encrypt(privateKey)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encrypt private key", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	key := &entities.OperatorKeyEntity{
+		ID:        uuid.New(),
+		ProjectID: projectId,
+		Name:      request.Name,
+		Address:   keystore.Address(privateKey),
+		Origin:    origin,
+		Keystore:  keyJSON,
+		CreatedAt: time.Now(),
+	}
+	if err := s.keyRepo.CreateKey(key); err != nil {
+		logger.ErrorContext(ctx, "failed to create operator key", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Operator key created",
+		zap.String("projectId", projectId.String()),
+		zap.String("keyId", key.ID.String()),
+		zap.String("address", key.Address),
+		zap.String("origin", string(origin)),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"key": key},
+	}, nil
+}
+
+// GetKeys returns the keys of the project, or of every project accessible to the caller when projectId is nil
+func (s *OperatorKeyService) GetKeys(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId *uuid.UUID,
+) (*entities.Response, error) {
+	var keys []*entities.OperatorKeyEntity
+	var err error
+	if projectId != nil {
+		if response, err := s.accessService.AuthorizeProject(ctx, caller, *projectId, entities.ProjectRoleViewer); response != nil {
+			return response, err
+		}
+		keys, err = s.keyRepo.GetKeysByProjectIDs([]string{projectId.String()})
+	} else {
+		projectIds, all, accessErr := s.accessService.GetAccessibleProjectIDs(caller)
+		if accessErr != nil {
+			logger.ErrorContext(ctx, "failed to get accessible projects", zap.Error(accessErr))
+			return internalServerErrorResponse(), accessErr
+		}
+		if all {
+			keys, err = s.keyRepo.GetAllKeys()
+		} else {
+			keys, err = s.keyRepo.GetKeysByProjectIDs(projectIds)
+		}
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get operator keys", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"keys": keys},
+	}, nil
+}
+
+func (s *OperatorKeyService) GetKey(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	keyId uuid.UUID,
+) (*entities.Response, error) {
+	key, response, err := s.getAuthorizedKey(ctx, caller, keyId, entities.ProjectRoleViewer)
+	if response != nil {
+		return response, err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"key": key},
+	}, nil
+}
+
+// DeleteKey deletes the key unless stacks, other than terminated ones, still use it
+func (s *OperatorKeyService) DeleteKey(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	keyId uuid.UUID,
+) (*entities.Response, error) {
+	if _, response, err := s.getAuthorizedKey(ctx, caller, keyId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	stackIds, err := s.keyRepo.GetStackIDsByKeyID(keyId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stacks of operator key", zap.String("keyId", keyId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	if len(stackIds) > 0 {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "The key is used by stacks",
+			Data:    map[string]interface{}{"stackIds": stackIds},
+		}, nil
+	}
+
+	if err := s.keyRepo.DeleteKey(keyId.String()); err != nil {
+		logger.ErrorContext(ctx, "failed to delete operator key", zap.String("keyId", keyId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Operator key deleted", zap.String("keyId", keyId.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+func (s *OperatorKeyService) getAuthorizedKey(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	keyId uuid.UUID,
+	required entities.ProjectRole,
+) (*entities.OperatorKeyEntity, *entities.Response, error) {
+	key, err := s.keyRepo.GetKeyByID(keyId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get operator key", zap.String("keyId", keyId.String()), zap.Error(err))
+		return nil, internalServerErrorResponse(), err
+	}
+
+	if key == nil {
+		return nil, &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Key not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, key.ProjectID, required); response != nil {
+		return nil, response, err
+	}
+	return key, nil, nil
+}
+
+func keystoreDisabledResponse() *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusServiceUnavailable,
+		Message: "Managed keys are disabled, no keystore passphrase is configured",
+		Data:    nil,
+	}
+}
@@ -3,10 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/keystore"
 	"github.com/tokamak-network/trh-backend/internal/logger"
 	"github.com/tokamak-network/trh-backend/internal/utils"
 	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
@@ -16,14 +20,18 @@ import (
 	"go.uber.org/zap"
 )
 
+// updateStackAttempts is the number of times UpdateStack applies a request to a stack changed by concurrent requests
+const updateStackAttempts = 3
+
 type DeploymentRepository interface {
 	GetDeploymentsByStackID(stackId string) ([]*entities.DeploymentEntity, error)
-	UpdateDeploymentStatus(deploymentId string, status entities.DeploymentStatus) error
+	UpdateDeploymentStatus(deploymentId string, status entities.DeploymentStatus, requestID string) error
 	GetDeploymentByID(deploymentId string) (*entities.DeploymentEntity, error)
 	GetDeploymentStatus(deploymentId string) (entities.DeploymentStatus, error)
 	UpdateStatusesByStackId(
 		stackID string,
 		status entities.DeploymentStatus,
+		requestID string,
 	) error
 }
 
@@ -32,11 +40,30 @@ type StackRepository interface {
 		stack *entities.StackEntity,
 		deployments []*entities.DeploymentEntity,
 		integrations []*entities.IntegrationEntity,
+		requestID string,
+	) error
+	UpdateStatus(stackId string, status entities.StackStatus, reason string, requestID string) error
+	UpdateStatusAtVersion(
+		stackId string,
+		version int64,
+		status entities.StackStatus,
+		reason string,
+		requestID string,
 	) error
-	UpdateStatus(stackId string, status entities.StackStatus, reason string) error
 	GetStackByID(stackId string) (*entities.StackEntity, error)
-	GetAllStacks() ([]*entities.StackEntity, error)
+	StackExists(stackId string) (bool, error)
+	GetStackByDeploymentPath(deploymentPath string) (*entities.StackEntity, error)
+	GetAllStacks(labelSelector map[string]string, includeArchived bool) ([]*entities.StackEntity, error)
+	GetStacksByProjectIDs(
+		projectIDs []string,
+		labelSelector map[string]string,
+		includeArchived bool,
+	) ([]*entities.StackEntity, error)
+	ArchiveStack(stackId string, requestID string) error
+	UpdateDetails(id string, version int64, name string, description string, labels map[string]string) error
+	UpdateConfig(id string, version int64, config json.RawMessage, reason string, requestID string) error
 	GetStackStatus(stackId string) (entities.StackStatus, error)
+	GetStatusTransitions(stackId string) ([]*entities.StatusTransitionEntity, error)
 	UpdateMetadata(
 		id string,
 		metadata *entities.StackMetadata,
@@ -46,15 +73,24 @@ type StackRepository interface {
 type IntegrationRepository interface {
 	CreateIntegration(
 		integration *entities.IntegrationEntity,
+		stackVersion int64,
 	) error
 	UpdateIntegrationStatus(
 		id string,
 		status entities.DeploymentStatus,
+		requestID string,
+	) error
+	UpdateIntegrationStatusAtVersion(
+		id string,
+		version int64,
+		status entities.DeploymentStatus,
+		requestID string,
 	) error
 	UpdateIntegrationStatusWithReason(
 		id string,
 		status entities.DeploymentStatus,
 		reason string,
+		requestID string,
 	) error
 	GetInstalledIntegration(
 		stackId string,
@@ -80,10 +116,12 @@ type IntegrationRepository interface {
 	UpdateIntegrationsStatusByStackID(
 		stackID string,
 		status entities.DeploymentStatus,
+		requestID string,
 	) error
 	UpdateMetadataAfterInstalled(
 		id string,
 		metadata entities.IntegrationInfo,
+		requestID string,
 	) error
 	UpdateConfig(
 		id string,
@@ -93,31 +131,66 @@ type IntegrationRepository interface {
 
 type TaskManager interface {
 	Start()
-	AddTask(id string, task entities.Task)
+	AddTask(ctx context.Context, id string, task entities.Task)
 	StopTask(id string)
 	Stop()
 }
 
+// LogSink stores the logs of the SDK commands, see the logsink package. The logs are named after the stack, as
+// <stackId>/<file>.
+type LogSink interface {
+	Append(ctx context.Context, name string) (io.WriteCloser, error)
+	Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error)
+	List(ctx context.Context, dir string) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
 type ThanosStackDeploymentService struct {
 	name            string
 	deploymentRepo  DeploymentRepository
 	stackRepo       StackRepository
 	integrationRepo IntegrationRepository
-	taskManager     TaskManager
+	credentialRepo  CredentialRepository
+	operatorKeyRepo OperatorKeyRepository
+	// unitOfWork commits the updates of a stack and of its deployments and integrations together
+	unitOfWork UnitOfWork
+	// driver runs the SDK commands on the deployments of the stacks
+	driver thanos.StackDriver
+	// keystore decrypts the managed keys of the system accounts, they are disabled without it
+	keystore    *keystore.Keystore
+	taskManager TaskManager
+	// logs stores the logs of the deployments and of the integrations, the driver writes them to the same sink
+	logs LogSink
+	// bundleSigningKey signs and verifies the exported stack bundles, they are disabled without it
+	bundleSigningKey []byte
 }
 
 func NewThanosService(
 	deploymentRepo DeploymentRepository,
 	stackRepo StackRepository,
 	integrationRepo IntegrationRepository,
+	credentialRepo CredentialRepository,
+	operatorKeyRepo OperatorKeyRepository,
+	unitOfWork UnitOfWork,
+	driver thanos.StackDriver,
+	keystore *keystore.Keystore,
 	taskManager TaskManager,
+	logs LogSink,
+	bundleSigningKey []byte,
 ) *ThanosStackDeploymentService {
 	thanosDeploymentSrv := &ThanosStackDeploymentService{
-		name:            "Thanos",
-		deploymentRepo:  deploymentRepo,
-		stackRepo:       stackRepo,
-		integrationRepo: integrationRepo,
-		taskManager:     taskManager,
+		name:             "Thanos",
+		deploymentRepo:   deploymentRepo,
+		stackRepo:        stackRepo,
+		integrationRepo:  integrationRepo,
+		credentialRepo:   credentialRepo,
+		operatorKeyRepo:  operatorKeyRepo,
+		unitOfWork:       unitOfWork,
+		driver:           driver,
+		keystore:         keystore,
+		taskManager:      taskManager,
+		logs:             logs,
+		bundleSigningKey: bundleSigningKey,
 	}
 
 	thanosDeploymentSrv.taskManager.Start()
@@ -130,6 +203,263 @@ func (s *ThanosStackDeploymentService) CreateThanosStack(
 	request dtos.DeployThanosRequest,
 ) (*entities.Response, error) {
 	stackId := uuid.New()
+	response, err := s.createThanosStack(ctx, stackId, request, nil)
+	if response != nil {
+		return response, err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]string{"stackId": stackId.String()},
+	}, nil
+}
+
+// CloneThanosStack creates a new stack from the configuration of an existing one. The installed block explorer and
+// monitoring are cloned as well when their secrets, which are not copied, are provided in the request.
+func (s *ThanosStackDeploymentService) CloneThanosStack(
+	ctx context.Context,
+	sourceStackId uuid.UUID,
+	request dtos.CloneThanosStackRequest,
+) (*entities.Response, error) {
+	source, err := s.stackRepo.GetStackByID(sourceStackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", sourceStackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if source == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	var config dtos.DeployThanosRequest
+	if err := json.Unmarshal(source.Config, &config); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", sourceStackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if source.ProjectID != nil {
+		config.ProjectID = source.ProjectID.String()
+	}
+	config.Name = fmt.Sprintf("%s (clone)", source.Name)
+	if dtos.ValidateStackName(config.Name) != nil {
+		config.Name = source.Name
+	}
+	config.Description = source.Description
+	config.Labels = source.Labels
+	config.DeploymentPath = ""
+	request.Apply(&config)
+
+	// The members of another project may have no access to the source stack, its secrets are not handed to them
+	if isOtherProject(source.ProjectID, config.ProjectID) && !request.ReplacesSecrets() {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Cloning into another project requires new AWS credentials and system accounts",
+			Data:    nil,
+		}, nil
+	}
+
+	if err := config.Prepare(ctx); err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	stackId := uuid.New()
+	integrations, skippedIntegrations, response, err := s.getClonedIntegrations(ctx, source, stackId, request)
+	if response != nil {
+		return response, err
+	}
+
+	response, err = s.createThanosStack(ctx, stackId, config, integrations)
+	if response != nil {
+		return response, err
+	}
+
+	logger.InfoContext(ctx, "Stack cloned",
+		zap.String("stackId", stackId.String()),
+		zap.String("sourceStackId", sourceStackId.String()),
+		zap.Strings("skippedIntegrations", skippedIntegrations),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data: map[string]interface{}{
+			"stackId":             stackId.String(),
+			"skippedIntegrations": skippedIntegrations,
+		},
+	}, nil
+}
+
+// isOtherProject reports whether the project id designates another project than the source one, invalid ids are
+// rejected when the stack is created
+func isOtherProject(sourceProjectID *uuid.UUID, projectId string) bool {
+	id, err := uuid.Parse(projectId)
+	if err != nil {
+		return false
+	}
+	return sourceProjectID == nil || *sourceProjectID != id
+}
+
+// getClonedIntegrations returns pending copies of the block explorer and monitoring installed on the source stack,
+// they are installed once the clone is deployed. Integrations whose secrets are not provided are skipped.
+func (s *ThanosStackDeploymentService) getClonedIntegrations(
+	ctx context.Context,
+	source *entities.StackEntity,
+	stackId uuid.UUID,
+	request dtos.CloneThanosStackRequest,
+) ([]*entities.IntegrationEntity, []string, *entities.Response, error) {
+	sourceIntegrations, err := s.integrationRepo.GetIntegrationsByStackID(source.ID.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integrations", zap.String("stackId", source.ID.String()), zap.Error(err))
+		return nil, nil, &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	integrations := make([]*entities.IntegrationEntity, 0)
+	skippedIntegrations := make([]string, 0)
+	for _, integration := range sourceIntegrations {
+		if integration.Status != string(entities.DeploymentStatusCompleted) {
+			continue
+		}
+
+		var config interface{}
+		var logPath string
+		switch integration.Type {
+		case enum.IntegrationTypeBlockExplorer.String():
+			if request.BlockExplorer == nil {
+				skippedIntegrations = append(skippedIntegrations, integration.Type)
+				continue
+			}
+			var sourceConfig dtos.InstallBlockExplorerRequest
+			if err := json.Unmarshal(integration.Config, &sourceConfig); err != nil {
+				logger.ErrorContext(ctx, "failed to unmarshal integration config", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+				return nil, nil, &entities.Response{
+					Status:  http.StatusInternalServerError,
+					Message: "Internal server error",
+					Data:    nil,
+				}, err
+			}
+			blockExplorerConfig := dtos.InstallBlockExplorerRequest{
+				DatabaseUsername: sourceConfig.DatabaseUsername,
+				DatabasePassword: request.BlockExplorer.DatabasePassword,
+				CoinmarketcapKey: request.BlockExplorer.CoinmarketcapKey,
+				WalletConnectID:  sourceConfig.WalletConnectID,
+			}
+			if err := blockExplorerConfig.Validate(ctx); err != nil {
+				return nil, nil, &entities.Response{
+					Status:  http.StatusBadRequest,
+					Message: err.Error(),
+					Data:    nil,
+				}, nil
+			}
+			config = blockExplorerConfig
+			logPath = utils.GetLogPath(stackId, "block-explorer")
+		case enum.IntegrationTypeMonitoring.String():
+			if request.Monitoring == nil || request.Monitoring.GrafanaPassword == "" {
+				skippedIntegrations = append(skippedIntegrations, integration.Type)
+				continue
+			}
+			config = request.Monitoring
+			logPath = utils.GetLogPath(stackId, "install-monitoring")
+		default:
+			// The bridge and the candidate registration are part of the stack configuration
+			continue
+		}
+
+		configBytes, err := json.Marshal(config)
+		if err != nil {
+			return nil, nil, &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+		integrations = append(integrations, &entities.IntegrationEntity{
+			ID:        uuid.New(),
+			StackID:   &stackId,
+			Type:      integration.Type,
+			Status:    string(entities.DeploymentStatusPending),
+			Config:    configBytes,
+			LogPath:   logPath,
+			RequestID: logger.RequestIDFromContext(ctx),
+		})
+	}
+
+	return integrations, skippedIntegrations, nil, nil
+}
+
+// createThanosStack persists the stack with its deployments and integrations and enqueues its deployment.
+// The response is only set when the stack could not be created.
+func (s *ThanosStackDeploymentService) createThanosStack(
+	ctx context.Context,
+	stackId uuid.UUID,
+	request dtos.DeployThanosRequest,
+	additionalIntegrations []*entities.IntegrationEntity,
+) (*entities.Response, error) {
+	projectId, err := uuid.Parse(request.ProjectID)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid projectId",
+			Data:    nil,
+		}, nil
+	}
+	if request.CredentialID != "" {
+		credential, err := s.credentialRepo.GetCredentialByID(request.CredentialID)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to get credential", zap.String("credentialId", request.CredentialID), zap.Error(err))
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+		if credential == nil || credential.ProjectID != projectId {
+			return &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: "Credential not found in the project",
+				Data:    nil,
+			}, nil
+		}
+		if request.AwsAssumeRole != nil {
+			err = dtos.ValidateAwsAssumeRole(
+				ctx,
+				credential.AwsAccessKey,
+				credential.AwsSecretAccessKey,
+				credential.AwsRegion,
+				request.AwsAssumeRole,
+			)
+			if err != nil {
+				return &entities.Response{
+					Status:  http.StatusBadRequest,
+					Message: err.Error(),
+					Data:    nil,
+				}, nil
+			}
+		}
+	}
+	if response, err := s.checkOperatorKeys(ctx, projectId, &request); response != nil {
+		return response, err
+	}
 	deploymentPath := utils.GetDeploymentPath(s.name, request.Network, stackId.String())
 	request.DeploymentPath = deploymentPath
 	config, err := json.Marshal(request)
@@ -140,9 +470,17 @@ func (s *ThanosStackDeploymentService) CreateThanosStack(
 			Data:    nil,
 		}, err
 	}
+	// Fall back to the chain name so stacks can be told apart in listings
+	name := request.Name
+	if name == "" {
+		name = request.ChainName
+	}
 	stack := &entities.StackEntity{
 		ID:             stackId,
-		Name:           s.name,
+		ProjectID:      &projectId,
+		Name:           name,
+		Description:    request.Description,
+		Labels:         request.Labels,
 		Network:        request.Network,
 		Config:         config,
 		DeploymentPath: deploymentPath,
@@ -152,24 +490,28 @@ func (s *ThanosStackDeploymentService) CreateThanosStack(
 	// We install the bridge by default
 	integrations := make([]*entities.IntegrationEntity, 0)
 	bridgeIntegration := &entities.IntegrationEntity{
-		ID:      uuid.New(),
-		StackID: &stack.ID,
-		Type:    enum.IntegrationTypeBridge.String(),
-		Status:  string(entities.DeploymentStatusPending),
+		ID:        uuid.New(),
+		StackID:   &stack.ID,
+		Type:      enum.IntegrationTypeBridge.String(),
+		Status:    string(entities.DeploymentStatusPending),
+		RequestID: logger.RequestIDFromContext(ctx),
 	}
 	integrations = append(integrations, bridgeIntegration)
 
 	if request.RegisterCandidate {
 		registerCandidateIntegration := &entities.IntegrationEntity{
-			ID:      uuid.New(),
-			StackID: &stack.ID,
-			Type:    enum.IntegrationTypeRegisterCandidate.String(),
-			Status:  string(entities.DeploymentStatusPending),
+			ID:        uuid.New(),
+			StackID:   &stack.ID,
+			Type:      enum.IntegrationTypeRegisterCandidate.String(),
+			Status:    string(entities.DeploymentStatusPending),
+			RequestID: logger.RequestIDFromContext(ctx),
 		}
 		integrations = append(integrations, registerCandidateIntegration)
 	}
 
-	deployments, err := getThanosStackDeployments(stackId, &request)
+	integrations = append(integrations, additionalIntegrations...)
+
+	deployments, err := getThanosStackDeployments(ctx, stackId, &request)
 	if err != nil {
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -178,9 +520,9 @@ func (s *ThanosStackDeploymentService) CreateThanosStack(
 		}, err
 	}
 
-	err = s.stackRepo.CreateStackByTx(stack, deployments, integrations)
+	err = s.stackRepo.CreateStackByTx(stack, deployments, integrations, logger.RequestIDFromContext(ctx))
 	if err != nil {
-		logger.Error("Failed to create thanos stack", zap.Error(err))
+		logger.ErrorContext(ctx, "Failed to create thanos stack", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -188,18 +530,14 @@ func (s *ThanosStackDeploymentService) CreateThanosStack(
 		}, err
 	}
 
-	logger.Info("Stack created", zap.String("stackId", stackId.String()))
+	logger.InfoContext(ctx, "Stack created", zap.String("stackId", stackId.String()))
 
 	taskId := fmt.Sprintf("deploy-thanos-stack-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
 		s.handleStackDeployment(ctx, stackId)
 	})
 
-	return &entities.Response{
-		Status:  http.StatusOK,
-		Message: "Successfully",
-		Data:    map[string]string{"stackId": stackId.String()},
-	}, nil
+	return nil, nil
 }
 
 func (s *ThanosStackDeploymentService) StopDeployingThanosStack(ctx context.Context, stackId uuid.UUID) (*entities.Response, error) {
@@ -228,12 +566,16 @@ func (s *ThanosStackDeploymentService) StopDeployingThanosStack(ctx context.Cont
 		}, nil
 	}
 
-	taskId := fmt.Sprintf("deploy-thanos-stack-%s", stackId.String())
-	s.taskManager.StopTask(taskId)
-	// Update stacks status to stopping
-	err = s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusStopped, "")
+	// The stack is stopped before its task is cancelled, a conflicting update leaves the deployment running
+	err = s.stackRepo.UpdateStatusAtVersion(stackId.String(), stack.Version, entities.StackStatusStopped, "", logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrInvalidStatusTransition) {
+		return invalidTransitionResponse(err), nil
+	}
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
 	if err != nil {
-		logger.Error("failed to update stacks status",
+		logger.ErrorContext(ctx, "failed to update stacks status",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
 		return &entities.Response{
@@ -242,6 +584,9 @@ func (s *ThanosStackDeploymentService) StopDeployingThanosStack(ctx context.Cont
 			Data:    nil,
 		}, err
 	}
+
+	taskId := fmt.Sprintf("deploy-thanos-stack-%s", stackId.String())
+	s.taskManager.StopTask(taskId)
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
@@ -277,7 +622,7 @@ func (s *ThanosStackDeploymentService) ResumeThanosStack(ctx context.Context, st
 	}
 
 	taskId := fmt.Sprintf("deploy-thanos-stack-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
 		s.handleStackDeployment(ctx, stackId)
 	})
 
@@ -306,7 +651,8 @@ func (s *ThanosStackDeploymentService) UpdateNetwork(ctx context.Context, stackI
 		}, nil
 	}
 
-	if stack.Status != entities.StackStatusDeployed {
+	// A failed update can be retried
+	if stack.Status != entities.StackStatusDeployed && stack.Status != entities.StackStatusFailedToUpdate {
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "Stack is not deployed, yet. Please wait for it to finish",
@@ -314,8 +660,8 @@ func (s *ThanosStackDeploymentService) UpdateNetwork(ctx context.Context, stackI
 		}, nil
 	}
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -324,18 +670,18 @@ func (s *ThanosStackDeploymentService) UpdateNetwork(ctx context.Context, stackI
 	}
 
 	logPath := utils.GetLogPath(stack.ID, "update-network")
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to create thanos sdk client", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -343,9 +689,19 @@ func (s *ThanosStackDeploymentService) UpdateNetwork(ctx context.Context, stackI
 		}, err
 	}
 
-	err = s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusUpdating, "")
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
+
+	err = s.stackRepo.UpdateStatusAtVersion(stackId.String(), stack.Version, entities.StackStatusUpdating, "", logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrInvalidStatusTransition) {
+		return invalidTransitionResponse(err), nil
+	}
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
 	if err != nil {
-		logger.Error("failed to update stack status", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to update stack status", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -354,15 +710,19 @@ func (s *ThanosStackDeploymentService) UpdateNetwork(ctx context.Context, stackI
 	}
 
 	taskId := fmt.Sprintf("update-network-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		err = thanos.UpdateNetwork(ctx, sdkClient, &request)
-		if err != nil {
-			logger.Error("failed to update network", zap.Error(err))
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		if err := sdkClient.UpdateNetwork(ctx, &request); err != nil {
+			logger.ErrorContext(ctx, "failed to update network", zap.Error(err))
+			if updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToUpdate, err.Error(), logger.RequestIDFromContext(ctx)); updateErr != nil {
+				logger.ErrorContext(ctx, "failed to update stack status after update error", zap.String("stackId", stackId.String()), zap.Error(updateErr))
+			}
+			return
 		}
 
-		err = s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusDeployed, "")
-		if err != nil {
-			logger.Error("failed to update stack status", zap.String("stackId", stackId.String()), zap.Error(err))
+		if err := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusDeployed, "", logger.RequestIDFromContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "failed to update stack status", zap.String("stackId", stackId.String()), zap.Error(err))
 			return
 		}
 	})
@@ -385,10 +745,26 @@ func (s *ThanosStackDeploymentService) TerminateThanosStack(ctx context.Context,
 		}, err
 	}
 
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if stack.Status == entities.StackStatusTerminated {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Stack is already terminated",
+			Data:    nil,
+		}, nil
+	}
+
 	// Check if stacks is in a valid state to be terminated
 	if stack.Status == entities.StackStatusDeploying || stack.Status == entities.StackStatusUpdating ||
 		stack.Status == entities.StackStatusTerminating {
-		logger.Error(
+		logger.ErrorContext(ctx,
 			"The stacks is still deploying, updating or terminating, please wait for it to finish",
 			zap.String("stackId", stackId.String()),
 		)
@@ -399,8 +775,27 @@ func (s *ThanosStackDeploymentService) TerminateThanosStack(ctx context.Context,
 		}, nil
 	}
 
+	// The stack is moved to terminating before the task is queued, so that concurrent calls cannot terminate it twice
+	err = s.stackRepo.UpdateStatusAtVersion(stackId.String(), stack.Version, entities.StackStatusTerminating, "", logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrInvalidStatusTransition) {
+		return invalidTransitionResponse(err), nil
+	}
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update stacks status",
+			zap.String("stackId", stackId.String()),
+			zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
 	taskId := fmt.Sprintf("terminate-thanos-stack-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
 		s.handleStackTermination(ctx, stack)
 	})
 
@@ -411,9 +806,81 @@ func (s *ThanosStackDeploymentService) TerminateThanosStack(ctx context.Context,
 	}, nil
 }
 
+// PurgeStack archives a terminated stack, its deployments and its integrations, and removes its deployment directory
+// and its logs. The directory of an adopted stack is kept, it is not owned by the backend.
+func (s *ThanosStackDeploymentService) PurgeStack(ctx context.Context, stackId uuid.UUID) (*entities.Response, error) {
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if stack.Status != entities.StackStatusTerminated {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Only terminated stacks can be purged",
+			Data:    nil,
+		}, nil
+	}
+
+	// The directory is removed first, a failed archival leaves the stack terminated so that the purge can be retried
+	if utils.IsInStorage(stack.DeploymentPath) {
+		if err := os.RemoveAll(stack.DeploymentPath); err != nil {
+			logger.ErrorContext(ctx, "failed to remove the deployment directory",
+				zap.String("stackId", stackId.String()),
+				zap.String("deploymentPath", stack.DeploymentPath),
+				zap.Error(err),
+			)
+			return internalServerErrorResponse(), err
+		}
+	} else {
+		logger.InfoContext(ctx, "Keeping the deployment directory outside of the storage",
+			zap.String("stackId", stackId.String()),
+			zap.String("deploymentPath", stack.DeploymentPath),
+		)
+	}
+
+	// The logs of the stack are kept by the log sink rather than in its deployment directory
+	logs, err := s.logs.List(ctx, utils.GetLogDir(stack.ID))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list the logs", zap.String("stackId", stackId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	for _, name := range logs {
+		if err := s.logs.Delete(ctx, name); err != nil {
+			logger.ErrorContext(ctx, "failed to delete log", zap.String("stackId", stackId.String()), zap.String("log", name), zap.Error(err))
+			return internalServerErrorResponse(), err
+		}
+	}
+
+	if err := s.stackRepo.ArchiveStack(stackId.String(), logger.RequestIDFromContext(ctx)); err != nil {
+		logger.ErrorContext(ctx, "failed to archive stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Stack purged", zap.String("stackId", stackId.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
 func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context, stackId string, request dtos.InstallBlockExplorerRequest) (*entities.Response, error) {
-	if err := request.Validate(); err != nil {
-		logger.Error("invalid block explorer request", zap.Error(err))
+	if err := request.Validate(ctx); err != nil {
+		logger.ErrorContext(ctx, "invalid block explorer request", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "Invalid block explorer request",
@@ -430,18 +897,18 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 		}, err
 	}
 
-	if stack.Status != entities.StackStatusDeployed {
+	if stack == nil {
 		return &entities.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Stack is not deployed, yet. Please wait for it to finish",
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
 			Data:    nil,
 		}, nil
 	}
 
-	if stack == nil {
+	if stack.Status != entities.StackStatusDeployed {
 		return &entities.Response{
-			Status:  http.StatusNotFound,
-			Message: "Stack not found",
+			Status:  http.StatusBadRequest,
+			Message: "Stack is not deployed, yet. Please wait for it to finish",
 			Data:    nil,
 		}, nil
 	}
@@ -449,7 +916,7 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 	// check if block explorer is already in non-terminated state
 	integrations, err := s.integrationRepo.GetActiveIntegrations(stackId, "block-explorer")
 	if err != nil {
-		logger.Error("failed to get integration", zap.String("plugin", "block-explorer"), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", "block-explorer"), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -458,7 +925,7 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 	}
 
 	if len(integrations) > 0 {
-		logger.Error("There is already an active block explorer", zap.String("plugin", "block-explorer"))
+		logger.ErrorContext(ctx, "There is already an active block explorer", zap.String("plugin", "block-explorer"))
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "There is already an active block explorer",
@@ -467,8 +934,8 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 	}
 
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -476,23 +943,19 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 		}, err
 	}
 
-	var (
-		blockExplorerUrl string
-	)
-
 	logPath := utils.GetLogPath(stack.ID, "block-explorer")
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -501,98 +964,121 @@ func (s *ThanosStackDeploymentService) InstallBlockExplorer(ctx context.Context,
 		}, err
 	}
 
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
+
+	confifgBytes, err := json.Marshal(request)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal block explorer config", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	blockExplorerIntegration := &entities.IntegrationEntity{
+		ID:        uuid.New(),
+		StackID:   &stack.ID,
+		Type:      enum.IntegrationTypeBlockExplorer.String(),
+		Status:    string(entities.DeploymentStatusInProgress),
+		Config:    confifgBytes,
+		LogPath:   logPath,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	// The integration is created at the version the stack was checked, a concurrent install makes it fail
+	err = s.integrationRepo.CreateIntegration(blockExplorerIntegration, stack.Version)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
 	taskId := fmt.Sprintf("install-block-explorer-%s", stackId)
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		confifgBytes, err := json.Marshal(request)
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		s.installBlockExplorer(ctx, stack, sdkClient, blockExplorerIntegration, request)
+	})
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+// installBlockExplorer installs the block explorer described by the integration and records the outcome on it
+func (s *ThanosStackDeploymentService) installBlockExplorer(
+	ctx context.Context,
+	stack *entities.StackEntity,
+	sdkClient thanos.StackClient,
+	integration *entities.IntegrationEntity,
+	request dtos.InstallBlockExplorerRequest,
+) {
+	blockExplorerUrl, err := sdkClient.InstallBlockExplorer(ctx, &request)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to install block explorer", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		err = s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to marshal block explorer config", zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err), zap.String("integrationId", integration.ID.String()))
 			return
 		}
-		blockExplorerIntegration := &entities.IntegrationEntity{
-			ID:      uuid.New(),
-			StackID: &stack.ID,
-			Type:    enum.IntegrationTypeBlockExplorer.String(),
-			Status:  string(entities.DeploymentStatusInProgress),
-			Config:  confifgBytes,
-			LogPath: logPath,
-		}
-		err = s.integrationRepo.CreateIntegration(blockExplorerIntegration)
+		return
+	}
+
+	if blockExplorerUrl == "" {
+		logger.ErrorContext(ctx, "block explorer URL is empty", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()))
+		err = s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, "Block explorer URL is empty", logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to create integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			return
-		}
-
-		blockExplorerUrl, err = thanos.InstallBlockExplorer(ctx, sdkClient, &request)
-		if err != nil {
-			logger.Error("failed to install block explorer", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			err = s.integrationRepo.UpdateIntegrationStatusWithReason(blockExplorerIntegration.ID.String(), entities.DeploymentStatusFailed, err.Error())
-			if err != nil {
-				logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err), zap.String("integrationId", blockExplorerIntegration.ID.String()))
-				return
-			}
-			return
-		}
-
-		if blockExplorerUrl == "" {
-			logger.Error("block explorer URL is empty", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()))
-			err = s.integrationRepo.UpdateIntegrationStatusWithReason(blockExplorerIntegration.ID.String(), entities.DeploymentStatusFailed, "Block explorer URL is empty")
-			if err != nil {
-				logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err), zap.String("integrationId", blockExplorerIntegration.ID.String()))
-				return
-			}
-			return
-		}
-
-		logger.Debug("block explorer successfully installed", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.String("url", blockExplorerUrl))
-		// create integration
-		config, err := json.Marshal(request)
-		if err != nil {
-			logger.Error("failed to marshal block explorer config", zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err), zap.String("integrationId", integration.ID.String()))
 			return
 		}
+		return
+	}
 
-		err = s.integrationRepo.UpdateConfig(
-			blockExplorerIntegration.ID.String(),
-			json.RawMessage(config),
-		)
-		if err != nil {
-			logger.Error("failed to update block explorer integration config", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			return
-		}
+	logger.DebugContext(ctx, "block explorer successfully installed", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.String("url", blockExplorerUrl))
+	// create integration
+	config, err := json.Marshal(request)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal block explorer config", zap.Error(err))
+		return
+	}
 
-		blockExplorerMedata := map[string]string{
-			"url": blockExplorerUrl,
-		}
-		bytes, err := json.Marshal(blockExplorerMedata)
-		if err != nil {
-			logger.Error("failed to marshal block explorer metadata", zap.Error(err))
-			return
-		}
-		err = s.integrationRepo.UpdateMetadataAfterInstalled(
-			blockExplorerIntegration.ID.String(),
-			entities.IntegrationInfo(bytes),
-		)
-		if err != nil {
-			logger.Error("failed to create integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			return
-		}
-		stack.Metadata.BlockExplorerUrl = blockExplorerUrl
+	err = s.integrationRepo.UpdateConfig(
+		integration.ID.String(),
+		json.RawMessage(config),
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update block explorer integration config", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		return
+	}
 
-		err = s.stackRepo.UpdateMetadata(
-			stackId,
-			stack.Metadata,
-		)
-		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
-			return
-		}
-	})
+	blockExplorerMedata := map[string]string{
+		"url": blockExplorerUrl,
+	}
+	bytes, err := json.Marshal(blockExplorerMedata)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal block explorer metadata", zap.Error(err))
+		return
+	}
+	err = s.integrationRepo.UpdateMetadataAfterInstalled(
+		integration.ID.String(),
+		entities.IntegrationInfo(bytes),
+		logger.RequestIDFromContext(ctx),
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		return
+	}
+	stack.Metadata.BlockExplorerUrl = blockExplorerUrl
 
-	return &entities.Response{
-		Status:  http.StatusOK,
-		Message: "Successfully",
-		Data:    nil,
-	}, nil
+	err = s.stackRepo.UpdateMetadata(
+		stack.ID.String(),
+		stack.Metadata,
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stack.ID.String()), zap.Error(err))
+		return
+	}
 }
 
 func (s *ThanosStackDeploymentService) UninstallBlockExplorer(ctx context.Context, stackId string) (*entities.Response, error) {
@@ -613,9 +1099,23 @@ func (s *ThanosStackDeploymentService) UninstallBlockExplorer(ctx context.Contex
 		}, nil
 	}
 
+	integration, err := s.integrationRepo.GetInstalledIntegration(stackId, enum.IntegrationTypeBlockExplorer.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if integration == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Block explorer is not installed",
+			Data:    nil,
+		}, nil
+	}
+
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -624,18 +1124,18 @@ func (s *ThanosStackDeploymentService) UninstallBlockExplorer(ctx context.Contex
 	}
 
 	logPath := utils.GetLogPath(stack.ID, "uninstall-block-explorer")
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -644,32 +1144,33 @@ func (s *ThanosStackDeploymentService) UninstallBlockExplorer(ctx context.Contex
 		}, err
 	}
 
-	taskId := fmt.Sprintf("uninstall-block-explorer-%s", stackId)
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		integration, err := s.integrationRepo.GetInstalledIntegration(stackId, enum.IntegrationTypeBlockExplorer.String())
-		if err != nil {
-			logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			return
-		}
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-		if integration == nil {
-			logger.Error("integration not found", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()))
-			return
-		}
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminating)
-		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
-			return
-		}
-		err = thanos.UninstallBlockExplorer(ctx, sdkClient)
+	// The integration is terminated at the version it was checked, a concurrent uninstall makes it fail
+	err = s.integrationRepo.UpdateIntegrationStatusAtVersion(integration.ID.String(), integration.Version, entities.DeploymentStatusTerminating, logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	taskId := fmt.Sprintf("uninstall-block-explorer-%s", stackId)
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		err = sdkClient.UninstallBlockExplorer(ctx)
 		if err != nil {
-			logger.Error("failed to install block-explorer", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to install block-explorer", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
 			return
 		}
 
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated)
+		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated, logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeBlockExplorer.String()), zap.Error(err))
 			return
 		}
 		stack.Metadata.BlockExplorerUrl = ""
@@ -679,7 +1180,7 @@ func (s *ThanosStackDeploymentService) UninstallBlockExplorer(ctx context.Contex
 			stack.Metadata,
 		)
 		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
 			return
 		}
 	})
@@ -720,7 +1221,7 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 	// check if bridge is already in non-terminated state
 	integrations, err := s.integrationRepo.GetActiveIntegrations(stackId, enum.IntegrationTypeBridge.String())
 	if err != nil {
-		logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -729,7 +1230,7 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 	}
 
 	if len(integrations) > 0 {
-		logger.Error("There is already an active bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()))
+		logger.ErrorContext(ctx, "There is already an active bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()))
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "There is already an active bridge",
@@ -738,8 +1239,8 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 	}
 
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -753,18 +1254,18 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 
 	logPath := utils.GetLogPath(stack.ID, "install-bridge")
 
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -773,41 +1274,52 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 		}, err
 	}
 
-	taskId := fmt.Sprintf("install-bridge-%s", stackId)
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		bridgeIntegration := &entities.IntegrationEntity{
-			ID:      uuid.New(),
-			StackID: &stack.ID,
-			Type:    enum.IntegrationTypeBridge.String(),
-			Status:  string(entities.DeploymentStatusInProgress),
-			LogPath: logPath,
-		}
-		err = s.integrationRepo.CreateIntegration(bridgeIntegration)
-		if err != nil {
-			logger.Error("failed to create integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
-			return
-		}
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-		bridgeUrl, err = thanos.InstallBridge(ctx, sdkClient)
+	bridgeIntegration := &entities.IntegrationEntity{
+		ID:        uuid.New(),
+		StackID:   &stack.ID,
+		Type:      enum.IntegrationTypeBridge.String(),
+		Status:    string(entities.DeploymentStatusInProgress),
+		LogPath:   logPath,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	// The integration is created at the version the stack was checked, a concurrent install makes it fail
+	err = s.integrationRepo.CreateIntegration(bridgeIntegration, stack.Version)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	taskId := fmt.Sprintf("install-bridge-%s", stackId)
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		bridgeUrl, err = sdkClient.InstallBridge(ctx)
 		if err != nil {
-			logger.Error("failed to install bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
-			err = s.integrationRepo.UpdateIntegrationStatusWithReason(bridgeIntegration.ID.String(), entities.DeploymentStatusFailed, err.Error())
+			logger.ErrorContext(ctx, "failed to install bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+			err = s.integrationRepo.UpdateIntegrationStatusWithReason(bridgeIntegration.ID.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx))
 			if err != nil {
-				logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err), zap.String("integrationId", bridgeIntegration.ID.String()))
+				logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err), zap.String("integrationId", bridgeIntegration.ID.String()))
 			}
 			return
 		}
 
 		if bridgeUrl == "" {
-			logger.Error("bridge URL is empty", zap.String("plugin", enum.IntegrationTypeBridge.String()))
-			err = s.integrationRepo.UpdateIntegrationStatusWithReason(bridgeIntegration.ID.String(), entities.DeploymentStatusFailed, "Bridge URL is empty")
+			logger.ErrorContext(ctx, "bridge URL is empty", zap.String("plugin", enum.IntegrationTypeBridge.String()))
+			err = s.integrationRepo.UpdateIntegrationStatusWithReason(bridgeIntegration.ID.String(), entities.DeploymentStatusFailed, "Bridge URL is empty", logger.RequestIDFromContext(ctx))
 			if err != nil {
-				logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err), zap.String("integrationId", bridgeIntegration.ID.String()))
+				logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err), zap.String("integrationId", bridgeIntegration.ID.String()))
 			}
 			return
 		}
 
-		logger.Debug("bridge successfully installed", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.String("url", bridgeUrl))
+		logger.DebugContext(ctx, "bridge successfully installed", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.String("url", bridgeUrl))
 
 		// create integration
 		bridgeMetadata := map[string]string{
@@ -815,16 +1327,17 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 		}
 		bytes, err := json.Marshal(bridgeMetadata)
 		if err != nil {
-			logger.Error("failed to marshal bridge metadata", zap.Error(err))
+			logger.ErrorContext(ctx, "failed to marshal bridge metadata", zap.Error(err))
 			return
 		}
 
 		err = s.integrationRepo.UpdateMetadataAfterInstalled(
 			bridgeIntegration.ID.String(),
 			entities.IntegrationInfo(bytes),
+			logger.RequestIDFromContext(ctx),
 		)
 		if err != nil {
-			logger.Error("failed to update bridge integration metadata", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update bridge integration metadata", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
 			return
 		}
 
@@ -835,11 +1348,11 @@ func (s *ThanosStackDeploymentService) InstallBridge(ctx context.Context, stackI
 			stack.Metadata,
 		)
 		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
 			return
 		}
 
-		logger.Info("Bridge installed successfully",
+		logger.InfoContext(ctx, "Bridge installed successfully",
 			zap.String("stackId", stackId),
 			zap.String("bridgeUrl", bridgeUrl),
 		)
@@ -870,9 +1383,23 @@ func (s *ThanosStackDeploymentService) UninstallBridge(ctx context.Context, stac
 		}, nil
 	}
 
+	integration, err := s.integrationRepo.GetInstalledIntegration(stackId, enum.IntegrationTypeBridge.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if integration == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Bridge is not installed",
+			Data:    nil,
+		}, nil
+	}
+
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -882,18 +1409,18 @@ func (s *ThanosStackDeploymentService) UninstallBridge(ctx context.Context, stac
 
 	logPath := utils.GetLogPath(stack.ID, "uninstall-bridge")
 
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -902,36 +1429,35 @@ func (s *ThanosStackDeploymentService) UninstallBridge(ctx context.Context, stac
 		}, err
 	}
 
-	taskId := fmt.Sprintf("uninstall-bridge-%s", stackId)
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		integration, err := s.integrationRepo.GetInstalledIntegration(stackId, enum.IntegrationTypeBridge.String())
-		if err != nil {
-			logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
-			return
-		}
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-		if integration == nil {
-			logger.Error("integration not found", zap.String("plugin", enum.IntegrationTypeBridge.String()))
-			return
-		}
-
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminating)
-		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
-			return
-		}
+	// The integration is terminated at the version it was checked, a concurrent uninstall makes it fail
+	err = s.integrationRepo.UpdateIntegrationStatusAtVersion(integration.ID.String(), integration.Version, entities.DeploymentStatusTerminating, logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
 
-		logger.Info("Uninstalling bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()))
+	taskId := fmt.Sprintf("uninstall-bridge-%s", stackId)
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		logger.InfoContext(ctx, "Uninstalling bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()))
 
-		err = thanos.UninstallBridge(ctx, sdkClient)
+		err = sdkClient.UninstallBridge(ctx)
 		if err != nil {
-			logger.Error("failed to install bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to install bridge", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
 			return
 		}
 
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated)
+		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated, logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
 			return
 		}
 		stack.Metadata.BridgeUrl = ""
@@ -941,7 +1467,7 @@ func (s *ThanosStackDeploymentService) UninstallBridge(ctx context.Context, stac
 			stack.Metadata,
 		)
 		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stackId), zap.Error(err))
 			return
 		}
 	})
@@ -953,10 +1479,14 @@ func (s *ThanosStackDeploymentService) UninstallBridge(ctx context.Context, stac
 	}, nil
 }
 
-func (s *ThanosStackDeploymentService) GetAllStacks() (*entities.Response, error) {
-	stacks, err := s.stackRepo.GetAllStacks()
+func (s *ThanosStackDeploymentService) GetAllStacks(
+	ctx context.Context,
+	labelSelector map[string]string,
+	includeArchived bool,
+) (*entities.Response, error) {
+	stacks, err := s.stackRepo.GetAllStacks(labelSelector, includeArchived)
 	if err != nil {
-		logger.Error("failed to get stacks", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stacks", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -967,14 +1497,269 @@ func (s *ThanosStackDeploymentService) GetAllStacks() (*entities.Response, error
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"stacks": stacks},
+		Data:    map[string]interface{}{"stacks": entities.StacksWithoutSecrets(stacks)},
 	}, nil
 }
 
-func (s *ThanosStackDeploymentService) GetStackStatus(stackId uuid.UUID) (*entities.Response, error) {
+func (s *ThanosStackDeploymentService) GetStacksByProjectIDs(
+	ctx context.Context,
+	projectIds []string,
+	labelSelector map[string]string,
+	includeArchived bool,
+) (*entities.Response, error) {
+	stacks, err := s.stackRepo.GetStacksByProjectIDs(projectIds, labelSelector, includeArchived)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stacks", zap.Strings("projectIds", projectIds), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"stacks": entities.StacksWithoutSecrets(stacks)},
+	}, nil
+}
+
+// UpdateStack edits the display name, description and labels of the stack.
+// Label changes of a deployed stack are propagated to its AWS resources in the background.
+func (s *ThanosStackDeploymentService) UpdateStack(
+	ctx context.Context,
+	stackId uuid.UUID,
+	request dtos.UpdateStackRequest,
+) (*entities.Response, error) {
+	var (
+		stack          *entities.StackEntity
+		previousLabels map[string]string
+		err            error
+	)
+	// The fields missing from the request keep their current value, so the update is applied again to the latest
+	// version of the stack when another request changed it in the meantime
+	for attempt := 1; ; attempt++ {
+		stack, err = s.stackRepo.GetStackByID(stackId.String())
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+
+		if stack == nil {
+			return &entities.Response{
+				Status:  http.StatusNotFound,
+				Message: "Stack not found",
+				Data:    nil,
+			}, nil
+		}
+
+		previousLabels = stack.Labels
+		if request.Name != nil {
+			stack.Name = *request.Name
+		}
+		if request.Description != nil {
+			stack.Description = *request.Description
+		}
+		if request.Labels != nil {
+			stack.Labels = *request.Labels
+		}
+
+		err = s.stackRepo.UpdateDetails(stackId.String(), stack.Version, stack.Name, stack.Description, stack.Labels)
+		if !errors.Is(err, entities.ErrVersionConflict) || attempt == updateStackAttempts {
+			break
+		}
+		logger.InfoContext(ctx, "Stack changed during the update, retrying", zap.String("stackId", stackId.String()), zap.Int("attempt", attempt))
+	}
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	stack.Version++
+
+	if request.Labels != nil && stack.Status == entities.StackStatusDeployed {
+		removedKeys := make([]string, 0)
+		for key := range previousLabels {
+			if _, ok := stack.Labels[key]; !ok {
+				removedKeys = append(removedKeys, key)
+			}
+		}
+
+		taskId := fmt.Sprintf("tag-thanos-stack-%s", stackId.String())
+		s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+			var stackConfig dtos.DeployThanosRequest
+			if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+				logger.WarnContext(ctx, "failed to tag AWS resources", zap.String("stackId", stackId.String()), zap.Error(err))
+				return
+			}
+			if err := s.driver.TagAWSResources(ctx, thanos.ClientConfig{
+				DeploymentPath:     stack.DeploymentPath,
+				AwsAccessKey:       stackConfig.AwsAccessKey,
+				AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+				AwsRegion:          stackConfig.AwsRegion,
+				AwsAssumeRole:      stackConfig.AwsAssumeRole,
+			}, stack.Labels, removedKeys); err != nil {
+				logger.WarnContext(ctx, "failed to tag AWS resources", zap.String("stackId", stackId.String()), zap.Error(err))
+			}
+		})
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"stack": stack.WithoutSecrets()},
+	}, nil
+}
+
+// UpdateStackCredentials replaces the AWS keys stored in the config of the stack, as a new config revision, once they
+// are validated and can see the deployed resources of the stack. Nothing is redeployed, the next operations use them.
+func (s *ThanosStackDeploymentService) UpdateStackCredentials(
+	ctx context.Context,
+	stackId uuid.UUID,
+	request dtos.UpdateStackCredentialsRequest,
+) (*entities.Response, error) {
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	if stack.Status == entities.StackStatusTerminated {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Stack is terminated",
+			Data:    nil,
+		}, nil
+	}
+
+	integrations, err := s.integrationRepo.GetIntegrationsByStackID(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integrations", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	// The running operation keeps the keys it started with
+	if isStackBusy(stack, integrations) {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "Stack has an operation in progress, please wait for it to finish",
+			Data:    nil,
+		}, nil
+	}
+
+	// The config is read as stored, the keys of a credential profile must not be written into it
+	var stackConfig dtos.DeployThanosRequest
+	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stackConfig.CredentialID != "" {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "Stack uses the credential profile " + stackConfig.CredentialID + ", rotate the keys of the profile instead",
+			Data:    nil,
+		}, nil
+	}
+
+	// The new keys must still be able to assume the role of the stack
+	if stackConfig.AwsAssumeRole != nil {
+		err = dtos.ValidateAwsAssumeRole(ctx, request.AwsAccessKey, request.AwsSecretAccessKey, stackConfig.AwsRegion, stackConfig.AwsAssumeRole)
+	} else {
+		err = dtos.ValidateAwsCredentials(ctx, request.AwsAccessKey, request.AwsSecretAccessKey, stackConfig.AwsRegion)
+	}
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	if err := s.driver.VerifyAWSResourcesAccess(
+		ctx,
+		stack.DeploymentPath,
+		request.AwsAccessKey,
+		request.AwsSecretAccessKey,
+		stackConfig.AwsRegion,
+		stackConfig.AwsAssumeRole,
+	); err != nil {
+		logger.WarnContext(ctx, "new AWS keys cannot access the stack resources", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	stackConfig.AwsAccessKey = request.AwsAccessKey
+	stackConfig.AwsSecretAccessKey = request.AwsSecretAccessKey
+	config, err := json.Marshal(stackConfig)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	// A stack changed since the busy check may have started an operation with the previous keys
+	err = s.stackRepo.UpdateConfig(stackId.String(), stack.Version, config, "credentials updated", logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	logger.InfoContext(ctx, "Stack credentials updated", zap.String("stackId", stackId.String()))
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+func (s *ThanosStackDeploymentService) GetStackStatus(ctx context.Context, stackId uuid.UUID) (*entities.Response, error) {
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -992,7 +1777,7 @@ func (s *ThanosStackDeploymentService) GetStackStatus(stackId uuid.UUID) (*entit
 
 	status, err := s.stackRepo.GetStackStatus(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack status", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack status", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1007,13 +1792,43 @@ func (s *ThanosStackDeploymentService) GetStackStatus(stackId uuid.UUID) (*entit
 	}, nil
 }
 
+// GetStackHistory returns the status transitions of the stack and of its deployments and integrations, oldest first
+func (s *ThanosStackDeploymentService) GetStackHistory(ctx context.Context, stackId uuid.UUID) (*entities.Response, error) {
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	transitions, err := s.stackRepo.GetStatusTransitions(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack history", zap.String("stackId", stackId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"transitions": transitions},
+	}, nil
+}
+
 func (s *ThanosStackDeploymentService) GetDeployments(
+	ctx context.Context,
 	stackId uuid.UUID,
 ) (*entities.Response, error) {
 
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1031,7 +1846,7 @@ func (s *ThanosStackDeploymentService) GetDeployments(
 
 	deployments, err := s.deploymentRepo.GetDeploymentsByStackID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get deployments", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get deployments", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1042,16 +1857,18 @@ func (s *ThanosStackDeploymentService) GetDeployments(
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"deployments": deployments},
+		Data:    map[string]interface{}{"deployments": entities.DeploymentsWithoutSecrets(deployments)},
 	}, nil
 }
 
 func (s *ThanosStackDeploymentService) GetStackDeploymentStatus(
+	ctx context.Context,
+	stackId uuid.UUID,
 	deploymentId uuid.UUID,
 ) (*entities.Response, error) {
-	status, err := s.deploymentRepo.GetDeploymentStatus(deploymentId.String())
+	deployment, err := s.deploymentRepo.GetDeploymentByID(deploymentId.String())
 	if err != nil {
-		logger.Error("failed to get deployment status", zap.String("deploymentId", deploymentId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get deployment status", zap.String("deploymentId", deploymentId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1059,6 +1876,15 @@ func (s *ThanosStackDeploymentService) GetStackDeploymentStatus(
 		}, err
 	}
 
+	if deployment == nil || deployment.StackID == nil || *deployment.StackID != stackId {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Deployment not found",
+			Data:    nil,
+		}, nil
+	}
+	status := deployment.Status
+
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
@@ -1067,12 +1893,13 @@ func (s *ThanosStackDeploymentService) GetStackDeploymentStatus(
 }
 
 func (s *ThanosStackDeploymentService) GetStackDeployment(
-	_ uuid.UUID,
+	ctx context.Context,
+	stackId uuid.UUID,
 	deploymentId uuid.UUID,
 ) (*entities.Response, error) {
 	deployment, err := s.deploymentRepo.GetDeploymentByID(deploymentId.String())
 	if err != nil {
-		logger.Error("failed to get deployment", zap.String("deploymentId", deploymentId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get deployment", zap.String("deploymentId", deploymentId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1080,7 +1907,7 @@ func (s *ThanosStackDeploymentService) GetStackDeployment(
 		}, err
 	}
 
-	if deployment == nil {
+	if deployment == nil || deployment.StackID == nil || *deployment.StackID != stackId {
 		return &entities.Response{
 			Status:  http.StatusNotFound,
 			Message: "Deployment not found",
@@ -1091,16 +1918,17 @@ func (s *ThanosStackDeploymentService) GetStackDeployment(
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"deployment": deployment},
+		Data:    map[string]interface{}{"deployment": deployment.WithoutSecrets()},
 	}, nil
 }
 
 func (s *ThanosStackDeploymentService) GetStackByID(
+	ctx context.Context,
 	stackId uuid.UUID,
 ) (*entities.Response, error) {
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1119,16 +1947,17 @@ func (s *ThanosStackDeploymentService) GetStackByID(
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"stack": stack},
+		Data:    map[string]interface{}{"stack": stack.WithoutSecrets()},
 	}, nil
 }
 
 func (s *ThanosStackDeploymentService) GetIntegrations(
+	ctx context.Context,
 	stackId uuid.UUID,
 ) (*entities.Response, error) {
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1145,7 +1974,7 @@ func (s *ThanosStackDeploymentService) GetIntegrations(
 	}
 	integrations, err := s.integrationRepo.GetActiveIntegrationsByStackID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get integrations", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integrations", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1155,17 +1984,18 @@ func (s *ThanosStackDeploymentService) GetIntegrations(
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"integrations": integrations},
+		Data:    map[string]interface{}{"integrations": entities.IntegrationsWithoutSecrets(integrations)},
 	}, nil
 }
 
 func (s *ThanosStackDeploymentService) GetIntegration(
+	ctx context.Context,
 	stackId uuid.UUID,
 	integrationId uuid.UUID,
 ) (*entities.Response, error) {
 	integration, err := s.integrationRepo.GetIntegrationById(integrationId.String())
 	if err != nil {
-		logger.Error("failed to get integrations", zap.String("stackId", stackId.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integrations", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1173,7 +2003,7 @@ func (s *ThanosStackDeploymentService) GetIntegration(
 		}, err
 	}
 
-	if integration == nil {
+	if integration == nil || integration.StackID == nil || *integration.StackID != stackId {
 		return &entities.Response{
 			Status:  http.StatusNotFound,
 			Message: "Integration not found",
@@ -1184,7 +2014,7 @@ func (s *ThanosStackDeploymentService) GetIntegration(
 	return &entities.Response{
 		Status:  http.StatusOK,
 		Message: "Successfully",
-		Data:    map[string]interface{}{"integration": integration},
+		Data:    map[string]interface{}{"integration": integration.WithoutSecrets()},
 	}, nil
 }
 
@@ -1221,7 +2051,7 @@ func (s *ThanosStackDeploymentService) InstallMonitoring(
 	// check if bridge is already in non-terminated state
 	integrations, err := s.integrationRepo.GetActiveIntegrations(stackId.String(), "monitoring")
 	if err != nil {
-		logger.Error("failed to get integration", zap.String("plugin", "monitoring"), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", "monitoring"), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1230,7 +2060,7 @@ func (s *ThanosStackDeploymentService) InstallMonitoring(
 	}
 
 	if len(integrations) > 0 {
-		logger.Error("There is already an active monitoring", zap.String("plugin", "monitoring"))
+		logger.ErrorContext(ctx, "There is already an active monitoring", zap.String("plugin", "monitoring"))
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "There is already an active monitoring",
@@ -1239,8 +2069,8 @@ func (s *ThanosStackDeploymentService) InstallMonitoring(
 	}
 
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1248,24 +2078,20 @@ func (s *ThanosStackDeploymentService) InstallMonitoring(
 		}, err
 	}
 
-	var (
-		grafanaURL string
-	)
-
 	logPath := utils.GetLogPath(stack.ID, "install-monitoring")
 
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -1274,87 +2100,119 @@ func (s *ThanosStackDeploymentService) InstallMonitoring(
 		}, err
 	}
 
-	taskId := fmt.Sprintf("install-monitoring-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		confifgBytes, err := json.Marshal(req)
-		if err != nil {
-			logger.Error("failed to marshal monitoring config", zap.Error(err))
-			return
-		}
-		monitoringIntegration := &entities.IntegrationEntity{
-			ID:      uuid.New(),
-			StackID: &stack.ID,
-			Type:    enum.IntegrationTypeMonitoring.String(),
-			Status:  string(entities.DeploymentStatusInProgress),
-			Config:  confifgBytes,
-			LogPath: logPath,
-		}
-		err = s.integrationRepo.CreateIntegration(monitoringIntegration)
-		if err != nil {
-			logger.Error("failed to create integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
-			return
-		}
-
-		config, err := thanos.GetMonitoringConfig(ctx, sdkClient, req.GrafanaPassword)
-		if err != nil {
-			logger.Error("failed to get monitoring config", zap.Error(err))
-			return
-		}
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-		grafanaURL, err = thanos.InstallMonitoring(ctx, sdkClient, config)
-		if err != nil {
-			logger.Error("failed to install monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
-			return
-		}
+	confifgBytes, err := json.Marshal(req)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal monitoring config", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+	monitoringIntegration := &entities.IntegrationEntity{
+		ID:        uuid.New(),
+		StackID:   &stack.ID,
+		Type:      enum.IntegrationTypeMonitoring.String(),
+		Status:    string(entities.DeploymentStatusInProgress),
+		Config:    confifgBytes,
+		LogPath:   logPath,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	// The integration is created at the version the stack was checked, a concurrent install makes it fail
+	err = s.integrationRepo.CreateIntegration(monitoringIntegration, stack.Version)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
 
-		if grafanaURL == "" {
-			logger.Error("monitoring URL is empty", zap.String("plugin", enum.IntegrationTypeMonitoring.String()))
-			return
-		}
+	taskId := fmt.Sprintf("install-monitoring-%s", stackId.String())
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		s.installMonitoring(ctx, stack, sdkClient, monitoringIntegration, req)
+	})
 
-		logger.Debug("monitoring successfully installed", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.String("url", grafanaURL))
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
 
-		// create integration
-		monitoringMetadata := map[string]string{
-			"url": grafanaURL,
+// installMonitoring installs the monitoring described by the integration and records the outcome on it
+func (s *ThanosStackDeploymentService) installMonitoring(
+	ctx context.Context,
+	stack *entities.StackEntity,
+	sdkClient thanos.StackClient,
+	integration *entities.IntegrationEntity,
+	req dtos.InstallMonitoringRequest,
+) {
+	config, err := sdkClient.GetMonitoringConfig(ctx, req.GrafanaPassword)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get monitoring config", zap.Error(err))
+		if err := s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err), zap.String("integrationId", integration.ID.String()))
 		}
-		bytes, err := json.Marshal(monitoringMetadata)
-		if err != nil {
-			logger.Error("failed to marshal monitoring metadata", zap.Error(err))
-			return
+		return
+	}
+
+	grafanaURL, err := sdkClient.InstallMonitoring(ctx, config)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to install monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+		if err := s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err), zap.String("integrationId", integration.ID.String()))
 		}
+		return
+	}
 
-		err = s.integrationRepo.UpdateMetadataAfterInstalled(
-			monitoringIntegration.ID.String(),
-			entities.IntegrationInfo(bytes),
-		)
-		if err != nil {
-			logger.Error("failed to update monitoring integration metadata", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
-			return
+	if grafanaURL == "" {
+		logger.ErrorContext(ctx, "monitoring URL is empty", zap.String("plugin", enum.IntegrationTypeMonitoring.String()))
+		if err := s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, "Monitoring URL is empty", logger.RequestIDFromContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err), zap.String("integrationId", integration.ID.String()))
 		}
+		return
+	}
 
-		stack.Metadata.MonitoringUrl = grafanaURL
+	logger.DebugContext(ctx, "monitoring successfully installed", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.String("url", grafanaURL))
 
-		err = s.stackRepo.UpdateMetadata(
-			stackId.String(),
-			stack.Metadata,
-		)
-		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId.String()), zap.Error(err))
-			return
-		}
+	// create integration
+	monitoringMetadata := map[string]string{
+		"url": grafanaURL,
+	}
+	bytes, err := json.Marshal(monitoringMetadata)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal monitoring metadata", zap.Error(err))
+		return
+	}
 
-		logger.Info("Monitoring installed successfully",
-			zap.String("stackId", stackId.String()),
-			zap.String("grafanaUrl", grafanaURL),
-		)
-	})
+	err = s.integrationRepo.UpdateMetadataAfterInstalled(
+		integration.ID.String(),
+		entities.IntegrationInfo(bytes),
+		logger.RequestIDFromContext(ctx),
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update monitoring integration metadata", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+		return
+	}
 
-	return &entities.Response{
-		Status:  http.StatusOK,
-		Message: "Successfully",
-		Data:    nil,
-	}, nil
+	stack.Metadata.MonitoringUrl = grafanaURL
+
+	err = s.stackRepo.UpdateMetadata(
+		stack.ID.String(),
+		stack.Metadata,
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stack.ID.String()), zap.Error(err))
+		return
+	}
+
+	logger.InfoContext(ctx, "Monitoring installed successfully",
+		zap.String("stackId", stack.ID.String()),
+		zap.String("grafanaUrl", grafanaURL),
+	)
 }
 
 func (s *ThanosStackDeploymentService) UninstallMonitoring(
@@ -1378,9 +2236,23 @@ func (s *ThanosStackDeploymentService) UninstallMonitoring(
 		}, nil
 	}
 
+	integration, err := s.integrationRepo.GetInstalledIntegration(stackId.String(), enum.IntegrationTypeMonitoring.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if integration == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Monitoring is not installed",
+			Data:    nil,
+		}, nil
+	}
+
 	stackConfig := dtos.DeployThanosRequest{}
-	if err := json.Unmarshal(stack.Config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1390,18 +2262,18 @@ func (s *ThanosStackDeploymentService) UninstallMonitoring(
 
 	logPath := utils.GetLogPath(stack.ID, "uninstall-monitoring")
 
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
@@ -1410,36 +2282,35 @@ func (s *ThanosStackDeploymentService) UninstallMonitoring(
 		}, err
 	}
 
-	taskId := fmt.Sprintf("uninstall-monitoring-%s", stackId.String())
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		integration, err := s.integrationRepo.GetInstalledIntegration(stackId.String(), enum.IntegrationTypeMonitoring.String())
-		if err != nil {
-			logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
-			return
-		}
-
-		if integration == nil {
-			logger.Error("integration not found", zap.String("plugin", enum.IntegrationTypeMonitoring.String()))
-			return
-		}
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminating)
-		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
-			return
-		}
+	// The integration is terminated at the version it was checked, a concurrent uninstall makes it fail
+	err = s.integrationRepo.UpdateIntegrationStatusAtVersion(integration.ID.String(), integration.Version, entities.DeploymentStatusTerminating, logger.RequestIDFromContext(ctx))
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
 
-		logger.Info("Uninstalling monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()))
+	taskId := fmt.Sprintf("uninstall-monitoring-%s", stackId.String())
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		logger.InfoContext(ctx, "Uninstalling monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()))
 
-		err = thanos.UninstallMonitoring(ctx, sdkClient)
+		err = sdkClient.UninstallMonitoring(ctx)
 		if err != nil {
-			logger.Error("failed to uninstall monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to uninstall monitoring", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
 			return
 		}
 
-		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated)
+		err = s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated, logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to update integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update integration", zap.String("plugin", enum.IntegrationTypeMonitoring.String()), zap.Error(err))
 			return
 		}
 		stack.Metadata.MonitoringUrl = ""
@@ -1449,7 +2320,7 @@ func (s *ThanosStackDeploymentService) UninstallMonitoring(
 			stack.Metadata,
 		)
 		if err != nil {
-			logger.Error("failed to update stack metadata", zap.String("stackId", stackId.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stackId.String()), zap.Error(err))
 			return
 		}
 	})
@@ -1463,184 +2334,281 @@ func (s *ThanosStackDeploymentService) UninstallMonitoring(
 
 // New helper method to handle deployment logic
 func (s *ThanosStackDeploymentService) handleStackDeployment(ctx context.Context, stackId uuid.UUID) {
-	logger.Info("Updating stacks status to creating", zap.String("stackId", stackId.String()))
+	logger.InfoContext(ctx, "Updating stacks status to creating", zap.String("stackId", stackId.String()))
 
-	err := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusDeploying, "")
+	err := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusDeploying, "", logger.RequestIDFromContext(ctx))
 	if err != nil {
-		logger.Error("failed to update stacks status",
+		logger.ErrorContext(ctx, "failed to update stacks status",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
 		return
 	}
 
 	err = s.deployThanosStack(ctx, stackId)
+	if err == nil {
+		err = s.completeStackDeployment(ctx, stackId)
+	}
 	if err != nil {
-		if err == context.Canceled {
+		if errors.Is(err, context.Canceled) {
 			return
 		}
-		logger.Error("failed to deploy thanos stacks",
+		logger.ErrorContext(ctx, "failed to deploy thanos stacks",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
 
-		// Update stacks status to failed
-		updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToDeploy, err.Error())
+		// Update stacks and integrations status to failed
+		reason := err.Error()
+		updateErr := s.unitOfWork.Do(ctx, func(repos Repositories) error {
+			requestID := logger.RequestIDFromContext(ctx)
+			if err := repos.Stacks.UpdateStatus(stackId.String(), entities.StackStatusFailedToDeploy, reason, requestID); err != nil {
+				return err
+			}
+			return repos.Integrations.UpdateIntegrationsStatusByStackID(stackId.String(), entities.DeploymentStatusFailed, requestID)
+		})
 		if updateErr != nil {
-			logger.Error("failed to update stacks status",
+			logger.ErrorContext(ctx, "failed to update stacks status",
 				zap.String("stackId", stackId.String()),
 				zap.Error(updateErr))
 		}
+		return
+	}
 
-		err = s.integrationRepo.UpdateIntegrationsStatusByStackID(stackId.String(), entities.DeploymentStatusFailed)
-		if err != nil {
-			logger.Error("failed to update integrations status", zap.String("stackId", stackId.String()), zap.Error(err))
-			return
-		}
+	logger.InfoContext(ctx, "Thanos stack deployed successfully",
+		zap.String("stackId", stackId.String()),
+	)
 
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil || stack == nil {
+		logger.ErrorContext(ctx, "failed to get stack by id", zap.String("stackId", stackId.String()), zap.Error(err))
+		return
+	}
+	var stackConfig dtos.DeployThanosRequest
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.Error(err))
 		return
 	}
 
+	s.installPendingIntegrations(ctx, stack, stackConfig)
+}
+
+// completeStackDeployment reads the chain information of the deployed stack and marks it deployed. The status, the
+// metadata of the stack and the info of its bridge and candidate registration are committed together, so that a
+// failure leaves the stack failed to deploy rather than deployed without its URLs.
+func (s *ThanosStackDeploymentService) completeStackDeployment(ctx context.Context, stackId uuid.UUID) error {
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack by id", zap.String("stackId", stackId.String()))
-		return
+		return fmt.Errorf("failed to get stack: %w", err)
 	}
 
-	// Update stacks status to active on success
-	updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusDeployed, "")
-	if updateErr != nil {
-		logger.Error("failed to update stacks status",
-			zap.String("stackId", stackId.String()),
-			zap.Error(updateErr))
+	if stack == nil {
+		return fmt.Errorf("stack %s not found", stackId)
 	}
 
-	config, err := json.Marshal(stack.Config)
-	if err != nil {
-		logger.Error("failed to marshal stack config", zap.Error(err))
-		return
-	}
 	var stackConfig dtos.DeployThanosRequest
-	if err := json.Unmarshal(config, &stackConfig); err != nil {
-		logger.Error("failed to unmarshal stack config", zap.Error(err))
-		return
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		return fmt.Errorf("failed to unmarshal stack config: %w", err)
 	}
 
 	logPath := utils.GetLogPath(stack.ID, "information")
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client",
-			zap.String("stackId", stackId.String()),
-			zap.Error(err))
-		return
+		return fmt.Errorf("failed to create thanos sdk client: %w", err)
 	}
+	defer sdkClient.Close()
 
 	// Get chain information
-	chainInformation, err := thanos.ShowChainInformation(ctx, sdkClient)
-	if err != nil || chainInformation == nil {
-		logger.Error("failed to show chain information", zap.Error(err))
-		return
-	}
-
-	err = s.stackRepo.UpdateMetadata(stackId.String(), &entities.StackMetadata{
-		L2Url:            chainInformation.L2RpcUrl,
-		BridgeUrl:        chainInformation.BridgeUrl,
-		BlockExplorerUrl: chainInformation.BlockExplorer,
-	})
+	chainInformation, err := sdkClient.ShowChainInformation(ctx)
 	if err != nil {
-		logger.Error("failed to update stack metadata", zap.Error(err))
-		return
+		return fmt.Errorf("failed to show chain information: %w", err)
+	}
+	if chainInformation == nil {
+		return errors.New("the chain information is empty")
 	}
 
 	bridgeUrl := chainInformation.BridgeUrl
 	if bridgeUrl == "" {
-		logger.Error("bridge url is empty", zap.String("stackId", stackId.String()))
-		return
+		return errors.New("the bridge url is empty")
 	}
 
-	// bridgeIntegration
-	bridgeIntegration, err := s.integrationRepo.GetIntegration(stackId.String(), enum.IntegrationTypeBridge.String())
+	bridgeInfo, err := json.Marshal(map[string]string{
+		"url": bridgeUrl,
+	})
 	if err != nil {
-		logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeBridge.String()), zap.Error(err))
-		return
+		return fmt.Errorf("failed to marshal bridge metadata: %w", err)
 	}
 
-	if bridgeIntegration == nil {
-		logger.Error("bridge integration not found", zap.String("plugin", enum.IntegrationTypeBridge.String()))
-		return
+	var registerCandidateInfo []byte
+	if stackConfig.RegisterCandidate {
+		info, err := sdkClient.GetRegisterCandidatesInfo(ctx, stackConfig.RegisterCandidateParams)
+		if err != nil {
+			return fmt.Errorf("failed to get register candidate info: %w", err)
+		}
+		registerCandidateInfo, err = json.Marshal(info)
+		if err != nil {
+			return fmt.Errorf("failed to marshal register candidate info: %w", err)
+		}
 	}
 
-	metadata := map[string]string{
-		"url": bridgeUrl,
-	}
+	return s.unitOfWork.Do(ctx, func(repos Repositories) error {
+		requestID := logger.RequestIDFromContext(ctx)
+		if err := repos.Stacks.UpdateStatus(stackId.String(), entities.StackStatusDeployed, "", requestID); err != nil {
+			return err
+		}
 
-	bytes, err := json.Marshal(metadata)
+		err := repos.Stacks.UpdateMetadata(stackId.String(), &entities.StackMetadata{
+			L2Url:            chainInformation.L2RpcUrl,
+			BridgeUrl:        bridgeUrl,
+			BlockExplorerUrl: chainInformation.BlockExplorer,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = completeDeployedIntegration(ctx, repos, stackId, enum.IntegrationTypeBridge, bridgeInfo)
+		if err != nil {
+			return err
+		}
+
+		if stackConfig.RegisterCandidate {
+			return completeDeployedIntegration(ctx, repos, stackId, enum.IntegrationTypeRegisterCandidate, registerCandidateInfo)
+		}
+		return nil
+	})
+}
+
+// completeDeployedIntegration marks the integration installed by the deployment of the stack completed with its info.
+// The integration created with the stack is reused unless it failed or was terminated, such as when a failed or
+// terminated stack is resumed, in which case it is terminated and replaced by a new one.
+func completeDeployedIntegration(
+	ctx context.Context,
+	repos Repositories,
+	stackId uuid.UUID,
+	integrationType enum.IntegrationType,
+	info entities.IntegrationInfo,
+) error {
+	requestID := logger.RequestIDFromContext(ctx)
+	integrations, err := repos.Integrations.GetActiveIntegrations(stackId.String(), integrationType.String())
 	if err != nil {
-		logger.Error("failed to marshal bridge metadata", zap.Error(err))
-		return
+		return fmt.Errorf("failed to get the %s integrations: %w", integrationType, err)
 	}
 
-	err = s.integrationRepo.UpdateMetadataAfterInstalled(
-		bridgeIntegration.ID.String(),
-		entities.IntegrationInfo(bytes),
-	)
+	var installed *entities.IntegrationEntity
+	for _, integration := range integrations {
+		if integration.Status == string(entities.DeploymentStatusFailed) {
+			err := repos.Integrations.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusTerminated, requestID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if installed == nil {
+			installed = integration
+		}
+	}
+	if installed != nil {
+		return repos.Integrations.UpdateMetadataAfterInstalled(installed.ID.String(), info, requestID)
+	}
+
+	stack, err := repos.Stacks.GetStackByID(stackId.String())
+	if err != nil {
+		return err
+	}
+	if stack == nil {
+		return fmt.Errorf("stack %s not found", stackId)
+	}
+	return repos.Integrations.CreateIntegration(&entities.IntegrationEntity{
+		ID:        uuid.New(),
+		StackID:   &stack.ID,
+		Type:      integrationType.String(),
+		Status:    string(entities.DeploymentStatusCompleted),
+		Info:      json.RawMessage(info),
+		RequestID: requestID,
+	}, stack.Version)
+}
 
+// installPendingIntegrations installs the block explorer and monitoring queued when the stack was created,
+// e.g. when it was cloned from a stack that had them installed
+func (s *ThanosStackDeploymentService) installPendingIntegrations(
+	ctx context.Context,
+	stack *entities.StackEntity,
+	stackConfig dtos.DeployThanosRequest,
+) {
+	integrations, err := s.integrationRepo.GetIntegrationsByStackID(stack.ID.String())
 	if err != nil {
-		logger.Error("failed to create integration", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integrations", zap.String("stackId", stack.ID.String()), zap.Error(err))
 		return
 	}
 
-	if stackConfig.RegisterCandidate {
-		registerCandidateIntegration, err := s.integrationRepo.GetIntegration(stackId.String(), enum.IntegrationTypeRegisterCandidate.String())
-		if err != nil {
-			logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
-			return
+	for _, integration := range integrations {
+		if integration.Status != string(entities.DeploymentStatusPending) {
+			continue
 		}
-
-		if registerCandidateIntegration == nil {
-			logger.Error("register candidate integration not found", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()))
-			return
+		if integration.Type != enum.IntegrationTypeBlockExplorer.String() &&
+			integration.Type != enum.IntegrationTypeMonitoring.String() {
+			continue
 		}
 
-		registerCandidateInfo, err := thanos.GetRegisterCandidatesInfo(ctx, sdkClient, stackConfig.RegisterCandidateParams)
+		sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+			LogPath:            integration.LogPath,
+			Network:            string(stack.Network),
+			DeploymentPath:     stack.DeploymentPath,
+			RegisterCandidate:  stackConfig.RegisterCandidate,
+			AwsAccessKey:       stackConfig.AwsAccessKey,
+			AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+			AwsRegion:          stackConfig.AwsRegion,
+			AwsAssumeRole:      stackConfig.AwsAssumeRole,
+		})
 		if err != nil {
-			logger.Error("failed to get register candidate info", zap.Error(err))
-			return
+			logger.ErrorContext(ctx, "failed to create thanos sdk client", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+			if err := s.integrationRepo.UpdateIntegrationStatusWithReason(integration.ID.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx)); err != nil {
+				logger.ErrorContext(ctx, "failed to update integration status", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+			}
+			continue
 		}
+		// The few clients of the integrations are closed once all of them are installed
+		defer sdkClient.Close()
 
-		bytes, err := json.Marshal(registerCandidateInfo)
-		if err != nil {
-			logger.Error("failed to marshal register candidate info", zap.Error(err))
-			return
+		if err := s.integrationRepo.UpdateIntegrationStatus(integration.ID.String(), entities.DeploymentStatusInProgress, logger.RequestIDFromContext(ctx)); err != nil {
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+			continue
 		}
 
-		err = s.integrationRepo.UpdateMetadataAfterInstalled(
-			registerCandidateIntegration.ID.String(),
-			bytes,
-		)
+		// Reload the stack, its metadata is updated by every installation
+		current, err := s.stackRepo.GetStackByID(stack.ID.String())
+		if err != nil || current == nil || current.Metadata == nil {
+			logger.ErrorContext(ctx, "failed to get stack by id", zap.String("stackId", stack.ID.String()), zap.Error(err))
+			continue
+		}
 
-		if err != nil {
-			logger.Error("failed to update register candidate integration metadata", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
-			return
+		switch integration.Type {
+		case enum.IntegrationTypeBlockExplorer.String():
+			var request dtos.InstallBlockExplorerRequest
+			if err := json.Unmarshal(integration.Config, &request); err != nil {
+				logger.ErrorContext(ctx, "failed to unmarshal integration config", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+				continue
+			}
+			s.installBlockExplorer(ctx, current, sdkClient, integration, request)
+		case enum.IntegrationTypeMonitoring.String():
+			var request dtos.InstallMonitoringRequest
+			if err := json.Unmarshal(integration.Config, &request); err != nil {
+				logger.ErrorContext(ctx, "failed to unmarshal integration config", zap.String("integrationId", integration.ID.String()), zap.Error(err))
+				continue
+			}
+			s.installMonitoring(ctx, current, sdkClient, integration, request)
 		}
 	}
-
-	logger.Info("Thanos stack deployed successfully",
-		zap.String("stackId", stackId.String()),
-	)
 }
 
 func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, stackId uuid.UUID) error {
-	statusChan := make(chan entities.DeploymentStatusWithID)
-	defer close(statusChan)
-
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
 		return fmt.Errorf("failed to get stack: %w", err)
@@ -1651,7 +2619,7 @@ func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, st
 	}
 
 	var deploymentConfig dtos.DeployThanosRequest
-	if err := json.Unmarshal(stack.Config, &deploymentConfig); err != nil {
+	if err := s.unmarshalStackConfig(stack.Config, &deploymentConfig); err != nil {
 		return fmt.Errorf("failed to unmarshal stack config: %w", err)
 	}
 
@@ -1664,26 +2632,8 @@ func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, st
 		return fmt.Errorf("no deployments found for stacks %s", stackId)
 	}
 
-	// Start a goroutine to handle status updates
-	errChan := make(chan error, 1)
-	go func() {
-		for status := range statusChan {
-			if err := s.deploymentRepo.UpdateDeploymentStatus(status.DeploymentID.String(), status.Status); err != nil {
-				errChan <- fmt.Errorf("failed to update deployment status: %w", err)
-				return
-			}
-			// If we've processed all deployments successfully, send nil to errChan
-			if status.Status == entities.DeploymentStatusCompleted {
-				select {
-				case errChan <- nil:
-				default:
-				}
-			}
-		}
-	}()
-
 	for _, deployment := range deployments {
-		logger.Info("Processing deployment",
+		logger.InfoContext(ctx, "Processing deployment",
 			zap.String("deploymentId", deployment.ID.String()),
 			zap.String("status", string(deployment.Status)),
 			zap.Int("step", deployment.Step))
@@ -1693,31 +2643,29 @@ func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, st
 			continue
 		}
 
-		sdkClient, err := thanos.NewThanosSDKClient(
-			ctx,
-			deployment.LogPath,
-			string(stack.Network),
-			stack.DeploymentPath,
-			deploymentConfig.RegisterCandidate,
-			deploymentConfig.AwsAccessKey,
-			deploymentConfig.AwsSecretAccessKey,
-			deploymentConfig.AwsRegion,
-		)
+		sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+			LogPath:            deployment.LogPath,
+			Network:            string(stack.Network),
+			DeploymentPath:     stack.DeploymentPath,
+			RegisterCandidate:  deploymentConfig.RegisterCandidate,
+			AwsAccessKey:       deploymentConfig.AwsAccessKey,
+			AwsSecretAccessKey: deploymentConfig.AwsSecretAccessKey,
+			AwsRegion:          deploymentConfig.AwsRegion,
+			AwsAssumeRole:      deploymentConfig.AwsAssumeRole,
+		})
 		if err != nil {
-			logger.Error("failed to create thanos sdk client",
+			logger.ErrorContext(ctx, "failed to create thanos sdk client",
 				zap.String("deploymentId", deployment.ID.String()),
 				zap.Error(err))
-			statusChan <- entities.DeploymentStatusWithID{
-				DeploymentID: deployment.ID,
-				Status:       entities.DeploymentStatusFailed,
-			}
+			s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusFailed)
 			return err
 		}
+		// The few clients of the steps are closed once all of them ran
+		defer sdkClient.Close()
 
 		// Update status to in-progress before starting deployment
-		statusChan <- entities.DeploymentStatusWithID{
-			DeploymentID: deployment.ID,
-			Status:       entities.DeploymentStatusInProgress,
+		if err := s.deploymentRepo.UpdateDeploymentStatus(deployment.ID.String(), entities.DeploymentStatusInProgress, logger.RequestIDFromContext(ctx)); err != nil {
+			return fmt.Errorf("failed to update deployment status: %w", err)
 		}
 
 		switch deployment.Step {
@@ -1726,31 +2674,29 @@ func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, st
 			if err := json.Unmarshal(deployment.Config, &deployL1ContractsConfig); err != nil {
 				return fmt.Errorf("failed to unmarshal deployment config: %w", err)
 			}
+			// The managed keys are only decrypted in memory for the SDK call, the stored config keeps their ids
+			if err := s.embedOperatorKeys(stack.ProjectID, l1ContractsOperatorKeyRefs(&deployL1ContractsConfig)); err != nil {
+				s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusFailed)
+				return fmt.Errorf("failed to decrypt the operator keys: %w", err)
+			}
 
-			if err := thanos.DeployL1Contracts(ctx, sdkClient, &deployL1ContractsConfig); err != nil {
+			if err := sdkClient.DeployL1Contracts(ctx, &deployL1ContractsConfig); err != nil {
 				if err == context.Canceled {
-					logger.Info("deployment cancelled",
+					logger.InfoContext(ctx, "deployment cancelled",
 						zap.String("deploymentId", deployment.ID.String()),
 						zap.Int("step", deployment.Step))
-					statusChan <- entities.DeploymentStatusWithID{
-						DeploymentID: deployment.ID,
-						Status:       entities.DeploymentStatusStopped,
-					}
+					s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusStopped)
 					return err
 				}
-				logger.Error("deployment failed",
+				logger.ErrorContext(ctx, "deployment failed",
 					zap.String("deploymentId", deployment.ID.String()),
 					zap.Int("step", deployment.Step),
 					zap.Error(err))
-				statusChan <- entities.DeploymentStatusWithID{
-					DeploymentID: deployment.ID,
-					Status:       entities.DeploymentStatusFailed,
-				}
+				s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusFailed)
 				return err
 			}
-			statusChan <- entities.DeploymentStatusWithID{
-				DeploymentID: deployment.ID,
-				Status:       entities.DeploymentStatusCompleted,
+			if err := s.deploymentRepo.UpdateDeploymentStatus(deployment.ID.String(), entities.DeploymentStatusCompleted, logger.RequestIDFromContext(ctx)); err != nil {
+				return fmt.Errorf("failed to update deployment status: %w", err)
 			}
 		case 2:
 			var deployAwsInfraConfig dtos.DeployThanosAWSInfraRequest
@@ -1758,56 +2704,77 @@ func (s *ThanosStackDeploymentService) deployThanosStack(ctx context.Context, st
 				return fmt.Errorf("failed to unmarshal deployment config: %w", err)
 			}
 
-			if err := thanos.DeployAWSInfrastructure(ctx, sdkClient, &deployAwsInfraConfig); err != nil {
+			if err := sdkClient.DeployAWSInfrastructure(ctx, &deployAwsInfraConfig); err != nil {
 				if err == context.Canceled {
-					logger.Info("deployment cancelled",
+					logger.InfoContext(ctx, "deployment cancelled",
 						zap.String("deploymentId", deployment.ID.String()),
 						zap.Int("step", deployment.Step))
-					statusChan <- entities.DeploymentStatusWithID{
-						DeploymentID: deployment.ID,
-						Status:       entities.DeploymentStatusStopped,
-					}
+					s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusStopped)
 					return err
 				}
-				logger.Error("deployment failed",
+				logger.ErrorContext(ctx, "deployment failed",
 					zap.String("deploymentId", deployment.ID.String()),
 					zap.Int("step", deployment.Step),
 					zap.Error(err))
-				statusChan <- entities.DeploymentStatusWithID{
-					DeploymentID: deployment.ID,
-					Status:       entities.DeploymentStatusFailed,
-				}
+				s.failDeployment(ctx, deployment.ID, entities.DeploymentStatusFailed)
 				return err
 			}
-			statusChan <- entities.DeploymentStatusWithID{
-				DeploymentID: deployment.ID,
-				Status:       entities.DeploymentStatusCompleted,
+
+			// Tagging is best effort, the SDK does not support tags so a failure must not fail the deployment
+			if err := s.driver.TagAWSResources(ctx, thanos.ClientConfig{
+				DeploymentPath:     stack.DeploymentPath,
+				AwsAccessKey:       deploymentConfig.AwsAccessKey,
+				AwsSecretAccessKey: deploymentConfig.AwsSecretAccessKey,
+				AwsRegion:          deploymentConfig.AwsRegion,
+				AwsAssumeRole:      deploymentConfig.AwsAssumeRole,
+			}, stack.Labels, nil); err != nil {
+				logger.WarnContext(ctx, "failed to tag AWS resources",
+					zap.String("stackId", stackId.String()),
+					zap.Error(err))
+			}
+
+			if err := s.deploymentRepo.UpdateDeploymentStatus(deployment.ID.String(), entities.DeploymentStatusCompleted, logger.RequestIDFromContext(ctx)); err != nil {
+				return fmt.Errorf("failed to update deployment status: %w", err)
 			}
 		}
 
 	}
 
-	// Wait for final status update
-	return <-errChan
+	return nil
+}
+
+// failDeployment records the end of a deployment that did not complete. The error of the deployment is the one
+// returned to the caller, so a failure to record it is only logged.
+func (s *ThanosStackDeploymentService) failDeployment(
+	ctx context.Context,
+	deploymentID uuid.UUID,
+	status entities.DeploymentStatus,
+) {
+	if err := s.deploymentRepo.UpdateDeploymentStatus(deploymentID.String(), status, logger.RequestIDFromContext(ctx)); err != nil {
+		logger.ErrorContext(ctx, "failed to update deployment status",
+			zap.String("deploymentId", deploymentID.String()),
+			zap.String("status", string(status)),
+			zap.Error(err))
+	}
 }
 
 func (s *ThanosStackDeploymentService) handleStackTermination(ctx context.Context, stack *entities.StackEntity) {
 	// Check if stacks exists
 	if stack == nil {
-		logger.Error("stack not found")
+		logger.ErrorContext(ctx, "stack not found")
 		return
 	}
 
 	stackId := stack.ID
 
 	stackConfig := dtos.DeployThanosRequest{}
-	err := json.Unmarshal(stack.Config, &stackConfig)
+	err := s.unmarshalStackConfig(stack.Config, &stackConfig)
 	if err != nil {
-		logger.Error("failed to unmarshal stacks config",
+		logger.ErrorContext(ctx, "failed to unmarshal stacks config",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
-		if updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToTerminate, err.Error()); updateErr != nil {
-			logger.Error("failed to update stacks status after unmarshal error",
+		if updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToTerminate, err.Error(), logger.RequestIDFromContext(ctx)); updateErr != nil {
+			logger.ErrorContext(ctx, "failed to update stacks status after unmarshal error",
 				zap.String("stackId", stackId.String()),
 				zap.Error(updateErr))
 		}
@@ -1816,83 +2783,100 @@ func (s *ThanosStackDeploymentService) handleStackTermination(ctx context.Contex
 
 	logPath := utils.GetLogPath(stack.ID, "destroy")
 
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		logPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
-	if err != nil {
-		logger.Error("failed to create thanos sdk client",
-			zap.Error(err))
-		return
-	}
-
-	err = s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusTerminating, "")
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            logPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to update stacks status after destroy error",
-			zap.String("stackId", stackId.String()),
+		logger.ErrorContext(ctx, "failed to create thanos sdk client",
 			zap.Error(err))
+		if updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToTerminate, err.Error(), logger.RequestIDFromContext(ctx)); updateErr != nil {
+			logger.ErrorContext(ctx, "failed to update stacks status after client error",
+				zap.String("stackId", stackId.String()),
+				zap.Error(updateErr))
+		}
 		return
 	}
+	defer sdkClient.Close()
 
-	err = thanos.DestroyAWSInfrastructure(ctx, sdkClient)
+	err = sdkClient.DestroyAWSInfrastructure(ctx)
 	if err != nil {
-		logger.Error("failed to destroy AWS infrastructure",
+		logger.ErrorContext(ctx, "failed to destroy AWS infrastructure",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
 
-		updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToTerminate, err.Error())
+		updateErr := s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusFailedToTerminate, err.Error(), logger.RequestIDFromContext(ctx))
 		if updateErr != nil {
-			logger.Error("failed to update stacks status after destroy error",
+			logger.ErrorContext(ctx, "failed to update stacks status after destroy error",
 				zap.String("stackId", stackId.String()),
 				zap.Error(updateErr))
 		}
 		return
 	}
 
-	err = s.stackRepo.UpdateStatus(stackId.String(), entities.StackStatusTerminated, "")
+	// The stack, its deployments and its integrations are terminated together
+	err = s.unitOfWork.Do(ctx, func(repos Repositories) error {
+		requestID := logger.RequestIDFromContext(ctx)
+		if err := repos.Stacks.UpdateStatus(stackId.String(), entities.StackStatusTerminated, "", requestID); err != nil {
+			return fmt.Errorf("failed to update stacks status to terminated: %w", err)
+		}
+		err := repos.Deployments.UpdateStatusesByStackId(stackId.String(), entities.DeploymentStatusTerminated, requestID)
+		if err != nil {
+			return fmt.Errorf("failed to update deployments status to terminated: %w", err)
+		}
+		err = repos.Integrations.UpdateIntegrationsStatusByStackID(stackId.String(), entities.DeploymentStatusTerminated, requestID)
+		if err != nil {
+			return fmt.Errorf("failed to update integrations status to terminated: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		logger.Error("failed to update stacks status to terminated",
+		logger.ErrorContext(ctx, "failed to terminate the stack",
 			zap.String("stackId", stackId.String()),
 			zap.Error(err))
 		return
 	}
 
-	err = s.deploymentRepo.UpdateStatusesByStackId(
-		stackId.String(),
-		entities.DeploymentStatusTerminated,
+	logger.InfoContext(ctx,
+		"AWS infrastructure destroyed successfully",
+		zap.String("stackId", stackId.String()),
 	)
-	if err != nil {
-		logger.Error("failed to update deployments status to terminated",
-			zap.String("stackId", stackId.String()),
-			zap.Error(err))
-		return
+}
+
+// unmarshalStackConfig reads the config of a stack. When the stack references a credential profile, its current keys
+// and region are filled in, so a rotated credential is used from the next operation.
+func (s *ThanosStackDeploymentService) unmarshalStackConfig(
+	config json.RawMessage,
+	stackConfig *dtos.DeployThanosRequest,
+) error {
+	if err := json.Unmarshal(config, stackConfig); err != nil {
+		return err
+	}
+	if stackConfig.CredentialID == "" {
+		return nil
 	}
 
-	// Update integrations status to terminated
-	err = s.integrationRepo.UpdateIntegrationsStatusByStackID(
-		stackId.String(),
-		entities.DeploymentStatusTerminated,
-	)
+	credential, err := s.credentialRepo.GetCredentialByID(stackConfig.CredentialID)
 	if err != nil {
-		logger.Error("failed to update integrations status to terminated",
-			zap.String("stackId", stackId.String()),
-			zap.Error(err))
-		return
+		return fmt.Errorf("failed to get credential: %w", err)
 	}
-
-	logger.Info(
-		"AWS infrastructure destroyed successfully",
-		zap.String("stackId", stackId.String()),
-	)
+	if credential == nil {
+		return fmt.Errorf("credential %s not found", stackConfig.CredentialID)
+	}
+	stackConfig.AwsAccessKey = credential.AwsAccessKey
+	stackConfig.AwsSecretAccessKey = credential.AwsSecretAccessKey
+	stackConfig.AwsRegion = credential.AwsRegion
+	return nil
 }
 
 func getThanosStackDeployments(
+	ctx context.Context,
 	stackId uuid.UUID,
 	config *dtos.DeployThanosRequest,
 ) ([]*entities.DeploymentEntity, error) {
@@ -1915,6 +2899,10 @@ func getThanosStackDeployments(
 		SequencerAccount:         config.SequencerAccount,
 		BatcherAccount:           config.BatcherAccount,
 		ProposerAccount:          config.ProposerAccount,
+		AdminKeyID:               config.AdminKeyID,
+		SequencerKeyID:           config.SequencerKeyID,
+		BatcherKeyID:             config.BatcherKeyID,
+		ProposerKeyID:            config.ProposerKeyID,
 		RegisterCandidate:        config.RegisterCandidate,
 		RegisterCandidateParams:  registerCandidateParams,
 	})
@@ -1922,12 +2910,13 @@ func getThanosStackDeployments(
 		return nil, err
 	}
 	l1ContractDeployment := &entities.DeploymentEntity{
-		ID:      l1ContractDeploymentID,
-		StackID: &stackId,
-		Step:    1,
-		Status:  entities.DeploymentStatusPending,
-		LogPath: l1ContractDeploymentLogPath,
-		Config:  l1ContractDeploymentConfig,
+		ID:        l1ContractDeploymentID,
+		StackID:   &stackId,
+		Step:      1,
+		Status:    entities.DeploymentStatusPending,
+		LogPath:   l1ContractDeploymentLogPath,
+		Config:    l1ContractDeploymentConfig,
+		RequestID: logger.RequestIDFromContext(ctx),
 	}
 	deployments = append(deployments, l1ContractDeployment)
 
@@ -1944,12 +2933,13 @@ func getThanosStackDeployments(
 		return nil, err
 	}
 	thanosInfrastructureDeployment := &entities.DeploymentEntity{
-		ID:      thanosInfrastructureDeploymentID,
-		StackID: &stackId,
-		Step:    2,
-		Status:  entities.DeploymentStatusPending,
-		LogPath: thanosInfrastructureDeploymentLogPath,
-		Config:  thanosInfrastructureDeploymentConfig,
+		ID:        thanosInfrastructureDeploymentID,
+		StackID:   &stackId,
+		Step:      2,
+		Status:    entities.DeploymentStatusPending,
+		LogPath:   thanosInfrastructureDeploymentLogPath,
+		Config:    thanosInfrastructureDeploymentConfig,
+		RequestID: logger.RequestIDFromContext(ctx),
 	}
 	deployments = append(deployments, thanosInfrastructureDeployment)
 
@@ -1959,7 +2949,7 @@ func getThanosStackDeployments(
 func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, stackId uuid.UUID, req dtos.RegisterCandidateRequest) (*entities.Response, error) {
 	stack, err := s.stackRepo.GetStackByID(stackId.String())
 	if err != nil {
-		logger.Error("failed to get stack by id", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get stack by id", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1986,7 +2976,7 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 	// check if register candidate is already in non-terminated state
 	integrations, err := s.integrationRepo.GetActiveIntegrations(stackId.String(), enum.IntegrationTypeRegisterCandidate.String())
 	if err != nil {
-		logger.Error("failed to get integration", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1995,7 +2985,7 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 	}
 
 	if len(integrations) > 0 {
-		logger.Error("There is already an active register candidate", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()))
+		logger.ErrorContext(ctx, "There is already an active register candidate", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()))
 		return &entities.Response{
 			Status:  http.StatusBadRequest,
 			Message: "There is already an active register candidate",
@@ -2004,9 +2994,9 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 	}
 
 	stackConfig := dtos.DeployThanosRequest{}
-	err = json.Unmarshal(stack.Config, &stackConfig)
+	err = s.unmarshalStackConfig(stack.Config, &stackConfig)
 	if err != nil {
-		logger.Error("failed to unmarshal stack config", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -2014,19 +3004,35 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 		}, err
 	}
 
+	// The SDK signs the registration with the admin key of the deployment settings, which must be the managed one
+	if stackConfig.AdminKeyID != "" {
+		adminKey, err := s.decryptOperatorKey(stack.ProjectID, stackConfig.AdminKeyID)
+		if err == nil {
+			err = s.driver.UseAdminKey(stack.DeploymentPath, adminKey)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to use the managed admin key", zap.String("keyId", stackConfig.AdminKeyID), zap.Error(err))
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+	}
+
 	registerCandidateLogPath := utils.GetLogPath(stackId, "register-candidate")
-	sdkClient, err := thanos.NewThanosSDKClient(
-		ctx,
-		registerCandidateLogPath,
-		string(stack.Network),
-		stack.DeploymentPath,
-		stackConfig.RegisterCandidate,
-		stackConfig.AwsAccessKey,
-		stackConfig.AwsSecretAccessKey,
-		stackConfig.AwsRegion,
-	)
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            registerCandidateLogPath,
+		Network:            string(stack.Network),
+		DeploymentPath:     stack.DeploymentPath,
+		RegisterCandidate:  stackConfig.RegisterCandidate,
+		AwsAccessKey:       stackConfig.AwsAccessKey,
+		AwsSecretAccessKey: stackConfig.AwsSecretAccessKey,
+		AwsRegion:          stackConfig.AwsRegion,
+		AwsAssumeRole:      stackConfig.AwsAssumeRole,
+	})
 	if err != nil {
-		logger.Error("failed to create thanos sdk client", zap.Error(err))
+		logger.ErrorContext(ctx, "failed to create thanos sdk client", zap.Error(err))
 		return &entities.Response{
 			Status:  http.StatusInternalServerError,
 			Message: "Internal server error",
@@ -2034,66 +3040,79 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 		}, err
 	}
 
-	taskId := fmt.Sprintf("register-candidate-%s", stackId.String())
+	// The task closes the client, unless the request fails before queueing it
+	queued := false
+	defer closeUnlessQueued(sdkClient, &queued)
 
-	s.taskManager.AddTask(taskId, func(ctx context.Context) {
-		integrationConfig, err := json.Marshal(req)
-		if err != nil {
-			logger.Error("failed to marshal integration config", zap.Error(err))
-			return
-		}
+	integrationConfig, err := json.Marshal(req)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to marshal integration config", zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
 
-		integrationId := uuid.New()
-		integration := &entities.IntegrationEntity{
-			ID:      integrationId,
-			StackID: &stackId,
-			Type:    enum.IntegrationTypeRegisterCandidate.String(),
-			Status:  string(entities.DeploymentStatusPending),
-			Config:  integrationConfig,
-			LogPath: registerCandidateLogPath,
-		}
-		err = s.integrationRepo.CreateIntegration(integration)
-		if err != nil {
-			logger.Error("failed to create integration", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
-			return
-		}
-		err = thanos.VerifyRegisterCandidates(ctx, sdkClient, &req)
+	integrationId := uuid.New()
+	integration := &entities.IntegrationEntity{
+		ID:        integrationId,
+		StackID:   &stackId,
+		Type:      enum.IntegrationTypeRegisterCandidate.String(),
+		Status:    string(entities.DeploymentStatusPending),
+		Config:    integrationConfig,
+		LogPath:   registerCandidateLogPath,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	// The integration is created at the version the stack was checked, a concurrent registration makes it fail
+	err = s.integrationRepo.CreateIntegration(integration, stack.Version)
+	if errors.Is(err, entities.ErrVersionConflict) {
+		return versionConflictResponse(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create integration", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	taskId := fmt.Sprintf("register-candidate-%s", stackId.String())
+
+	queued = true
+	s.taskManager.AddTask(ctx, taskId, func(ctx context.Context) {
+		defer sdkClient.Close()
+		err = sdkClient.VerifyRegisterCandidates(ctx, &req)
 		if err != nil {
-			logger.Error("failed to register candidate", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("stackId", stackId.String()))
-			err = s.integrationRepo.UpdateIntegrationStatusWithReason(integrationId.String(), entities.DeploymentStatusFailed, err.Error())
+			logger.ErrorContext(ctx, "failed to register candidate", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("stackId", stackId.String()))
+			err = s.integrationRepo.UpdateIntegrationStatusWithReason(integrationId.String(), entities.DeploymentStatusFailed, err.Error(), logger.RequestIDFromContext(ctx))
 			if err != nil {
-				logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("integrationId", integrationId.String()))
+				logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("integrationId", integrationId.String()))
 			}
 			return
 		}
-		err = s.integrationRepo.UpdateIntegrationStatus(integrationId.String(), entities.DeploymentStatusCompleted)
+		err = s.integrationRepo.UpdateIntegrationStatus(integrationId.String(), entities.DeploymentStatusCompleted, logger.RequestIDFromContext(ctx))
 		if err != nil {
-			logger.Error("failed to update integration status", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("integrationId", integrationId.String()))
+			logger.ErrorContext(ctx, "failed to update integration status", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err), zap.String("integrationId", integrationId.String()))
 		}
 
-		registerCandidateInfo, err := thanos.GetRegisterCandidatesInfo(ctx, sdkClient, &req)
+		registerCandidateInfo, err := sdkClient.GetRegisterCandidatesInfo(ctx, &req)
 		if err != nil {
-			logger.Error("failed to get register candidate info", zap.Error(err))
+			logger.ErrorContext(ctx, "failed to get register candidate info", zap.Error(err))
 			return
 		}
 
 		bytes, err := json.Marshal(registerCandidateInfo)
 		if err != nil {
-			logger.Error("failed to marshal register candidate info", zap.Error(err))
+			logger.ErrorContext(ctx, "failed to marshal register candidate info", zap.Error(err))
 			return
 		}
 
 		err = s.integrationRepo.UpdateMetadataAfterInstalled(
 			integrationId.String(),
 			bytes,
+			logger.RequestIDFromContext(ctx),
 		)
 
 		if err != nil {
-			logger.Error("failed to update register candidate integration metadata", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
+			logger.ErrorContext(ctx, "failed to update register candidate integration metadata", zap.String("plugin", enum.IntegrationTypeRegisterCandidate.String()), zap.Error(err))
 			return
 		}
 
-		logger.Info("Register candidate successfully", zap.String("stackId", stackId.String()))
+		logger.InfoContext(ctx, "Register candidate successfully", zap.String("stackId", stackId.String()))
 	})
 
 	return &entities.Response{
@@ -2102,3 +3121,30 @@ func (s *ThanosStackDeploymentService) RegisterCandidate(ctx context.Context, st
 		Data:    nil,
 	}, nil
 }
+
+// invalidTransitionResponse answers a request whose status change is not allowed from the current status, the status
+// changed since it was checked
+func invalidTransitionResponse(err error) *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusConflict,
+		Message: err.Error(),
+		Data:    nil,
+	}
+}
+
+// versionConflictResponse answers a request whose stack or integration was changed by another request since it was
+// read, the request can be retried
+func versionConflictResponse() *entities.Response {
+	return &entities.Response{
+		Status:  http.StatusConflict,
+		Message: "The stack was changed by another request, please retry",
+		Data:    nil,
+	}
+}
+
+// closeUnlessQueued closes the SDK client of a request which returned before queueing the task using it
+func closeUnlessQueued(client thanos.StackClient, queued *bool) {
+	if !*queued {
+		client.Close()
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/enum"
+	"github.com/tokamak-network/trh-backend/pkg/stacks/thanos"
+	trhSdkConstants "github.com/tokamak-network/trh-sdk/pkg/constants"
+	trhSdkTypes "github.com/tokamak-network/trh-sdk/pkg/types"
+	trhSdkUtils "github.com/tokamak-network/trh-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// AdoptThanosStack registers a chain deployed with the trh-sdk CLI so that it can be managed through the API.
+// The deployment directory is used in place, the stack is created as deployed along with completed deployments and
+// the integrations found running on the cluster.
+func (s *ThanosStackDeploymentService) AdoptThanosStack(
+	ctx context.Context,
+	request dtos.AdoptThanosStackRequest,
+) (*entities.Response, error) {
+	projectId, err := uuid.Parse(request.ProjectID)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid projectId",
+			Data:    nil,
+		}, nil
+	}
+
+	// The checks run on the resolved path, an alias or a symlink would otherwise reach the directory of another stack
+	requestedPath := filepath.Clean(request.DeploymentPath)
+	deploymentPath, err := filepath.EvalSymlinks(requestedPath)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "deploymentPath is not an accessible directory",
+			Data:    nil,
+		}, nil
+	}
+	if utils.IsInStorage(deploymentPath) {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "deploymentPath must not be inside the storage of the backend",
+			Data:    nil,
+		}, nil
+	}
+	if utils.GetAdoptionRoot() != "" && !utils.IsInAdoptionRoot(deploymentPath) {
+		return &entities.Response{
+			Status:  http.StatusForbidden,
+			Message: "deploymentPath must be inside the adoption root",
+			Data:    nil,
+		}, nil
+	}
+
+	for _, path := range []string{deploymentPath, requestedPath} {
+		existing, err := s.stackRepo.GetStackByDeploymentPath(path)
+		if err != nil {
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+		if existing != nil {
+			return &entities.Response{
+				Status:  http.StatusConflict,
+				Message: "Deployment is already managed by stack " + existing.ID.String(),
+				Data:    nil,
+			}, nil
+		}
+	}
+
+	settings, err := trhSdkUtils.ReadConfigFromJSONFile(deploymentPath)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to read deployment settings", zap.String("deploymentPath", deploymentPath), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: "Failed to read " + trhSdkTypes.ConfigFileName + ": " + err.Error(),
+			Data:    nil,
+		}, nil
+	}
+	if settings == nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: trhSdkTypes.ConfigFileName + " not found in the deployment path",
+			Data:    nil,
+		}, nil
+	}
+
+	config, err := adoptedStackConfig(projectId, deploymentPath, settings, request)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	stackId := uuid.New()
+	sdkClient, err := s.driver.NewClient(ctx, thanos.ClientConfig{
+		LogPath:            utils.GetLogPath(stackId, "adopt"),
+		Network:            string(config.Network),
+		DeploymentPath:     deploymentPath,
+		RegisterCandidate:  false,
+		AwsAccessKey:       config.AwsAccessKey,
+		AwsSecretAccessKey: config.AwsSecretAccessKey,
+		AwsRegion:          config.AwsRegion,
+		AwsAssumeRole:      config.AwsAssumeRole,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create thanos sdk client", zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	defer sdkClient.Close()
+
+	chainInformation, err := sdkClient.ShowChainInformation(ctx)
+	if err != nil || chainInformation == nil || chainInformation.L2RpcUrl == "" {
+		logger.WarnContext(ctx, "failed to show chain information", zap.String("deploymentPath", deploymentPath), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "The chain of the deployment is not running",
+			Data:    nil,
+		}, nil
+	}
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	name := config.Name
+	if name == "" {
+		name = config.ChainName
+	}
+	stack := &entities.StackEntity{
+		ID:             stackId,
+		ProjectID:      &projectId,
+		Name:           name,
+		Description:    config.Description,
+		Labels:         config.Labels,
+		Network:        config.Network,
+		Config:         configBytes,
+		DeploymentPath: deploymentPath,
+		Status:         entities.StackStatusDeployed,
+	}
+
+	deployments, err := getThanosStackDeployments(ctx, stackId, config)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	for _, deployment := range deployments {
+		deployment.Status = entities.DeploymentStatusCompleted
+	}
+
+	integrations, err := getAdoptedIntegrations(ctx, stackId, chainInformation)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	err = s.stackRepo.CreateStackByTx(stack, deployments, integrations, logger.RequestIDFromContext(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create adopted stack", zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	metadata := &entities.StackMetadata{
+		L2Url:            chainInformation.L2RpcUrl,
+		BridgeUrl:        chainInformation.BridgeUrl,
+		BlockExplorerUrl: chainInformation.BlockExplorer,
+	}
+	if settings.L2ChainID != 0 {
+		metadata.L2ChainID = strconv.FormatUint(settings.L2ChainID, 10)
+	}
+	if err := s.stackRepo.UpdateMetadata(stackId.String(), metadata); err != nil {
+		logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stackId.String()), zap.Error(err))
+	}
+
+	integrationTypes := make([]string, 0, len(integrations))
+	for _, integration := range integrations {
+		integrationTypes = append(integrationTypes, integration.Type)
+	}
+	logger.InfoContext(ctx, "Stack adopted",
+		zap.String("stackId", stackId.String()),
+		zap.String("deploymentPath", deploymentPath),
+		zap.Strings("integrations", integrationTypes),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data: map[string]interface{}{
+			"stackId":      stackId.String(),
+			"integrations": integrationTypes,
+		},
+	}, nil
+}
+
+// adoptedStackConfig maps the settings written by the trh-sdk CLI to the configuration of a backend stack
+func adoptedStackConfig(
+	projectId uuid.UUID,
+	deploymentPath string,
+	settings *trhSdkTypes.Config,
+	request dtos.AdoptThanosStackRequest,
+) (*dtos.DeployThanosRequest, error) {
+	if settings.Stack != "" && settings.Stack != trhSdkConstants.ThanosStack {
+		return nil, fmt.Errorf("unsupported stack %s", settings.Stack)
+	}
+
+	var network entities.DeploymentNetwork
+	switch strings.ToLower(settings.Network) {
+	case trhSdkConstants.Mainnet:
+		network = entities.DeploymentNetworkMainnet
+	case trhSdkConstants.Testnet:
+		network = entities.DeploymentNetworkTestnet
+	default:
+		return nil, fmt.Errorf("unsupported network %s", settings.Network)
+	}
+
+	if settings.AWS == nil || settings.K8s == nil {
+		return nil, errors.New("the AWS infrastructure of the deployment is not deployed")
+	}
+
+	config := &dtos.DeployThanosRequest{
+		ProjectID:          projectId.String(),
+		Name:               request.Name,
+		Description:        request.Description,
+		Labels:             request.Labels,
+		Network:            network,
+		L1RpcUrl:           settings.L1RPCURL,
+		L1BeaconUrl:        settings.L1BeaconURL,
+		AdminAccount:       utils.TrimPrivateKey(settings.AdminPrivateKey),
+		SequencerAccount:   utils.TrimPrivateKey(settings.SequencerPrivateKey),
+		BatcherAccount:     utils.TrimPrivateKey(settings.BatcherPrivateKey),
+		ProposerAccount:    utils.TrimPrivateKey(settings.ProposerPrivateKey),
+		AwsAccessKey:       settings.AWS.AccessKey,
+		AwsSecretAccessKey: settings.AWS.SecretKey,
+		AwsRegion:          settings.AWS.Region,
+		ChainName:          settings.ChainName,
+		DeploymentPath:     deploymentPath,
+	}
+	if request.AwsAccessKey != "" {
+		config.AwsAccessKey = request.AwsAccessKey
+		config.AwsSecretAccessKey = request.AwsSecretAccessKey
+	}
+	config.AwsAssumeRole = request.AwsAssumeRole
+	if config.AwsAccessKey == "" || config.AwsSecretAccessKey == "" || config.AwsRegion == "" {
+		return nil, errors.New("the AWS credentials are missing, please provide them")
+	}
+	if chainConfig := settings.ChainConfiguration; chainConfig != nil {
+		config.L2BlockTime = int(chainConfig.L2BlockTime)
+		config.BatchSubmissionFrequency = int(chainConfig.BatchSubmissionFrequency)
+		config.OutputRootFrequency = int(chainConfig.OutputRootFrequency)
+		config.ChallengePeriod = int(chainConfig.ChallengePeriod)
+	}
+
+	return config, nil
+}
+
+// getAdoptedIntegrations returns the integrations found running on the cluster of an adopted stack. Their install
+// configuration is unknown, they can be uninstalled but not reinstalled as is.
+func getAdoptedIntegrations(
+	ctx context.Context,
+	stackId uuid.UUID,
+	chainInformation *trhSdkTypes.ChainInformation,
+) ([]*entities.IntegrationEntity, error) {
+	integrations := make([]*entities.IntegrationEntity, 0)
+	for integrationType, url := range map[enum.IntegrationType]string{
+		enum.IntegrationTypeBridge:        chainInformation.BridgeUrl,
+		enum.IntegrationTypeBlockExplorer: chainInformation.BlockExplorer,
+	} {
+		if url == "" {
+			continue
+		}
+		info, err := json.Marshal(map[string]string{"url": url})
+		if err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, &entities.IntegrationEntity{
+			ID:        uuid.New(),
+			StackID:   &stackId,
+			Type:      integrationType.String(),
+			Status:    string(entities.DeploymentStatusCompleted),
+			Info:      info,
+			RequestID: logger.RequestIDFromContext(ctx),
+		})
+	}
+	return integrations, nil
+}
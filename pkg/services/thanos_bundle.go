@@ -0,0 +1,921 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+const (
+	stackBundleManifestFile  = "manifest.json"
+	stackBundleSignatureFile = "manifest.sig"
+	stackBundleRecordsFile   = "stack.json"
+	stackBundleDeploymentDir = "deployment"
+	stackBundleLogsDir       = "logs"
+
+	// maxStackBundleSize bounds the extracted size of an imported bundle
+	maxStackBundleSize = 8 << 30
+	// maxStackBundleManifestSize and maxStackBundleSignatureSize bound the head of a bundle, read before its
+	// signature is verified
+	maxStackBundleManifestSize  = 64 << 20
+	maxStackBundleSignatureSize = 1 << 10
+)
+
+var errInvalidStackBundle = errors.New("invalid stack bundle")
+
+// ExportStack writes a signed tar.gz bundle of the stack to the archive: its database rows, its deployment directory
+// and optionally its logs. Terraform provider plugins are left out, terraform init downloads them again.
+func (s *ThanosStackDeploymentService) ExportStack(
+	ctx context.Context,
+	stackId uuid.UUID,
+	includeLogs bool,
+	archive io.Writer,
+) (*entities.Response, error) {
+	if len(s.bundleSigningKey) == 0 {
+		return &entities.Response{
+			Status:  http.StatusServiceUnavailable,
+			Message: "Stack bundles are disabled, no signing key is configured",
+			Data:    nil,
+		}, nil
+	}
+
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	deployments, err := s.deploymentRepo.GetDeploymentsByStackID(stackId.String())
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	integrations, err := s.integrationRepo.GetIntegrationsByStackID(stackId.String())
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if isStackBusy(stack, integrations) {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "Stack has an operation in progress, please wait for it to finish",
+			Data:    nil,
+		}, nil
+	}
+
+	// The credential profiles are not exported, the bundle carries the current keys of the profile in place of the
+	// reference. The managed keys keep their references and are exported as their encrypted keystores.
+	var stackConfig dtos.DeployThanosRequest
+	if err := s.unmarshalStackConfig(stack.Config, &stackConfig); err != nil {
+		logger.ErrorContext(ctx, "failed to unmarshal stack config", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	operatorKeys, err := s.bundleOperatorKeys(stack.ProjectID, &stackConfig, deployments)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get the operator keys", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	if stackConfig.CredentialID != "" {
+		stackConfig.CredentialID = ""
+		if stack.Config, err = json.Marshal(stackConfig); err != nil {
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+	}
+
+	records, err := json.Marshal(entities.StackBundleRecords{
+		Stack:        stack,
+		Deployments:  deployments,
+		Integrations: integrations,
+		OperatorKeys: operatorKeys,
+	})
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	manifest := entities.StackBundleManifest{
+		Version:        entities.StackBundleVersion,
+		StackID:        stack.ID,
+		Network:        stack.Network,
+		DeploymentPath: stack.DeploymentPath,
+		LogDir:         utils.GetLogDir(stack.ID),
+		IncludesLogs:   includeLogs,
+		ExportedAt:     time.Now().UTC(),
+	}
+
+	// The content is staged first: the manifest listing its digests comes first in the bundle, so that the importing
+	// instance checks the signature before writing anything
+	staging, err := os.CreateTemp("", "stack-bundle-*.tar")
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	defer os.Remove(staging.Name())
+	defer staging.Close()
+	writer := &stackBundleWriter{tarWriter: tar.NewWriter(staging)}
+
+	err = writer.addFile(stackBundleRecordsFile, records)
+	if err == nil {
+		err = writer.addDir(ctx, stackBundleDeploymentDir, stack.DeploymentPath)
+	}
+	if err == nil && includeLogs {
+		err = writer.addLogs(ctx, stackBundleLogsDir, s.logs, manifest.LogDir)
+	}
+	if err == nil {
+		err = writer.tarWriter.Close()
+	}
+	if err == nil {
+		_, err = staging.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		manifest.Files = writer.files
+		err = writeStackBundle(archive, s.bundleSigningKey, &manifest, staging)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to export stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	logger.InfoContext(ctx, "Stack exported",
+		zap.String("stackId", stackId.String()),
+		zap.Int("files", len(manifest.Files)),
+		zap.Bool("includeLogs", includeLogs),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+// ImportStack restores a bundle produced by ExportStack into the project. The deployment directory is moved to the
+// path of this instance and every reference to the path of the exporting instance is rewritten. The logs are written
+// to the log sink.
+func (s *ThanosStackDeploymentService) ImportStack(
+	ctx context.Context,
+	projectId uuid.UUID,
+	archive io.Reader,
+) (*entities.Response, error) {
+	if len(s.bundleSigningKey) == 0 {
+		return &entities.Response{
+			Status:  http.StatusServiceUnavailable,
+			Message: "Stack bundles are disabled, no signing key is configured",
+			Data:    nil,
+		}, nil
+	}
+
+	if err := os.MkdirAll(utils.GetImportPath(), 0755); err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	// Extract next to the deployments so that the directories can be renamed into place
+	workDir, err := os.MkdirTemp(utils.GetImportPath(), "bundle-")
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	defer os.RemoveAll(workDir)
+
+	manifest, records, err := s.readStackBundle(archive, workDir)
+	if err != nil {
+		if errors.Is(err, errInvalidStackBundle) {
+			logger.WarnContext(ctx, "rejected stack bundle", zap.Error(err))
+			return &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: err.Error(),
+				Data:    nil,
+			}, nil
+		}
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	stack := records.Stack
+	// Archived stacks keep their id, a purged stack cannot be imported again
+	exists, err := s.stackRepo.StackExists(stack.ID.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stack.ID.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	if exists {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "Stack already exists",
+			Data:    nil,
+		}, nil
+	}
+
+	deploymentPath := utils.GetDeploymentPath(s.name, stack.Network, stack.ID.String())
+	logDir := utils.GetLogDir(stack.ID)
+	if _, err := os.Stat(deploymentPath); err == nil {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: fmt.Sprintf("%s already exists", deploymentPath),
+			Data:    nil,
+		}, nil
+	}
+	existingLogs, err := s.logs.List(ctx, logDir)
+	if err != nil {
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	if len(existingLogs) > 0 {
+		return &entities.Response{
+			Status:  http.StatusConflict,
+			Message: "Logs of the stack already exist",
+			Data:    nil,
+		}, nil
+	}
+
+	keyIds, createdKeys, response, err := s.importOperatorKeys(ctx, projectId, records.OperatorKeys)
+	if response != nil {
+		return response, err
+	}
+	// The bundle configs reference the keys by their exported ids
+	keyReplacements := make([]string, 0, 2*len(keyIds))
+	for exportedId, keyId := range keyIds {
+		if exportedId != keyId {
+			keyReplacements = append(keyReplacements, exportedId, keyId)
+		}
+	}
+	keyReplacer := strings.NewReplacer(keyReplacements...)
+
+	replacer := strings.NewReplacer(manifest.DeploymentPath, deploymentPath)
+	if err := rewriteStackBundlePaths(filepath.Join(workDir, stackBundleDeploymentDir), replacer); err != nil {
+		s.deleteOperatorKeys(ctx, createdKeys)
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	stack.ProjectID = &projectId
+	stack.DeploymentPath = deploymentPath
+	stack.Config = json.RawMessage(keyReplacer.Replace(replacer.Replace(string(stack.Config))))
+	for _, deployment := range records.Deployments {
+		deployment.StackID = &stack.ID
+		deployment.LogPath = importedLogName(logDir, deployment.LogPath)
+		deployment.Config = json.RawMessage(keyReplacer.Replace(replacer.Replace(string(deployment.Config))))
+	}
+	for _, integration := range records.Integrations {
+		integration.StackID = &stack.ID
+		integration.LogPath = importedLogName(logDir, integration.LogPath)
+		integration.Config = json.RawMessage(replacer.Replace(string(integration.Config)))
+	}
+
+	moved := make([]string, 0, 1)
+	source := filepath.Join(workDir, stackBundleDeploymentDir)
+	if _, err := os.Stat(source); err == nil {
+		if err = os.MkdirAll(filepath.Dir(deploymentPath), 0755); err == nil {
+			err = os.Rename(source, deploymentPath)
+		}
+		if err != nil {
+			s.deleteOperatorKeys(ctx, createdKeys)
+			return &entities.Response{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			}, err
+		}
+		moved = append(moved, deploymentPath)
+	}
+
+	importedLogs, err := s.importLogs(ctx, filepath.Join(workDir, stackBundleLogsDir), logDir)
+	if err != nil {
+		removeAll(moved)
+		s.deleteOperatorKeys(ctx, createdKeys)
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	err = s.stackRepo.CreateStackByTx(stack, records.Deployments, records.Integrations, logger.RequestIDFromContext(ctx))
+	if err != nil {
+		removeAll(moved)
+		s.deleteLogs(ctx, importedLogs)
+		s.deleteOperatorKeys(ctx, createdKeys)
+		logger.ErrorContext(ctx, "failed to import stack", zap.String("stackId", stack.ID.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stack.Metadata != nil {
+		if err := s.stackRepo.UpdateMetadata(stack.ID.String(), stack.Metadata); err != nil {
+			logger.ErrorContext(ctx, "failed to update stack metadata", zap.String("stackId", stack.ID.String()), zap.Error(err))
+		}
+	}
+
+	logger.InfoContext(ctx, "Stack imported",
+		zap.String("stackId", stack.ID.String()),
+		zap.String("projectId", projectId.String()),
+		zap.Time("exportedAt", manifest.ExportedAt),
+	)
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]string{"stackId": stack.ID.String()},
+	}, nil
+}
+
+// importLogs writes the logs extracted into the directory to the log sink, under the log directory of the stack. The
+// logs written are deleted when one fails.
+func (s *ThanosStackDeploymentService) importLogs(ctx context.Context, dir string, logDir string) ([]string, error) {
+	var imported []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(logDir, filepath.ToSlash(rel))
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		log, err := s.logs.Append(ctx, name)
+		if err != nil {
+			return err
+		}
+		imported = append(imported, name)
+		_, err = io.Copy(log, file)
+		if closeErr := log.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		s.deleteLogs(ctx, imported)
+		return nil, err
+	}
+	return imported, nil
+}
+
+func (s *ThanosStackDeploymentService) deleteLogs(ctx context.Context, names []string) {
+	for _, name := range names {
+		if err := s.logs.Delete(ctx, name); err != nil {
+			logger.WarnContext(ctx, "failed to delete log", zap.String("log", name), zap.Error(err))
+		}
+	}
+}
+
+// importedLogName names the log of an imported stack in its log directory. The logs were formerly named after their
+// absolute path on the exporting instance.
+func importedLogName(logDir string, logPath string) string {
+	if logPath == "" {
+		return ""
+	}
+	return path.Join(logDir, path.Base(filepath.ToSlash(logPath)))
+}
+
+// readStackBundle checks the signed manifest at the head of the bundle, then extracts the files it lists into the
+// directory. Nothing is written before the signature is verified.
+func (s *ThanosStackDeploymentService) readStackBundle(
+	archive io.Reader,
+	dir string,
+) (*entities.StackBundleManifest, *entities.StackBundleRecords, error) {
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	manifestBytes, err := readStackBundleHead(tarReader, stackBundleManifestFile, maxStackBundleManifestSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := readStackBundleHead(tarReader, stackBundleSignatureFile, maxStackBundleSignatureSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !utils.VerifyStackBundleManifest(s.bundleSigningKey, manifestBytes, strings.TrimSpace(string(signature))) {
+		return nil, nil, fmt.Errorf("%w: signature mismatch", errInvalidStackBundle)
+	}
+
+	var manifest entities.StackBundleManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+	}
+	if manifest.Version != entities.StackBundleVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", errInvalidStackBundle, manifest.Version)
+	}
+
+	if err := extractStackBundle(tarReader, dir, manifest.Files); err != nil {
+		return nil, nil, err
+	}
+
+	recordsBytes, err := os.ReadFile(filepath.Join(dir, stackBundleRecordsFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: missing %s", errInvalidStackBundle, stackBundleRecordsFile)
+	}
+	var records entities.StackBundleRecords
+	if err := json.Unmarshal(recordsBytes, &records); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+	}
+	if records.Stack == nil || records.Stack.ID != manifest.StackID {
+		return nil, nil, fmt.Errorf("%w: stack does not match the manifest", errInvalidStackBundle)
+	}
+
+	return &manifest, &records, nil
+}
+
+// isStackBusy reports whether a task may be changing the stack or its integrations
+func isStackBusy(stack *entities.StackEntity, integrations []*entities.IntegrationEntity) bool {
+	switch stack.Status {
+	case entities.StackStatusDeploying, entities.StackStatusUpdating, entities.StackStatusTerminating:
+		return true
+	}
+	for _, integration := range integrations {
+		if integration.Status == string(entities.DeploymentStatusInProgress) {
+			return true
+		}
+	}
+	return false
+}
+
+type stackBundleWriter struct {
+	tarWriter *tar.Writer
+	files     []entities.StackBundleFile
+}
+
+func (w *stackBundleWriter) addFile(name string, content []byte) error {
+	if err := w.tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := w.tarWriter.Write(content); err != nil {
+		return err
+	}
+	digest := sha256.Sum256(content)
+	w.files = append(w.files, entities.StackBundleFile{
+		Path:   name,
+		Mode:   0644,
+		Size:   int64(len(content)),
+		SHA256: hex.EncodeToString(digest[:]),
+	})
+	return nil
+}
+
+// writeStackBundle writes the gzipped bundle: the manifest, its signature, then the entries of the staged tar
+func writeStackBundle(
+	archive io.Writer,
+	key []byte,
+	manifest *entities.StackBundleManifest,
+	staged io.Reader,
+) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	signature := []byte(utils.SignStackBundleManifest(key, manifestBytes))
+
+	gzipWriter := gzip.NewWriter(archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{stackBundleManifestFile, manifestBytes},
+		{stackBundleSignatureFile, signature},
+	} {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.name,
+			Mode:     0644,
+			Size:     int64(len(file.content)),
+			ModTime:  manifest.ExportedAt,
+		}); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(file.content); err != nil {
+			return err
+		}
+	}
+
+	tarReader := tar.NewReader(staged)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// addLogs adds the logs of the directory of the log sink under the prefix
+func (w *stackBundleWriter) addLogs(ctx context.Context, prefix string, logs LogSink, dir string) error {
+	names, err := logs.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		log, err := logs.Open(ctx, name, 0)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted by the retention since listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(log)
+		log.Close()
+		if err != nil {
+			return err
+		}
+		if err := w.addFile(path.Join(prefix, strings.TrimPrefix(name, dir+"/")), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDir adds the directory tree under the prefix, a missing directory is skipped
+func (w *stackBundleWriter) addDir(ctx context.Context, prefix string, root string) error {
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "providers" && filepath.Base(filepath.Dir(p)) == ".terraform" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return w.tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			// Links into the tree are made relative so that they survive the move to another path
+			if filepath.IsAbs(target) {
+				relTarget, err := filepath.Rel(filepath.Dir(p), target)
+				if err != nil || !strings.HasPrefix(target, root+string(filepath.Separator)) {
+					logger.WarnContext(ctx, "skipping symlink leaving the exported directory", zap.String("path", p), zap.String("target", target))
+					return nil
+				}
+				target = relTarget
+			}
+			if err := w.tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     name,
+				Linkname: filepath.ToSlash(target),
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			}); err != nil {
+				return err
+			}
+			w.files = append(w.files, entities.StackBundleFile{
+				Path:       name,
+				Mode:       int64(info.Mode().Perm()),
+				LinkTarget: filepath.ToSlash(target),
+			})
+			return nil
+		case info.Mode().IsRegular():
+			file, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if err := w.tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     name,
+				Mode:     int64(info.Mode().Perm()),
+				Size:     info.Size(),
+				ModTime:  info.ModTime(),
+			}); err != nil {
+				return err
+			}
+			hash := sha256.New()
+			if _, err := io.Copy(io.MultiWriter(w.tarWriter, hash), io.LimitReader(file, info.Size())); err != nil {
+				return err
+			}
+			w.files = append(w.files, entities.StackBundleFile{
+				Path:   name,
+				Mode:   int64(info.Mode().Perm()),
+				Size:   info.Size(),
+				SHA256: hex.EncodeToString(hash.Sum(nil)),
+			})
+			return nil
+		default:
+			// Sockets, devices and pipes have no place in a deployment directory
+			return nil
+		}
+	})
+}
+
+// readStackBundleHead reads the next entry of the bundle, which must be the named file
+func readStackBundleHead(tarReader *tar.Reader, name string, maxSize int64) ([]byte, error) {
+	header, err := tarReader.Next()
+	if err == io.EOF || (err == nil && (header.Name != name || header.Typeflag != tar.TypeReg)) {
+		return nil, fmt.Errorf("%w: the bundle does not start with %s", errInvalidStackBundle, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+	}
+	if header.Size > maxSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", errInvalidStackBundle, name, maxSize)
+	}
+	content, err := io.ReadAll(io.LimitReader(tarReader, header.Size))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+	}
+	return content, nil
+}
+
+// extractStackBundle extracts the remaining entries of the bundle into the directory. Every file must be listed in
+// the verified manifest, with the size and the digest it lists, and every file listed must be present. Entries
+// escaping the directory or written through a symlink are rejected.
+func extractStackBundle(tarReader *tar.Reader, dir string, manifestFiles []entities.StackBundleFile) error {
+	expected := make(map[string]entities.StackBundleFile, len(manifestFiles))
+	var size int64
+	for _, file := range manifestFiles {
+		expected[file.Path] = file
+		size += file.Size
+	}
+	if size > maxStackBundleSize {
+		return fmt.Errorf("%w: content exceeds %d bytes", errInvalidStackBundle, int64(maxStackBundleSize))
+	}
+
+	extracted := make(map[string]bool, len(expected))
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+		}
+
+		name := path.Clean(header.Name)
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%w: illegal path %s", errInvalidStackBundle, header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := checkNoSymlinkParent(dir, target); err != nil {
+			return err
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		if header.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file, listed := expected[name]
+		if !listed || extracted[name] {
+			return fmt.Errorf("%w: %s is not listed in the manifest", errInvalidStackBundle, name)
+		}
+		extracted[name] = true
+
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			resolved := path.Join(path.Dir(name), header.Linkname)
+			topDir := strings.SplitN(name, "/", 2)[0]
+			if path.IsAbs(header.Linkname) || !strings.HasPrefix(resolved, topDir+"/") {
+				return fmt.Errorf("%w: illegal link %s -> %s", errInvalidStackBundle, header.Name, header.Linkname)
+			}
+			if file.LinkTarget != header.Linkname {
+				return fmt.Errorf("%w: %s does not match the manifest", errInvalidStackBundle, name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+			}
+		case tar.TypeReg:
+			if file.LinkTarget != "" || file.Size != header.Size {
+				return fmt.Errorf("%w: %s does not match the manifest", errInvalidStackBundle, name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			output, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode|0600)
+			if err != nil {
+				return fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+			}
+			hash := sha256.New()
+			_, err = io.Copy(io.MultiWriter(output, hash), io.LimitReader(tarReader, header.Size))
+			closeErr := output.Close()
+			if err != nil {
+				return fmt.Errorf("%w: %s", errInvalidStackBundle, err)
+			}
+			if closeErr != nil {
+				return closeErr
+			}
+			if hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+				return fmt.Errorf("%w: %s does not match the manifest", errInvalidStackBundle, name)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported entry %s", errInvalidStackBundle, header.Name)
+		}
+	}
+
+	if len(extracted) != len(expected) {
+		return fmt.Errorf("%w: content does not match the manifest", errInvalidStackBundle)
+	}
+	return nil
+}
+
+// checkNoSymlinkParent rejects paths whose parent directories inside the root are symlinks
+func checkNoSymlinkParent(root string, target string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s is written through a symlink", errInvalidStackBundle, target)
+		}
+	}
+	return nil
+}
+
+// rewriteStackBundlePaths replaces the paths of the exporting instance in the text files of the directory.
+// Files containing a NUL byte are treated as binary and left untouched.
+func rewriteStackBundlePaths(dir string, replacer *strings.Replacer) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			return nil
+		}
+		rewritten := replacer.Replace(string(content))
+		if rewritten == string(content) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(p, []byte(rewritten), info.Mode().Perm())
+	})
+}
+
+func removeAll(paths []string) {
+	for _, p := range paths {
+		_ = os.RemoveAll(p)
+	}
+}
@@ -0,0 +1,374 @@
+package services_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/keystore"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/memory"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+)
+
+const bundleSigningKey = "bundle-signing-key"
+
+// operatorKeyRepository keeps the managed keys in memory
+type operatorKeyRepository struct {
+	services.OperatorKeyRepository
+	keys map[uuid.UUID]*entities.OperatorKeyEntity
+}
+
+func (r *operatorKeyRepository) CreateKey(key *entities.OperatorKeyEntity) error {
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *operatorKeyRepository) GetKeyByID(id string) (*entities.OperatorKeyEntity, error) {
+	return r.keys[uuid.MustParse(id)], nil
+}
+
+func (r *operatorKeyRepository) GetKeyByName(projectID string, name string) (*entities.OperatorKeyEntity, error) {
+	for _, key := range r.keys {
+		if key.ProjectID.String() == projectID && key.Name == name {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *operatorKeyRepository) DeleteKey(id string) error {
+	delete(r.keys, uuid.MustParse(id))
+	return nil
+}
+
+// bundleInstance is an instance exchanging stack bundles, with its own database
+type bundleInstance struct {
+	*fixture
+	keys    *operatorKeyRepository
+	service *services.ThanosStackDeploymentService
+}
+
+func newBundleInstance(t *testing.T, passphrase string) *bundleInstance {
+	t.Helper()
+	f := newFixture(t)
+	keys := &operatorKeyRepository{keys: make(map[uuid.UUID]*entities.OperatorKeyEntity)}
+	return &bundleInstance{
+		fixture: f,
+		keys:    keys,
+		service: services.NewThanosService(
+			f.deployments,
+			f.stacks,
+			f.integrations,
+			nil,
+			keys,
+			memory.NewUnitOfWork(f.store),
+			f.driver,
+			keystore.New(passphrase),
+			f.tasks,
+			f.logs,
+			[]byte(bundleSigningKey),
+		),
+	}
+}
+
+// useStorage stores the deployments and the imported bundles in a temporary directory for the test
+func useStorage(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get the working directory: %v", err)
+	}
+	utils.SetStorageRoot(t.TempDir())
+	t.Cleanup(func() {
+		utils.SetStorageRoot(filepath.Join(wd, "storage"))
+	})
+}
+
+// createManagedKeyStack stores a deployed stack whose admin account is a managed key, and returns the private key
+func (b *bundleInstance) createManagedKeyStack(t *testing.T, passphrase string) (uuid.UUID, string) {
+	t.Helper()
+	projectID := uuid.New()
+	privateKey, err := keystore.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	keyJSON, err := keystore.New(passphrase).Encrypt(privateKey)
+	if err != nil {
+		t.Fatalf("failed to encrypt the key: %v", err)
+	}
+	key := &entities.OperatorKeyEntity{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		Name:      "admin",
+		Address:   keystore.Address(privateKey),
+		Origin:    entities.OperatorKeyOriginImported,
+		Keystore:  keyJSON,
+		CreatedAt: time.Now(),
+	}
+	if err := b.keys.CreateKey(key); err != nil {
+		t.Fatalf("failed to create the key: %v", err)
+	}
+
+	stackID := uuid.New()
+	deploymentPath := utils.GetDeploymentPath("Thanos", entities.DeploymentNetworkTestnet, stackID.String())
+	if err := os.MkdirAll(deploymentPath, 0o755); err != nil {
+		t.Fatalf("failed to create the deployment directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(deploymentPath, "settings.json"), []byte(`{"chain_name":"thanos"}`), 0o644); err != nil {
+		t.Fatalf("failed to write the settings: %v", err)
+	}
+	config, err := json.Marshal(dtos.DeployThanosRequest{
+		ProjectID:        projectID.String(),
+		Network:          entities.DeploymentNetworkTestnet,
+		AdminKeyID:       key.ID.String(),
+		SequencerAccount: "sequencer",
+		BatcherAccount:   "batcher",
+		ProposerAccount:  "proposer",
+		ChainName:        "thanos",
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal the config: %v", err)
+	}
+	deploymentConfig, err := json.Marshal(dtos.DeployL1ContractsRequest{AdminKeyID: key.ID.String()})
+	if err != nil {
+		t.Fatalf("failed to marshal the deployment config: %v", err)
+	}
+	stack := &entities.StackEntity{
+		ID:             stackID,
+		ProjectID:      &projectID,
+		Name:           "thanos",
+		Network:        entities.DeploymentNetworkTestnet,
+		Config:         config,
+		DeploymentPath: deploymentPath,
+		Status:         entities.StackStatusDeployed,
+	}
+	deployments := []*entities.DeploymentEntity{{
+		ID:      uuid.New(),
+		StackID: &stackID,
+		Step:    1,
+		Status:  entities.DeploymentStatusCompleted,
+		Config:  deploymentConfig,
+	}}
+	if err := b.stacks.CreateStackByTx(stack, deployments, nil, ""); err != nil {
+		t.Fatalf("failed to create the stack: %v", err)
+	}
+	hexKey, err := keystore.New(passphrase).Decrypt(keyJSON)
+	if err != nil {
+		t.Fatalf("failed to decrypt the key: %v", err)
+	}
+	return stackID, hexKey
+}
+
+func (b *bundleInstance) export(t *testing.T, stackID uuid.UUID) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	response, err := b.service.ExportStack(context.Background(), stackID, false, &archive)
+	assertResponse(t, response, err, http.StatusOK)
+	return archive.Bytes()
+}
+
+// readBundle returns the content of the files of the bundle by their names
+func readBundle(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("invalid bundle: %v", err)
+	}
+	files := make(map[string][]byte)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("invalid bundle: %v", err)
+		}
+		if files[header.Name], err = io.ReadAll(tarReader); err != nil {
+			t.Fatalf("invalid bundle: %v", err)
+		}
+	}
+}
+
+func TestExportImportManagedKeys(t *testing.T) {
+	useStorage(t)
+	source := newBundleInstance(t, "passphrase")
+	stackID, privateKey := source.createManagedKeyStack(t, "passphrase")
+
+	archive := source.export(t, stackID)
+	for name, content := range readBundle(t, archive) {
+		if bytes.Contains(content, []byte(privateKey)) {
+			t.Fatalf("%s contains the private key", name)
+		}
+	}
+
+	// The instances share the storage root, the deployment directory is moved there on import
+	if err := os.RemoveAll(source.stack(t, stackID).DeploymentPath); err != nil {
+		t.Fatalf("failed to remove the deployment directory: %v", err)
+	}
+
+	// Another instance with another passphrase cannot open the keys
+	other := newBundleInstance(t, "another passphrase")
+	response, err := other.service.ImportStack(context.Background(), uuid.New(), bytes.NewReader(archive))
+	assertResponse(t, response, err, http.StatusBadRequest)
+	if len(other.keys.keys) != 0 {
+		t.Errorf("keys = %v, want none left", other.keys.keys)
+	}
+
+	// An instance sharing the passphrase and the keys imports the key into the target project under a new id
+	target := newBundleInstance(t, "passphrase")
+	target.keys.keys = source.keys.keys
+	projectID := uuid.New()
+	response, err = target.service.ImportStack(context.Background(), projectID, bytes.NewReader(archive))
+	assertResponse(t, response, err, http.StatusOK)
+
+	var config dtos.DeployThanosRequest
+	if err := json.Unmarshal(target.stack(t, stackID).Config, &config); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	key, _ := target.keys.GetKeyByID(config.AdminKeyID)
+	if key == nil || key.ProjectID != projectID || config.AdminAccount != "" {
+		t.Fatalf("admin key = %+v, account %q, want a key of the target project", key, config.AdminAccount)
+	}
+	deployments, err := target.deployments.GetDeploymentsByStackID(stackID.String())
+	if err != nil || len(deployments) != 1 {
+		t.Fatalf("deployments = %v (%v)", deployments, err)
+	}
+	var deploymentConfig dtos.DeployL1ContractsRequest
+	if err := json.Unmarshal(deployments[0].Config, &deploymentConfig); err != nil || deploymentConfig.AdminKeyID != key.ID.String() {
+		t.Errorf("deployment admin key = %q (%v), want %s", deploymentConfig.AdminKeyID, err, key.ID)
+	}
+}
+
+type bundleEntry struct {
+	header  tar.Header
+	content []byte
+}
+
+// writeBundle returns a gzipped tar of the entries, as a bundle crafted by hand
+func writeBundle(t *testing.T, entries ...bundleEntry) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := entry.header
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.content))
+		}
+		if header.Mode == 0 {
+			header.Mode = 0o644
+		}
+		if err := tarWriter.WriteHeader(&header); err != nil {
+			t.Fatalf("failed to write %s: %v", header.Name, err)
+		}
+		if _, err := tarWriter.Write(entry.content); err != nil {
+			t.Fatalf("failed to write %s: %v", header.Name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("failed to close the bundle: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("failed to close the bundle: %v", err)
+	}
+	return archive.Bytes()
+}
+
+func regularEntry(name string, content []byte) bundleEntry {
+	return bundleEntry{header: tar.Header{Typeflag: tar.TypeReg, Name: name}, content: content}
+}
+
+// countingReader counts the bytes read from the archive
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestImportRejectsUnsignedBundles(t *testing.T) {
+	useStorage(t)
+	b := newBundleInstance(t, "passphrase")
+	stackID, _ := b.createManagedKeyStack(t, "passphrase")
+	archive := b.export(t, stackID)
+	files := readBundle(t, archive)
+	if err := os.RemoveAll(b.stack(t, stackID).DeploymentPath); err != nil {
+		t.Fatalf("failed to remove the deployment directory: %v", err)
+	}
+	manifest := files["manifest.json"]
+	signature := []byte(utils.SignStackBundleManifest([]byte(bundleSigningKey), manifest))
+
+	large := make([]byte, 4<<20)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatalf("failed to generate the content: %v", err)
+	}
+	tests := []struct {
+		name    string
+		entries []bundleEntry
+	}{
+		{"signed with another key", []bundleEntry{
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", []byte(utils.SignStackBundleManifest([]byte("another key"), manifest))),
+			regularEntry("deployment/large", large),
+		}},
+		{"manifest after the content", []bundleEntry{
+			regularEntry("deployment/large", large),
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", signature),
+		}},
+		{"file missing from the manifest", []bundleEntry{
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", signature),
+			regularEntry("deployment/large", large),
+		}},
+		{"tampered records", []bundleEntry{
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", signature),
+			regularEntry("stack.json", bytes.Replace(files["stack.json"], []byte("thanos"), []byte("tampered"), 1)),
+		}},
+		{"path traversal", []bundleEntry{
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", signature),
+			regularEntry("../escape", []byte("escape")),
+		}},
+		{"symlink leaving the bundle", []bundleEntry{
+			regularEntry("manifest.json", manifest),
+			regularEntry("manifest.sig", signature),
+			{header: tar.Header{Typeflag: tar.TypeSymlink, Name: "deployment/link", Linkname: "../../etc"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := &countingReader{reader: bytes.NewReader(writeBundle(t, tt.entries...))}
+			response, err := b.service.ImportStack(context.Background(), uuid.New(), archive)
+			assertResponse(t, response, err, http.StatusBadRequest)
+			// The bundle is rejected before the large file is read
+			if archive.read > 1<<20 {
+				t.Errorf("read %d bytes of the bundle before rejecting it", archive.read)
+			}
+		})
+	}
+
+	imports, err := os.ReadDir(utils.GetImportPath())
+	if err != nil || len(imports) != 0 {
+		t.Errorf("imports = %v (%v), want none left", imports, err)
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/keystore"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+// operatorKeyRef is a system account of a stack config, given either as a private key or as a managed key id
+type operatorKeyRef struct {
+	account *string
+	keyID   *string
+}
+
+func stackOperatorKeyRefs(config *dtos.DeployThanosRequest) []operatorKeyRef {
+	return []operatorKeyRef{
+		{&config.AdminAccount, &config.AdminKeyID},
+		{&config.SequencerAccount, &config.SequencerKeyID},
+		{&config.BatcherAccount, &config.BatcherKeyID},
+		{&config.ProposerAccount, &config.ProposerKeyID},
+	}
+}
+
+func l1ContractsOperatorKeyRefs(config *dtos.DeployL1ContractsRequest) []operatorKeyRef {
+	return []operatorKeyRef{
+		{&config.AdminAccount, &config.AdminKeyID},
+		{&config.SequencerAccount, &config.SequencerKeyID},
+		{&config.BatcherAccount, &config.BatcherKeyID},
+		{&config.ProposerAccount, &config.ProposerKeyID},
+	}
+}
+
+func hasOperatorKeyIDs(refs []operatorKeyRef) bool {
+	for _, ref := range refs {
+		if *ref.keyID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOperatorKeys returns a non-nil response when a managed key of the request is not a key of the project
+func (s *ThanosStackDeploymentService) checkOperatorKeys(
+	ctx context.Context,
+	projectId uuid.UUID,
+	request *dtos.DeployThanosRequest,
+) (*entities.Response, error) {
+	refs := stackOperatorKeyRefs(request)
+	if !hasOperatorKeyIDs(refs) {
+		return nil, nil
+	}
+	if s.keystore == nil {
+		return keystoreDisabledResponse(), nil
+	}
+
+	for _, ref := range refs {
+		if *ref.keyID == "" {
+			continue
+		}
+		key, err := s.operatorKeyRepo.GetKeyByID(*ref.keyID)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to get operator key", zap.String("keyId", *ref.keyID), zap.Error(err))
+			return internalServerErrorResponse(), err
+		}
+		if key == nil || key.ProjectID != projectId {
+			return &entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("Key %s not found in the project", *ref.keyID),
+				Data:    nil,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// embedOperatorKeys replaces the managed key ids of the accounts with the decrypted private keys. The keys must
+// belong to the project of the stack.
+func (s *ThanosStackDeploymentService) embedOperatorKeys(projectId *uuid.UUID, refs []operatorKeyRef) error {
+	for _, ref := range refs {
+		if *ref.keyID == "" {
+			continue
+		}
+		privateKey, err := s.decryptOperatorKey(projectId, *ref.keyID)
+		if err != nil {
+			return err
+		}
+		*ref.account, *ref.keyID = privateKey, ""
+	}
+	return nil
+}
+
+func (s *ThanosStackDeploymentService) decryptOperatorKey(projectId *uuid.UUID, keyId string) (string, error) {
+	if s.keystore == nil {
+		return "", fmt.Errorf("key %s is managed but no keystore passphrase is configured", keyId)
+	}
+	key, err := s.operatorKeyRepo.GetKeyByID(keyId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get operator key: %w", err)
+	}
+	if key == nil || projectId == nil || key.ProjectID != *projectId {
+		return "", fmt.Errorf("key %s not found in the project of the stack", keyId)
+	}
+	return s.keystore.Decrypt(key.Keystore)
+}
+
+// bundleOperatorKeys returns the managed keys referenced by the stack config and its L1 contracts deployment, with
+// their keystores as stored. The keys must belong to the project of the stack.
+func (s *ThanosStackDeploymentService) bundleOperatorKeys(
+	projectId *uuid.UUID,
+	stackConfig *dtos.DeployThanosRequest,
+	deployments []*entities.DeploymentEntity,
+) ([]*entities.StackBundleOperatorKey, error) {
+	refs := stackOperatorKeyRefs(stackConfig)
+	for _, deployment := range deployments {
+		if deployment.Step != 1 {
+			continue
+		}
+		var config dtos.DeployL1ContractsRequest
+		if err := json.Unmarshal(deployment.Config, &config); err != nil {
+			return nil, err
+		}
+		refs = append(refs, l1ContractsOperatorKeyRefs(&config)...)
+	}
+
+	var keys []*entities.StackBundleOperatorKey
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		keyId := *ref.keyID
+		if keyId == "" || seen[keyId] {
+			continue
+		}
+		seen[keyId] = true
+		key, err := s.operatorKeyRepo.GetKeyByID(keyId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get operator key: %w", err)
+		}
+		if key == nil || projectId == nil || key.ProjectID != *projectId {
+			return nil, fmt.Errorf("key %s not found in the project of the stack", keyId)
+		}
+		keys = append(keys, &entities.StackBundleOperatorKey{
+			ID:       key.ID,
+			Name:     key.Name,
+			Address:  key.Address,
+			Keystore: key.Keystore,
+		})
+	}
+	return keys, nil
+}
+
+// importOperatorKeys stores the keys of an imported bundle in the project. It returns the ids of the keys in the
+// project by the ids in the bundle, and the ids of the keys it created, to be deleted if the import fails. The
+// keystores must open with the keystore passphrase of this instance, the private keys are never sent in the bundle.
+func (s *ThanosStackDeploymentService) importOperatorKeys(
+	ctx context.Context,
+	projectId uuid.UUID,
+	keys []*entities.StackBundleOperatorKey,
+) (map[string]string, []string, *entities.Response, error) {
+	if len(keys) == 0 {
+		return nil, nil, nil, nil
+	}
+	if s.keystore == nil {
+		return nil, nil, keystoreDisabledResponse(), nil
+	}
+
+	keyIds := make(map[string]string, len(keys))
+	var created []string
+	fail := func(response *entities.Response, err error) (map[string]string, []string, *entities.Response, error) {
+		s.deleteOperatorKeys(ctx, created)
+		return nil, nil, response, err
+	}
+	for _, key := range keys {
+		privateKey, err := s.keystore.Decrypt(key.Keystore)
+		if err == nil {
+			var parsed *ecdsa.PrivateKey
+			if parsed, err = keystore.ParsePrivateKey(privateKey); err == nil && !strings.EqualFold(keystore.Address(parsed), key.Address) {
+				err = fmt.Errorf("the keystore does not hold the key of %s", key.Address)
+			}
+		}
+		if err != nil {
+			logger.WarnContext(ctx, "failed to open the keystore of an imported key", zap.String("keyId", key.ID.String()), zap.Error(err))
+			return fail(&entities.Response{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("Key %s of the bundle does not open with the keystore passphrase of this instance", key.ID),
+				Data:    nil,
+			}, nil)
+		}
+
+		existing, err := s.operatorKeyRepo.GetKeyByID(key.ID.String())
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to get operator key", zap.String("keyId", key.ID.String()), zap.Error(err))
+			return fail(internalServerErrorResponse(), err)
+		}
+		if existing != nil && existing.ProjectID == projectId {
+			keyIds[key.ID.String()] = existing.ID.String()
+			continue
+		}
+		imported := &entities.OperatorKeyEntity{
+			ID:        key.ID,
+			ProjectID: projectId,
+			Name:      key.Name,
+			Address:   key.Address,
+			Origin:    entities.OperatorKeyOriginImported,
+			Keystore:  key.Keystore,
+			CreatedAt: time.Now(),
+		}
+		if existing != nil {
+			imported.ID = uuid.New()
+		}
+
+		sameName, err := s.operatorKeyRepo.GetKeyByName(projectId.String(), key.Name)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to get operator key", zap.String("projectId", projectId.String()), zap.Error(err))
+			return fail(internalServerErrorResponse(), err)
+		}
+		if sameName != nil && strings.EqualFold(sameName.Address, key.Address) {
+			keyIds[key.ID.String()] = sameName.ID.String()
+			continue
+		}
+		if sameName != nil {
+			imported.Name = key.Name + "-" + imported.ID.String()[:8]
+		}
+
+		if err := s.operatorKeyRepo.CreateKey(imported); err != nil {
+			logger.ErrorContext(ctx, "failed to create operator key", zap.String("projectId", projectId.String()), zap.Error(err))
+			return fail(internalServerErrorResponse(), err)
+		}
+		created = append(created, imported.ID.String())
+		keyIds[key.ID.String()] = imported.ID.String()
+	}
+	return keyIds, created, nil, nil
+}
+
+func (s *ThanosStackDeploymentService) deleteOperatorKeys(ctx context.Context, keyIds []string) {
+	for _, keyId := range keyIds {
+		if err := s.operatorKeyRepo.DeleteKey(keyId); err != nil {
+			logger.WarnContext(ctx, "failed to delete operator key", zap.String("keyId", keyId), zap.Error(err))
+		}
+	}
+}
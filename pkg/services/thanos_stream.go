@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+const (
+	stackStatusPollInterval = 2 * time.Second
+	logPollInterval         = time.Second
+)
+
+// WatchStackStatus sends the status of the stack, then every change of it, until the context is done.
+// The response is only set when the stack cannot be watched.
+func (s *ThanosStackDeploymentService) WatchStackStatus(
+	ctx context.Context,
+	stackId uuid.UUID,
+	send func(status entities.StackStatus) error,
+) (*entities.Response, error) {
+	stack, err := s.stackRepo.GetStackByID(stackId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get stack", zap.String("stackId", stackId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if stack == nil {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Stack not found",
+			Data:    nil,
+		}, nil
+	}
+
+	ticker := time.NewTicker(stackStatusPollInterval)
+	defer ticker.Stop()
+
+	current := stack.Status
+	if err := send(current); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+
+		status, err := s.stackRepo.GetStackStatus(stackId.String())
+		if err != nil {
+			logger.WarnContext(ctx, "failed to get stack status", zap.String("stackId", stackId.String()), zap.Error(err))
+			continue
+		}
+		if status == current {
+			continue
+		}
+		current = status
+		if err := send(current); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// StreamDeploymentLogs sends the log of the deployment line by line. When following, it waits for new lines until the
+// deployment is no longer pending or in progress, or the context is done.
+// The response is only set when the log cannot be streamed.
+func (s *ThanosStackDeploymentService) StreamDeploymentLogs(
+	ctx context.Context,
+	stackId uuid.UUID,
+	deploymentId uuid.UUID,
+	follow bool,
+	send func(line string) error,
+) (*entities.Response, error) {
+	deployment, err := s.deploymentRepo.GetDeploymentByID(deploymentId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get deployment", zap.String("deploymentId", deploymentId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if deployment == nil || deployment.StackID == nil || *deployment.StackID != stackId {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Deployment not found",
+			Data:    nil,
+		}, nil
+	}
+
+	return s.streamLog(ctx, deployment.LogPath, follow, func() bool {
+		return s.isDeploymentRunning(deployment.ID)
+	}, send)
+}
+
+// StreamIntegrationLogs sends the log of the integration line by line, like StreamDeploymentLogs. When following, it
+// waits for new lines while the integration is pending, in progress or terminating.
+func (s *ThanosStackDeploymentService) StreamIntegrationLogs(
+	ctx context.Context,
+	stackId uuid.UUID,
+	integrationId uuid.UUID,
+	follow bool,
+	send func(line string) error,
+) (*entities.Response, error) {
+	integration, err := s.integrationRepo.GetIntegrationById(integrationId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get integration", zap.String("integrationId", integrationId.String()), zap.Error(err))
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+
+	if integration == nil || integration.StackID == nil || *integration.StackID != stackId {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Integration not found",
+			Data:    nil,
+		}, nil
+	}
+	// The integrations installed with the stack share the log of its deployment
+	if integration.LogPath == "" {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Log not found",
+			Data:    nil,
+		}, nil
+	}
+
+	return s.streamLog(ctx, integration.LogPath, follow, func() bool {
+		return s.isIntegrationRunning(integration.ID)
+	}, send)
+}
+
+// streamLog sends the log line by line from the log sink. The log is read up to its end, then opened again from there
+// while following and the log is written to, as reported by isRunning.
+func (s *ThanosStackDeploymentService) streamLog(
+	ctx context.Context,
+	name string,
+	follow bool,
+	isRunning func() bool,
+	send func(line string) error,
+) (*entities.Response, error) {
+	log, err := s.openLog(ctx, name, 0, follow, isRunning)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &entities.Response{
+				Status:  http.StatusNotFound,
+				Message: "Log not found",
+				Data:    nil,
+			}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return &entities.Response{
+			Status:  http.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		}, err
+	}
+	// The log is opened again while following
+	defer func() {
+		if log != nil {
+			log.Close()
+		}
+	}()
+
+	reader := bufio.NewReader(log)
+	var offset int64
+	var partial strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		partial.WriteString(line)
+		if err == nil {
+			if err := send(strings.TrimRight(partial.String(), "\r\n")); err != nil {
+				return nil, err
+			}
+			partial.Reset()
+			continue
+		}
+		if err != io.EOF {
+			return nil, err
+		}
+
+		// Reached the end of the log, it may still be written to
+		if !follow || !isRunning() {
+			if partial.Len() > 0 {
+				return nil, send(partial.String())
+			}
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(logPollInterval):
+		}
+		log.Close()
+		if log, err = s.logs.Open(ctx, name, offset); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+		reader.Reset(log)
+	}
+}
+
+// openLog opens the log from the offset, when following it waits for the log to be created
+func (s *ThanosStackDeploymentService) openLog(
+	ctx context.Context,
+	name string,
+	offset int64,
+	follow bool,
+	isRunning func() bool,
+) (io.ReadCloser, error) {
+	for {
+		log, err := s.logs.Open(ctx, name, offset)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) || !follow || !isRunning() {
+			return log, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+func (s *ThanosStackDeploymentService) isDeploymentRunning(deploymentId uuid.UUID) bool {
+	status, err := s.deploymentRepo.GetDeploymentStatus(deploymentId.String())
+	if err != nil {
+		return false
+	}
+	return status == entities.DeploymentStatusPending || status == entities.DeploymentStatusInProgress
+}
+
+func (s *ThanosStackDeploymentService) isIntegrationRunning(integrationId uuid.UUID) bool {
+	integration, err := s.integrationRepo.GetIntegrationById(integrationId.String())
+	if err != nil || integration == nil {
+		return false
+	}
+	switch entities.DeploymentStatus(integration.Status) {
+	case entities.DeploymentStatusPending, entities.DeploymentStatusInProgress, entities.DeploymentStatusTerminating:
+		return true
+	}
+	return false
+}
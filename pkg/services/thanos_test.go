@@ -0,0 +1,715 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"github.com/tokamak-network/trh-backend/pkg/enum"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/logsink"
+	"github.com/tokamak-network/trh-backend/pkg/infrastructure/memory"
+	"github.com/tokamak-network/trh-backend/pkg/services"
+	"github.com/tokamak-network/trh-backend/pkg/stacks/thanos/thanostest"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+type fixture struct {
+	service      *services.ThanosStackDeploymentService
+	store        *memory.Store
+	stacks       *memory.StackRepository
+	deployments  *memory.DeploymentRepository
+	integrations *memory.IntegrationRepository
+	tasks        *memory.TaskManager
+	driver       *thanostest.Driver
+	logs         *logsink.FileSink
+}
+
+// newFixture returns a service running its tasks against the in-memory repositories and the fake driver
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	store := memory.NewStore()
+	logs, err := logsink.NewFileSink(t.TempDir(), logsink.Retention{}, false)
+	if err != nil {
+		t.Fatalf("failed to create the log sink: %v", err)
+	}
+	f := &fixture{
+		store:        store,
+		stacks:       memory.NewStackRepository(store),
+		deployments:  memory.NewDeploymentRepository(store),
+		integrations: memory.NewIntegrationRepository(store),
+		tasks:        memory.NewTaskManager(),
+		driver:       thanostest.NewDriver(),
+		logs:         logs,
+	}
+	f.service = f.newService(f.stacks)
+	t.Cleanup(func() {
+		f.driver.Reset()
+		f.tasks.Stop()
+	})
+	return f
+}
+
+// newService returns a service sharing the repositories of the fixture, with the stacks read through the repository
+func (f *fixture) newService(stacks services.StackRepository) *services.ThanosStackDeploymentService {
+	return services.NewThanosService(
+		f.deployments,
+		stacks,
+		f.integrations,
+		nil,
+		nil,
+		memory.NewUnitOfWork(f.store),
+		f.driver,
+		nil,
+		f.tasks,
+		f.logs,
+		nil,
+	)
+}
+
+// staleStackRepository returns the stacks as read before a concurrent update
+type staleStackRepository struct {
+	*memory.StackRepository
+}
+
+func (r staleStackRepository) GetStackByID(id string) (*entities.StackEntity, error) {
+	stack, err := r.StackRepository.GetStackByID(id)
+	if stack != nil {
+		stack.Version--
+	}
+	return stack, err
+}
+
+// createStack creates a stack and returns its id without waiting for its deployment
+func (f *fixture) createStack(t *testing.T) uuid.UUID {
+	t.Helper()
+	response, err := f.service.CreateThanosStack(context.Background(), dtos.DeployThanosRequest{
+		ProjectID:                uuid.NewString(),
+		Network:                  entities.DeploymentNetworkTestnet,
+		L1RpcUrl:                 "http://l1.thanos.test",
+		L1BeaconUrl:              "http://beacon.thanos.test",
+		L2BlockTime:              2,
+		BatchSubmissionFrequency: 1440,
+		OutputRootFrequency:      240,
+		ChallengePeriod:          12,
+		AdminAccount:             "admin",
+		SequencerAccount:         "sequencer",
+		BatcherAccount:           "batcher",
+		ProposerAccount:          "proposer",
+		AwsAccessKey:             "access-key",
+		AwsSecretAccessKey:       "secret-key",
+		AwsRegion:                "ap-northeast-2",
+		ChainName:                "thanos-test",
+	})
+	assertResponse(t, response, err, http.StatusOK)
+	stackID, err := uuid.Parse(response.Data.(map[string]string)["stackId"])
+	if err != nil {
+		t.Fatalf("invalid stack id: %v", err)
+	}
+	return stackID
+}
+
+// deployStack creates a stack and waits for its deployment
+func (f *fixture) deployStack(t *testing.T) uuid.UUID {
+	t.Helper()
+	stackID := f.createStack(t)
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+	return stackID
+}
+
+func (f *fixture) stack(t *testing.T, stackID uuid.UUID) *entities.StackEntity {
+	t.Helper()
+	stack, err := f.stacks.GetStackByID(stackID.String())
+	if err != nil || stack == nil {
+		t.Fatalf("failed to get the stack: %v", err)
+	}
+	return stack
+}
+
+func (f *fixture) assertStackStatus(t *testing.T, stackID uuid.UUID, want entities.StackStatus) {
+	t.Helper()
+	if got := f.stack(t, stackID).Status; got != want {
+		t.Fatalf("stack status = %s, want %s", got, want)
+	}
+}
+
+func (f *fixture) assertDeploymentStatuses(t *testing.T, stackID uuid.UUID, want ...entities.DeploymentStatus) {
+	t.Helper()
+	deployments, err := f.deployments.GetDeploymentsByStackID(stackID.String())
+	if err != nil {
+		t.Fatalf("failed to get the deployments: %v", err)
+	}
+	got := make([]entities.DeploymentStatus, len(deployments))
+	for i, deployment := range deployments {
+		got[i] = deployment.Status
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("deployment statuses = %v, want %v", got, want)
+	}
+}
+
+// integration returns the last integration of the type created for the stack
+func (f *fixture) integration(t *testing.T, stackID uuid.UUID, integrationType enum.IntegrationType) *entities.IntegrationEntity {
+	t.Helper()
+	integrations, err := f.integrations.GetIntegrationsByStackID(stackID.String())
+	if err != nil {
+		t.Fatalf("failed to get the integrations: %v", err)
+	}
+	for i := len(integrations) - 1; i >= 0; i-- {
+		if integrations[i].Type == integrationType.String() {
+			return integrations[i]
+		}
+	}
+	t.Fatalf("no %s integration", integrationType)
+	return nil
+}
+
+func assertResponse(t *testing.T, response *entities.Response, err error, status uint64) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response == nil || response.Status != status {
+		t.Fatalf("response = %+v, want status %d", response, status)
+	}
+}
+
+func TestAuthorizeUnknownStack(t *testing.T) {
+	f := newFixture(t)
+	access := services.NewAccessService(nil, nil, f.stacks)
+
+	response, err := access.AuthorizeStack(context.Background(), &entities.UserEntity{IsAdmin: true}, uuid.New(), services.StackActionView)
+	assertResponse(t, response, err, http.StatusNotFound)
+
+	response, err = f.service.GetStackStatus(context.Background(), uuid.New())
+	assertResponse(t, response, err, http.StatusNotFound)
+
+	response, err = f.service.InstallBlockExplorer(context.Background(), uuid.NewString(), dtos.InstallBlockExplorerRequest{
+		DatabaseUsername: "explorer",
+		DatabasePassword: "Explorer-password-1",
+		CoinmarketcapKey: "cmc",
+		WalletConnectID:  "walletconnect",
+	})
+	assertResponse(t, response, err, http.StatusNotFound)
+}
+
+func TestDeployThanosStack(t *testing.T) {
+	f := newFixture(t)
+
+	stackID := f.deployStack(t)
+
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+	stack := f.stack(t, stackID)
+	if stack.Metadata == nil || stack.Metadata.L2Url != "http://l2.thanos.test" ||
+		stack.Metadata.BridgeUrl != "http://bridge.thanos.test" {
+		t.Errorf("metadata = %+v, want the chain information", stack.Metadata)
+	}
+
+	bridge := f.integration(t, stackID, enum.IntegrationTypeBridge)
+	if bridge.Status != string(entities.DeploymentStatusCompleted) {
+		t.Errorf("bridge status = %s, want %s", bridge.Status, entities.DeploymentStatusCompleted)
+	}
+	var info map[string]string
+	if err := json.Unmarshal(bridge.Info, &info); err != nil || info["url"] != "http://bridge.thanos.test" {
+		t.Errorf("bridge info = %s, want the bridge url", bridge.Info)
+	}
+
+	methods := slices.DeleteFunc(f.driver.Methods(), func(method string) bool { return method == "NewClient" })
+	want := []string{"DeployL1Contracts", "DeployAWSInfrastructure", "TagAWSResources", "ShowChainInformation"}
+	if !slices.Equal(methods, want) {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+
+	// The resources are tagged with the keys of the stack, not the ambient AWS profile
+	for _, call := range f.driver.Calls() {
+		if call.Method == "TagAWSResources" &&
+			(call.Config.AwsAccessKey != "access-key" || call.Config.AwsSecretAccessKey != "secret-key" ||
+				call.Config.DeploymentPath != stack.DeploymentPath) {
+			t.Errorf("TagAWSResources config = %+v, want the keys and deployment of the stack", call.Config)
+		}
+	}
+}
+
+func TestDeployThanosStackFailure(t *testing.T) {
+	f := newFixture(t)
+	f.driver.FailOn("DeployAWSInfrastructure", errors.New("terraform apply failed"))
+
+	stackID := f.createStack(t)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusFailedToDeploy)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusFailed)
+	if bridge := f.integration(t, stackID, enum.IntegrationTypeBridge); bridge.Status != string(entities.DeploymentStatusFailed) {
+		t.Errorf("bridge status = %s, want %s", bridge.Status, entities.DeploymentStatusFailed)
+	}
+
+	transitions, err := f.stacks.GetStatusTransitions(stackID.String())
+	if err != nil {
+		t.Fatalf("failed to get the history: %v", err)
+	}
+	failed := slices.ContainsFunc(transitions, func(transition *entities.StatusTransitionEntity) bool {
+		return transition.Subject == entities.StatusSubjectStack &&
+			transition.ToStatus == string(entities.StackStatusFailedToDeploy) &&
+			transition.Reason == "terraform apply failed"
+	})
+	if !failed {
+		t.Errorf("history %v does not record the failure with its reason", transitions)
+	}
+}
+
+func TestDeployThanosStackFailureToComplete(t *testing.T) {
+	f := newFixture(t)
+	f.driver.FailOn("ShowChainInformation", errors.New("no chain information"))
+
+	stackID := f.createStack(t)
+	f.tasks.Wait()
+
+	// The status and the metadata are committed together, the stack is not left deployed without its URLs
+	f.assertStackStatus(t, stackID, entities.StackStatusFailedToDeploy)
+	if stack := f.stack(t, stackID); stack.Metadata != nil {
+		t.Errorf("metadata = %+v, want none", stack.Metadata)
+	}
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+}
+
+func TestCloneThanosStackIntoAnotherProject(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	projectID := uuid.NewString()
+
+	response, err := f.service.CloneThanosStack(context.Background(), stackID, dtos.CloneThanosStackRequest{
+		ProjectID: &projectID,
+	})
+	assertResponse(t, response, err, http.StatusBadRequest)
+	if stacks, _ := f.stacks.GetAllStacks(nil, true); len(stacks) != 1 {
+		t.Errorf("%d stacks after the rejected clone, want 1", len(stacks))
+	}
+}
+
+func TestStopAndResumeThanosStack(t *testing.T) {
+	f := newFixture(t)
+	gate := f.driver.BlockOn("DeployL1Contracts")
+
+	stackID := f.createStack(t)
+	<-gate.Entered()
+
+	response, err := f.service.StopDeployingThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusStopped)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusStopped, entities.DeploymentStatusPending)
+	if count := f.driver.CallCount("DeployAWSInfrastructure"); count != 0 {
+		t.Errorf("the infrastructure was deployed %d times after the stop", count)
+	}
+
+	response, err = f.service.StopDeployingThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusBadRequest)
+
+	gate.Release()
+	response, err = f.service.ResumeThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+	if count := f.driver.CallCount("DeployL1Contracts"); count != 2 {
+		t.Errorf("the L1 contracts were deployed %d times, want 2", count)
+	}
+}
+
+func TestStopThanosStackVersionConflict(t *testing.T) {
+	f := newFixture(t)
+	gate := f.driver.BlockOn("DeployL1Contracts")
+
+	stackID := f.createStack(t)
+	<-gate.Entered()
+
+	response, err := f.newService(staleStackRepository{f.stacks}).StopDeployingThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusConflict)
+
+	gate.Release()
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+}
+
+func TestResumeFailedThanosStack(t *testing.T) {
+	f := newFixture(t)
+	f.driver.FailOn("DeployL1Contracts", errors.New("insufficient funds"))
+
+	stackID := f.createStack(t)
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusFailedToDeploy)
+
+	f.driver.FailOn("DeployL1Contracts", nil)
+	response, err := f.service.ResumeThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+}
+
+func TestUpdateNetworkFailure(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	f.driver.FailOn("UpdateNetwork", errors.New("unreachable RPC"))
+
+	request := dtos.UpdateNetworkRequest{L1RpcUrl: "http://l1-new.thanos.test", L1BeaconUrl: "http://beacon-new.thanos.test"}
+	response, err := f.service.UpdateNetwork(context.Background(), stackID, request)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusFailedToUpdate)
+
+	f.driver.FailOn("UpdateNetwork", nil)
+	response, err = f.service.UpdateNetwork(context.Background(), stackID, request)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+}
+
+func TestTerminateThanosStack(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+
+	response, err := f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusTerminated)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusTerminated, entities.DeploymentStatusTerminated)
+	if bridge := f.integration(t, stackID, enum.IntegrationTypeBridge); bridge.Status != string(entities.DeploymentStatusTerminated) {
+		t.Errorf("bridge status = %s, want %s", bridge.Status, entities.DeploymentStatusTerminated)
+	}
+
+	response, err = f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusBadRequest)
+}
+
+func TestPurgeThanosStack(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+
+	response, err := f.service.PurgeStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusBadRequest)
+
+	response, err = f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	writer, err := f.logs.Append(context.Background(), stackID.String()+"/destroy_logs.txt")
+	if err != nil {
+		t.Fatalf("failed to append to the log: %v", err)
+	}
+	if _, err := writer.Write([]byte("destroyed\n")); err != nil {
+		t.Fatalf("failed to write the log: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close the log: %v", err)
+	}
+
+	response, err = f.service.PurgeStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	if logs, err := f.logs.List(context.Background(), stackID.String()); err != nil || len(logs) != 0 {
+		t.Errorf("logs after the purge = %v, %v", logs, err)
+	}
+
+	response, err = f.service.PurgeStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusNotFound)
+}
+
+func TestTerminateThanosStackVersionConflict(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+
+	response, err := f.newService(staleStackRepository{f.stacks}).TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusConflict)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+	if count := f.driver.CallCount("DestroyAWSInfrastructure"); count != 0 {
+		t.Errorf("the infrastructure was destroyed %d times after the conflict", count)
+	}
+}
+
+func TestResumeTerminatedThanosStack(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	response, err := f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	response, err = f.service.ResumeThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+	// The terminated bridge is final, the deployment installs a new one
+	bridges, err := f.integrations.GetActiveIntegrations(stackID.String(), enum.IntegrationTypeBridge.String())
+	if err != nil || len(bridges) != 1 || bridges[0].Status != string(entities.DeploymentStatusCompleted) {
+		t.Errorf("active bridges = %v (%v), want a single completed one", bridges, err)
+	}
+}
+
+func TestTerminateThanosStackFailure(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	f.driver.FailOn("DestroyAWSInfrastructure", errors.New("terraform destroy failed"))
+
+	response, err := f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.assertStackStatus(t, stackID, entities.StackStatusFailedToTerminate)
+	f.assertDeploymentStatuses(t, stackID, entities.DeploymentStatusCompleted, entities.DeploymentStatusCompleted)
+}
+
+func TestTerminateDeployingThanosStack(t *testing.T) {
+	f := newFixture(t)
+	gate := f.driver.BlockOn("DeployAWSInfrastructure")
+
+	stackID := f.createStack(t)
+	<-gate.Entered()
+
+	response, err := f.service.TerminateThanosStack(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusBadRequest)
+
+	gate.Release()
+	f.tasks.Wait()
+	f.assertStackStatus(t, stackID, entities.StackStatusDeployed)
+}
+
+func TestReinstallBridge(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+
+	response, err := f.service.InstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusBadRequest)
+
+	response, err = f.service.UninstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+	if bridge := f.integration(t, stackID, enum.IntegrationTypeBridge); bridge.Status != string(entities.DeploymentStatusTerminated) {
+		t.Fatalf("bridge status = %s, want %s", bridge.Status, entities.DeploymentStatusTerminated)
+	}
+	if url := f.stack(t, stackID).Metadata.BridgeUrl; url != "" {
+		t.Errorf("bridge url = %q after the uninstall", url)
+	}
+
+	f.driver.BridgeURL = "http://new-bridge.thanos.test"
+	response, err = f.service.InstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	bridge := f.integration(t, stackID, enum.IntegrationTypeBridge)
+	if bridge.Status != string(entities.DeploymentStatusCompleted) {
+		t.Errorf("bridge status = %s, want %s", bridge.Status, entities.DeploymentStatusCompleted)
+	}
+	if url := f.stack(t, stackID).Metadata.BridgeUrl; url != "http://new-bridge.thanos.test" {
+		t.Errorf("bridge url = %q, want the url of the new bridge", url)
+	}
+}
+
+func TestInstallBridgeFailure(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	response, err := f.service.UninstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	f.driver.FailOn("InstallBridge", errors.New("helm install failed"))
+	response, err = f.service.InstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	bridge := f.integration(t, stackID, enum.IntegrationTypeBridge)
+	if bridge.Status != string(entities.DeploymentStatusFailed) || bridge.Reason != "helm install failed" {
+		t.Errorf("bridge = %s (%q), want failed with the error of the install", bridge.Status, bridge.Reason)
+	}
+}
+
+func TestInstallBridgeVersionConflict(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	response, err := f.service.UninstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+
+	// The stack changes between the check of the install and the creation of the integration
+	gate := f.driver.BlockOn("NewClient")
+	responses := make(chan *entities.Response, 1)
+	go func() {
+		response, err := f.service.InstallBridge(context.Background(), stackID.String())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		responses <- response
+	}()
+	<-gate.Entered()
+	stack := f.stack(t, stackID)
+	if err := f.stacks.UpdateDetails(stackID.String(), stack.Version, "renamed", "", nil); err != nil {
+		t.Fatalf("failed to update the stack: %v", err)
+	}
+	gate.Release()
+
+	if response := <-responses; response == nil || response.Status != http.StatusConflict {
+		t.Fatalf("response = %+v, want status %d", response, http.StatusConflict)
+	}
+	active, err := f.integrations.GetActiveIntegrations(stackID.String(), enum.IntegrationTypeBridge.String())
+	if err != nil || len(active) != 0 {
+		t.Errorf("active bridges = %v (%v), want none", active, err)
+	}
+}
+
+func TestStreamIntegrationLogs(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	bridge := f.integration(t, stackID, enum.IntegrationTypeBridge)
+	response, err := f.service.StreamIntegrationLogs(context.Background(), stackID, bridge.ID, false, func(string) error {
+		return nil
+	})
+	assertResponse(t, response, err, http.StatusNotFound)
+
+	response, err = f.service.UninstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+	response, err = f.service.InstallBridge(context.Background(), stackID.String())
+	assertResponse(t, response, err, http.StatusOK)
+	f.tasks.Wait()
+	bridge = f.integration(t, stackID, enum.IntegrationTypeBridge)
+
+	writer, err := f.logs.Append(context.Background(), bridge.LogPath)
+	if err != nil {
+		t.Fatalf("failed to append to the log: %v", err)
+	}
+	if _, err := writer.Write([]byte("installing\ninstalled\npartial")); err != nil {
+		t.Fatalf("failed to write the log: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close the log: %v", err)
+	}
+
+	var lines []string
+	response, err = f.service.StreamIntegrationLogs(context.Background(), stackID, bridge.ID, true, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if response != nil || err != nil {
+		t.Fatalf("response = %+v, %v", response, err)
+	}
+	if want := []string{"installing", "installed", "partial"}; !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	response, err = f.service.StreamIntegrationLogs(context.Background(), uuid.New(), bridge.ID, false, func(string) error {
+		return nil
+	})
+	assertResponse(t, response, err, http.StatusNotFound)
+}
+
+func TestGetChildrenOfAnotherStack(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+	otherID := f.deployStack(t)
+	deployments, err := f.deployments.GetDeploymentsByStackID(stackID.String())
+	if err != nil || len(deployments) == 0 {
+		t.Fatalf("deployments = %v (%v), want some", deployments, err)
+	}
+	deploymentID := deployments[0].ID
+	bridge := f.integration(t, stackID, enum.IntegrationTypeBridge)
+
+	response, err := f.service.GetStackDeployment(context.Background(), stackID, deploymentID)
+	assertResponse(t, response, err, http.StatusOK)
+	response, err = f.service.GetStackDeployment(context.Background(), otherID, deploymentID)
+	assertResponse(t, response, err, http.StatusNotFound)
+
+	response, err = f.service.GetStackDeploymentStatus(context.Background(), stackID, deploymentID)
+	assertResponse(t, response, err, http.StatusOK)
+	response, err = f.service.GetStackDeploymentStatus(context.Background(), otherID, deploymentID)
+	assertResponse(t, response, err, http.StatusNotFound)
+
+	response, err = f.service.GetIntegration(context.Background(), stackID, bridge.ID)
+	assertResponse(t, response, err, http.StatusOK)
+	response, err = f.service.GetIntegration(context.Background(), otherID, bridge.ID)
+	assertResponse(t, response, err, http.StatusNotFound)
+}
+
+func TestAdoptThanosStackPath(t *testing.T) {
+	f := newFixture(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get the working directory: %v", err)
+	}
+	storage, adoption, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	utils.SetStorageRoot(storage)
+	utils.SetAdoptionRoot(adoption)
+	t.Cleanup(func() {
+		utils.SetStorageRoot(filepath.Join(wd, "storage"))
+		utils.SetAdoptionRoot("")
+	})
+
+	deployment := filepath.Join(storage, "deployments", "Thanos", "Testnet", uuid.NewString())
+	if err := os.MkdirAll(deployment, 0o755); err != nil {
+		t.Fatalf("failed to create the deployment: %v", err)
+	}
+	alias := filepath.Join(adoption, "alias")
+	if err := os.Symlink(deployment, alias); err != nil {
+		t.Fatalf("failed to create the symlink: %v", err)
+	}
+
+	adopt := func(path string) (*entities.Response, error) {
+		return f.service.AdoptThanosStack(context.Background(), dtos.AdoptThanosStackRequest{
+			ProjectID:      uuid.NewString(),
+			DeploymentPath: path,
+		})
+	}
+	response, err := adopt(alias)
+	assertResponse(t, response, err, http.StatusBadRequest)
+	response, err = adopt(filepath.Join(adoption, "..", filepath.Base(storage), "deployments"))
+	assertResponse(t, response, err, http.StatusBadRequest)
+	response, err = adopt(outside)
+	assertResponse(t, response, err, http.StatusForbidden)
+	response, err = adopt(filepath.Join(adoption, "missing"))
+	assertResponse(t, response, err, http.StatusBadRequest)
+}
+
+func TestGetStackWithoutSecrets(t *testing.T) {
+	f := newFixture(t)
+	stackID := f.deployStack(t)
+
+	response, err := f.service.GetStackByID(context.Background(), stackID)
+	assertResponse(t, response, err, http.StatusOK)
+	stack := response.Data.(map[string]interface{})["stack"].(*entities.StackEntity)
+	var config map[string]interface{}
+	if err := json.Unmarshal(stack.Config, &config); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	for _, field := range entities.SecretConfigFields {
+		if _, ok := config[field]; ok {
+			t.Errorf("the config contains %s", field)
+		}
+	}
+	if config["awsAccessKey"] != "access-key" {
+		t.Errorf("awsAccessKey = %v, want access-key", config["awsAccessKey"])
+	}
+	var stored dtos.DeployThanosRequest
+	if err := json.Unmarshal(f.stack(t, stackID).Config, &stored); err != nil || stored.AdminAccount != "admin" {
+		t.Errorf("stored admin account = %q (%v), want it kept", stored.AdminAccount, err)
+	}
+}
@@ -0,0 +1,17 @@
+package services
+
+import "context"
+
+// Repositories are the repositories of a unit of work, bound to its transaction
+type Repositories struct {
+	Deployments  DeploymentRepository
+	Stacks       StackRepository
+	Integrations IntegrationRepository
+}
+
+// UnitOfWork commits the updates of several rows atomically
+type UnitOfWork interface {
+	// Do runs fn with repositories sharing a single transaction. The transaction is committed when fn returns nil and
+	// rolled back when it returns an error, which Do returns.
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
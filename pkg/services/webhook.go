@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(subscription *entities.WebhookSubscriptionEntity) error
+	GetSubscriptionByID(id string) (*entities.WebhookSubscriptionEntity, error)
+	GetSubscriptionsByProjectID(projectID string) ([]*entities.WebhookSubscriptionEntity, error)
+	DeleteSubscription(id string) error
+	GetDeliveriesBySubscriptionID(subscriptionID string, limit int) ([]*entities.WebhookDeliveryEntity, error)
+	DispatchEvents(limit int) (int, error)
+	ClaimDueDeliveries(limit int) ([]*entities.WebhookDeliveryJob, error)
+	MarkDeliverySucceeded(id string, attempts int, responseStatus int) error
+	MarkDeliveryFailed(id string, attempts int, responseStatus int, reason string, nextAttemptAt *time.Time) error
+}
+
+type WebhookService struct {
+	webhookRepo   WebhookRepository
+	accessService *AccessService
+}
+
+func NewWebhookService(
+	webhookRepo WebhookRepository,
+	accessService *AccessService,
+) *WebhookService {
+	return &WebhookService{
+		webhookRepo:   webhookRepo,
+		accessService: accessService,
+	}
+}
+
+func (s *WebhookService) CreateSubscription(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	request dtos.CreateWebhookRequest,
+) (*entities.Response, error) {
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		secret, err = utils.GenerateWebhookSecret()
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to generate webhook secret", zap.Error(err))
+			return internalServerErrorResponse(), err
+		}
+	}
+
+	subscription := &entities.WebhookSubscriptionEntity{
+		ID:        uuid.New(),
+		ProjectID: projectId,
+		URL:       request.URL,
+		Events:    request.Events,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	if err := s.webhookRepo.CreateSubscription(subscription); err != nil {
+		logger.ErrorContext(ctx, "failed to create webhook subscription", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	logger.InfoContext(ctx, "Webhook subscription created",
+		zap.String("projectId", projectId.String()),
+		zap.String("webhookId", subscription.ID.String()),
+	)
+
+	// The secret is only returned once, it is needed by the receiver to verify the signatures
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"webhook": subscription, "secret": secret},
+	}, nil
+}
+
+func (s *WebhookService) GetSubscriptions(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+) (*entities.Response, error) {
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleViewer); response != nil {
+		return response, err
+	}
+
+	subscriptions, err := s.webhookRepo.GetSubscriptionsByProjectID(projectId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get webhook subscriptions", zap.String("projectId", projectId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"webhooks": subscriptions},
+	}, nil
+}
+
+func (s *WebhookService) DeleteSubscription(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	webhookId uuid.UUID,
+) (*entities.Response, error) {
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleAdmin); response != nil {
+		return response, err
+	}
+
+	if response, err := s.getProjectSubscription(ctx, projectId, webhookId); response != nil {
+		return response, err
+	}
+
+	if err := s.webhookRepo.DeleteSubscription(webhookId.String()); err != nil {
+		logger.ErrorContext(ctx, "failed to delete webhook subscription", zap.String("webhookId", webhookId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    nil,
+	}, nil
+}
+
+// GetDeliveries returns the most recent deliveries of the subscription, newest first
+func (s *WebhookService) GetDeliveries(
+	ctx context.Context,
+	caller *entities.UserEntity,
+	projectId uuid.UUID,
+	webhookId uuid.UUID,
+	limit int,
+) (*entities.Response, error) {
+	if response, err := s.accessService.AuthorizeProject(ctx, caller, projectId, entities.ProjectRoleViewer); response != nil {
+		return response, err
+	}
+
+	if response, err := s.getProjectSubscription(ctx, projectId, webhookId); response != nil {
+		return response, err
+	}
+
+	deliveries, err := s.webhookRepo.GetDeliveriesBySubscriptionID(webhookId.String(), limit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get webhook deliveries", zap.String("webhookId", webhookId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	return &entities.Response{
+		Status:  http.StatusOK,
+		Message: "Successfully",
+		Data:    map[string]interface{}{"deliveries": deliveries},
+	}, nil
+}
+
+// getProjectSubscription returns a non-nil response when the subscription does not exist in the project
+func (s *WebhookService) getProjectSubscription(
+	ctx context.Context,
+	projectId uuid.UUID,
+	webhookId uuid.UUID,
+) (*entities.Response, error) {
+	subscription, err := s.webhookRepo.GetSubscriptionByID(webhookId.String())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get webhook subscription", zap.String("webhookId", webhookId.String()), zap.Error(err))
+		return internalServerErrorResponse(), err
+	}
+
+	if subscription == nil || subscription.ProjectID != projectId {
+		return &entities.Response{
+			Status:  http.StatusNotFound,
+			Message: "Webhook not found",
+			Data:    nil,
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
+	"go.uber.org/zap"
+)
+
+const (
+	WebhookEventHeader     = "X-TRH-Event"
+	WebhookDeliveryHeader  = "X-TRH-Delivery"
+	WebhookTimestampHeader = "X-TRH-Timestamp"
+	WebhookSignatureHeader = "X-TRH-Signature"
+
+	webhookPollInterval   = 5 * time.Second
+	webhookBatchSize      = 50
+	webhookRequestTimeout = 10 * time.Second
+	webhookMaxAttempts    = 8
+	webhookBaseBackoff    = 30 * time.Second
+	webhookMaxBackoff     = time.Hour
+)
+
+// WebhookDispatcher moves lifecycle events from the outbox to the subscribed endpoints.
+// Failed deliveries are retried with exponential backoff until webhookMaxAttempts is reached.
+type WebhookDispatcher struct {
+	webhookRepo WebhookRepository
+	client      *http.Client
+}
+
+func NewWebhookDispatcher(webhookRepo WebhookRepository) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepo: webhookRepo,
+		client:      &http.Client{Timeout: webhookRequestTimeout},
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.Dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch fans out the pending events and sends the deliveries which are due
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) {
+	for {
+		dispatched, err := d.webhookRepo.DispatchEvents(webhookBatchSize)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to dispatch webhook events", zap.Error(err))
+			break
+		}
+		if dispatched < webhookBatchSize {
+			break
+		}
+	}
+
+	jobs, err := d.webhookRepo.ClaimDueDeliveries(webhookBatchSize)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to claim webhook deliveries", zap.Error(err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *entities.WebhookDeliveryJob) {
+			defer wg.Done()
+			d.deliver(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, job *entities.WebhookDeliveryJob) {
+	delivery := job.Delivery
+	attempts := delivery.Attempts + 1
+
+	responseStatus, err := d.send(ctx, job)
+	if err == nil {
+		if err := d.webhookRepo.MarkDeliverySucceeded(delivery.ID.String(), attempts, responseStatus); err != nil {
+			logger.ErrorContext(ctx, "failed to update webhook delivery", zap.String("deliveryId", delivery.ID.String()), zap.Error(err))
+		}
+		return
+	}
+
+	var nextAttemptAt *time.Time
+	if attempts < webhookMaxAttempts {
+		next := time.Now().Add(webhookBackoff(attempts))
+		nextAttemptAt = &next
+	}
+
+	logger.WarnContext(ctx, "failed to deliver webhook",
+		zap.String("deliveryId", delivery.ID.String()),
+		zap.String("webhookId", job.Subscription.ID.String()),
+		zap.Int("attempts", attempts),
+		zap.Bool("retrying", nextAttemptAt != nil),
+		zap.Error(err),
+	)
+
+	if err := d.webhookRepo.MarkDeliveryFailed(
+		delivery.ID.String(),
+		attempts,
+		responseStatus,
+		err.Error(),
+		nextAttemptAt,
+	); err != nil {
+		logger.ErrorContext(ctx, "failed to update webhook delivery", zap.String("deliveryId", delivery.ID.String()), zap.Error(err))
+	}
+}
+
+// send posts the signed event to the subscription URL and returns the response status code
+func (d *WebhookDispatcher) send(ctx context.Context, job *entities.WebhookDeliveryJob) (int, error) {
+	body, err := json.Marshal(job.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "trh-backend-webhooks")
+	req.Header.Set(WebhookEventHeader, string(job.Event.Type))
+	req.Header.Set(WebhookDeliveryHeader, job.Delivery.ID.String())
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+utils.SignWebhookPayload(job.Subscription.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff returns the delay before the next attempt, doubling after every failed attempt
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,84 @@
+package thanos
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tokamak-network/trh-backend/internal/utils"
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	trhSDKUtils "github.com/tokamak-network/trh-sdk/pkg/utils"
+)
+
+// VerifyAWSResourcesAccess checks that the keys can see the EKS cluster of a deployed chain, the SDK names the cluster
+// after the namespace. Nothing is checked when the AWS infrastructure is not deployed yet.
+// The keys, or the temporary credentials of the role they assume, are passed to the AWS CLI through the environment,
+// the profile configured by the SDK is left untouched.
+func VerifyAWSResourcesAccess(
+	ctx context.Context,
+	deploymentPath string,
+	awsAccessKey string,
+	awsSecretAccessKey string,
+	awsRegion string,
+	awsAssumeRole *dtos.AwsAssumeRole,
+) error {
+	config, err := trhSDKUtils.ReadConfigFromJSONFile(deploymentPath)
+	if err != nil {
+		return err
+	}
+	if config == nil || config.K8s == nil || config.K8s.Namespace == "" {
+		return nil
+	}
+	if config.AWS != nil && config.AWS.Region != "" && config.AWS.Region != awsRegion {
+		return fmt.Errorf("the AWS infrastructure is deployed in %s, not in %s", config.AWS.Region, awsRegion)
+	}
+
+	env, err := awsCommandEnv(ctx, awsAccessKey, awsSecretAccessKey, awsRegion, awsAssumeRole)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "aws", "eks", "describe-cluster",
+		"--name", config.K8s.Namespace,
+		"--region", awsRegion,
+		"--query", "cluster.status",
+		"--output", "text",
+	)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("the keys cannot access the EKS cluster %s: %s", config.K8s.Namespace, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// awsCommandEnv returns the environment of the AWS CLI commands run with the keys, or with the temporary credentials
+// of the role they assume
+func awsCommandEnv(
+	ctx context.Context,
+	awsAccessKey string,
+	awsSecretAccessKey string,
+	awsRegion string,
+	awsAssumeRole *dtos.AwsAssumeRole,
+) ([]string, error) {
+	if awsAssumeRole == nil {
+		return utils.AWSCommandEnv(awsAccessKey, awsSecretAccessKey, ""), nil
+	}
+	credentials, err := utils.AssumeAWSRole(
+		ctx,
+		awsAccessKey,
+		awsSecretAccessKey,
+		awsRegion,
+		awsAssumeRole.RoleArn,
+		awsAssumeRole.ExternalID,
+		awsAssumeRole.GetSessionDuration(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return utils.AWSCommandEnv(credentials.AccessKeyID, credentials.SecretAccessKey, credentials.SessionToken), nil
+}
@@ -0,0 +1,133 @@
+package thanos
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	thanosStack "github.com/tokamak-network/trh-sdk/pkg/stacks/thanos"
+	thanosTypes "github.com/tokamak-network/trh-sdk/pkg/types"
+)
+
+// ClientConfig describes the deployment an SDK client works on
+type ClientConfig struct {
+	// LogPath is the name of the log the SDK logs the commands of the client to
+	LogPath            string
+	Network            string
+	DeploymentPath     string
+	RegisterCandidate  bool
+	AwsAccessKey       string
+	AwsSecretAccessKey string
+	AwsRegion          string
+	AwsAssumeRole      *dtos.AwsAssumeRole
+}
+
+// StackClient runs the SDK commands on the deployment of a stack
+type StackClient interface {
+	DeployL1Contracts(ctx context.Context, req *dtos.DeployL1ContractsRequest) error
+	DeployAWSInfrastructure(ctx context.Context, req *dtos.DeployThanosAWSInfraRequest) error
+	DestroyAWSInfrastructure(ctx context.Context) error
+	ShowChainInformation(ctx context.Context) (*thanosTypes.ChainInformation, error)
+	UpdateNetwork(ctx context.Context, req *dtos.UpdateNetworkRequest) error
+	InstallBridge(ctx context.Context) (string, error)
+	UninstallBridge(ctx context.Context) error
+	InstallBlockExplorer(ctx context.Context, req *dtos.InstallBlockExplorerRequest) (string, error)
+	UninstallBlockExplorer(ctx context.Context) error
+	GetMonitoringConfig(ctx context.Context, password string) (*thanosStack.MonitoringConfig, error)
+	InstallMonitoring(ctx context.Context, config *thanosStack.MonitoringConfig) (string, error)
+	UninstallMonitoring(ctx context.Context) error
+	VerifyRegisterCandidates(ctx context.Context, req *dtos.RegisterCandidateRequest) error
+	GetRegisterCandidatesInfo(
+		ctx context.Context,
+		req *dtos.RegisterCandidateRequest,
+	) (*thanosTypes.RegistrationAdditionalInfo, error)
+	// Close flushes and closes the log of the client
+	Close() error
+}
+
+// StackDriver creates the SDK clients of the stacks and runs the commands working on their deployment directory
+// without a client
+type StackDriver interface {
+	NewClient(ctx context.Context, config ClientConfig) (StackClient, error)
+	TagAWSResources(ctx context.Context, config ClientConfig, labels map[string]string, removedKeys []string) error
+	VerifyAWSResourcesAccess(
+		ctx context.Context,
+		deploymentPath string,
+		awsAccessKey string,
+		awsSecretAccessKey string,
+		awsRegion string,
+		awsAssumeRole *dtos.AwsAssumeRole,
+	) error
+	UseAdminKey(deploymentPath string, adminPrivateKey string) error
+}
+
+// LogSink stores the logs of the SDK clients, see the logsink package
+type LogSink interface {
+	Append(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+// SDKDriver is the StackDriver of the Thanos SDK
+type SDKDriver struct {
+	logs LogSink
+}
+
+func NewSDKDriver(logs LogSink) *SDKDriver {
+	return &SDKDriver{logs: logs}
+}
+
+type sdkClient struct {
+	stack *thanosStack.ThanosStack
+	log   io.Closer
+}
+
+func (d *SDKDriver) NewClient(ctx context.Context, config ClientConfig) (StackClient, error) {
+	log, err := d.logs.Append(ctx, config.LogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the log: %w", err)
+	}
+	stack, err := NewThanosSDKClient(
+		ctx,
+		log,
+		config.Network,
+		config.DeploymentPath,
+		config.RegisterCandidate,
+		config.AwsAccessKey,
+		config.AwsSecretAccessKey,
+		config.AwsRegion,
+		config.AwsAssumeRole,
+	)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	return &sdkClient{stack: stack, log: log}, nil
+}
+
+func (c *sdkClient) Close() error {
+	return c.log.Close()
+}
+
+func (d *SDKDriver) TagAWSResources(
+	ctx context.Context,
+	config ClientConfig,
+	labels map[string]string,
+	removedKeys []string,
+) error {
+	return TagAWSResources(ctx, config, labels, removedKeys)
+}
+
+func (d *SDKDriver) VerifyAWSResourcesAccess(
+	ctx context.Context,
+	deploymentPath string,
+	awsAccessKey string,
+	awsSecretAccessKey string,
+	awsRegion string,
+	awsAssumeRole *dtos.AwsAssumeRole,
+) error {
+	return VerifyAWSResourcesAccess(ctx, deploymentPath, awsAccessKey, awsSecretAccessKey, awsRegion, awsAssumeRole)
+}
+
+func (d *SDKDriver) UseAdminKey(deploymentPath string, adminPrivateKey string) error {
+	return UseAdminKey(deploymentPath, adminPrivateKey)
+}
@@ -0,0 +1,34 @@
+package thanos
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	thanosTypes "github.com/tokamak-network/trh-sdk/pkg/types"
+)
+
+// UseAdminKey sets the admin private key of the settings.json of the deployment, the SDK signs the transactions of
+// the deployed chain with it. The other settings are kept as they are.
+func UseAdminKey(deploymentPath string, adminPrivateKey string) error {
+	path := filepath.Join(deploymentPath, thanosTypes.ConfigFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the deployment settings: %w", err)
+	}
+
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("failed to parse the deployment settings: %w", err)
+	}
+	if settings["admin_private_key"], err = json.Marshal(adminPrivateKey); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,166 @@
+package thanos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tokamak-network/trh-backend/internal/logger"
+	trhSDKUtils "github.com/tokamak-network/trh-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// The Resource Groups Tagging API accepts at most 20 ARNs per call
+const tagResourcesBatchSize = 20
+
+// clusterTagPrefix is the key prefix of the tag EKS puts on the resources of a cluster
+const clusterTagPrefix = "kubernetes.io/cluster/"
+
+// awsResource is an entry of the ResourceTagMappingList of the Resource Groups Tagging API
+type awsResource struct {
+	ResourceARN string   `json:"ResourceARN"`
+	Tags        []awsTag `json:"Tags"`
+}
+
+type awsTag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// TagAWSResources applies the labels as tags to the AWS resources of a deployed chain and removes the tags of
+// deleted labels. The SDK does not accept tags, so the resources are discovered through the Resource Groups
+// Tagging API by the namespace the SDK named them after. The AWS CLI runs with the keys of the config, or the role
+// they assume, the profile configured by the SDK is left untouched.
+func TagAWSResources(
+	ctx context.Context,
+	clientConfig ClientConfig,
+	labels map[string]string,
+	removedKeys []string,
+) error {
+	if len(labels) == 0 && len(removedKeys) == 0 {
+		return nil
+	}
+
+	config, err := trhSDKUtils.ReadConfigFromJSONFile(clientConfig.DeploymentPath)
+	if err != nil {
+		return err
+	}
+	if config == nil || config.K8s == nil || config.K8s.Namespace == "" || config.AWS == nil {
+		return fmt.Errorf("the AWS infrastructure of %s is not deployed", clientConfig.DeploymentPath)
+	}
+	namespace := config.K8s.Namespace
+	region := config.AWS.Region
+
+	env, err := awsCommandEnv(
+		ctx,
+		clientConfig.AwsAccessKey,
+		clientConfig.AwsSecretAccessKey,
+		region,
+		clientConfig.AwsAssumeRole,
+	)
+	if err != nil {
+		return err
+	}
+
+	output, err := runAWSCommand(ctx, env, "resourcegroupstaggingapi", "get-resources",
+		"--region", region,
+		"--query", "ResourceTagMappingList[]",
+		"--output", "json",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list AWS resources: %w", err)
+	}
+
+	var resources []awsResource
+	if err := json.Unmarshal(output, &resources); err != nil {
+		return fmt.Errorf("failed to parse AWS resources: %w", err)
+	}
+
+	resourceARNs := make([]string, 0)
+	for _, resource := range resources {
+		if resource.belongsTo(namespace) {
+			resourceARNs = append(resourceARNs, resource.ResourceARN)
+		}
+	}
+	if len(resourceARNs) == 0 {
+		logger.WarnContext(ctx, "No AWS resources found to tag", zap.String("namespace", namespace))
+		return nil
+	}
+
+	tags, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(resourceARNs); start += tagResourcesBatchSize {
+		end := min(start+tagResourcesBatchSize, len(resourceARNs))
+		batch := resourceARNs[start:end]
+
+		if len(labels) > 0 {
+			args := append([]string{"resourcegroupstaggingapi", "tag-resources", "--region", region, "--resource-arn-list"}, batch...)
+			args = append(args, "--tags", string(tags))
+			if _, err := runAWSCommand(ctx, env, args...); err != nil {
+				return fmt.Errorf("failed to tag AWS resources: %w", err)
+			}
+		}
+
+		if len(removedKeys) > 0 {
+			args := append([]string{"resourcegroupstaggingapi", "untag-resources", "--region", region, "--resource-arn-list"}, batch...)
+			args = append(args, "--tag-keys")
+			args = append(args, removedKeys...)
+			if _, err := runAWSCommand(ctx, env, args...); err != nil {
+				return fmt.Errorf("failed to untag AWS resources: %w", err)
+			}
+		}
+	}
+
+	logger.InfoContext(ctx, "AWS resources tagged",
+		zap.String("namespace", namespace),
+		zap.Int("resources", len(resourceARNs)),
+	)
+
+	return nil
+}
+
+// belongsTo reports whether the resource carries the cluster tag of the namespace or is named after it. The name
+// must be a whole segment of the resource id, so the chain foo does not claim the resources of the chain foo-2.
+func (r awsResource) belongsTo(namespace string) bool {
+	for _, tag := range r.Tags {
+		if tag.Key == clusterTagPrefix+namespace {
+			return true
+		}
+	}
+
+	// arn:partition:service:region:account-id:resource-id, the resource id may itself hold / and : separators
+	parts := strings.SplitN(r.ResourceARN, ":", 6)
+	if len(parts) < 6 {
+		return false
+	}
+	segments := strings.FieldsFunc(parts[5], func(c rune) bool {
+		return c == '/' || c == ':'
+	})
+	for _, segment := range segments {
+		if segment == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// runAWSCommand runs the AWS CLI with the environment and returns its standard output
+func runAWSCommand(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	cmd.Env = env
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,30 @@
+package thanos
+
+import "testing"
+
+func TestAWSResourceBelongsTo(t *testing.T) {
+	tests := []struct {
+		arn  string
+		tags []string
+		want bool
+	}{
+		{"arn:aws:eks:ap-northeast-2:123456789012:cluster/foo", nil, true},
+		{"arn:aws:eks:ap-northeast-2:123456789012:cluster/foo-2", nil, false},
+		{"arn:aws:s3:::foo", nil, true},
+		{"arn:aws:s3:::foo-2", nil, false},
+		{"arn:aws:elasticfilesystem:ap-northeast-2:123456789012:file-system/fs-foo", nil, false},
+		{"arn:aws:logs:ap-northeast-2:123456789012:log-group:foo:*", nil, true},
+		{"arn:aws:ec2:ap-northeast-2:123456789012:vpc/vpc-0abc", []string{"kubernetes.io/cluster/foo"}, true},
+		{"arn:aws:ec2:ap-northeast-2:123456789012:vpc/vpc-0abc", []string{"kubernetes.io/cluster/foo-2"}, false},
+		{"arn:aws:ec2:ap-northeast-2:foo", nil, false},
+	}
+	for _, tt := range tests {
+		resource := awsResource{ResourceARN: tt.arn}
+		for _, key := range tt.tags {
+			resource.Tags = append(resource.Tags, awsTag{Key: key, Value: "owned"})
+		}
+		if got := resource.belongsTo("foo"); got != tt.want {
+			t.Errorf("belongsTo(%s, %v) = %v, want %v", tt.arn, tt.tags, got, tt.want)
+		}
+	}
+}
@@ -2,33 +2,42 @@ package thanos
 
 import (
 	"context"
+	"io"
+	"os"
 
 	"github.com/tokamak-network/trh-backend/internal/consts"
 	"github.com/tokamak-network/trh-backend/internal/logger"
+	"github.com/tokamak-network/trh-backend/internal/utils"
 	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
-	trhSDKLogging "github.com/tokamak-network/trh-sdk/pkg/logging"
 	thanosStack "github.com/tokamak-network/trh-sdk/pkg/stacks/thanos"
 	thanosTypes "github.com/tokamak-network/trh-sdk/pkg/types"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// NewThanosSDKClient creates the SDK client of the deployment, logging its commands to the console and as JSON to the
+// log writer
 func NewThanosSDKClient(
 	ctx context.Context,
-	logPath string,
+	logWriter io.Writer,
 	network string,
 	deploymentPath string,
 	registerCandidate bool,
 	awsAccessKey string,
 	awsSecretAccessKey string,
 	awsRegion string,
+	awsAssumeRole *dtos.AwsAssumeRole,
 ) (*thanosStack.ThanosStack, error) {
-	l, err := trhSDKLogging.InitLogger(logPath)
-	if err != nil {
-		return nil, err
+	// The SDK logs the commands it runs, along with the keys and passwords they are given
+	l := newSDKLogger(logWriter).WithOptions(zap.WrapCore(logger.NewRedactingCore)).Sugar()
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		l = l.With(logger.RequestIDField, requestID)
 	}
 
-	logger.Info("Initializing Thanos SDK...")
+	logger.InfoContext(ctx, "Initializing Thanos SDK...")
 
 	var awsConfig *thanosTypes.AWSConfig
+	var err error
 
 	if awsAccessKey != "" && awsSecretAccessKey != "" && awsRegion != "" {
 		awsConfig = &thanosTypes.AWSConfig{
@@ -36,11 +45,30 @@ func NewThanosSDKClient(
 			SecretKey: awsSecretAccessKey,
 			Region:    awsRegion,
 		}
+
+		// The SDK logs into the default profile with the keys, the role configured on it takes precedence over them.
+		// Stacks without a role must not inherit the one of the previous stack.
+		if awsAssumeRole != nil {
+			err = utils.ConfigureAWSAssumeRole(
+				ctx,
+				awsAccessKey,
+				awsSecretAccessKey,
+				awsAssumeRole.RoleArn,
+				awsAssumeRole.ExternalID,
+				awsAssumeRole.GetSessionDuration(),
+			)
+		} else {
+			err = utils.ClearAWSAssumeRole()
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to configure the AWS role", zap.Error(err))
+			return nil, err
+		}
 	}
 
 	s, err := thanosStack.NewThanosStack(ctx, l, network, false, deploymentPath, awsConfig)
 	if err != nil {
-		logger.Errorf("Failed to create thanos stacks: %s", err)
+		logger.ErrorContext(ctx, "Failed to create thanos stacks", zap.Error(err))
 		return nil, err
 	}
 
@@ -51,39 +79,56 @@ func NewThanosSDKClient(
 	return s, nil
 }
 
-func DeployAWSInfrastructure(ctx context.Context, sdkClient *thanosStack.ThanosStack, req *dtos.DeployThanosAWSInfraRequest) error {
-	logger.Info("Deploying AWS Infrastructure...")
+// newSDKLogger builds the logger of the SDK like its own InitLogger, which only logs to a file
+func newSDKLogger(logWriter io.Writer) *zap.Logger {
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:      "timestamp",
+		MessageKey:   "msg",
+		LineEnding:   zapcore.DefaultLineEnding,
+		EncodeLevel:  zapcore.LowercaseLevelEncoder,
+		EncodeTime:   zapcore.ISO8601TimeEncoder,
+		EncodeCaller: zapcore.ShortCallerEncoder,
+	}
+	core := zapcore.NewTee(
+		zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
+		zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(logWriter), zapcore.DebugLevel),
+	)
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+}
+
+func (c *sdkClient) DeployAWSInfrastructure(ctx context.Context, req *dtos.DeployThanosAWSInfraRequest) error {
+	logger.InfoContext(ctx, "Deploying AWS Infrastructure...")
 
 	deployInfraInput := thanosStack.DeployInfraInput{
 		ChainName:   req.ChainName,
 		L1BeaconURL: req.L1BeaconUrl,
 	}
 
-	err := sdkClient.Deploy(ctx, consts.AWS, &deployInfraInput)
+	err := c.stack.Deploy(ctx, consts.AWS, &deployInfraInput)
 	if err != nil {
 		return err
 	}
 
-	logger.Info("AWS Infrastructure deployed successfully")
+	logger.InfoContext(ctx, "AWS Infrastructure deployed successfully")
 
 	return nil
 }
 
-func DestroyAWSInfrastructure(ctx context.Context, sdkClient *thanosStack.ThanosStack) error {
-	logger.Info("Destroying AWS Infrastructure...")
+func (c *sdkClient) DestroyAWSInfrastructure(ctx context.Context) error {
+	logger.InfoContext(ctx, "Destroying AWS Infrastructure...")
 
-	err := sdkClient.Destroy(ctx)
+	err := c.stack.Destroy(ctx)
 	if err != nil {
 		return err
 	}
 
-	logger.Info("AWS Infrastructure destroyed successfully")
+	logger.InfoContext(ctx, "AWS Infrastructure destroyed successfully")
 
 	return nil
 }
 
-func DeployL1Contracts(ctx context.Context, sdkClient *thanosStack.ThanosStack, req *dtos.DeployL1ContractsRequest) error {
-	logger.Info("Deploying L1 Contracts...")
+func (c *sdkClient) DeployL1Contracts(ctx context.Context, req *dtos.DeployL1ContractsRequest) error {
+	logger.InfoContext(ctx, "Deploying L1 Contracts...")
 
 	chainConfig := thanosTypes.ChainConfiguration{
 		BatchSubmissionFrequency: uint64(req.BatchSubmissionFrequency),
@@ -115,42 +160,32 @@ func DeployL1Contracts(ctx context.Context, sdkClient *thanosStack.ThanosStack,
 		}
 	}
 
-	err := sdkClient.DeployContracts(ctx, &contractDeploymentInput)
+	err := c.stack.DeployContracts(ctx, &contractDeploymentInput)
 	if err != nil {
 		return err
 	}
 
-	logger.Info("L1 Contracts deployed successfully")
+	logger.InfoContext(ctx, "L1 Contracts deployed successfully")
 	return nil
 }
 
-func ShowChainInformation(
-	ctx context.Context,
-	sdkClient *thanosStack.ThanosStack,
-) (*thanosTypes.ChainInformation, error) {
-	return sdkClient.ShowInformation(ctx)
+func (c *sdkClient) ShowChainInformation(ctx context.Context) (*thanosTypes.ChainInformation, error) {
+	return c.stack.ShowInformation(ctx)
 }
 
-func InstallBridge(
-	ctx context.Context,
-	sdkClient *thanosStack.ThanosStack,
-) (string, error) {
-	return sdkClient.InstallBridge(ctx)
+func (c *sdkClient) InstallBridge(ctx context.Context) (string, error) {
+	return c.stack.InstallBridge(ctx)
 }
 
-func UninstallBridge(
-	ctx context.Context,
-	sdkClient *thanosStack.ThanosStack,
-) error {
-	return sdkClient.UninstallBridge(ctx)
+func (c *sdkClient) UninstallBridge(ctx context.Context) error {
+	return c.stack.UninstallBridge(ctx)
 }
 
-func InstallBlockExplorer(
+func (c *sdkClient) InstallBlockExplorer(
 	ctx context.Context,
-	s *thanosStack.ThanosStack,
 	req *dtos.InstallBlockExplorerRequest,
 ) (string, error) {
-	return s.InstallBlockExplorer(ctx, &thanosStack.InstallBlockExplorerInput{
+	return c.stack.InstallBlockExplorer(ctx, &thanosStack.InstallBlockExplorerInput{
 		DatabaseUsername:       req.DatabaseUsername,
 		DatabasePassword:       req.DatabasePassword,
 		CoinmarketcapKey:       req.CoinmarketcapKey,
@@ -158,53 +193,43 @@ func InstallBlockExplorer(
 	})
 }
 
-func UninstallBlockExplorer(
-	ctx context.Context,
-	s *thanosStack.ThanosStack,
-) error {
-	return s.UninstallBlockExplorer(ctx)
+func (c *sdkClient) UninstallBlockExplorer(ctx context.Context) error {
+	return c.stack.UninstallBlockExplorer(ctx)
 }
 
-func GetMonitoringConfig(
+func (c *sdkClient) GetMonitoringConfig(
 	ctx context.Context,
-	s *thanosStack.ThanosStack,
 	password string,
 ) (*thanosStack.MonitoringConfig, error) {
-	return s.GetMonitoringConfig(ctx, password)
+	return c.stack.GetMonitoringConfig(ctx, password)
 }
 
-func InstallMonitoring(
+func (c *sdkClient) InstallMonitoring(
 	ctx context.Context,
-	s *thanosStack.ThanosStack,
 	config *thanosStack.MonitoringConfig,
 ) (string, error) {
-	return s.InstallMonitoring(ctx, config)
+	return c.stack.InstallMonitoring(ctx, config)
 }
 
-func UninstallMonitoring(
-	ctx context.Context,
-	s *thanosStack.ThanosStack,
-) error {
-	return s.UninstallMonitoring(ctx)
+func (c *sdkClient) UninstallMonitoring(ctx context.Context) error {
+	return c.stack.UninstallMonitoring(ctx)
 }
 
-func UpdateNetwork(
+func (c *sdkClient) UpdateNetwork(
 	ctx context.Context,
-	s *thanosStack.ThanosStack,
 	req *dtos.UpdateNetworkRequest,
 ) error {
-	return s.UpdateNetwork(ctx, &thanosStack.UpdateNetworkInput{
+	return c.stack.UpdateNetwork(ctx, &thanosStack.UpdateNetworkInput{
 		L1RPC:       req.L1RpcUrl,
 		L1BeaconURL: req.L1BeaconUrl,
 	})
 }
 
-func VerifyRegisterCandidates(
+func (c *sdkClient) VerifyRegisterCandidates(
 	ctx context.Context,
-	s *thanosStack.ThanosStack,
 	req *dtos.RegisterCandidateRequest,
 ) error {
-	return s.VerifyRegisterCandidates(ctx, &thanosStack.RegisterCandidateInput{
+	return c.stack.VerifyRegisterCandidates(ctx, &thanosStack.RegisterCandidateInput{
 		Amount:   req.Amount,
 		Memo:     req.Memo,
 		NameInfo: req.NameInfo,
@@ -212,8 +237,11 @@ func VerifyRegisterCandidates(
 	})
 }
 
-func GetRegisterCandidatesInfo(ctx context.Context, s *thanosStack.ThanosStack, registerCandidate *dtos.RegisterCandidateRequest) (*thanosTypes.RegistrationAdditionalInfo, error) {
-	return s.GetRegistrationAdditionalInfo(ctx, &thanosStack.RegisterCandidateInput{
+func (c *sdkClient) GetRegisterCandidatesInfo(
+	ctx context.Context,
+	registerCandidate *dtos.RegisterCandidateRequest,
+) (*thanosTypes.RegistrationAdditionalInfo, error) {
+	return c.stack.GetRegistrationAdditionalInfo(ctx, &thanosStack.RegisterCandidateInput{
 		Amount:   registerCandidate.Amount,
 		Memo:     registerCandidate.Memo,
 		NameInfo: registerCandidate.NameInfo,
@@ -0,0 +1,305 @@
+// Package thanostest provides a scriptable thanos.StackDriver for the tests of the services. Its clients succeed
+// without running anything, unless a method is told to fail or to block.
+package thanostest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tokamak-network/trh-backend/pkg/api/dtos"
+	"github.com/tokamak-network/trh-backend/pkg/stacks/thanos"
+	thanosStack "github.com/tokamak-network/trh-sdk/pkg/stacks/thanos"
+	thanosTypes "github.com/tokamak-network/trh-sdk/pkg/types"
+)
+
+// Call is a call received by the driver or by one of its clients
+type Call struct {
+	// Method is the name of the method of thanos.StackDriver or thanos.StackClient
+	Method string
+	// Config is the config of the client, empty for the methods of the driver
+	Config thanos.ClientConfig
+}
+
+// Driver is a fake thanos.StackDriver. The zero value is not usable, use NewDriver.
+type Driver struct {
+	mu       sync.Mutex
+	calls    []Call
+	failures map[string]error
+	gates    map[string]*Gate
+
+	// ChainInformation is returned by ShowChainInformation
+	ChainInformation *thanosTypes.ChainInformation
+	// BridgeURL, BlockExplorerURL and MonitoringURL are returned by the installs
+	BridgeURL        string
+	BlockExplorerURL string
+	MonitoringURL    string
+	// RegistrationInfo is returned by GetRegisterCandidatesInfo
+	RegistrationInfo *thanosTypes.RegistrationAdditionalInfo
+}
+
+func NewDriver() *Driver {
+	return &Driver{
+		failures: make(map[string]error),
+		gates:    make(map[string]*Gate),
+		ChainInformation: &thanosTypes.ChainInformation{
+			L2RpcUrl:      "http://l2.thanos.test",
+			BridgeUrl:     "http://bridge.thanos.test",
+			BlockExplorer: "http://explorer.thanos.test",
+		},
+		BridgeURL:        "http://bridge.thanos.test",
+		BlockExplorerURL: "http://explorer.thanos.test",
+		MonitoringURL:    "http://grafana.thanos.test",
+		RegistrationInfo: &thanosTypes.RegistrationAdditionalInfo{URL: "http://staking.thanos.test"},
+	}
+}
+
+// FailOn makes the calls of the method return err, until Reset. A nil err makes them succeed again.
+func (d *Driver) FailOn(method string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err == nil {
+		delete(d.failures, method)
+		return
+	}
+	d.failures[method] = err
+}
+
+// BlockOn makes the calls of the method wait until the gate is released or their context is cancelled, in which case
+// they return the error of the context
+func (d *Driver) BlockOn(method string) *Gate {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	gate := &Gate{entered: make(chan struct{}), released: make(chan struct{})}
+	d.gates[method] = gate
+	return gate
+}
+
+// Reset forgets the failures and the gates, the released gates are not waited on anymore
+func (d *Driver) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures = make(map[string]error)
+	for _, gate := range d.gates {
+		gate.Release()
+	}
+	d.gates = make(map[string]*Gate)
+}
+
+// Calls returns the calls received so far, in order
+func (d *Driver) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	calls := make([]Call, len(d.calls))
+	copy(calls, d.calls)
+	return calls
+}
+
+// Methods returns the methods called so far, in order
+func (d *Driver) Methods() []string {
+	calls := d.Calls()
+	methods := make([]string, len(calls))
+	for i, call := range calls {
+		methods[i] = call.Method
+	}
+	return methods
+}
+
+// CallCount returns the number of calls of the method
+func (d *Driver) CallCount(method string) int {
+	count := 0
+	for _, call := range d.Calls() {
+		if call.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// call records the call, waits on the gate of the method and returns its scripted error
+func (d *Driver) call(ctx context.Context, method string, config thanos.ClientConfig) error {
+	d.mu.Lock()
+	d.calls = append(d.calls, Call{Method: method, Config: config})
+	gate := d.gates[method]
+	d.mu.Unlock()
+
+	if gate != nil {
+		if err := gate.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failures[method]
+}
+
+// url reads one of the scripted URLs
+func (d *Driver) url(url *string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return *url
+}
+
+func (d *Driver) NewClient(ctx context.Context, config thanos.ClientConfig) (thanos.StackClient, error) {
+	if err := d.call(ctx, "NewClient", config); err != nil {
+		return nil, err
+	}
+	return &client{driver: d, config: config}, nil
+}
+
+func (d *Driver) TagAWSResources(
+	ctx context.Context,
+	config thanos.ClientConfig,
+	labels map[string]string,
+	removedKeys []string,
+) error {
+	return d.call(ctx, "TagAWSResources", config)
+}
+
+func (d *Driver) VerifyAWSResourcesAccess(
+	ctx context.Context,
+	deploymentPath string,
+	awsAccessKey string,
+	awsSecretAccessKey string,
+	awsRegion string,
+	awsAssumeRole *dtos.AwsAssumeRole,
+) error {
+	return d.call(ctx, "VerifyAWSResourcesAccess", thanos.ClientConfig{
+		DeploymentPath:     deploymentPath,
+		AwsAccessKey:       awsAccessKey,
+		AwsSecretAccessKey: awsSecretAccessKey,
+		AwsRegion:          awsRegion,
+		AwsAssumeRole:      awsAssumeRole,
+	})
+}
+
+func (d *Driver) UseAdminKey(deploymentPath string, adminPrivateKey string) error {
+	return d.call(context.Background(), "UseAdminKey", thanos.ClientConfig{DeploymentPath: deploymentPath})
+}
+
+// Gate holds the calls of a method until it is released
+type Gate struct {
+	enterOnce   sync.Once
+	releaseOnce sync.Once
+	entered     chan struct{}
+	released    chan struct{}
+}
+
+// Entered is closed once a call is waiting on the gate
+func (g *Gate) Entered() <-chan struct{} {
+	return g.entered
+}
+
+// Release lets the waiting and the next calls through
+func (g *Gate) Release() {
+	g.releaseOnce.Do(func() { close(g.released) })
+}
+
+func (g *Gate) wait(ctx context.Context) error {
+	g.enterOnce.Do(func() { close(g.entered) })
+	select {
+	case <-g.released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type client struct {
+	driver *Driver
+	config thanos.ClientConfig
+}
+
+// Close is not recorded, the services close every client they create
+func (c *client) Close() error {
+	return nil
+}
+
+func (c *client) DeployL1Contracts(ctx context.Context, req *dtos.DeployL1ContractsRequest) error {
+	return c.driver.call(ctx, "DeployL1Contracts", c.config)
+}
+
+func (c *client) DeployAWSInfrastructure(ctx context.Context, req *dtos.DeployThanosAWSInfraRequest) error {
+	return c.driver.call(ctx, "DeployAWSInfrastructure", c.config)
+}
+
+func (c *client) DestroyAWSInfrastructure(ctx context.Context) error {
+	return c.driver.call(ctx, "DestroyAWSInfrastructure", c.config)
+}
+
+func (c *client) ShowChainInformation(ctx context.Context) (*thanosTypes.ChainInformation, error) {
+	if err := c.driver.call(ctx, "ShowChainInformation", c.config); err != nil {
+		return nil, err
+	}
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	if c.driver.ChainInformation == nil {
+		return nil, nil
+	}
+	information := *c.driver.ChainInformation
+	return &information, nil
+}
+
+func (c *client) UpdateNetwork(ctx context.Context, req *dtos.UpdateNetworkRequest) error {
+	return c.driver.call(ctx, "UpdateNetwork", c.config)
+}
+
+func (c *client) InstallBridge(ctx context.Context) (string, error) {
+	if err := c.driver.call(ctx, "InstallBridge", c.config); err != nil {
+		return "", err
+	}
+	return c.driver.url(&c.driver.BridgeURL), nil
+}
+
+func (c *client) UninstallBridge(ctx context.Context) error {
+	return c.driver.call(ctx, "UninstallBridge", c.config)
+}
+
+func (c *client) InstallBlockExplorer(ctx context.Context, req *dtos.InstallBlockExplorerRequest) (string, error) {
+	if err := c.driver.call(ctx, "InstallBlockExplorer", c.config); err != nil {
+		return "", err
+	}
+	return c.driver.url(&c.driver.BlockExplorerURL), nil
+}
+
+func (c *client) UninstallBlockExplorer(ctx context.Context) error {
+	return c.driver.call(ctx, "UninstallBlockExplorer", c.config)
+}
+
+func (c *client) GetMonitoringConfig(ctx context.Context, password string) (*thanosStack.MonitoringConfig, error) {
+	if err := c.driver.call(ctx, "GetMonitoringConfig", c.config); err != nil {
+		return nil, err
+	}
+	return &thanosStack.MonitoringConfig{AdminPassword: password}, nil
+}
+
+func (c *client) InstallMonitoring(ctx context.Context, config *thanosStack.MonitoringConfig) (string, error) {
+	if err := c.driver.call(ctx, "InstallMonitoring", c.config); err != nil {
+		return "", err
+	}
+	return c.driver.url(&c.driver.MonitoringURL), nil
+}
+
+func (c *client) UninstallMonitoring(ctx context.Context) error {
+	return c.driver.call(ctx, "UninstallMonitoring", c.config)
+}
+
+func (c *client) VerifyRegisterCandidates(ctx context.Context, req *dtos.RegisterCandidateRequest) error {
+	return c.driver.call(ctx, "VerifyRegisterCandidates", c.config)
+}
+
+func (c *client) GetRegisterCandidatesInfo(
+	ctx context.Context,
+	req *dtos.RegisterCandidateRequest,
+) (*thanosTypes.RegistrationAdditionalInfo, error) {
+	if err := c.driver.call(ctx, "GetRegisterCandidatesInfo", c.config); err != nil {
+		return nil, err
+	}
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	return c.driver.RegistrationInfo, nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/tokamak-network/trh-backend/internal/logger"
 	"github.com/tokamak-network/trh-backend/pkg/domain/entities"
 )
 
@@ -68,9 +69,10 @@ func (tm *TaskManager) Start() {
 	}
 }
 
-// AddTask adds a task with a unique ID
-func (tm *TaskManager) AddTask(id string, task entities.Task) {
-	ctx, cancel := context.WithCancel(tm.ctx)
+// AddTask adds a task with a unique ID. The task runs with the lifetime of the task manager but keeps the
+// request id of the parent context, so its logs can be correlated with the request that enqueued it.
+func (tm *TaskManager) AddTask(parent context.Context, id string, task entities.Task) {
+	ctx, cancel := context.WithCancel(logger.WithRequestID(tm.ctx, logger.RequestIDFromContext(parent)))
 	mt := &managedTask{
 		id:     id,
 		task:   task,